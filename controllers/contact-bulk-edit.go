@@ -0,0 +1,511 @@
+package controllers
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+
+	"github.com/pquerna/ffjson/ffjson"
+	"github.com/qedus/nds"
+
+	"github.com/news-ai/api/controllers"
+
+	"github.com/news-ai/tabulae/models"
+	"github.com/news-ai/tabulae/sync"
+)
+
+/*
+* Private
+ */
+
+var (
+	bulkEditActionSet       = "set"
+	bulkEditActionClear     = "clear"
+	bulkEditActionReplace   = "replace"
+	bulkEditActionAddTag    = "addtag"
+	bulkEditActionRemoveTag = "removetag"
+)
+
+// A single change to make to every selected contact. Tags, employers and
+// past employers are comma separated when they are set.
+type bulkEditChange struct {
+	Action  string `json:"action"`
+	Field   string `json:"field"`
+	Value   string `json:"value"`
+	Find    string `json:"find"`
+	Replace string `json:"replace"`
+}
+
+// Contacts are selected by their ids, or by a list and a query over it
+type bulkEditDetails struct {
+	Contacts []int64             `json:"contacts"`
+	ListId   int64               `json:"listid"`
+	Query    models.ContactQuery `json:"query"`
+
+	Changes []bulkEditChange `json:"changes"`
+}
+
+type BulkEditResult struct {
+	Selected int     `json:"selected"`
+	Changed  int     `json:"changed"`
+	Contacts []int64 `json:"contacts"`
+}
+
+/*
+* Private methods
+ */
+
+func contactTextField(contact *models.Contact, field string) (*string, bool) {
+	fields := map[string]*string{
+		"firstname":   &contact.FirstName,
+		"lastname":    &contact.LastName,
+		"notes":       &contact.Notes,
+		"location":    &contact.Location,
+		"phonenumber": &contact.PhoneNumber,
+		"website":     &contact.Website,
+		"blog":        &contact.Blog,
+		"linkedin":    &contact.LinkedIn,
+	}
+
+	value, ok := fields[field]
+	return value, ok
+}
+
+func splitBulkEditValues(value string) []string {
+	values := []string{}
+	splitValues := strings.Split(value, ",")
+	for i := 0; i < len(splitValues); i++ {
+		if strings.TrimSpace(splitValues[i]) != "" {
+			values = append(values, strings.TrimSpace(splitValues[i]))
+		}
+	}
+	return values
+}
+
+func parseBulkEditIds(value string) ([]int64, error) {
+	ids := []int64{}
+	values := splitBulkEditValues(value)
+	for i := 0; i < len(values); i++ {
+		id, err := strconv.ParseInt(values[i], 10, 64)
+		if err != nil {
+			return []int64{}, errors.New(values[i] + " is not an id")
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func isBulkEditCustomField(field string) bool {
+	_, ok := contactTextField(&models.Contact{}, field)
+	return !ok && field != "" && field != "tags" && field != "employers" && field != "pastemployers"
+}
+
+// Checks the parts of a change that don't depend on the contact's list.
+// Employers have to be publications that exist.
+func validateBulkEditChange(c context.Context, change bulkEditChange) error {
+	if change.Field == "" && change.Action != bulkEditActionAddTag && change.Action != bulkEditActionRemoveTag {
+		return errors.New("Changes need a field")
+	}
+
+	switch change.Action {
+	case bulkEditActionSet, bulkEditActionClear:
+	case bulkEditActionReplace:
+		if change.Find == "" {
+			return errors.New("Replacing needs a value to find")
+		}
+
+		if change.Field == "employers" || change.Field == "pastemployers" {
+			return errors.New("Employers can't be replaced, only set or cleared")
+		}
+	case bulkEditActionAddTag, bulkEditActionRemoveTag:
+		if strings.TrimSpace(change.Value) == "" {
+			return errors.New("Tag changes need a tag")
+		}
+	default:
+		return errors.New("Action " + change.Action + " is not supported")
+	}
+
+	if change.Action == bulkEditActionSet && (change.Field == "employers" || change.Field == "pastemployers") {
+		publicationIds, err := parseBulkEditIds(change.Value)
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i < len(publicationIds); i++ {
+			_, err = getPublication(c, publicationIds[i])
+			if err != nil {
+				log.Errorf(c, "%v", err)
+				return errors.New("No publication by the id " + strconv.FormatInt(publicationIds[i], 10))
+			}
+		}
+	}
+
+	return nil
+}
+
+func setContactCustomField(contact *models.Contact, name string, value string) {
+	for i := 0; i < len(contact.CustomFields); i++ {
+		if contact.CustomFields[i].Name == name {
+			contact.CustomFields[i].Value = value
+			return
+		}
+	}
+
+	contact.CustomFields = append(contact.CustomFields, models.CustomContactField{
+		Name:  name,
+		Value: value,
+	})
+}
+
+func removeContactCustomField(contact *models.Contact, name string) {
+	customFields := []models.CustomContactField{}
+	for i := 0; i < len(contact.CustomFields); i++ {
+		if contact.CustomFields[i].Name != name {
+			customFields = append(customFields, contact.CustomFields[i])
+		}
+	}
+	contact.CustomFields = customFields
+}
+
+// Tags are saved normalized, so they are compared that way
+func applyBulkEditTagChange(contact *models.Contact, change bulkEditChange) {
+	tag := models.NormalizeTag(change.Value)
+	tags := []string{}
+	hasTag := false
+	for i := 0; i < len(contact.Tags); i++ {
+		if models.NormalizeTag(contact.Tags[i]) == tag {
+			hasTag = true
+			if change.Action == bulkEditActionRemoveTag {
+				continue
+			}
+		}
+		tags = append(tags, contact.Tags[i])
+	}
+
+	if change.Action == bulkEditActionAddTag && !hasTag {
+		tags = append(tags, tag)
+	}
+	contact.Tags = tags
+}
+
+// Applies a change to a contact. Custom fields have to be columns of the
+// contact's list, and values have to fit the type of the column.
+func applyBulkEditChange(contact *models.Contact, mediaList models.MediaList, change bulkEditChange) error {
+	if change.Action == bulkEditActionAddTag || change.Action == bulkEditActionRemoveTag {
+		applyBulkEditTagChange(contact, change)
+		return nil
+	}
+
+	if textField, ok := contactTextField(contact, change.Field); ok {
+		switch change.Action {
+		case bulkEditActionSet:
+			*textField = change.Value
+		case bulkEditActionClear:
+			*textField = ""
+		case bulkEditActionReplace:
+			*textField = strings.Replace(*textField, change.Find, change.Replace, -1)
+		}
+		return nil
+	}
+
+	switch change.Field {
+	case "tags":
+		switch change.Action {
+		case bulkEditActionSet:
+			contact.Tags = splitBulkEditValues(change.Value)
+		case bulkEditActionClear:
+			contact.Tags = []string{}
+		case bulkEditActionReplace:
+			tags := []string{}
+			for i := 0; i < len(contact.Tags); i++ {
+				tag := strings.TrimSpace(strings.Replace(contact.Tags[i], change.Find, change.Replace, -1))
+				if tag != "" {
+					tags = append(tags, tag)
+				}
+			}
+			contact.Tags = tags
+		}
+		return nil
+	case "employers", "pastemployers":
+		publicationIds := []int64{}
+		if change.Action == bulkEditActionSet {
+			publicationIds, _ = parseBulkEditIds(change.Value)
+		}
+
+		if change.Field == "employers" {
+			contact.Employers = publicationIds
+		} else {
+			contact.PastEmployers = publicationIds
+		}
+		return nil
+	}
+
+	field, ok := getFieldsMapField(mediaList, change.Field)
+	if !ok || !field.CustomField || field.ReadOnly {
+		return errors.New(change.Field + " is not a field that can be edited")
+	}
+
+	value := ""
+	for i := 0; i < len(contact.CustomFields); i++ {
+		if contact.CustomFields[i].Name == change.Field {
+			value = contact.CustomFields[i].Value
+		}
+	}
+
+	switch change.Action {
+	case bulkEditActionSet:
+		value = change.Value
+	case bulkEditActionClear:
+		value = ""
+	case bulkEditActionReplace:
+		value = strings.Replace(value, change.Find, change.Replace, -1)
+	}
+
+	if value == "" {
+		removeContactCustomField(contact, change.Field)
+		return nil
+	}
+
+	err := field.ValidateValue(value)
+	if err != nil {
+		return err
+	}
+
+	setContactCustomField(contact, change.Field, value)
+	return nil
+}
+
+// The contacts picked by ids. Contacts of lists the user can't edit are left
+// out, and so are master contacts of other people.
+func getContactsForBulkEditIds(c context.Context, r *http.Request, ids []int64) ([]models.Contact, error) {
+	user, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Contact{}, err
+	}
+
+	keys := []*datastore.Key{}
+	existingIds := map[int64]bool{}
+	for i := 0; i < len(ids); i++ {
+		if _, ok := existingIds[ids[i]]; !ok {
+			existingIds[ids[i]] = true
+			keys = append(keys, datastore.NewKey(c, "Contact", "", ids[i], nil))
+		}
+	}
+
+	contacts := make([]models.Contact, len(keys))
+	err = nds.GetMulti(c, keys, contacts)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Contact{}, err
+	}
+
+	canEditLists := map[int64]bool{}
+	editableContacts := []models.Contact{}
+	for i := 0; i < len(contacts); i++ {
+		contacts[i].Format(keys[i], "contacts")
+		if contacts[i].IsDeleted {
+			continue
+		}
+
+		if contacts[i].IsMasterContact {
+			if contacts[i].CreatedBy == user.Id {
+				editableContacts = append(editableContacts, contacts[i])
+			}
+			continue
+		}
+
+		canEdit, ok := canEditLists[contacts[i].ListId]
+		if !ok {
+			canEdit = requireContactRole(c, r, contacts[i], models.ListRoleEditor) == nil
+			canEditLists[contacts[i].ListId] = canEdit
+		}
+
+		if canEdit {
+			editableContacts = append(editableContacts, contacts[i])
+		}
+	}
+
+	return editableContacts, nil
+}
+
+// The contacts of a list that match a query, or all of them without one
+func getContactsForBulkEditQuery(c context.Context, r *http.Request, listId int64, contactQuery models.ContactQuery) ([]models.Contact, error) {
+	mediaList, err := getMediaList(c, r, listId)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Contact{}, err
+	}
+
+	err = requireMediaListRole(mediaList, models.ListRoleEditor)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Contact{}, err
+	}
+
+	if mediaList.IsSmartList {
+		return []models.Contact{}, errors.New("Contacts of a smart list have to be edited in their own lists")
+	}
+
+	contacts, err := getContactsForMediaList(c, mediaList)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Contact{}, err
+	}
+
+	if len(contactQuery.Filters) == 0 {
+		return contacts, nil
+	}
+
+	return evaluateContactQuery(c, r, mediaList, contacts, contactQuery)
+}
+
+/*
+* Public methods
+ */
+
+/*
+* Update methods
+ */
+
+// Makes the same changes to many contacts at once: setting, clearing or
+// finding and replacing a field, and adding or removing a tag. Every change
+// is checked against every contact before anything is saved. Contacts are
+// saved in chunks, each with its sync.
+func BulkEditContacts(c context.Context, r *http.Request) (BulkEditResult, interface{}, error) {
+	buf, _ := ioutil.ReadAll(r.Body)
+	decoder := ffjson.NewDecoder()
+	var bulkEdit bulkEditDetails
+	err := decoder.Decode(buf, &bulkEdit)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return BulkEditResult{}, nil, err
+	}
+
+	user, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return BulkEditResult{}, nil, err
+	}
+
+	if len(bulkEdit.Changes) == 0 {
+		return BulkEditResult{}, nil, errors.New("No changes to make")
+	}
+
+	for i := 0; i < len(bulkEdit.Changes); i++ {
+		err = validateBulkEditChange(c, bulkEdit.Changes[i])
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return BulkEditResult{}, nil, err
+		}
+	}
+
+	contacts := []models.Contact{}
+	if len(bulkEdit.Contacts) > 0 {
+		contacts, err = getContactsForBulkEditIds(c, r, bulkEdit.Contacts)
+	} else if bulkEdit.ListId != 0 {
+		contacts, err = getContactsForBulkEditQuery(c, r, bulkEdit.ListId, bulkEdit.Query)
+	} else {
+		err = errors.New("Contacts have to be picked by their ids or by a list")
+	}
+
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return BulkEditResult{}, nil, err
+	}
+
+	mediaLists := map[int64]models.MediaList{}
+	changedContacts := []models.Contact{}
+	revisions := [][]models.ContactRevision{}
+	for i := 0; i < len(contacts); i++ {
+		mediaList, ok := mediaLists[contacts[i].ListId]
+		if !ok && contacts[i].ListId != 0 {
+			mediaList, err = getMediaList(c, r, contacts[i].ListId)
+			if err != nil {
+				log.Errorf(c, "%v", err)
+				return BulkEditResult{}, nil, err
+			}
+			mediaLists[contacts[i].ListId] = mediaList
+		}
+
+		// Custom fields are changed in place, so the contact gets its own copy
+		previousContact := contacts[i]
+		contact := contacts[i]
+		contact.CustomFields = append([]models.CustomContactField{}, previousContact.CustomFields...)
+		for x := 0; x < len(bulkEdit.Changes); x++ {
+			if isBulkEditCustomField(bulkEdit.Changes[x].Field) && contact.ListId == 0 {
+				continue
+			}
+
+			err = applyBulkEditChange(&contact, mediaList, bulkEdit.Changes[x])
+			if err != nil {
+				log.Errorf(c, "%v", err)
+				return BulkEditResult{}, nil, err
+			}
+		}
+
+		// Values are compared the way they are saved
+		contact.Normalize()
+		contactChangeRevisions := contactRevisions(previousContact, contact, models.RevisionSourceBulkEdit, user.Id)
+		if len(contactChangeRevisions) == 0 {
+			continue
+		}
+
+		// Identity fields changed on a child are kept as overrides for its list
+		if contact.ParentContact != 0 && !contact.IsMasterContact {
+			addContactOverrides(&contact, previousContact)
+		}
+
+		contact.Updated = time.Now()
+		changedContacts = append(changedContacts, contact)
+		revisions = append(revisions, contactChangeRevisions)
+	}
+
+	// Contacts saved before an error are synced, and still get their
+	// revisions, masters and webhooks before the error is returned
+	saved, saveErr := saveContactsAndSync(c, changedContacts, queueContactsSync(sync.ActionUpdate))
+	changedContacts = changedContacts[:saved]
+
+	savedRevisions := []models.ContactRevision{}
+	for i := 0; i < saved; i++ {
+		savedRevisions = append(savedRevisions, revisions[i]...)
+	}
+
+	err = saveContactRevisions(c, savedRevisions)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+	}
+
+	changedContactIds := []int64{}
+	for i := 0; i < len(changedContacts); i++ {
+		changedContactIds = append(changedContactIds, changedContacts[i].Id)
+		if changedContacts[i].IsMasterContact {
+			err = propagateMasterContact(c, r, changedContacts[i])
+			if err != nil {
+				log.Errorf(c, "%v", err)
+			}
+		}
+	}
+
+	TriggerContactUpdatedWebhooks(c, changedContacts)
+
+	if saveErr != nil {
+		log.Errorf(c, "%v", saveErr)
+		return BulkEditResult{}, nil, saveErr
+	}
+
+	bulkEditResult := BulkEditResult{
+		Selected: len(contacts),
+		Changed:  len(changedContacts),
+		Contacts: changedContactIds,
+	}
+	return bulkEditResult, nil, nil
+}
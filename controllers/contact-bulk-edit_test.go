@@ -0,0 +1,150 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/news-ai/tabulae/models"
+)
+
+func TestApplyBulkEditChange(t *testing.T) {
+	mediaList := models.MediaList{
+		FieldsMap: []models.CustomFieldsMap{
+			{Name: "Beat", Value: "beat", CustomField: true},
+			{Name: "Circulation", Value: "circulation", CustomField: true, Type: models.FieldTypeNumber},
+			{Name: "Email", Value: "email"},
+		},
+	}
+
+	contactWith := func(edit func(contact *models.Contact)) models.Contact {
+		contact := models.Contact{
+			FirstName: "Jane",
+			Notes:     "Met at the launch",
+			Tags:      []string{"tech", "fashion"},
+			Employers: []int64{1},
+			CustomFields: []models.CustomContactField{
+				{Name: "beat", Value: "Tech news"},
+			},
+		}
+		if edit != nil {
+			edit(&contact)
+		}
+		return contact
+	}
+
+	tests := []struct {
+		name    string
+		change  bulkEditChange
+		want    models.Contact
+		wantErr bool
+	}{
+		{
+			name:   "set text",
+			change: bulkEditChange{Action: bulkEditActionSet, Field: "firstname", Value: "Janet"},
+			want:   contactWith(func(contact *models.Contact) { contact.FirstName = "Janet" }),
+		},
+		{
+			name:   "clear text",
+			change: bulkEditChange{Action: bulkEditActionClear, Field: "notes"},
+			want:   contactWith(func(contact *models.Contact) { contact.Notes = "" }),
+		},
+		{
+			name:   "replace text",
+			change: bulkEditChange{Action: bulkEditActionReplace, Field: "notes", Find: "launch", Replace: "party"},
+			want:   contactWith(func(contact *models.Contact) { contact.Notes = "Met at the party" }),
+		},
+		{
+			name:   "add tag",
+			change: bulkEditChange{Action: bulkEditActionAddTag, Value: " Food "},
+			want:   contactWith(func(contact *models.Contact) { contact.Tags = []string{"tech", "fashion", "food"} }),
+		},
+		{
+			name:   "add tag the contact has in another case",
+			change: bulkEditChange{Action: bulkEditActionAddTag, Value: "Tech"},
+			want:   contactWith(nil),
+		},
+		{
+			name:   "remove tag in another case",
+			change: bulkEditChange{Action: bulkEditActionRemoveTag, Value: "Tech"},
+			want:   contactWith(func(contact *models.Contact) { contact.Tags = []string{"fashion"} }),
+		},
+		{
+			name:   "remove tag the contact doesn't have",
+			change: bulkEditChange{Action: bulkEditActionRemoveTag, Value: "food"},
+			want:   contactWith(nil),
+		},
+		{
+			name:   "set tags",
+			change: bulkEditChange{Action: bulkEditActionSet, Field: "tags", Value: "food, travel"},
+			want:   contactWith(func(contact *models.Contact) { contact.Tags = []string{"food", "travel"} }),
+		},
+		{
+			name:   "replace tags",
+			change: bulkEditChange{Action: bulkEditActionReplace, Field: "tags", Find: "tech", Replace: ""},
+			want:   contactWith(func(contact *models.Contact) { contact.Tags = []string{"fashion"} }),
+		},
+		{
+			name:   "set employers",
+			change: bulkEditChange{Action: bulkEditActionSet, Field: "employers", Value: "2, 3"},
+			want:   contactWith(func(contact *models.Contact) { contact.Employers = []int64{2, 3} }),
+		},
+		{
+			name:   "clear employers",
+			change: bulkEditChange{Action: bulkEditActionClear, Field: "employers"},
+			want:   contactWith(func(contact *models.Contact) { contact.Employers = []int64{} }),
+		},
+		{
+			name:   "replace custom field",
+			change: bulkEditChange{Action: bulkEditActionReplace, Field: "beat", Find: "Tech", Replace: "Food"},
+			want: contactWith(func(contact *models.Contact) {
+				contact.CustomFields = []models.CustomContactField{{Name: "beat", Value: "Food news"}}
+			}),
+		},
+		{
+			name:   "set new custom field",
+			change: bulkEditChange{Action: bulkEditActionSet, Field: "circulation", Value: "1000"},
+			want: contactWith(func(contact *models.Contact) {
+				contact.CustomFields = append(contact.CustomFields, models.CustomContactField{Name: "circulation", Value: "1000"})
+			}),
+		},
+		{
+			name:   "clear custom field",
+			change: bulkEditChange{Action: bulkEditActionClear, Field: "beat"},
+			want:   contactWith(func(contact *models.Contact) { contact.CustomFields = []models.CustomContactField{} }),
+		},
+		{
+			name:    "custom field value of the wrong type",
+			change:  bulkEditChange{Action: bulkEditActionSet, Field: "circulation", Value: "many"},
+			wantErr: true,
+		},
+		{
+			name:    "field that isn't custom",
+			change:  bulkEditChange{Action: bulkEditActionSet, Field: "email", Value: "jane@example.com"},
+			wantErr: true,
+		},
+		{
+			name:    "field not in the list",
+			change:  bulkEditChange{Action: bulkEditActionSet, Field: "region", Value: "West"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		contact := contactWith(nil)
+		err := applyBulkEditChange(&contact, mediaList, test.change)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%v: changed to %+v, want an error", test.name, contact)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(contact, test.want) {
+			t.Errorf("%v: changed to %+v, want %+v", test.name, contact, test.want)
+		}
+	}
+}
@@ -0,0 +1,327 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+
+	"github.com/qedus/nds"
+
+	"github.com/news-ai/tabulae/models"
+
+	"github.com/news-ai/web/utilities"
+)
+
+/*
+* Private
+ */
+
+// How many contacts each request of RebuildContactEngagements goes through
+var engagementRebuildLimit = 200
+
+/*
+* Private methods
+ */
+
+// When the email went out, or will go out if it is scheduled
+func emailSentAt(email models.Email) time.Time {
+	if !email.SendAt.IsZero() {
+		return email.SendAt
+	}
+	return email.Created
+}
+
+// Drops duplicates and emails that weren't sent to a contact
+func uniqueContactIds(contactIds []int64) []int64 {
+	uniqueIds := []int64{}
+	existingIds := map[int64]bool{}
+	for i := 0; i < len(contactIds); i++ {
+		if _, ok := existingIds[contactIds[i]]; ok || contactIds[i] == 0 {
+			continue
+		}
+		existingIds[contactIds[i]] = true
+		uniqueIds = append(uniqueIds, contactIds[i])
+	}
+	return uniqueIds
+}
+
+func isEngagementEmail(email models.Email) bool {
+	return email.ContactId != 0 && email.IsSent && !email.Cancel
+}
+
+// Loads the engagement of contacts. Contacts that were never emailed don't
+// have one and are left out.
+func getContactEngagements(c context.Context, contactIds []int64) (map[int64]models.ContactEngagement, error) {
+	engagements := map[int64]models.ContactEngagement{}
+	if len(contactIds) == 0 {
+		return engagements, nil
+	}
+
+	keys := make([]*datastore.Key, len(contactIds))
+	for i := 0; i < len(contactIds); i++ {
+		engagement := models.ContactEngagement{ContactId: contactIds[i]}
+		keys[i] = engagement.Key(c)
+	}
+
+	contactEngagements := make([]models.ContactEngagement, len(keys))
+	err := nds.GetMulti(c, keys, contactEngagements)
+	multiErr, isMultiErr := err.(appengine.MultiError)
+	if err != nil && !isMultiErr {
+		log.Errorf(c, "%v", err)
+		return engagements, err
+	}
+
+	for i := 0; i < len(contactEngagements); i++ {
+		if isMultiErr && multiErr[i] != nil {
+			if multiErr[i] != datastore.ErrNoSuchEntity {
+				log.Errorf(c, "%v", multiErr[i])
+			}
+			continue
+		}
+		contactEngagements[i].Id = keys[i].IntID()
+		engagements[contactIds[i]] = contactEngagements[i]
+	}
+
+	return engagements, nil
+}
+
+// Applies a change to the engagement of the contact an email was sent to.
+// Trackers call this for every event, so it runs in a transaction to not lose
+// events that arrive at the same time.
+func updateContactEngagement(c context.Context, email models.Email, update func(*models.ContactEngagement)) error {
+	if email.ContactId == 0 {
+		return nil
+	}
+
+	err := nds.RunInTransaction(c, func(ctx context.Context) error {
+		engagement := models.ContactEngagement{ContactId: email.ContactId}
+		err := nds.Get(ctx, engagement.Key(ctx), &engagement)
+		if err != nil && err != datastore.ErrNoSuchEntity {
+			return err
+		}
+
+		engagement.ContactId = email.ContactId
+		if engagement.CreatedBy == 0 {
+			engagement.CreatedBy = email.CreatedBy
+		}
+		update(&engagement)
+
+		_, err = engagement.Save(ctx)
+		return err
+	}, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+	}
+	return err
+}
+
+func recordEmailSent(c context.Context, email models.Email) error {
+	return updateContactEngagement(c, email, func(engagement *models.ContactEngagement) {
+		engagement.EmailsSent += 1
+		if sentAt := emailSentAt(email); sentAt.After(engagement.LastContacted) {
+			engagement.LastContacted = sentAt
+		}
+	})
+}
+
+// Every open moves the last opened date, but an email only counts as opened
+// once
+func recordEmailOpened(c context.Context, email models.Email, firstOpen bool) error {
+	return updateContactEngagement(c, email, func(engagement *models.ContactEngagement) {
+		if firstOpen {
+			engagement.EmailsOpened += 1
+		}
+		engagement.LastOpened = time.Now()
+	})
+}
+
+func recordEmailClicked(c context.Context, email models.Email) error {
+	return updateContactEngagement(c, email, func(engagement *models.ContactEngagement) {
+		engagement.EmailsClicked += 1
+	})
+}
+
+// Counts the engagement from the emails sent to a contact. Emails don't keep
+// when they were opened, so that date is taken from the existing engagement
+// when there is one.
+func engagementFromEmails(engagement models.ContactEngagement, emails []models.Email) models.ContactEngagement {
+	engagement.EmailsSent = 0
+	engagement.EmailsOpened = 0
+	engagement.EmailsClicked = 0
+	engagement.LastContacted = time.Time{}
+
+	lastOpened := time.Time{}
+	for i := 0; i < len(emails); i++ {
+		if !isEngagementEmail(emails[i]) {
+			continue
+		}
+
+		engagement.EmailsSent += 1
+		if sentAt := emailSentAt(emails[i]); sentAt.After(engagement.LastContacted) {
+			engagement.LastContacted = sentAt
+		}
+
+		if emails[i].Opened > 0 || emails[i].SendGridOpened > 0 {
+			engagement.EmailsOpened += 1
+			if emails[i].Updated.After(lastOpened) {
+				lastOpened = emails[i].Updated
+			}
+		}
+
+		if emails[i].Clicked > 0 || emails[i].SendGridClicked > 0 {
+			engagement.EmailsClicked += 1
+		}
+	}
+
+	if engagement.LastOpened.IsZero() {
+		engagement.LastOpened = lastOpened
+	}
+
+	return engagement
+}
+
+// Recounts the engagement of contacts from their emails. This is used when
+// emails stop counting, like when they are cancelled.
+func rebuildContactEngagements(c context.Context, contactIds []int64) error {
+	engagements, err := getContactEngagements(c, contactIds)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	keys := []*datastore.Key{}
+	rebuiltEngagements := []models.ContactEngagement{}
+	for i := 0; i < len(contactIds); i++ {
+		ks, err := datastore.NewQuery("Email").Filter("ContactId =", contactIds[i]).Filter("IsSent =", true).KeysOnly().GetAll(c, nil)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return err
+		}
+
+		emails := make([]models.Email, len(ks))
+		err = nds.GetMulti(c, ks, emails)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return err
+		}
+
+		engagement, ok := engagements[contactIds[i]]
+		if !ok {
+			engagement = models.ContactEngagement{ContactId: contactIds[i]}
+			engagement.Created = time.Now()
+			if len(emails) > 0 {
+				engagement.CreatedBy = emails[0].CreatedBy
+			}
+		}
+
+		engagement = engagementFromEmails(engagement, emails)
+		engagement.Updated = time.Now()
+		keys = append(keys, engagement.Key(c))
+		rebuiltEngagements = append(rebuiltEngagements, engagement)
+	}
+
+	_, err = putMultiInBatches(c, keys, rebuiltEngagements)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	return nil
+}
+
+/*
+* Public methods
+ */
+
+/*
+* Get methods
+ */
+
+func GetEngagementForContact(c context.Context, r *http.Request, id string) (models.ContactEngagement, interface{}, error) {
+	currentId, err := utilities.StringIdToInt(id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.ContactEngagement{}, nil, err
+	}
+
+	// To check if the user can access it
+	contact, err := getContact(c, r, currentId)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.ContactEngagement{}, nil, err
+	}
+
+	engagements, err := getContactEngagements(c, []int64{contact.Id})
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.ContactEngagement{}, nil, err
+	}
+
+	engagement, ok := engagements[contact.Id]
+	if !ok {
+		engagement = models.ContactEngagement{ContactId: contact.Id}
+	}
+	engagement.Type = "engagements"
+
+	return engagement, nil, nil
+}
+
+/*
+* Action methods
+ */
+
+// Builds the engagement of contacts that were emailed before engagement was
+// tracked. Each request goes through engagementRebuildLimit contacts from
+// the cursor of the last one, and returns the cursor to continue from, which
+// is empty once every contact is done.
+func RebuildContactEngagements(c context.Context, r *http.Request) (string, error) {
+	query := datastore.NewQuery("Email").Filter("IsSent =", true).Filter("ContactId >", int64(0)).Project("ContactId").Distinct().Order("ContactId")
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		decodedCursor, err := datastore.DecodeCursor(cursor)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return "", errors.New("Cursor is not valid")
+		}
+		query = query.Start(decodedCursor)
+	}
+
+	contactIds := []int64{}
+	nextCursor := ""
+	t := query.Run(c)
+	for {
+		if len(contactIds) == engagementRebuildLimit {
+			cursor, err := t.Cursor()
+			if err != nil {
+				log.Errorf(c, "%v", err)
+				return "", err
+			}
+			nextCursor = cursor.String()
+			break
+		}
+
+		var email models.Email
+		_, err := t.Next(&email)
+		if err == datastore.Done {
+			break
+		}
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return "", err
+		}
+		contactIds = append(contactIds, email.ContactId)
+	}
+
+	err := rebuildContactEngagements(c, contactIds)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return "", err
+	}
+
+	return nextCursor, nil
+}
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/news-ai/tabulae/models"
+)
+
+func TestEngagementFromEmails(t *testing.T) {
+	day := func(date int) time.Time {
+		return time.Date(2017, time.January, date, 12, 0, 0, 0, time.UTC)
+	}
+
+	email := func(created int, edit func(email *models.Email)) models.Email {
+		email := models.Email{ContactId: 1, IsSent: true}
+		email.Created = day(created)
+		email.Updated = day(created)
+		if edit != nil {
+			edit(&email)
+		}
+		return email
+	}
+
+	tests := []struct {
+		name       string
+		engagement models.ContactEngagement
+		emails     []models.Email
+		want       models.ContactEngagement
+	}{
+		{
+			name:   "no emails",
+			emails: []models.Email{},
+			want:   models.ContactEngagement{},
+		},
+		{
+			name: "sent, opened and clicked",
+			emails: []models.Email{
+				email(1, nil),
+				email(2, func(email *models.Email) { email.Opened = 2; email.Updated = day(4) }),
+				email(3, func(email *models.Email) { email.SendGridOpened = 1; email.SendGridClicked = 1; email.Updated = day(5) }),
+			},
+			want: models.ContactEngagement{EmailsSent: 3, EmailsOpened: 2, EmailsClicked: 1, LastContacted: day(3), LastOpened: day(5)},
+		},
+		{
+			name: "scheduled emails count from when they go out",
+			emails: []models.Email{
+				email(1, func(email *models.Email) { email.SendAt = day(6) }),
+				email(3, nil),
+			},
+			want: models.ContactEngagement{EmailsSent: 2, LastContacted: day(6)},
+		},
+		{
+			name: "drafts, cancelled emails and emails to no contact",
+			emails: []models.Email{
+				email(1, nil),
+				email(2, func(email *models.Email) { email.IsSent = false; email.Opened = 1 }),
+				email(3, func(email *models.Email) { email.Cancel = true; email.Clicked = 1 }),
+				email(4, func(email *models.Email) { email.ContactId = 0 }),
+			},
+			want: models.ContactEngagement{EmailsSent: 1, LastContacted: day(1)},
+		},
+		{
+			name:       "counts are recounted and the last open is kept",
+			engagement: models.ContactEngagement{EmailsSent: 5, EmailsOpened: 5, EmailsClicked: 5, LastContacted: day(9), LastOpened: day(8)},
+			emails: []models.Email{
+				email(1, func(email *models.Email) { email.Opened = 1; email.Updated = day(2) }),
+			},
+			want: models.ContactEngagement{EmailsSent: 1, EmailsOpened: 1, LastContacted: day(1), LastOpened: day(8)},
+		},
+	}
+
+	for _, test := range tests {
+		engagement := engagementFromEmails(test.engagement, test.emails)
+		if !reflect.DeepEqual(engagement, test.want) {
+			t.Errorf("%v: counted %+v, want %+v", test.name, engagement, test.want)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package controllers
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+
+	"github.com/qedus/nds"
+
+	apiModels "github.com/news-ai/api/models"
+
+	"github.com/news-ai/tabulae/models"
+	"github.com/news-ai/tabulae/search"
+)
+
+/*
+* Private
+ */
+
+// A value of a facet and how many contacts have it
+type FacetBucket struct {
+	Value string `json:"value"`
+	Name  string `json:"name,omitempty"`
+	Count int    `json:"count"`
+}
+
+// Counts of what the contacts matching a search or filter have, for filter
+// sidebars and to see what a list is made of
+type ContactFacets struct {
+	Type string `json:"type"`
+
+	// How many contacts were counted
+	Contacts int `json:"contacts"`
+
+	Employers []FacetBucket `json:"employers"`
+	Tags      []FacetBucket `json:"tags"`
+	Locations []FacetBucket `json:"locations"`
+
+	Bounced      int `json:"bounced"`
+	Unsubscribed int `json:"unsubscribed"`
+
+	// Every option of the select fields of the list, by field
+	CustomFields map[string][]FacetBucket `json:"customfields"`
+}
+
+var (
+	// Employers, tags and locations only keep their most common values
+	facetBucketLimit = 10
+
+	// How many different values of a select field searches count. Values of
+	// multi select fields are counted together before they are split.
+	facetFieldValueLimit = 500
+)
+
+type facetBucketsByCount []FacetBucket
+
+func (fb facetBucketsByCount) Len() int {
+	return len(fb)
+}
+
+func (fb facetBucketsByCount) Swap(i, j int) {
+	fb[i], fb[j] = fb[j], fb[i]
+}
+
+func (fb facetBucketsByCount) Less(i, j int) bool {
+	if fb[i].Count != fb[j].Count {
+		return fb[i].Count > fb[j].Count
+	}
+	return fb[i].Value < fb[j].Value
+}
+
+/*
+* Private methods
+ */
+
+func topFacetBuckets(counts map[string]int) []FacetBucket {
+	buckets := []FacetBucket{}
+	for value, count := range counts {
+		buckets = append(buckets, FacetBucket{
+			Value: value,
+			Count: count,
+		})
+	}
+
+	sort.Sort(facetBucketsByCount(buckets))
+	if len(buckets) > facetBucketLimit {
+		buckets = buckets[:facetBucketLimit]
+	}
+	return buckets
+}
+
+func termsToFacetBuckets(buckets []search.TermsBucket) []FacetBucket {
+	facetBuckets := []FacetBucket{}
+	for i := 0; i < len(buckets); i++ {
+		facetBuckets = append(facetBuckets, FacetBucket{
+			Value: buckets[i].Value,
+			Count: buckets[i].Count,
+		})
+	}
+	return facetBuckets
+}
+
+func selectFieldsOfList(mediaList models.MediaList) []models.CustomFieldsMap {
+	selectFields := []models.CustomFieldsMap{}
+	for i := 0; i < len(mediaList.FieldsMap); i++ {
+		if mediaList.FieldsMap[i].Type == models.FieldTypeSelect || mediaList.FieldsMap[i].Type == models.FieldTypeMultiSelect {
+			selectFields = append(selectFields, mediaList.FieldsMap[i])
+		}
+	}
+	return selectFields
+}
+
+// Select fields keep the order of their options, including the ones no
+// contact has, so the sidebar can show every choice
+func selectFieldBuckets(field models.CustomFieldsMap, counts map[string]int) []FacetBucket {
+	buckets := []FacetBucket{}
+	for i := 0; i < len(field.Options); i++ {
+		buckets = append(buckets, FacetBucket{
+			Value: field.Options[i],
+			Count: counts[field.Options[i]],
+		})
+	}
+	return buckets
+}
+
+// Names the employer buckets by their publication. Publications that no
+// longer exist keep only their id.
+func nameEmployerBuckets(c context.Context, buckets []FacetBucket) {
+	keys := []*datastore.Key{}
+	for i := 0; i < len(buckets); i++ {
+		publicationId, _ := strconv.ParseInt(buckets[i].Value, 10, 64)
+		keys = append(keys, datastore.NewKey(c, "Publication", "", publicationId, nil))
+	}
+
+	publications := make([]models.Publication, len(keys))
+	err := nds.GetMulti(c, keys, publications)
+	multiErr, isMultiErr := err.(appengine.MultiError)
+	if err != nil && !isMultiErr {
+		log.Errorf(c, "%v", err)
+		return
+	}
+
+	for i := 0; i < len(publications); i++ {
+		if isMultiErr && multiErr[i] != nil {
+			continue
+		}
+		buckets[i].Name = publications[i].Name
+	}
+}
+
+func countContactFacets(c context.Context, mediaList models.MediaList, contacts []models.Contact) ContactFacets {
+	facets := ContactFacets{
+		Type:         "facets",
+		Contacts:     len(contacts),
+		CustomFields: map[string][]FacetBucket{},
+	}
+
+	unsubscribedEmails, err := getUnsubscribedEmails(c, mediaList.CreatedBy)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+	}
+
+	selectFields := selectFieldsOfList(mediaList)
+
+	employerCounts := map[string]int{}
+	tagCounts := map[string]int{}
+	locationCounts := map[string]int{}
+	selectFieldCounts := make([]map[string]int, len(selectFields))
+	for i := 0; i < len(selectFields); i++ {
+		selectFieldCounts[i] = map[string]int{}
+	}
+
+	for i := 0; i < len(contacts); i++ {
+		for x := 0; x < len(contacts[i].Employers); x++ {
+			employerCounts[strconv.FormatInt(contacts[i].Employers[x], 10)] += 1
+		}
+
+		tags := models.NormalizeTags(contacts[i].Tags)
+		for x := 0; x < len(tags); x++ {
+			tagCounts[tags[x]] += 1
+		}
+
+		if location := strings.TrimSpace(contacts[i].Location); location != "" {
+			locationCounts[location] += 1
+		}
+
+		if contacts[i].EmailBounced {
+			facets.Bounced += 1
+		}
+
+		if _, ok := unsubscribedEmails[strings.ToLower(contacts[i].Email)]; ok && contacts[i].Email != "" {
+			facets.Unsubscribed += 1
+		}
+
+		for x := 0; x < len(selectFields); x++ {
+			values := selectFields[x].FieldValues(contacts[i].FieldValue(selectFields[x].Value))
+			for y := 0; y < len(values); y++ {
+				selectFieldCounts[x][values[y]] += 1
+			}
+		}
+	}
+
+	facets.Employers = topFacetBuckets(employerCounts)
+	facets.Tags = topFacetBuckets(tagCounts)
+	facets.Locations = topFacetBuckets(locationCounts)
+	for i := 0; i < len(selectFields); i++ {
+		facets.CustomFields[selectFields[i].Value] = selectFieldBuckets(selectFields[i], selectFieldCounts[i])
+	}
+
+	nameEmployerBuckets(c, facets.Employers)
+	return facets
+}
+
+// Counts facets over every contact of the list that matches a search. The
+// search index counts them, so the matches aren't loaded.
+func searchContactFacets(c context.Context, mediaList models.MediaList, user apiModels.User, queryField string) (ContactFacets, error) {
+	aggregations := []search.TermsAggregation{
+		{Name: "employers", Field: "employers", Size: facetBucketLimit},
+		{Name: "tags", Field: "tags", Size: facetBucketLimit},
+		{Name: "locations", Field: "location", Size: facetBucketLimit},
+		{Name: "bounced", Field: "emailbounced", Size: 2},
+	}
+
+	unsubscribedEmails, err := getUnsubscribedEmails(c, mediaList.CreatedBy)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+	}
+
+	// Without any unsubscribed emails every email would be counted
+	if len(unsubscribedEmails) > 0 {
+		emails := []string{}
+		for email := range unsubscribedEmails {
+			emails = append(emails, email)
+		}
+		aggregations = append(aggregations, search.TermsAggregation{
+			Name:    "unsubscribed",
+			Field:   "email",
+			Size:    len(emails),
+			Include: emails,
+		})
+	}
+
+	selectFields := selectFieldsOfList(mediaList)
+	for i := 0; i < len(selectFields); i++ {
+		aggregations = append(aggregations, search.TermsAggregation{
+			Name:  search.TermsCustomFieldPrefix + selectFields[i].Value,
+			Field: search.TermsCustomFieldPrefix + selectFields[i].Value,
+			Size:  facetFieldValueLimit,
+		})
+	}
+
+	terms, total, err := search.SearchContactTermsByList(c, queryField, user, mediaList.CreatedBy, mediaList.Id, aggregations)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return ContactFacets{}, err
+	}
+
+	facets := ContactFacets{
+		Type:         "facets",
+		Contacts:     total,
+		Employers:    termsToFacetBuckets(terms["employers"]),
+		Tags:         termsToFacetBuckets(terms["tags"]),
+		Locations:    termsToFacetBuckets(terms["locations"]),
+		CustomFields: map[string][]FacetBucket{},
+	}
+
+	for i := 0; i < len(terms["bounced"]); i++ {
+		if terms["bounced"][i].Value == "true" {
+			facets.Bounced = terms["bounced"][i].Count
+		}
+	}
+
+	for i := 0; i < len(terms["unsubscribed"]); i++ {
+		facets.Unsubscribed += terms["unsubscribed"][i].Count
+	}
+
+	for i := 0; i < len(selectFields); i++ {
+		counts := map[string]int{}
+		buckets := terms[search.TermsCustomFieldPrefix+selectFields[i].Value]
+		for x := 0; x < len(buckets); x++ {
+			values := selectFields[i].FieldValues(buckets[x].Value)
+			for y := 0; y < len(values); y++ {
+				counts[values[y]] += buckets[x].Count
+			}
+		}
+		facets.CustomFields[selectFields[i].Value] = selectFieldBuckets(selectFields[i], counts)
+	}
+
+	nameEmployerBuckets(c, facets.Employers)
+	return facets, nil
+}
+
+// Counts facets over every contact of the list
+func listContactFacets(c context.Context, mediaList models.MediaList) (ContactFacets, error) {
+	contacts, err := getContactsForMediaList(c, mediaList)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return ContactFacets{}, err
+	}
+
+	return countContactFacets(c, mediaList, contacts), nil
+}
+
+// Adds the facets to what is included with the contacts
+func includeContactFacets(included interface{}, facets ContactFacets) []interface{} {
+	includedWithFacets := []interface{}{}
+	if publications, ok := included.([]models.Publication); ok {
+		for i := 0; i < len(publications); i++ {
+			includedWithFacets = append(includedWithFacets, publications[i])
+		}
+	}
+	return append(includedWithFacets, facets)
+}
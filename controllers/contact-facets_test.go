@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/news-ai/tabulae/models"
+	"github.com/news-ai/tabulae/search"
+)
+
+func TestTopFacetBuckets(t *testing.T) {
+	defaultFacetBucketLimit := facetBucketLimit
+	facetBucketLimit = 3
+	defer func() {
+		facetBucketLimit = defaultFacetBucketLimit
+	}()
+
+	buckets := topFacetBuckets(map[string]int{"b": 2, "a": 2, "c": 3, "d": 1})
+	want := []FacetBucket{{Value: "c", Count: 3}, {Value: "a", Count: 2}, {Value: "b", Count: 2}}
+	if !reflect.DeepEqual(buckets, want) {
+		t.Errorf("buckets are %+v, want %+v", buckets, want)
+	}
+}
+
+func TestSelectFieldBuckets(t *testing.T) {
+	field := models.CustomFieldsMap{Value: "region", Type: models.FieldTypeSelect, Options: []string{"East", "West"}}
+	buckets := selectFieldBuckets(field, map[string]int{"West": 2, "North": 1})
+	want := []FacetBucket{{Value: "East", Count: 0}, {Value: "West", Count: 2}}
+	if !reflect.DeepEqual(buckets, want) {
+		t.Errorf("buckets are %+v, want %+v", buckets, want)
+	}
+}
+
+// Facets are counted from the contacts of a list, or by the search index
+// for the contacts that match a search, and both have to count the same
+func TestContactFacets(t *testing.T) {
+	inst, c, r, user := newTestRequest(t, "GET", "/", "")
+	defer inst.Close()
+
+	publication := models.Publication{Name: "Acme"}
+	_, err := publication.Create(c, r, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unsubscribe := models.ContactUnsubscribe{Email: "john@example.com", Unsubscribed: true}
+	unsubscribe.CreatedBy = user.Id
+	_, err = unsubscribe.Create(c, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mediaList := models.MediaList{
+		FieldsMap: []models.CustomFieldsMap{
+			{Value: "region", CustomField: true, Type: models.FieldTypeSelect, Options: []string{"East", "West"}},
+			{Value: "topics", CustomField: true, Type: models.FieldTypeMultiSelect, Options: []string{"A", "B", "C"}},
+		},
+	}
+	mediaList.Id = 10
+	mediaList.CreatedBy = user.Id
+
+	jane := models.Contact{
+		Email:        "jane@example.com",
+		Notes:        "Launch",
+		Employers:    []int64{publication.Id},
+		Tags:         []string{"Tech"},
+		Location:     "New York",
+		EmailBounced: true,
+		CustomFields: []models.CustomContactField{{Name: "region", Value: "East"}, {Name: "topics", Value: "A,B"}},
+	}
+	john := models.Contact{
+		Email:        "John@Example.com",
+		Notes:        "Launch",
+		Employers:    []int64{publication.Id, 99},
+		Tags:         []string{"tech", "Food"},
+		CustomFields: []models.CustomContactField{{Name: "region", Value: "East"}, {Name: "topics", Value: "B"}},
+	}
+	contacts := []models.Contact{jane, john}
+	for i := 0; i < len(contacts); i++ {
+		contacts[i].Id = int64(i + 1)
+		contacts[i].ListId = mediaList.Id
+		contacts[i].CreatedBy = user.Id
+	}
+
+	memoryBackend := search.NewMemoryBackend()
+	memoryBackend.AddContacts(contacts...)
+	search.SetBackend(memoryBackend)
+
+	want := ContactFacets{
+		Type:     "facets",
+		Contacts: 2,
+		Employers: []FacetBucket{
+			{Value: strconv.FormatInt(publication.Id, 10), Name: "Acme", Count: 2},
+			{Value: "99", Count: 1},
+		},
+		Tags:         []FacetBucket{{Value: "tech", Count: 2}, {Value: "food", Count: 1}},
+		Locations:    []FacetBucket{{Value: "New York", Count: 1}},
+		Bounced:      1,
+		Unsubscribed: 1,
+		CustomFields: map[string][]FacetBucket{
+			"region": {{Value: "East", Count: 2}, {Value: "West", Count: 0}},
+			"topics": {{Value: "A", Count: 1}, {Value: "B", Count: 2}, {Value: "C", Count: 0}},
+		},
+	}
+
+	facets := countContactFacets(c, mediaList, contacts)
+	if !reflect.DeepEqual(facets, want) {
+		t.Errorf("counted %+v, want %+v", facets, want)
+	}
+
+	facets, err = searchContactFacets(c, mediaList, user, "launch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(facets, want) {
+		t.Errorf("searched %+v, want %+v", facets, want)
+	}
+}
@@ -0,0 +1,522 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+
+	"github.com/qedus/nds"
+
+	"github.com/news-ai/api/controllers"
+	apiModels "github.com/news-ai/api/models"
+
+	"github.com/news-ai/tabulae/models"
+	"github.com/news-ai/tabulae/sync"
+
+	"github.com/news-ai/web/utilities"
+)
+
+/*
+* Private
+ */
+
+var (
+	// Fields that are shared between a master contact and its children
+	masterContactFields = []string{"firstname", "lastname", "email", "employers", "linkedin", "twitter", "instagram"}
+
+	// How many contacts one run of the backfill links
+	masterBackfillBatchSize = 500
+)
+
+type MasterContact struct {
+	Contact  models.Contact     `json:"contact"`
+	Children []models.Contact   `json:"children"`
+	Emails   []models.Email     `json:"emails"`
+	Feeds    []models.Feed      `json:"feeds"`
+	Lists    []models.MediaList `json:"lists"`
+}
+
+/*
+* Private methods
+ */
+
+func isContactFieldOverridden(contact models.Contact, field string) bool {
+	for i := 0; i < len(contact.Overrides); i++ {
+		if contact.Overrides[i] == field {
+			return true
+		}
+	}
+	return false
+}
+
+func int64sEqual(first []int64, second []int64) bool {
+	if len(first) != len(second) {
+		return false
+	}
+
+	for i := 0; i < len(first); i++ {
+		if first[i] != second[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Records the identity fields that were changed on a child contact so they
+// are not overwritten by its master contact later on
+func addContactOverrides(contact *models.Contact, previousContact models.Contact) {
+	changed := map[string]bool{
+		"firstname": contact.FirstName != previousContact.FirstName,
+		"lastname":  contact.LastName != previousContact.LastName,
+		"email":     contact.Email != previousContact.Email,
+		"employers": !int64sEqual(contact.Employers, previousContact.Employers),
+		"linkedin":  contact.LinkedIn != previousContact.LinkedIn,
+		"twitter":   contact.Twitter != previousContact.Twitter,
+		"instagram": contact.Instagram != previousContact.Instagram,
+	}
+
+	for i := 0; i < len(masterContactFields); i++ {
+		if changed[masterContactFields[i]] && !isContactFieldOverridden(*contact, masterContactFields[i]) {
+			contact.Overrides = append(contact.Overrides, masterContactFields[i])
+		}
+	}
+}
+
+// Copies the identity fields of a master contact onto a child contact,
+// leaving out the ones the child overrides. Returns if anything changed.
+func applyMasterContact(master models.Contact, child *models.Contact) bool {
+	updated := false
+
+	fields := map[string][]*string{
+		"firstname": {&child.FirstName, &master.FirstName},
+		"lastname":  {&child.LastName, &master.LastName},
+		"email":     {&child.Email, &master.Email},
+		"linkedin":  {&child.LinkedIn, &master.LinkedIn},
+		"twitter":   {&child.Twitter, &master.Twitter},
+		"instagram": {&child.Instagram, &master.Instagram},
+	}
+
+	for field, values := range fields {
+		if !isContactFieldOverridden(*child, field) && *values[0] != *values[1] {
+			*values[0] = *values[1]
+			updated = true
+		}
+	}
+
+	if !isContactFieldOverridden(*child, "employers") && !int64sEqual(child.Employers, master.Employers) {
+		child.Employers = master.Employers
+		updated = true
+	}
+
+	return updated
+}
+
+func masterContactsQuery(user apiModels.User) *datastore.Query {
+	query := datastore.NewQuery("Contact").Filter("IsMasterContact =", true)
+
+	// Master contacts are shared by a team when the user is on one
+	if user.TeamId != 0 {
+		return query.Filter("TeamId =", user.TeamId)
+	}
+	return query.Filter("CreatedBy =", user.Id)
+}
+
+func filterMasterContactByEmail(c context.Context, user apiModels.User, email string) (models.Contact, error) {
+	query := masterContactsQuery(user).Filter("Email =", email)
+
+	ks, err := query.Limit(1).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Contact{}, err
+	}
+
+	if len(ks) == 0 {
+		return models.Contact{}, errors.New("No master contact by this email")
+	}
+
+	var contact models.Contact
+	err = nds.Get(c, ks[0], &contact)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Contact{}, err
+	}
+
+	contact.Format(ks[0], "contacts")
+	return contact, nil
+}
+
+// Master contacts of the user (or their team) with one of the emails, by
+// email. Each email is looked up by its keys, and the master contacts that
+// were found are read together.
+func filterMasterContactsByEmails(c context.Context, user apiModels.User, emails map[string]bool) (map[string]models.Contact, error) {
+	masterContacts := map[string]models.Contact{}
+
+	ks := []*datastore.Key{}
+	for email := range emails {
+		emailKeys, err := masterContactsQuery(user).Filter("Email =", email).Limit(1).KeysOnly().GetAll(c, nil)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return masterContacts, err
+		}
+		ks = append(ks, emailKeys...)
+	}
+
+	if len(ks) == 0 {
+		return masterContacts, nil
+	}
+
+	contacts := make([]models.Contact, len(ks))
+	err := nds.GetMulti(c, ks, contacts)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return masterContacts, err
+	}
+
+	for i := 0; i < len(contacts); i++ {
+		contacts[i].Format(ks[i], "contacts")
+		masterContacts[contacts[i].Email] = contacts[i]
+	}
+
+	return masterContacts, nil
+}
+
+func newMasterContact(user apiModels.User, contact models.Contact) models.Contact {
+	return models.Contact{
+		FirstName:       contact.FirstName,
+		LastName:        contact.LastName,
+		Email:           contact.Email,
+		Employers:       contact.Employers,
+		LinkedIn:        contact.LinkedIn,
+		Twitter:         contact.Twitter,
+		Instagram:       contact.Instagram,
+		IsMasterContact: true,
+		TeamId:          user.TeamId,
+	}
+}
+
+func needsMasterContact(contact models.Contact) bool {
+	return !contact.IsMasterContact && contact.ParentContact == 0 && contact.Email != ""
+}
+
+// Links a contact to the user's (or team's) master contact with the same
+// email, creating the master contact if there isn't one yet
+func linkContactToMaster(c context.Context, r *http.Request, user apiModels.User, contact *models.Contact) error {
+	if !needsMasterContact(*contact) {
+		return nil
+	}
+
+	master, err := filterMasterContactByEmail(c, user, contact.Email)
+	if err != nil {
+		master = newMasterContact(user, *contact)
+		_, err = master.Create(c, r, user)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return err
+		}
+
+		err = sync.ResourceSync(r, master.Id, "Contact", sync.ActionCreate)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
+	}
+
+	contact.ParentContact = master.Id
+	applyMasterContact(master, contact)
+	return nil
+}
+
+// Links many contacts of a user to their master contacts at once, like the
+// contacts of an upload. Master contacts that are missing are created
+// together, and contacts with the same email share one.
+func linkContactsToMasters(c context.Context, r *http.Request, user apiModels.User, contacts []models.Contact) error {
+	emails := map[string]bool{}
+	for i := 0; i < len(contacts); i++ {
+		if needsMasterContact(contacts[i]) {
+			emails[contacts[i].Email] = true
+		}
+	}
+
+	masterContacts, err := filterMasterContactsByEmails(c, user, emails)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	newMasterContacts := []models.Contact{}
+	for i := 0; i < len(contacts); i++ {
+		if !needsMasterContact(contacts[i]) {
+			continue
+		}
+
+		if _, ok := masterContacts[contacts[i].Email]; !ok {
+			master := newMasterContact(user, contacts[i])
+			master.CreatedBy = user.Id
+			master.Created = time.Now()
+			master.Updated = time.Now()
+			master.Normalize()
+			master.FormatName()
+
+			masterContacts[master.Email] = master
+			newMasterContacts = append(newMasterContacts, master)
+		}
+	}
+
+	_, err = saveContactsAndSync(c, newMasterContacts, queueContactsSync(sync.ActionCreate))
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	for i := 0; i < len(newMasterContacts); i++ {
+		masterContacts[newMasterContacts[i].Email] = newMasterContacts[i]
+	}
+
+	for i := 0; i < len(contacts); i++ {
+		if !needsMasterContact(contacts[i]) {
+			continue
+		}
+
+		master := masterContacts[contacts[i].Email]
+		contacts[i].ParentContact = master.Id
+		applyMasterContact(master, &contacts[i])
+	}
+
+	return nil
+}
+
+func filterContactsForMasterContact(c context.Context, masterId int64) ([]models.Contact, error) {
+	ks, err := datastore.NewQuery("Contact").Filter("ParentContact =", masterId).Filter("IsDeleted =", false).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Contact{}, err
+	}
+
+	contacts := make([]models.Contact, len(ks))
+	err = nds.GetMulti(c, ks, contacts)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Contact{}, err
+	}
+
+	for i := 0; i < len(contacts); i++ {
+		contacts[i].Format(ks[i], "contacts")
+	}
+
+	return contacts, nil
+}
+
+// Pushes the identity fields of a master contact down to its children
+func propagateMasterContact(c context.Context, r *http.Request, master models.Contact) error {
+	children, err := filterContactsForMasterContact(c, master.Id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	updatedChildren := []models.Contact{}
+	previousChildren := []models.Contact{}
+	for i := 0; i < len(children); i++ {
+		previousChild := children[i]
+		if applyMasterContact(master, &children[i]) {
+			children[i].Updated = time.Now()
+			updatedChildren = append(updatedChildren, children[i])
+			previousChildren = append(previousChildren, previousChild)
+		}
+	}
+
+	// The children that were saved before an error still get their revisions
+	saved, saveErr := saveContactsAndSync(c, updatedChildren, queueContactsSync(sync.ActionUpdate))
+	updatedChildren = updatedChildren[:saved]
+	TriggerContactUpdatedWebhooks(c, updatedChildren)
+
+	for i := 0; i < len(updatedChildren); i++ {
+		err = RecordContactRevisions(c, r, previousChildren[i], updatedChildren[i], models.RevisionSourceMaster)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
+	}
+
+	if saveErr != nil {
+		log.Errorf(c, "%v", saveErr)
+		return saveErr
+	}
+	return nil
+}
+
+/*
+* Public methods
+ */
+
+/*
+* Get methods
+ */
+
+// Gets the master contact of a contact along with the emails, feeds and lists
+// of all of its children
+func GetMasterContact(c context.Context, r *http.Request, id string) (MasterContact, interface{}, error) {
+	currentId, err := utilities.StringIdToInt(id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return MasterContact{}, nil, err
+	}
+
+	contact, err := getContact(c, r, currentId)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return MasterContact{}, nil, err
+	}
+
+	master := contact
+	if !contact.IsMasterContact {
+		if contact.ParentContact == 0 {
+			return MasterContact{}, nil, errors.New("Contact does not have a master contact")
+		}
+
+		master, err = getContact(c, r, contact.ParentContact)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return MasterContact{}, nil, err
+		}
+	}
+
+	user, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return MasterContact{}, nil, errors.New("Could not get user")
+	}
+
+	children, err := filterContactsForMasterContact(c, master.Id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return MasterContact{}, nil, err
+	}
+
+	masterContact := MasterContact{
+		Contact:  master,
+		Children: []models.Contact{},
+		Emails:   []models.Email{},
+		Feeds:    []models.Feed{},
+		Lists:    []models.MediaList{},
+	}
+
+	mediaListExists := map[int64]bool{}
+	for i := 0; i < len(children); i++ {
+		// Only show children on lists the user can get to
+		mediaList, err := getMediaList(c, r, children[i].ListId)
+		if err != nil {
+			continue
+		}
+
+		if _, ok := mediaListExists[mediaList.Id]; !ok {
+			masterContact.Lists = append(masterContact.Lists, mediaList)
+			mediaListExists[mediaList.Id] = true
+		}
+
+		masterContact.Children = append(masterContact.Children, children[i])
+
+		feeds, err := GetFeedsByResourceId(c, r, "ContactId", children[i].Id)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
+		masterContact.Feeds = append(masterContact.Feeds, feeds...)
+
+		ks, err := datastore.NewQuery("Email").Filter("CreatedBy =", user.Id).Filter("ContactId =", children[i].Id).Filter("IsSent =", true).KeysOnly().GetAll(c, nil)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			continue
+		}
+
+		emails := make([]models.Email, len(ks))
+		err = nds.GetMulti(c, ks, emails)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			continue
+		}
+
+		for x := 0; x < len(emails); x++ {
+			emails[x].Format(ks[x], "emails")
+		}
+		masterContact.Emails = append(masterContact.Emails, emails...)
+	}
+
+	includes := contactsToPublications(c, append([]models.Contact{master}, masterContact.Children...))
+	return masterContact, includes, nil
+}
+
+/*
+* Action methods
+ */
+
+// Links contacts that were created before there were master contacts to
+// them. The contacts keep their own values: fields that differ from their
+// master contact become overrides. Runs a batch at a time from a task and
+// returns how many contacts were linked, so it can run until none are left.
+func BackfillMasterContacts(c context.Context, r *http.Request) (int, error) {
+	ks, err := datastore.NewQuery("Contact").Filter("IsMasterContact =", false).Filter("ParentContact =", int64(0)).Filter("IsDeleted =", false).Filter("Email >", "").Limit(masterBackfillBatchSize).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return 0, err
+	}
+
+	contacts := make([]models.Contact, len(ks))
+	err = nds.GetMulti(c, ks, contacts)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return 0, err
+	}
+
+	// Master contacts belong to a user or their team
+	contactsByUser := map[int64][]models.Contact{}
+	for i := 0; i < len(contacts); i++ {
+		contacts[i].Format(ks[i], "contacts")
+		contactsByUser[contacts[i].CreatedBy] = append(contactsByUser[contacts[i].CreatedBy], contacts[i])
+	}
+
+	linked := 0
+	for userId, userContacts := range contactsByUser {
+		user, _, err := controllers.GetUserById(c, r, userId)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			continue
+		}
+
+		previousContacts := make([]models.Contact, len(userContacts))
+		copy(previousContacts, userContacts)
+
+		err = linkContactsToMasters(c, r, user, userContacts)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			continue
+		}
+
+		linkedContacts := []models.Contact{}
+		for i := 0; i < len(userContacts); i++ {
+			if userContacts[i].ParentContact == 0 {
+				continue
+			}
+
+			addContactOverrides(&userContacts[i], previousContacts[i])
+
+			contact := previousContacts[i]
+			contact.ParentContact = userContacts[i].ParentContact
+			contact.Overrides = userContacts[i].Overrides
+			contact.Updated = time.Now()
+
+			linkedContacts = append(linkedContacts, contact)
+		}
+
+		saved, err := saveContactsAndSync(c, linkedContacts, queueContactsSync(sync.ActionUpdate))
+		linked += saved
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return linked, err
+		}
+	}
+
+	return linked, nil
+}
@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/news-ai/tabulae/models"
+	"github.com/news-ai/tabulae/sync"
+)
+
+func TestAddContactOverrides(t *testing.T) {
+	previousContact := models.Contact{FirstName: "Jane", Email: "jane@example.com", Employers: []int64{1}}
+
+	tests := []struct {
+		name      string
+		contact   models.Contact
+		overrides []string
+		want      []string
+	}{
+		{
+			name:    "nothing changed",
+			contact: previousContact,
+			want:    nil,
+		},
+		{
+			name:    "identity fields",
+			contact: models.Contact{FirstName: "Janet", Email: "jane@example.com", Employers: []int64{2}},
+			want:    []string{"firstname", "employers"},
+		},
+		{
+			name:      "already overridden",
+			contact:   models.Contact{FirstName: "Janet", Email: "janet@example.com", Employers: []int64{1}},
+			overrides: []string{"firstname"},
+			want:      []string{"firstname", "email"},
+		},
+		{
+			name:    "other fields",
+			contact: models.Contact{FirstName: "Jane", Email: "jane@example.com", Employers: []int64{1}, Notes: "Met at the launch"},
+			want:    nil,
+		},
+	}
+
+	for _, test := range tests {
+		contact := test.contact
+		contact.Overrides = test.overrides
+		addContactOverrides(&contact, previousContact)
+		if !reflect.DeepEqual(contact.Overrides, test.want) {
+			t.Errorf("%v: overrides are %v, want %v", test.name, contact.Overrides, test.want)
+		}
+	}
+}
+
+func TestApplyMasterContact(t *testing.T) {
+	master := models.Contact{FirstName: "Jane", LastName: "Doe", Email: "jane@example.com", Employers: []int64{1}, Twitter: "jane"}
+
+	tests := []struct {
+		name        string
+		child       models.Contact
+		want        models.Contact
+		wantUpdated bool
+	}{
+		{
+			name:  "same fields",
+			child: models.Contact{FirstName: "Jane", LastName: "Doe", Email: "jane@example.com", Employers: []int64{1}, Twitter: "jane"},
+			want:  models.Contact{FirstName: "Jane", LastName: "Doe", Email: "jane@example.com", Employers: []int64{1}, Twitter: "jane"},
+		},
+		{
+			name:        "copies identity fields",
+			child:       models.Contact{FirstName: "J", Email: "jane@example.com", Notes: "Met at the launch"},
+			want:        models.Contact{FirstName: "Jane", LastName: "Doe", Email: "jane@example.com", Employers: []int64{1}, Twitter: "jane", Notes: "Met at the launch"},
+			wantUpdated: true,
+		},
+		{
+			name:        "keeps overridden fields",
+			child:       models.Contact{FirstName: "Janet", Email: "jane@example.com", Employers: []int64{2}, Overrides: []string{"firstname", "employers"}},
+			want:        models.Contact{FirstName: "Janet", LastName: "Doe", Email: "jane@example.com", Employers: []int64{2}, Twitter: "jane", Overrides: []string{"firstname", "employers"}},
+			wantUpdated: true,
+		},
+	}
+
+	for _, test := range tests {
+		child := test.child
+		updated := applyMasterContact(master, &child)
+		if updated != test.wantUpdated || !reflect.DeepEqual(child, test.want) {
+			t.Errorf("%v: changed to %+v (%v), want %+v (%v)", test.name, child, updated, test.want, test.wantUpdated)
+		}
+	}
+}
+
+func TestFilterMasterContactsByEmails(t *testing.T) {
+	inst, c, _, user := newTestRequest(t, "POST", "/", "")
+	defer inst.Close()
+	newTestBus()
+
+	jane := newMasterContact(user, models.Contact{Email: "jane@example.com"})
+	john := newMasterContact(user, models.Contact{Email: "john@example.com"})
+	child := models.Contact{Email: "janet@example.com"}
+	contacts := []models.Contact{jane, john, child}
+	for i := 0; i < len(contacts); i++ {
+		contacts[i].CreatedBy = user.Id
+	}
+	_, err := saveContactsAndSync(c, contacts, queueContactsSync(sync.ActionCreate))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	emails := map[string]bool{"jane@example.com": true, "janet@example.com": true, "jim@example.com": true}
+	masterContacts, err := filterMasterContactsByEmails(c, user, emails)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(masterContacts) != 1 || masterContacts["jane@example.com"].Id != contacts[0].Id {
+		t.Errorf("found %+v, want only the master contact of jane@example.com", masterContacts)
+	}
+}
@@ -0,0 +1,472 @@
+package controllers
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+
+	"github.com/pquerna/ffjson/ffjson"
+	"github.com/qedus/nds"
+
+	"github.com/news-ai/api/controllers"
+
+	"github.com/news-ai/tabulae/models"
+	"github.com/news-ai/tabulae/sync"
+)
+
+/*
+* Private
+ */
+
+var (
+	duplicateEmailScore  = 1.0
+	duplicateSocialScore = 0.9
+	duplicateNameScore   = 0.8
+
+	// The merged contacts are saved in one transaction with their sync,
+	// which can only write to 25 entity groups
+	maxMergeContacts = 20
+)
+
+type mergeContactsDetails struct {
+	Primary  int64   `json:"primary"`
+	Contacts []int64 `json:"contacts"`
+}
+
+type DuplicateContacts struct {
+	Contacts []int64  `json:"contacts"`
+	Score    float64  `json:"score"`
+	Reasons  []string `json:"reasons"`
+}
+
+/*
+* Private methods
+ */
+
+func normalizeContactName(contact models.Contact) string {
+	name := strings.ToLower(contact.FirstName + " " + contact.LastName)
+	return strings.Join(strings.Fields(name), " ")
+}
+
+func findParent(parents []int, i int) int {
+	for parents[i] != i {
+		parents[i] = parents[parents[i]]
+		i = parents[i]
+	}
+	return i
+}
+
+// Groups contacts that look like the same person. Contacts are compared on
+// their email, their social handles, and their name at the same employer.
+func duplicateContactGroups(contacts []models.Contact) []DuplicateContacts {
+	parents := make([]int, len(contacts))
+	scores := make([]float64, len(contacts))
+	reasons := make([]map[string]bool, len(contacts))
+	for i := 0; i < len(contacts); i++ {
+		parents[i] = i
+		reasons[i] = map[string]bool{}
+	}
+
+	buckets := map[string][]int{}
+	bucketScore := map[string]float64{}
+	bucketReason := map[string]string{}
+
+	addToBucket := func(reason string, value string, score float64, i int) {
+		if value == "" {
+			return
+		}
+		key := reason + ":" + value
+		buckets[key] = append(buckets[key], i)
+		bucketScore[key] = score
+		bucketReason[key] = reason
+	}
+
+	for i := 0; i < len(contacts); i++ {
+		addToBucket("email", strings.ToLower(strings.TrimSpace(contacts[i].Email)), duplicateEmailScore, i)
+		addToBucket("twitter", contacts[i].Twitter, duplicateSocialScore, i)
+		addToBucket("instagram", contacts[i].Instagram, duplicateSocialScore, i)
+		addToBucket("linkedin", contacts[i].LinkedIn, duplicateSocialScore, i)
+
+		name := normalizeContactName(contacts[i])
+		if name != "" {
+			for x := 0; x < len(contacts[i].Employers); x++ {
+				employer := name + "@" + strconv.FormatInt(contacts[i].Employers[x], 10)
+				addToBucket("name", employer, duplicateNameScore, i)
+			}
+		}
+	}
+
+	for key, members := range buckets {
+		if len(members) < 2 {
+			continue
+		}
+
+		for i := 1; i < len(members); i++ {
+			first := findParent(parents, members[0])
+			current := findParent(parents, members[i])
+			if first != current {
+				parents[current] = first
+				if scores[current] > scores[first] {
+					scores[first] = scores[current]
+				}
+				for reason := range reasons[current] {
+					reasons[first][reason] = true
+				}
+			}
+		}
+
+		root := findParent(parents, members[0])
+		if bucketScore[key] > scores[root] {
+			scores[root] = bucketScore[key]
+		}
+		reasons[root][bucketReason[key]] = true
+	}
+
+	groupIndex := map[int]int{}
+	groups := []DuplicateContacts{}
+	for i := 0; i < len(contacts); i++ {
+		root := findParent(parents, i)
+		if _, ok := groupIndex[root]; !ok {
+			groupIndex[root] = len(groups)
+			groups = append(groups, DuplicateContacts{})
+		}
+		index := groupIndex[root]
+		groups[index].Contacts = append(groups[index].Contacts, contacts[i].Id)
+	}
+
+	duplicateGroups := []DuplicateContacts{}
+	for root, index := range groupIndex {
+		if len(groups[index].Contacts) < 2 {
+			continue
+		}
+
+		groups[index].Score = scores[root]
+		for reason := range reasons[root] {
+			groups[index].Reasons = append(groups[index].Reasons, reason)
+		}
+		duplicateGroups = append(duplicateGroups, groups[index])
+	}
+
+	return duplicateGroups
+}
+
+// Keeps the primary value unless it is blank
+func mergeContactField(primary *string, duplicate string) {
+	if *primary == "" && duplicate != "" {
+		*primary = duplicate
+	}
+}
+
+func mergeInt64s(primary []int64, duplicate []int64) []int64 {
+	exists := map[int64]bool{}
+	for i := 0; i < len(primary); i++ {
+		exists[primary[i]] = true
+	}
+
+	for i := 0; i < len(duplicate); i++ {
+		if _, ok := exists[duplicate[i]]; !ok {
+			primary = append(primary, duplicate[i])
+			exists[duplicate[i]] = true
+		}
+	}
+	return primary
+}
+
+func mergeContacts(primary *models.Contact, duplicates []models.Contact) {
+	tags := map[string]bool{}
+	for i := 0; i < len(primary.Tags); i++ {
+		tags[primary.Tags[i]] = true
+	}
+
+	customFields := map[string]bool{}
+	for i := 0; i < len(primary.CustomFields); i++ {
+		customFields[primary.CustomFields[i].Name] = true
+	}
+
+	for i := 0; i < len(duplicates); i++ {
+		mergeContactField(&primary.FirstName, duplicates[i].FirstName)
+		mergeContactField(&primary.LastName, duplicates[i].LastName)
+		mergeContactField(&primary.Email, duplicates[i].Email)
+		mergeContactField(&primary.Notes, duplicates[i].Notes)
+		mergeContactField(&primary.LinkedIn, duplicates[i].LinkedIn)
+		mergeContactField(&primary.Twitter, duplicates[i].Twitter)
+		mergeContactField(&primary.Instagram, duplicates[i].Instagram)
+		mergeContactField(&primary.Website, duplicates[i].Website)
+		mergeContactField(&primary.Blog, duplicates[i].Blog)
+		mergeContactField(&primary.Location, duplicates[i].Location)
+		mergeContactField(&primary.PhoneNumber, duplicates[i].PhoneNumber)
+		mergeContactField(&primary.ImageURL, duplicates[i].ImageURL)
+
+		primary.Employers = mergeInt64s(primary.Employers, duplicates[i].Employers)
+		primary.PastEmployers = mergeInt64s(primary.PastEmployers, duplicates[i].PastEmployers)
+
+		for x := 0; x < len(duplicates[i].Tags); x++ {
+			if _, ok := tags[duplicates[i].Tags[x]]; !ok {
+				primary.Tags = append(primary.Tags, duplicates[i].Tags[x])
+				tags[duplicates[i].Tags[x]] = true
+			}
+		}
+
+		for x := 0; x < len(duplicates[i].CustomFields); x++ {
+			if _, ok := customFields[duplicates[i].CustomFields[x].Name]; !ok {
+				primary.CustomFields = append(primary.CustomFields, duplicates[i].CustomFields[x])
+				customFields[duplicates[i].CustomFields[x].Name] = true
+			}
+		}
+	}
+}
+
+/*
+* Public methods
+ */
+
+/*
+* Get methods
+ */
+
+// Finds groups of contacts across all of the user's lists that look like
+// the same person
+func GetDuplicateContacts(c context.Context, r *http.Request) ([]DuplicateContacts, interface{}, int, int, error) {
+	user, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []DuplicateContacts{}, nil, 0, 0, err
+	}
+
+	ks, err := datastore.NewQuery("Contact").Filter("CreatedBy =", user.Id).Filter("IsMasterContact =", false).Filter("IsDeleted =", false).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []DuplicateContacts{}, nil, 0, 0, err
+	}
+
+	contacts := make([]models.Contact, len(ks))
+	err = nds.GetMulti(c, ks, contacts)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []DuplicateContacts{}, nil, 0, 0, err
+	}
+
+	for i := 0; i < len(contacts); i++ {
+		contacts[i].Format(ks[i], "contacts")
+	}
+
+	groups := duplicateContactGroups(contacts)
+	return groups, nil, len(groups), 0, nil
+}
+
+/*
+* Update methods
+ */
+
+// Merges contacts into a primary contact. The primary keeps its own values
+// and takes any that it is missing from the others. Emails, feeds and media
+// lists that pointed at the merged contacts are moved over to the primary.
+func MergeContacts(c context.Context, r *http.Request) (models.Contact, interface{}, error) {
+	buf, _ := ioutil.ReadAll(r.Body)
+	decoder := ffjson.NewDecoder()
+	var mergeDetails mergeContactsDetails
+	err := decoder.Decode(buf, &mergeDetails)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Contact{}, nil, err
+	}
+
+	primary, err := getContact(c, r, mergeDetails.Primary)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Contact{}, nil, err
+	}
+
+	// The primary takes the values of the others, so the user has to be able
+	// to edit it
+	err = requireContactRole(c, r, primary, models.ListRoleEditor)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Contact{}, nil, err
+	}
+
+	duplicates := []models.Contact{}
+	for i := 0; i < len(mergeDetails.Contacts); i++ {
+		if mergeDetails.Contacts[i] == primary.Id {
+			continue
+		}
+
+		contact, err := getContact(c, r, mergeDetails.Contacts[i])
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return models.Contact{}, nil, err
+		}
+
+		// Only contacts the user can edit can be merged away
+		err = requireContactRole(c, r, contact, models.ListRoleEditor)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return models.Contact{}, nil, err
+		}
+
+		duplicates = append(duplicates, contact)
+	}
+
+	if len(duplicates) == 0 {
+		return models.Contact{}, nil, errors.New("No contacts to merge")
+	}
+
+	if len(duplicates) > maxMergeContacts {
+		return models.Contact{}, nil, errors.New("Only " + strconv.Itoa(maxMergeContacts) + " contacts can be merged at a time")
+	}
+
+	// Most recently updated contacts win when the primary is missing a field
+	for i := 1; i < len(duplicates); i++ {
+		for x := i; x > 0 && duplicates[x].Updated.After(duplicates[x-1].Updated); x-- {
+			duplicates[x], duplicates[x-1] = duplicates[x-1], duplicates[x]
+		}
+	}
+
+	previousPrimary := primary
+	previousPrimary.Tags = append([]string{}, primary.Tags...)
+	previousPrimary.CustomFields = append([]models.CustomContactField{}, primary.CustomFields...)
+
+	mergeContacts(&primary, duplicates)
+
+	keys := []*datastore.Key{primary.Key(c)}
+	mergedContacts := []models.Contact{primary}
+	duplicateIds := map[int64]bool{}
+	for i := 0; i < len(duplicates); i++ {
+		duplicates[i].IsDeleted = true
+		duplicates[i].Deleted = time.Now()
+		duplicates[i].Updated = time.Now()
+		keys = append(keys, duplicates[i].Key(c))
+		mergedContacts = append(mergedContacts, duplicates[i])
+		duplicateIds[duplicates[i].Id] = true
+	}
+
+	primary.Updated = time.Now()
+	mergedContacts[0] = primary
+	err = saveAndSyncEvents(c, func(ctx context.Context) ([]sync.OutboxEvent, error) {
+		_, err := nds.PutMulti(ctx, keys, mergedContacts)
+		if err != nil {
+			return []sync.OutboxEvent{}, err
+		}
+
+		primaryEvent, err := sync.QueueResourceSync(ctx, primary.Id, "Contact", sync.ActionUpdate)
+		if err != nil {
+			return []sync.OutboxEvent{}, err
+		}
+
+		deletedIds := []int64{}
+		for i := 0; i < len(duplicates); i++ {
+			deletedIds = append(deletedIds, duplicates[i].Id)
+		}
+		duplicatesEvent, err := sync.QueueResourceBulkSync(ctx, deletedIds, "Contact", sync.ActionDelete)
+		return []sync.OutboxEvent{primaryEvent, duplicatesEvent}, err
+	})
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Contact{}, nil, err
+	}
+
+	updatedLists := map[int64]bool{}
+	for i := 0; i < len(duplicates); i++ {
+		// Move emails over to the primary contact
+		emailKeys, err := datastore.NewQuery("Email").Filter("ContactId =", duplicates[i].Id).KeysOnly().GetAll(c, nil)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		} else {
+			emails := make([]models.Email, len(emailKeys))
+			err = nds.GetMulti(c, emailKeys, emails)
+			if err != nil {
+				log.Errorf(c, "%v", err)
+			}
+
+			emailIds := []int64{}
+			for x := 0; x < len(emails); x++ {
+				emails[x].Format(emailKeys[x], "emails")
+				emails[x].ContactId = primary.Id
+				_, err = emails[x].Save(c)
+				if err != nil {
+					log.Errorf(c, "%v", err)
+					continue
+				}
+				emailIds = append(emailIds, emails[x].Id)
+			}
+
+			err = sync.EmailResourceBulkSync(r, emailIds, sync.ActionUpdate)
+			if err != nil {
+				log.Errorf(c, "%v", err)
+			}
+		}
+
+		// Move feeds over to the primary contact
+		feeds, err := GetFeedsByResourceId(c, r, "ContactId", duplicates[i].Id)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
+
+		for x := 0; x < len(feeds); x++ {
+			feeds[x].ContactId = primary.Id
+			_, err = feeds[x].Save(c)
+			if err != nil {
+				log.Errorf(c, "%v", err)
+			}
+		}
+
+		// Replace the contact in every media list it is a part of
+		listKeys, err := datastore.NewQuery("MediaList").Filter("Contacts =", duplicates[i].Id).KeysOnly().GetAll(c, nil)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			continue
+		}
+
+		for x := 0; x < len(listKeys); x++ {
+			updatedLists[listKeys[x].IntID()] = true
+		}
+	}
+
+	for listId := range updatedLists {
+		var mediaList models.MediaList
+		mediaListKey := datastore.NewKey(c, "MediaList", "", listId, nil)
+		err = nds.Get(c, mediaListKey, &mediaList)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			continue
+		}
+		mediaList.Format(mediaListKey, "lists")
+
+		contactIds := []int64{}
+		contactExists := map[int64]bool{}
+		for x := 0; x < len(mediaList.Contacts); x++ {
+			contactId := mediaList.Contacts[x]
+			if _, ok := duplicateIds[contactId]; ok {
+				contactId = primary.Id
+			}
+
+			if _, ok := contactExists[contactId]; !ok {
+				contactIds = append(contactIds, contactId)
+				contactExists[contactId] = true
+			}
+		}
+
+		mediaList.Contacts = contactIds
+		err = saveMediaListAndSync(c, &mediaList, "", mediaList.Contacts)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
+	}
+
+	err = RecordContactRevisions(c, r, previousPrimary, primary, models.RevisionSourceMerge)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+	}
+
+	TriggerWebhooks(c, primary.CreatedBy, primary.TeamId, models.WebhookContactUpdated, primary)
+
+	return primary, nil, nil
+}
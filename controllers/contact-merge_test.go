@@ -0,0 +1,118 @@
+package controllers
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/news-ai/tabulae/models"
+)
+
+func TestDuplicateContactGroups(t *testing.T) {
+	contact := func(id int64, edit func(contact *models.Contact)) models.Contact {
+		contact := models.Contact{}
+		contact.Id = id
+		edit(&contact)
+		return contact
+	}
+
+	tests := []struct {
+		name     string
+		contacts []models.Contact
+		want     []DuplicateContacts
+	}{
+		{
+			name: "no duplicates",
+			contacts: []models.Contact{
+				contact(1, func(contact *models.Contact) { contact.Email = "jane@example.com" }),
+				contact(2, func(contact *models.Contact) { contact.Email = "john@example.com" }),
+			},
+			want: []DuplicateContacts{},
+		},
+		{
+			name: "same email",
+			contacts: []models.Contact{
+				contact(1, func(contact *models.Contact) { contact.Email = "jane@example.com" }),
+				contact(2, func(contact *models.Contact) { contact.Email = " Jane@Example.com " }),
+			},
+			want: []DuplicateContacts{{Contacts: []int64{1, 2}, Score: duplicateEmailScore, Reasons: []string{"email"}}},
+		},
+		{
+			name: "joined through a social handle and a name at an employer",
+			contacts: []models.Contact{
+				contact(1, func(contact *models.Contact) { contact.Twitter = "jane" }),
+				contact(2, func(contact *models.Contact) {
+					contact.Twitter = "jane"
+					contact.FirstName = "Jane"
+					contact.LastName = "Doe"
+					contact.Employers = []int64{5}
+				}),
+				contact(3, func(contact *models.Contact) {
+					contact.FirstName = "jane"
+					contact.LastName = " doe"
+					contact.Employers = []int64{6, 5}
+				}),
+				contact(4, func(contact *models.Contact) {
+					contact.FirstName = "Jane"
+					contact.LastName = "Doe"
+					contact.Employers = []int64{7}
+				}),
+			},
+			want: []DuplicateContacts{{Contacts: []int64{1, 2, 3}, Score: duplicateSocialScore, Reasons: []string{"name", "twitter"}}},
+		},
+	}
+
+	for _, test := range tests {
+		// Groups and their reasons come back in no particular order
+		groups := map[int64]DuplicateContacts{}
+		for _, group := range duplicateContactGroups(test.contacts) {
+			sort.Strings(group.Reasons)
+			groups[group.Contacts[0]] = group
+		}
+		want := map[int64]DuplicateContacts{}
+		for i := 0; i < len(test.want); i++ {
+			want[test.want[i].Contacts[0]] = test.want[i]
+		}
+		if !reflect.DeepEqual(groups, want) {
+			t.Errorf("%v: grouped %+v, want %+v", test.name, groups, want)
+		}
+	}
+}
+
+func TestMergeContacts(t *testing.T) {
+	primary := models.Contact{
+		FirstName:    "Jane",
+		Employers:    []int64{1},
+		Tags:         []string{"tech"},
+		CustomFields: []models.CustomContactField{{Name: "beat", Value: "Tech"}},
+	}
+	duplicates := []models.Contact{
+		{
+			FirstName:    "Janet",
+			LastName:     "Doe",
+			Employers:    []int64{2, 1},
+			Tags:         []string{"tech", "food"},
+			CustomFields: []models.CustomContactField{{Name: "beat", Value: "Food"}, {Name: "region", Value: "East"}},
+		},
+		{
+			LastName:      "Smith",
+			Twitter:       "jane",
+			PastEmployers: []int64{3},
+		},
+	}
+
+	mergeContacts(&primary, duplicates)
+
+	want := models.Contact{
+		FirstName:     "Jane",
+		LastName:      "Doe",
+		Twitter:       "jane",
+		Employers:     []int64{1, 2},
+		PastEmployers: []int64{3},
+		Tags:          []string{"tech", "food"},
+		CustomFields:  []models.CustomContactField{{Name: "beat", Value: "Tech"}, {Name: "region", Value: "East"}},
+	}
+	if !reflect.DeepEqual(primary, want) {
+		t.Errorf("merged into %+v, want %+v", primary, want)
+	}
+}
@@ -0,0 +1,255 @@
+package controllers
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+
+	"github.com/pquerna/ffjson/ffjson"
+	"github.com/qedus/nds"
+
+	"github.com/news-ai/api/controllers"
+
+	"github.com/news-ai/tabulae/models"
+
+	"github.com/news-ai/web/utilities"
+)
+
+/*
+* Private
+ */
+
+type revertContactDetails struct {
+	RevisionId int64 `json:"revision"`
+}
+
+/*
+* Private methods
+ */
+
+func getContactRevision(c context.Context, id int64) (models.ContactRevision, error) {
+	if id == 0 {
+		return models.ContactRevision{}, errors.New("datastore: no such entity")
+	}
+
+	var contactRevision models.ContactRevision
+	contactRevisionId := datastore.NewKey(c, "ContactRevision", "", id, nil)
+	err := nds.Get(c, contactRevisionId, &contactRevision)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.ContactRevision{}, err
+	}
+
+	if !contactRevision.Created.IsZero() {
+		contactRevision.Format(contactRevisionId, "contactrevisions")
+		return contactRevision, nil
+	}
+
+	return models.ContactRevision{}, errors.New("No contact revision by this id")
+}
+
+// Builds one revision for every tracked field that differs between the two
+// versions of a contact
+func contactRevisions(previousContact models.Contact, contact models.Contact, source string, userId int64) []models.ContactRevision {
+	previousValues := previousContact.RevisionValues()
+	values := contact.RevisionValues()
+
+	fields := []string{}
+	for field := range values {
+		fields = append(fields, field)
+	}
+	for field := range previousValues {
+		if _, ok := values[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	revisions := []models.ContactRevision{}
+	for i := 0; i < len(fields); i++ {
+		if previousValues[fields[i]] == values[fields[i]] {
+			continue
+		}
+
+		revision := models.ContactRevision{
+			ContactId: contact.Id,
+			Field:     fields[i],
+			OldValue:  previousValues[fields[i]],
+			NewValue:  values[fields[i]],
+			Source:    source,
+		}
+		revision.CreatedBy = userId
+		revision.Created = time.Now()
+		revision.Updated = time.Now()
+		revisions = append(revisions, revision)
+	}
+
+	return revisions
+}
+
+func saveContactRevisions(c context.Context, revisions []models.ContactRevision) error {
+	if len(revisions) == 0 {
+		return nil
+	}
+
+	keys := []*datastore.Key{}
+	for i := 0; i < len(revisions); i++ {
+		keys = append(keys, revisions[i].Key(c))
+	}
+
+	_, err := putMultiInBatches(c, keys, revisions)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	return nil
+}
+
+/*
+* Public methods
+ */
+
+/*
+* Get methods
+ */
+
+func GetContactHistory(c context.Context, r *http.Request, id string) ([]models.ContactRevision, interface{}, int, int, error) {
+	currentId, err := utilities.StringIdToInt(id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.ContactRevision{}, nil, 0, 0, err
+	}
+
+	// To check if the user can access it
+	_, err = getContact(c, r, currentId)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.ContactRevision{}, nil, 0, 0, err
+	}
+
+	query := datastore.NewQuery("ContactRevision").Filter("ContactId =", currentId).Order("-Created")
+	query = controllers.ConstructQuery(query, r)
+	ks, err := query.KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.ContactRevision{}, nil, 0, 0, err
+	}
+
+	contactRevisions := make([]models.ContactRevision, len(ks))
+	err = nds.GetMulti(c, ks, contactRevisions)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.ContactRevision{}, nil, 0, 0, err
+	}
+
+	for i := 0; i < len(contactRevisions); i++ {
+		contactRevisions[i].Format(ks[i], "contactrevisions")
+	}
+
+	return contactRevisions, nil, len(contactRevisions), 0, nil
+}
+
+/*
+* Create methods
+ */
+
+// Records what changed on a contact. Changes made without a logged in user
+// (background tasks) are saved without a CreatedBy.
+func RecordContactRevisions(c context.Context, r *http.Request, previousContact models.Contact, contact models.Contact, source string) error {
+	userId := int64(0)
+	user, err := controllers.GetCurrentUser(c, r)
+	if err == nil {
+		userId = user.Id
+	}
+
+	revisions := contactRevisions(previousContact, contact, source, userId)
+	return saveContactRevisions(c, revisions)
+}
+
+/*
+* Update methods
+ */
+
+// Reverts the field of a single revision back to its old value
+func RevertContactField(c context.Context, r *http.Request, id string) (models.Contact, interface{}, error) {
+	currentId, err := utilities.StringIdToInt(id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Contact{}, nil, err
+	}
+
+	buf, _ := ioutil.ReadAll(r.Body)
+	decoder := ffjson.NewDecoder()
+	var revertDetails revertContactDetails
+	err = decoder.Decode(buf, &revertDetails)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Contact{}, nil, err
+	}
+
+	contact, err := getContact(c, r, currentId)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Contact{}, nil, err
+	}
+
+	if contact.ReadOnly {
+		return models.Contact{}, nil, errors.New("You don't have permissions to edit these objects")
+	}
+
+	// Access to master contacts is checked when getting them
+	err = requireContactRole(c, r, contact, models.ListRoleEditor)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Contact{}, nil, err
+	}
+
+	contactRevision, err := getContactRevision(c, revertDetails.RevisionId)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Contact{}, nil, err
+	}
+
+	if contactRevision.ContactId != contact.Id {
+		return models.Contact{}, nil, errors.New("Revision does not belong to this contact")
+	}
+
+	previousContact := contact
+	previousContact.CustomFields = append([]models.CustomContactField{}, contact.CustomFields...)
+
+	err = contact.SetRevisionValue(contactRevision.Field, contactRevision.OldValue)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Contact{}, nil, err
+	}
+
+	_, err = Save(c, r, &contact)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Contact{}, nil, err
+	}
+
+	err = RecordContactRevisions(c, r, previousContact, contact, models.RevisionSourceRevert)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+	}
+
+	TriggerWebhooks(c, contact.CreatedBy, contact.TeamId, models.WebhookContactUpdated, contact)
+
+	if contact.IsMasterContact {
+		err = propagateMasterContact(c, r, contact)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
+	}
+
+	return contact, nil, nil
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/news-ai/tabulae/models"
+)
+
+func TestContactRevisions(t *testing.T) {
+	previousContact := models.Contact{
+		FirstName:    "Jane",
+		Employers:    []int64{1},
+		Tags:         []string{"tech"},
+		CustomFields: []models.CustomContactField{{Name: "beat", Value: "Tech"}},
+	}
+
+	tests := []struct {
+		name    string
+		contact models.Contact
+		want    map[string][2]string
+	}{
+		{
+			name:    "nothing changed",
+			contact: previousContact,
+			want:    map[string][2]string{},
+		},
+		{
+			name: "fields changed",
+			contact: models.Contact{
+				FirstName:    "Janet",
+				Employers:    []int64{1, 2},
+				Tags:         []string{"tech", "food, drink"},
+				CustomFields: []models.CustomContactField{{Name: "beat", Value: "Food"}},
+			},
+			want: map[string][2]string{
+				"firstname":         {"Jane", "Janet"},
+				"employers":         {"1", "1,2"},
+				"tags":              {`["tech"]`, `["tech","food, drink"]`},
+				"customfields.beat": {"Tech", "Food"},
+			},
+		},
+		{
+			name:    "custom field removed",
+			contact: models.Contact{FirstName: "Jane", Employers: []int64{1}, Tags: []string{"tech"}},
+			want: map[string][2]string{
+				"customfields.beat": {"Tech", ""},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		revisions := contactRevisions(previousContact, test.contact, models.RevisionSourceUser, 1)
+		changes := map[string][2]string{}
+		for i := 0; i < len(revisions); i++ {
+			changes[revisions[i].Field] = [2]string{revisions[i].OldValue, revisions[i].NewValue}
+		}
+		if !reflect.DeepEqual(changes, test.want) {
+			t.Errorf("%v: revisions are %v, want %v", test.name, changes, test.want)
+		}
+	}
+}
+
+func TestSetRevisionValue(t *testing.T) {
+	contact := models.Contact{
+		Employers:    []int64{1, 2},
+		Tags:         []string{"tech", "food, drink"},
+		CustomFields: []models.CustomContactField{{Name: "beat", Value: "Tech"}},
+	}
+	values := contact.RevisionValues()
+
+	revertedContact := models.Contact{}
+	for field, value := range values {
+		err := revertedContact.SetRevisionValue(field, value)
+		if err != nil {
+			t.Fatalf("%v: %v", field, err)
+		}
+	}
+
+	if !reflect.DeepEqual(revertedContact.RevisionValues(), values) {
+		t.Errorf("reverted to %v, want %v", revertedContact.RevisionValues(), values)
+	}
+	if !reflect.DeepEqual(revertedContact.Tags, contact.Tags) {
+		t.Errorf("tags reverted to %v, want %v", revertedContact.Tags, contact.Tags)
+	}
+
+	err := revertedContact.SetRevisionValue("tags", "tech,food")
+	if err == nil {
+		t.Errorf("tags that aren't JSON were reverted to %v", revertedContact.Tags)
+	}
+}
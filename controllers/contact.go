@@ -10,6 +10,7 @@ import (
 
 	"golang.org/x/net/context"
 
+	"google.golang.org/appengine"
 	"google.golang.org/appengine/datastore"
 	"google.golang.org/appengine/log"
 	// "google.golang.org/appengine/memcache"
@@ -74,29 +75,29 @@ func getContact(c context.Context, r *http.Request, id int64) (models.Contact, e
 			return models.Contact{}, err
 		}
 
-		contactList, err := getMediaList(c, r, contact.ListId)
-		if err != nil {
-			err = errors.New("Forbidden")
-			log.Errorf(c, "%v", err)
-			return models.Contact{}, err
-		}
-
-		// If it is a public list
-		if contact.ListId != 0 && contactList.PublicList {
-			// You don't own it and you are not an admin
-			if contactList.PublicList && !permissions.AccessToObject(contact.CreatedBy, user.Id) && !user.IsAdmin {
-				contact.ReadOnly = true
+		// Master contacts don't belong to a list
+		if contact.IsMasterContact {
+			if !permissions.AccessToObject(contact.CreatedBy, user.Id) && (contact.TeamId == 0 || contact.TeamId != user.TeamId) && !user.IsAdmin {
+				err = errors.New("Forbidden")
+				log.Errorf(c, "%v", err)
+				return models.Contact{}, err
 			}
 			return contact, nil
 		}
 
-		// This runs if it is not a public list
-		if contactList.TeamId != user.TeamId && !contact.IsMasterContact && !permissions.AccessToObject(contact.CreatedBy, user.Id) && !user.IsAdmin {
+		contactList, err := getMediaList(c, r, contact.ListId)
+		if err != nil {
 			err = errors.New("Forbidden")
 			log.Errorf(c, "%v", err)
 			return models.Contact{}, err
 		}
 
+		// Viewers of the list, including anyone looking at a public list,
+		// can only read its contacts
+		if !models.ListRoleAtLeast(contactList.Role, models.ListRoleEditor) {
+			contact.ReadOnly = true
+		}
+
 		return contact, nil
 	}
 	return models.Contact{}, errors.New("No contact by this id")
@@ -116,26 +117,58 @@ func createContact(c context.Context, r *http.Request, ct *models.Contact) (*mod
 	ct.FormatName()
 	ct.Normalize()
 
+	// Contacts can only be added to lists the user can edit, and their custom
+	// field values have to fit the types of the list's fields
+	if ct.ListId != 0 {
+		mediaList, err := getMediaList(c, r, ct.ListId)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return ct, err
+		}
+
+		if !models.ListRoleAtLeast(mediaList.Role, models.ListRoleEditor) {
+			return ct, errors.New("You don't have permissions to edit these objects")
+		}
+
+		if mediaList.IsSmartList {
+			return ct, errors.New("Contacts can't be added to a smart list")
+		}
+
+		err = validateContactCustomFields(mediaList, *ct)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return ct, err
+		}
+	}
+
 	_, err = enrichContact(c, r, ct)
 	if err != nil {
 		log.Errorf(c, "%v", err)
 	}
 
-	ct.Create(c, r, currentUser)
-	_, err = Save(c, r, ct)
+	err = linkContactToMaster(c, r, currentUser, ct)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+	}
 
-	// Sync with ES
-	sync.ResourceSync(r, ct.Id, "Contact", "create")
+	ct.Normalize()
+	addEmployerFromEmail(c, r, ct)
 
-	// If user is just created
-	if ct.Twitter != "" {
-		sync.TwitterSync(r, ct.Twitter)
-	}
-	if ct.Instagram != "" {
-		sync.InstagramSync(r, ct.Instagram, currentUser.InstagramAuthKey)
+	err = saveAndSync(c, "Contact", sync.ActionCreate, func(ctx context.Context) (int64, error) {
+		_, err := ct.Create(ctx, r, currentUser)
+		return ct.Id, err
+	})
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return ct, err
 	}
 
-	return ct, err
+	TriggerWebhooks(c, ct.CreatedBy, ct.TeamId, models.WebhookContactCreated, *ct)
+
+	// If user is just created
+	syncContactSocialFeeds(c, r, *ct, currentUser)
+
+	return ct, nil
 }
 
 /*
@@ -149,27 +182,72 @@ func updateContact(c context.Context, r *http.Request, contact *models.Contact,
 		return *contact, nil, err
 	}
 
+	// Moving a contact to another list needs the editor role on that list
+	listId := contact.ListId
+	if updatedContact.ListId != 0 && updatedContact.ListId != contact.ListId {
+		mediaList, err := getMediaList(c, r, updatedContact.ListId)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return *contact, nil, err
+		}
+
+		err = requireMediaListRole(mediaList, models.ListRoleEditor)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return *contact, nil, err
+		}
+
+		if mediaList.IsSmartList {
+			return *contact, nil, errors.New("Contacts can't be added to a smart list")
+		}
+		listId = mediaList.Id
+	}
+
+	// Custom field values have to fit the types of the list's fields
+	if len(updatedContact.CustomFields) > 0 && listId != 0 {
+		mediaList, err := getMediaList(c, r, listId)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return *contact, nil, err
+		}
+
+		err = validateContactCustomFields(mediaList, updatedContact)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return *contact, nil, err
+		}
+	}
+
 	// Check if the old Twitter is changed to a new one
 	// If both of them are not empty but also not the same
 	if contact.Twitter != "" && updatedContact.Twitter != "" && contact.Twitter != updatedContact.Twitter {
 		updatedContact.Normalize()
 		contact.TwitterPrivate = false
 		contact.TwitterInvalid = false
-		sync.TwitterSync(r, updatedContact.Twitter)
+		err = sync.TwitterSync(r, updatedContact.Twitter)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
 	}
 
 	// If you are changing Instagram usernames
 	if contact.Instagram != "" && updatedContact.Instagram != "" && contact.Instagram != updatedContact.Instagram {
 		contact.InstagramPrivate = false
 		contact.InstagramInvalid = false
-		sync.InstagramSync(r, updatedContact.Instagram, currentUser.InstagramAuthKey)
+		err = sync.InstagramSync(r, updatedContact.Instagram, currentUser.InstagramAuthKey)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
 	}
 
 	if contact.Twitter == "" && updatedContact.Twitter != "" {
 		updatedContact.Normalize()
 		contact.TwitterPrivate = false
 		contact.TwitterInvalid = false
-		sync.TwitterSync(r, updatedContact.Twitter)
+		err = sync.TwitterSync(r, updatedContact.Twitter)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
 	}
 
 	// If they add a new Instagram
@@ -177,10 +255,14 @@ func updateContact(c context.Context, r *http.Request, contact *models.Contact,
 		updatedContact.Normalize()
 		contact.InstagramPrivate = false
 		contact.InstagramInvalid = false
-		sync.InstagramSync(r, updatedContact.Instagram, currentUser.InstagramAuthKey)
+		err = sync.InstagramSync(r, updatedContact.Instagram, currentUser.InstagramAuthKey)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
 	}
 
 	previousEmail := contact.Email
+	previousContact := *contact
 
 	utilities.UpdateIfNotBlank(&contact.FirstName, updatedContact.FirstName)
 	utilities.UpdateIfNotBlank(&contact.LastName, updatedContact.LastName)
@@ -201,9 +283,7 @@ func updateContact(c context.Context, r *http.Request, contact *models.Contact,
 		}
 	}
 
-	if updatedContact.ListId != 0 {
-		contact.ListId = updatedContact.ListId
-	}
+	contact.ListId = listId
 
 	if len(updatedContact.CustomFields) > 0 {
 		contact.CustomFields = updatedContact.CustomFields
@@ -236,12 +316,31 @@ func updateContact(c context.Context, r *http.Request, contact *models.Contact,
 		contact.Tags = updatedContact.Tags
 	}
 
+	// Identity fields changed on a child are kept as overrides for its list
+	if contact.ParentContact != 0 && !contact.IsMasterContact {
+		addContactOverrides(contact, previousContact)
+	}
+
 	_, err = Save(c, r, contact)
 	if err != nil {
 		log.Errorf(c, "%v", err)
 		return models.Contact{}, nil, err
 	}
 
+	err = RecordContactRevisions(c, r, previousContact, *contact, models.RevisionSourceUser)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+	}
+
+	TriggerWebhooks(c, contact.CreatedBy, contact.TeamId, models.WebhookContactUpdated, *contact)
+
+	if contact.IsMasterContact {
+		err = propagateMasterContact(c, r, *contact)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
+	}
+
 	// When editing a contact on the list view we need the timeseries data in it
 	if contact.ListId == 0 {
 		return *contact, nil, nil
@@ -304,19 +403,8 @@ func filterContact(c context.Context, r *http.Request, queryType, query string)
 	}
 
 	if len(contacts) > 0 {
-		user, err := controllers.GetCurrentUser(c, r)
-		if err != nil {
-			log.Errorf(c, "%v", err)
-			return models.Contact{}, err
-		}
-
-		mediaList, err := getMediaList(c, r, contacts[0].ListId)
+		err = requireContactRole(c, r, contacts[0], models.ListRoleViewer)
 		if err != nil {
-			log.Errorf(c, "%v", err)
-			return models.Contact{}, err
-		}
-
-		if !contacts[0].IsMasterContact && mediaList.TeamId != user.TeamId && !permissions.AccessToObject(contacts[0].CreatedBy, user.Id) {
 			err = errors.New("Forbidden")
 			log.Errorf(c, "%v", err)
 			return models.Contact{}, err
@@ -545,6 +633,23 @@ func getIncludesForContacts(c context.Context, r *http.Request, contacts []model
 * Action methods
  */
 
+// Starts following the social feeds of a contact. Nothing is saved with
+// them, so failures are only logged.
+func syncContactSocialFeeds(c context.Context, r *http.Request, contact models.Contact, user apiModels.User) {
+	if contact.Twitter != "" {
+		err := sync.TwitterSync(r, contact.Twitter)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
+	}
+	if contact.Instagram != "" {
+		err := sync.InstagramSync(r, contact.Instagram, user.InstagramAuthKey)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
+	}
+}
+
 func enrichContact(c context.Context, r *http.Request, contact *models.Contact) (interface{}, error) {
 	if contact.Email == "" {
 		return nil, errors.New("Contact does not have an email")
@@ -589,14 +694,20 @@ func enrichContact(c context.Context, r *http.Request, contact *models.Contact)
 				if contactDetail.Data.SocialProfiles[i].TypeID == "twitter" {
 					if contact.Twitter == "" {
 						contact.Twitter = contactDetail.Data.SocialProfiles[i].Username
-						sync.TwitterSync(r, contact.Twitter)
+						err = sync.TwitterSync(r, contact.Twitter)
+						if err != nil {
+							log.Errorf(c, "%v", err)
+						}
 					}
 				}
 
 				if contactDetail.Data.SocialProfiles[i].TypeID == "instagram" {
 					if contact.Instagram == "" {
 						contact.Instagram = contactDetail.Data.SocialProfiles[i].URL
-						sync.InstagramSync(r, contact.Instagram, currentUser.InstagramAuthKey)
+						err = sync.InstagramSync(r, contact.Instagram, currentUser.InstagramAuthKey)
+						if err != nil {
+							log.Errorf(c, "%v", err)
+						}
 					}
 				}
 			}
@@ -779,6 +890,7 @@ func GetContacts(c context.Context, r *http.Request) ([]models.Contact, interfac
 	return []models.Contact{}, nil, 0, 0, nil
 }
 
+// Contacts that no longer exist, like ones purged from the trash, are left out
 func GetContactsByIds(c context.Context, r *http.Request, ids []int64) ([]models.Contact, error) {
 	var ks []*datastore.Key
 
@@ -790,16 +902,26 @@ func GetContactsByIds(c context.Context, r *http.Request, ids []int64) ([]models
 	var contacts []models.Contact
 	contacts = make([]models.Contact, len(ks))
 	err := nds.GetMulti(c, ks, contacts)
-	if err != nil {
+	multiErr, isMultiErr := err.(appengine.MultiError)
+	if err != nil && !isMultiErr {
 		log.Errorf(c, "%v", err)
 		return []models.Contact{}, err
 	}
 
+	existingContacts := []models.Contact{}
 	for i := 0; i < len(contacts); i++ {
+		if isMultiErr && multiErr[i] != nil {
+			if multiErr[i] != datastore.ErrNoSuchEntity {
+				log.Errorf(c, "%v", multiErr[i])
+				return []models.Contact{}, multiErr[i]
+			}
+			continue
+		}
 		contacts[i].Format(ks[i], "contacts")
+		existingContacts = append(existingContacts, contacts[i])
 	}
 
-	return contacts, nil
+	return existingContacts, nil
 }
 
 func GetContact(c context.Context, r *http.Request, id string) (models.Contact, interface{}, error) {
@@ -820,6 +942,15 @@ func GetContact(c context.Context, r *http.Request, id string) (models.Contact,
 	return contact, includes, nil
 }
 
+func GetContactById(c context.Context, r *http.Request, id int64) (models.Contact, error) {
+	contact, err := getContact(c, r, id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Contact{}, err
+	}
+	return contact, nil
+}
+
 func ContactsToDefaultFields(c context.Context, r *http.Request, contacts []models.Contact, mediaList models.MediaList) ([]models.Contact, error) {
 	instagramUsers := []string{}
 	twitterUsers := []string{}
@@ -843,6 +974,8 @@ func ContactsToDefaultFields(c context.Context, r *http.Request, contacts []mode
 	readOnlyPresent := []string{}
 	instagramTimeseries := []apiSearch.InstagramTimeseries{}
 	twitterTimeseries := []apiSearch.TwitterTimeseries{}
+	engagements := map[int64]models.ContactEngagement{}
+	engagementsLoaded := false
 
 	// Check if there are special fields we need to get data for
 	for i := 0; i < len(mediaList.FieldsMap); i++ {
@@ -858,6 +991,14 @@ func ContactsToDefaultFields(c context.Context, r *http.Request, contacts []mode
 					twitterTimeseries, _ = apiSearch.SearchTwitterTimeseriesByUsernames(c, r, twitterUsers)
 				}
 			}
+			if _, ok := models.EngagementFields[mediaList.FieldsMap[i].Value]; (ok || mediaList.FieldsMap[i].Value == "lastcontacted") && !engagementsLoaded {
+				contactIds := []int64{}
+				for x := 0; x < len(contacts); x++ {
+					contactIds = append(contactIds, contacts[x].Id)
+				}
+				engagements, _ = getContactEngagements(c, contactIds)
+				engagementsLoaded = true
+			}
 		}
 	}
 
@@ -923,6 +1064,14 @@ func ContactsToDefaultFields(c context.Context, r *http.Request, contacts []mode
 					}
 				}
 
+				// The last time a contact was emailed is kept with their
+				// engagement
+				if _, ok := models.EngagementFields[customField.Name]; ok || customField.Name == "lastcontacted" {
+					if engagement, ok := engagements[contacts[i].Id]; ok {
+						customField.Value = engagement.FieldValue(customField.Name)
+					}
+				}
+
 				if customField.Name == "latestheadline" {
 					// Get the feed of the contact
 					headlines, _, _, _, err := GetHeadlinesForContactById(c, r, contacts[i].Id)
@@ -933,62 +1082,6 @@ func ContactsToDefaultFields(c context.Context, r *http.Request, contacts []mode
 					}
 				}
 
-				if customField.Name == "lastcontacted" && contacts[i].Email != "" {
-					// lastCreatedMemcacheKey := "lastcontacted" + strconv.FormatInt(user.Id, 10) + contacts[i].Email
-					// item, err := memcache.Get(c, lastCreatedMemcacheKey)
-					// if err != nil {
-					emails, _, _, err := GetOrderedEmailsForContact(c, r, contacts[i])
-
-					// Set the value of the post name to the user
-					if err == nil && len(emails) > 0 {
-						lastUnarchivedEmail := -1
-						for y := 0; y < len(emails); y++ {
-							if !emails[y].Archived && !emails[y].Cancel {
-								lastUnarchivedEmail = y
-								break
-							}
-						}
-						// The processing here is a little more complex
-						// customField.Value = emails[0].Created
-						// Also, don't count it if the email has been
-						// archived. So we look for the last unarchived email.
-						if lastUnarchivedEmail != -1 && lastUnarchivedEmail < len(emails) {
-							if !emails[lastUnarchivedEmail].SendAt.IsZero() {
-								customField.Value = emails[lastUnarchivedEmail].SendAt.Format(time.RFC3339)
-							} else {
-								customField.Value = emails[lastUnarchivedEmail].Created.Format(time.RFC3339)
-							}
-
-							// Outliar checks
-							// First check if email is sent & delivered.
-							if customField.Value != "" && emails[lastUnarchivedEmail].IsSent && emails[lastUnarchivedEmail].Delievered {
-								// Sometimes email is marked as sent, but hasn't actually been sent
-								// because Gmail rejected it. This is to check that.
-								if emails[lastUnarchivedEmail].Method == "gmail" && emails[lastUnarchivedEmail].GmailId == "" {
-									customField.Value = ""
-								} else if emails[lastUnarchivedEmail].Method == "sendgrid" && emails[lastUnarchivedEmail].SendGridId == "" {
-									customField.Value = ""
-								}
-								// else {
-								// 	item1 := &memcache.Item{
-								// 		Key:   lastCreatedMemcacheKey,
-								// 		Value: []byte(customField.Value),
-								// 	}
-								// 	err = memcache.Set(c, item1)
-								// 	if err != nil {
-								// 		log.Errorf(c, "%v", err)
-								// 	}
-								// }
-							}
-						}
-					}
-					// } else {
-					// 	emailDate := string(item.Value[:])
-					// 	log.Debugf(c, "memcache: %v", emailDate)
-					// 	customField.Value = emailDate
-					// }
-				}
-
 				if customField.Value != "" {
 					contacts[i].CustomFields = append(contacts[i].CustomFields, customField)
 				}
@@ -1396,6 +1489,8 @@ func BatchCreateContactsForDuplicateList(c context.Context, r *http.Request, con
 	}
 
 	for i := 0; i < len(ks); i++ {
+		contacts[i].Id = ks[i].IntID()
+
 		// Duplicate Feed
 		feeds, err := GetFeedsByResourceId(c, r, "ContactId", previousKeys[i])
 		if err != nil {
@@ -1413,20 +1508,41 @@ func BatchCreateContactsForDuplicateList(c context.Context, r *http.Request, con
 		contactIds = append(contactIds, ks[i].IntID())
 	}
 
+	TriggerContactCreatedWebhooks(c, contacts[:len(ks)])
+
 	return contactIds, nil
 }
 
 // Does a ES sync in parse package & Twitter sync here
-func BatchCreateContactsForExcelUpload(c context.Context, r *http.Request, contacts []models.Contact, mediaListId int64) ([]int64, []int64, error) {
+func BatchCreateContactsForExcelUpload(c context.Context, r *http.Request, contacts []models.Contact, mediaListId int64) ([]int64, []int64, []models.RejectedCell, error) {
 	var keys []*datastore.Key
 	var contactIds []int64
 	var publicationIds []int64
 	var selectedContacts []models.Contact
+	rejectedCells := []models.RejectedCell{}
 
 	currentUser, err := controllers.GetCurrentUser(c, r)
 	if err != nil {
 		log.Errorf(c, "%v", err)
-		return []int64{}, []int64{}, err
+		return []int64{}, []int64{}, []models.RejectedCell{}, err
+	}
+
+	mediaList, err := getMediaList(c, r, mediaListId)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []int64{}, []int64{}, []models.RejectedCell{}, err
+	}
+
+	if !models.ListRoleAtLeast(mediaList.Role, models.ListRoleEditor) {
+		return []int64{}, []int64{}, []models.RejectedCell{}, errors.New("You don't have permissions to edit these objects")
+	}
+
+	if mediaList.IsSmartList {
+		return []int64{}, []int64{}, []models.RejectedCell{}, errors.New("Contacts can't be added to a smart list")
+	}
+
+	if len(mediaList.Contacts) > 0 {
+		snapshotMediaListBefore(c, r, mediaList, models.SnapshotReasonUpload)
 	}
 
 	for i := 0; i < len(contacts); i++ {
@@ -1438,6 +1554,8 @@ func BatchCreateContactsForExcelUpload(c context.Context, r *http.Request, conta
 		contacts[i].FormatName()
 		keys = append(keys, contacts[i].Key(c))
 
+		rejectedCells = append(rejectedCells, removeInvalidCustomFields(mediaList, &contacts[i], i+1)...)
+
 		for x := 0; x < len(contacts[i].Employers); x++ {
 			publicationIds = append(publicationIds, contacts[i].Employers[x])
 		}
@@ -1449,6 +1567,12 @@ func BatchCreateContactsForExcelUpload(c context.Context, r *http.Request, conta
 		selectedContacts = append(selectedContacts, contacts[i])
 	}
 
+	// Contacts in the same upload share their master contacts
+	err = linkContactsToMasters(c, r, currentUser, selectedContacts)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+	}
+
 	ks := []*datastore.Key{}
 
 	err = nds.RunInTransaction(c, func(ctx context.Context) error {
@@ -1463,24 +1587,34 @@ func BatchCreateContactsForExcelUpload(c context.Context, r *http.Request, conta
 
 	if err != nil {
 		log.Errorf(c, "%v", err)
-		return []int64{}, []int64{}, err
+		return []int64{}, []int64{}, []models.RejectedCell{}, err
 	}
 
+	revisions := []models.ContactRevision{}
 	for i := 0; i < len(ks); i++ {
 		contactIds = append(contactIds, ks[i].IntID())
+
+		selectedContacts[i].Id = ks[i].IntID()
+		revisions = append(revisions, contactRevisions(models.Contact{}, selectedContacts[i], models.RevisionSourceImport, currentUser.Id)...)
 	}
 
-	return contactIds, publicationIds, nil
+	err = saveContactRevisions(c, revisions)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+	}
+
+	TriggerContactCreatedWebhooks(c, selectedContacts[:len(ks)])
+
+	return contactIds, publicationIds, rejectedCells, nil
 }
 
 /*
 * Update methods
  */
 
-// Function to save a new contact into App Engine
-func Save(c context.Context, r *http.Request, ct *models.Contact) (*models.Contact, error) {
-	ct.Normalize()
-
+// Adds the company of the domain of a contact's email as their employer, when
+// they don't have one and the domain isn't an email provider
+func addEmployerFromEmail(c context.Context, r *http.Request, ct *models.Contact) {
 	if ct.Email != "" && len(ct.Employers) == 0 {
 		contactURLArray := strings.Split(ct.Email, "@")
 		companyData, err := apiSearch.SearchCompanyDatabase(c, r, contactURLArray[1])
@@ -1510,10 +1644,22 @@ func Save(c context.Context, r *http.Request, ct *models.Contact) (*models.Conta
 			log.Infof(c, "%v", contactURLArray)
 		}
 	}
+}
 
+// Function to save a new contact into App Engine
+func Save(c context.Context, r *http.Request, ct *models.Contact) (*models.Contact, error) {
+	ct.Normalize()
+	addEmployerFromEmail(c, r, ct)
 	ct.Normalize()
-	ct.Save(c, r)
-	sync.ResourceSync(r, ct.Id, "Contact", "create")
+
+	err := saveAndSync(c, "Contact", sync.ActionUpdate, func(ctx context.Context) (int64, error) {
+		_, err := ct.Save(ctx, r)
+		return ct.Id, err
+	})
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return ct, err
+	}
 	return ct, nil
 }
 
@@ -1531,20 +1677,19 @@ func EnrichContact(c context.Context, r *http.Request, id string) (models.Contac
 		return models.Contact{}, nil, errors.New("Could not get user")
 	}
 
-	mediaList, err := getMediaList(c, r, contact.ListId)
+	err = requireContactRole(c, r, contact, models.ListRoleEditor)
 	if err != nil {
 		log.Errorf(c, "%v", err)
 		return models.Contact{}, nil, err
 	}
 
-	if mediaList.TeamId != user.TeamId && !permissions.AccessToObject(contact.CreatedBy, user.Id) && !user.IsAdmin {
-		return models.Contact{}, nil, errors.New("You don't have permissions to edit these objects")
-	}
-
 	contact.FirstName = strings.TrimSpace(contact.FirstName)
 	contact.LastName = strings.TrimSpace(contact.LastName)
 	contact.Email = strings.TrimSpace(contact.Email)
 
+	previousContact := contact
+	previousContact.Tags = append([]string{}, contact.Tags...)
+
 	_, err = enrichContact(c, r, &contact)
 	if err != nil {
 		log.Errorf(c, "%v", err)
@@ -1552,17 +1697,18 @@ func EnrichContact(c context.Context, r *http.Request, id string) (models.Contac
 	}
 
 	_, err = Save(c, r, &contact)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Contact{}, nil, err
+	}
 
-	// Sync with ES
-	sync.ResourceSync(r, contact.Id, "Contact", "create")
+	err = RecordContactRevisions(c, r, previousContact, contact, models.RevisionSourceEnrichment)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+	}
 
 	// If user is just created
-	if contact.Twitter != "" {
-		sync.TwitterSync(r, contact.Twitter)
-	}
-	if contact.Instagram != "" {
-		sync.InstagramSync(r, contact.Instagram, user.InstagramAuthKey)
-	}
+	syncContactSocialFeeds(c, r, contact, user)
 
 	return contact, nil, nil
 }
@@ -1575,22 +1721,13 @@ func UpdateSingleContact(c context.Context, r *http.Request, id string) (models.
 		return models.Contact{}, nil, err
 	}
 
-	user, err := controllers.GetCurrentUser(c, r)
-	if err != nil {
-		log.Errorf(c, "%v", err)
-		return models.Contact{}, nil, errors.New("Could not get user")
-	}
-
-	mediaList, err := getMediaList(c, r, contact.ListId)
+	// Access to master contacts is checked when getting them
+	err = requireContactRole(c, r, contact, models.ListRoleEditor)
 	if err != nil {
 		log.Errorf(c, "%v", err)
 		return models.Contact{}, nil, err
 	}
 
-	if mediaList.TeamId != user.TeamId && !permissions.AccessToObject(contact.CreatedBy, user.Id) && !user.IsAdmin {
-		return models.Contact{}, nil, errors.New("You don't have permissions to edit these objects")
-	}
-
 	buf, _ := ioutil.ReadAll(r.Body)
 	decoder := ffjson.NewDecoder()
 	var updatedContact models.Contact
@@ -1613,13 +1750,6 @@ func UpdateBatchContact(c context.Context, r *http.Request) ([]models.Contact, i
 		return []models.Contact{}, nil, 0, 0, err
 	}
 
-	// Get logged in user
-	user, err := controllers.GetCurrentUser(c, r)
-	if err != nil {
-		log.Errorf(c, "%v", err)
-		return []models.Contact{}, nil, 0, 0, errors.New("Could not get user")
-	}
-
 	// Check if each of the contacts have permissions before updating anything
 	currentContacts := []models.Contact{}
 	for i := 0; i < len(updatedContacts); i++ {
@@ -1629,13 +1759,9 @@ func UpdateBatchContact(c context.Context, r *http.Request) ([]models.Contact, i
 			return []models.Contact{}, nil, 0, 0, err
 		}
 
-		mediaList, err := getMediaList(c, r, contact.ListId)
+		err = requireContactRole(c, r, contact, models.ListRoleEditor)
 		if err != nil {
 			log.Errorf(c, "%v", err)
-			return []models.Contact{}, nil, 0, 0, err
-		}
-
-		if mediaList.TeamId != user.TeamId && !permissions.AccessToObject(contact.CreatedBy, user.Id) && !user.IsAdmin {
 			return []models.Contact{}, nil, 0, 0, errors.New("Forbidden")
 		}
 
@@ -1683,6 +1809,16 @@ func CopyContacts(c context.Context, r *http.Request) ([]models.Contact, interfa
 		return []models.Contact{}, nil, 0, 0, err
 	}
 
+	err = requireMediaListRole(mediaList, models.ListRoleEditor)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Contact{}, nil, 0, 0, err
+	}
+
+	if mediaList.IsSmartList {
+		return []models.Contact{}, nil, 0, 0, errors.New("Contacts can't be added to a smart list")
+	}
+
 	mediaListFields := map[string]bool{}
 	for i := 0; i < len(mediaList.FieldsMap); i++ {
 		if mediaList.FieldsMap[i].CustomField && !mediaList.FieldsMap[i].ReadOnly {
@@ -1742,12 +1878,16 @@ func CopyContacts(c context.Context, r *http.Request) ([]models.Contact, interfa
 		}
 	}
 
-	// Append media list
+	// Append media list, and sync all the contacts in bulk
 	mediaList.Contacts = append(mediaList.Contacts, newContactIds...)
-	mediaList.Save(c)
+	err = saveMediaListAndSync(c, &mediaList, "", mediaList.Contacts)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Contact{}, nil, 0, 0, err
+	}
+
+	TriggerContactCreatedWebhooks(c, newContacts)
 
-	// Sync all the contacts in bulk here
-	sync.ListUploadResourceBulkSync(r, mediaList.Id, mediaList.Contacts, []int64{})
 	return newContacts, nil, 0, 0, nil
 }
 
@@ -1772,28 +1912,43 @@ func BulkDeleteContacts(c context.Context, r *http.Request) ([]models.Contact, i
 	}
 
 	if len(deleteContacts.Contacts) > 0 {
-		var listId int64
-
 		contacts := []models.Contact{}
-		contactIds := []int64{}
+		snapshotLists := map[int64]bool{}
 		for i := 0; i < len(deleteContacts.Contacts); i++ {
 			contact, err := getContact(c, r, deleteContacts.Contacts[i])
 			if err == nil {
-				if contact.CreatedBy == user.Id {
+				// Editors of a list can delete its contacts, master contacts
+				// can only be deleted by the people who made them
+				canDelete := !contact.ReadOnly
+				if contact.IsMasterContact {
+					canDelete = contact.CreatedBy == user.Id
+				}
+
+				if canDelete {
 					if contact.ListId != 0 {
-						listId = contact.ListId
+						// Snapshot each list before the first of its contacts goes
+						if _, ok := snapshotLists[contact.ListId]; !ok {
+							snapshotLists[contact.ListId] = true
+							mediaList, err := getMediaList(c, r, contact.ListId)
+							if err == nil {
+								snapshotMediaListBefore(c, r, mediaList, models.SnapshotReasonBulkDelete)
+							}
+						}
 					}
 
 					contact.IsDeleted = true
-					contact.Save(c, r)
-
-					contactIds = append(contactIds, contact.Id)
+					contact.Deleted = time.Now()
+					contact.Updated = time.Now()
 					contacts = append(contacts, contact)
 				}
 			}
 		}
 
-		sync.ListUploadResourceBulkSync(r, listId, contactIds, []int64{})
+		_, err = saveContactsAndSync(c, contacts, queueContactsSync(sync.ActionDelete))
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return []models.Contact{}, nil, 0, 0, err
+		}
 		return contacts, nil, len(contacts), 0, nil
 	}
 
@@ -1815,30 +1970,25 @@ func DeleteContact(c context.Context, r *http.Request, id string) (interface{},
 		return nil, nil, err
 	}
 
-	mediaList, err := getMediaList(c, r, contact.ListId)
-	if err != nil {
-		log.Errorf(c, "%v", err)
-		return nil, nil, err
-	}
-
-	user, err := controllers.GetCurrentUser(c, r)
+	err = requireContactRole(c, r, contact, models.ListRoleEditor)
 	if err != nil {
-		log.Errorf(c, "%v", err)
-		return nil, nil, err
-	}
-
-	// Double check permissions. Admins should not be able to delete.
-	if mediaList.TeamId != user.TeamId && !permissions.AccessToObject(contact.CreatedBy, user.Id) {
 		err = errors.New("Forbidden")
 		log.Errorf(c, "%v", err)
 		return nil, nil, err
 	}
 
 	contact.IsDeleted = true
-	contact.Save(c, r)
+	contact.Deleted = time.Now()
 
 	// Pubsub to remove ES contact
-	sync.ResourceSync(r, contact.Id, "Contact", "delete")
+	err = saveAndSync(c, "Contact", sync.ActionDelete, func(ctx context.Context) (int64, error) {
+		_, err := contact.Save(ctx, r)
+		return contact.Id, err
+	})
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return nil, nil, err
+	}
 	return nil, nil, nil
 }
 
@@ -1861,6 +2011,21 @@ func MoveContacts(c context.Context, r *http.Request) ([]models.Contact, interfa
 		return []models.Contact{}, nil, 0, 0, err
 	}
 
+	err = requireMediaListRole(newMediaList, models.ListRoleEditor)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Contact{}, nil, 0, 0, err
+	}
+
+	if newMediaList.IsSmartList {
+		return []models.Contact{}, nil, 0, 0, errors.New("Contacts can't be added to a smart list")
+	}
+
+	snapshotMediaListBefore(c, r, newMediaList, models.SnapshotReasonMove)
+	snapshotLists := map[int64]bool{
+		newMediaList.Id: true,
+	}
+
 	mediaListFields := map[string]bool{}
 	for i := 0; i < len(newMediaList.FieldsMap); i++ {
 		if newMediaList.FieldsMap[i].CustomField && !newMediaList.FieldsMap[i].ReadOnly {
@@ -1872,7 +2037,15 @@ func MoveContacts(c context.Context, r *http.Request) ([]models.Contact, interfa
 
 	for i := 0; i < len(moveContacts.Contacts); i++ {
 		contact, err := getContact(c, r, moveContacts.Contacts[i])
-		if err == nil {
+		if err == nil && !contact.ReadOnly {
+			if _, ok := snapshotLists[contact.ListId]; !ok && contact.ListId != 0 {
+				snapshotLists[contact.ListId] = true
+				mediaList, err := getMediaList(c, r, contact.ListId)
+				if err == nil {
+					snapshotMediaListBefore(c, r, mediaList, models.SnapshotReasonMove)
+				}
+			}
+
 			contact.Updated = time.Now()
 			contact.ListId = moveContacts.NewListId
 
@@ -1907,11 +2080,13 @@ func MoveContacts(c context.Context, r *http.Request) ([]models.Contact, interfa
 		}
 	}
 
-	// Append media list
+	// Append media list, and sync all the contacts in bulk
 	newMediaList.Contacts = append(newMediaList.Contacts, newContactIds...)
-	newMediaList.Save(c)
+	err = saveMediaListAndSync(c, &newMediaList, "", newMediaList.Contacts)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Contact{}, nil, 0, 0, err
+	}
 
-	// Sync all the contacts in bulk here
-	sync.ListUploadResourceBulkSync(r, newMediaList.Id, newMediaList.Contacts, []int64{})
 	return newContacts, nil, 0, 0, nil
 }
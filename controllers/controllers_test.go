@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/aetest"
+
+	gcontext "github.com/gorilla/context"
+
+	apiModels "github.com/news-ai/api/models"
+
+	"github.com/news-ai/tabulae/sync"
+)
+
+// Starts the development datastore and makes a request as a new user. Tests
+// that need the datastore are skipped by go test -short.
+func newTestRequest(t *testing.T, method string, url string, body string) (aetest.Instance, context.Context, *http.Request, apiModels.User) {
+	if testing.Short() {
+		t.Skip("needs the development datastore")
+	}
+
+	inst, err := aetest.NewInstance(&aetest.Options{StronglyConsistentDatastore: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := inst.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		inst.Close()
+		t.Fatal(err)
+	}
+	c := appengine.NewContext(r)
+
+	user := apiModels.User{
+		Email:    "test@newsai.org",
+		IsActive: true,
+	}
+	_, err = user.Create(c, r)
+	if err != nil {
+		inst.Close()
+		t.Fatal(err)
+	}
+	gcontext.Set(r, "user", user)
+
+	return inst, c, r, user
+}
+
+// Publishes to memory for the rest of the test, so its events can be checked
+func newTestBus() *sync.MemoryBus {
+	bus := sync.NewMemoryBus()
+	sync.SetBus(bus)
+	return bus
+}
+
+// The events published to a topic, decoded
+func testEvents(t *testing.T, bus *sync.MemoryBus, topic string) []sync.Event {
+	messages := bus.Messages(topic)
+	events := []sync.Event{}
+	for i := 0; i < len(messages); i++ {
+		event, err := messages[i].Event()
+		if err != nil {
+			t.Fatalf("message %v on %v: %v", i, topic, err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
@@ -0,0 +1,235 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+
+	"github.com/qedus/nds"
+
+	"github.com/news-ai/tabulae/models"
+)
+
+/*
+* Private
+ */
+
+// How many media lists a field type migration goes through
+var fieldTypeMigrationLimit = 50
+
+/*
+* Private methods
+ */
+
+func getFieldsMapField(mediaList models.MediaList, value string) (models.CustomFieldsMap, bool) {
+	for i := 0; i < len(mediaList.FieldsMap); i++ {
+		if mediaList.FieldsMap[i].Value == value {
+			return mediaList.FieldsMap[i], true
+		}
+	}
+	return models.CustomFieldsMap{}, false
+}
+
+// Checks the custom field values of a contact against the types of the
+// fields in its media list
+func validateContactCustomFields(mediaList models.MediaList, contact models.Contact) error {
+	for i := 0; i < len(contact.CustomFields); i++ {
+		field, ok := getFieldsMapField(mediaList, contact.CustomFields[i].Name)
+		if !ok || field.ReadOnly {
+			continue
+		}
+
+		err := field.ValidateValue(contact.CustomFields[i].Value)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Leaves out the custom field values of an uploaded contact that don't fit
+// the type of their column, and returns them so the upload can report them.
+// Imports keep going when a value doesn't fit its field.
+func removeInvalidCustomFields(mediaList models.MediaList, contact *models.Contact, row int) []models.RejectedCell {
+	rejectedCells := []models.RejectedCell{}
+	customFields := []models.CustomContactField{}
+	for i := 0; i < len(contact.CustomFields); i++ {
+		field, ok := getFieldsMapField(mediaList, contact.CustomFields[i].Name)
+		if ok && !field.ReadOnly {
+			err := field.ValidateValue(contact.CustomFields[i].Value)
+			if err != nil {
+				rejectedCells = append(rejectedCells, models.RejectedCell{
+					Row:    row,
+					Column: field.Name,
+					Value:  contact.CustomFields[i].Value,
+					Error:  err.Error(),
+				})
+				continue
+			}
+		}
+		customFields = append(customFields, contact.CustomFields[i])
+	}
+	contact.CustomFields = customFields
+	return rejectedCells
+}
+
+func validateFieldsMap(fieldsMap []models.CustomFieldsMap) error {
+	for i := 0; i < len(fieldsMap); i++ {
+		if fieldsMap[i].Type == "" {
+			continue
+		}
+
+		if !models.IsValidFieldType(fieldsMap[i].Type) {
+			return errors.New(fieldsMap[i].Name + ": " + fieldsMap[i].Type + " is not a field type")
+		}
+
+		if (fieldsMap[i].Type == models.FieldTypeSelect || fieldsMap[i].Type == models.FieldTypeMultiSelect) && len(fieldsMap[i].Options) == 0 {
+			return errors.New(fieldsMap[i].Name + ": select fields need options")
+		}
+	}
+	return nil
+}
+
+func getContactsForMediaList(c context.Context, mediaList models.MediaList) ([]models.Contact, error) {
+	keys := []*datastore.Key{}
+	for i := 0; i < len(mediaList.Contacts); i++ {
+		keys = append(keys, datastore.NewKey(c, "Contact", "", mediaList.Contacts[i], nil))
+	}
+
+	// Contacts purged from the trash are left out
+	contacts := make([]models.Contact, len(keys))
+	err := nds.GetMulti(c, keys, contacts)
+	multiErr, isMultiErr := err.(appengine.MultiError)
+	if err != nil && !isMultiErr {
+		log.Errorf(c, "%v", err)
+		return []models.Contact{}, err
+	}
+
+	nonDeletedContacts := []models.Contact{}
+	for i := 0; i < len(contacts); i++ {
+		if isMultiErr && multiErr[i] != nil {
+			if multiErr[i] != datastore.ErrNoSuchEntity {
+				log.Errorf(c, "%v", multiErr[i])
+				return []models.Contact{}, multiErr[i]
+			}
+			continue
+		}
+		contacts[i].Format(keys[i], "contacts")
+		if !contacts[i].IsDeleted {
+			nonDeletedContacts = append(nonDeletedContacts, contacts[i])
+		}
+	}
+
+	return nonDeletedContacts, nil
+}
+
+func needsFieldTypeMigration(mediaList models.MediaList) bool {
+	for i := 0; i < len(mediaList.FieldsMap); i++ {
+		if mediaList.FieldsMap[i].Type == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Default fields are always text, and custom fields get the type that fits
+// all of the values the contacts have for them
+func inferFieldTypes(mediaList *models.MediaList, contacts []models.Contact) {
+	for i := 0; i < len(mediaList.FieldsMap); i++ {
+		if mediaList.FieldsMap[i].Type != "" {
+			continue
+		}
+
+		if !mediaList.FieldsMap[i].CustomField {
+			mediaList.FieldsMap[i].Type = models.FieldTypeText
+			continue
+		}
+
+		values := []string{}
+		for x := 0; x < len(contacts); x++ {
+			values = append(values, contacts[x].FieldValue(mediaList.FieldsMap[i].Value))
+		}
+		mediaList.FieldsMap[i].Type = models.InferFieldType(values)
+	}
+}
+
+/*
+* Public methods
+ */
+
+/*
+* Update methods
+ */
+
+// Infers the types of a page of media lists from the "cursor" parameter, for
+// custom fields that were added before fields had types. The cursor of the
+// next page is returned, and is empty after the last page.
+func MigrateCustomFieldTypes(c context.Context, r *http.Request) (string, error) {
+	query := datastore.NewQuery("MediaList").KeysOnly()
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		decodedCursor, err := datastore.DecodeCursor(cursor)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return "", errors.New("Cursor is not valid")
+		}
+		query = query.Start(decodedCursor)
+	}
+
+	ks := []*datastore.Key{}
+	nextCursor := ""
+	t := query.Run(c)
+	for {
+		if len(ks) == fieldTypeMigrationLimit {
+			cursor, err := t.Cursor()
+			if err != nil {
+				log.Errorf(c, "%v", err)
+				return "", err
+			}
+			nextCursor = cursor.String()
+			break
+		}
+
+		k, err := t.Next(nil)
+		if err == datastore.Done {
+			break
+		}
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return "", err
+		}
+		ks = append(ks, k)
+	}
+
+	mediaLists := make([]models.MediaList, len(ks))
+	err := nds.GetMulti(c, ks, mediaLists)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return "", err
+	}
+
+	for i := 0; i < len(mediaLists); i++ {
+		mediaLists[i].Format(ks[i], "lists")
+		if !needsFieldTypeMigration(mediaLists[i]) {
+			continue
+		}
+
+		contacts, err := getContactsForMediaList(c, mediaLists[i])
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			continue
+		}
+
+		inferFieldTypes(&mediaLists[i], contacts)
+		_, err = mediaLists[i].Save(c)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
+	}
+
+	return nextCursor, nil
+}
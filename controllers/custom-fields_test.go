@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/news-ai/tabulae/models"
+)
+
+func TestRemoveInvalidCustomFields(t *testing.T) {
+	mediaList := models.MediaList{
+		FieldsMap: []models.CustomFieldsMap{
+			{Name: "Followers", Value: "followers", CustomField: true, Type: models.FieldTypeNumber},
+			{Name: "Website", Value: "website", CustomField: true, Type: models.FieldTypeURL},
+			{Name: "Region", Value: "region", CustomField: true, Type: models.FieldTypeSelect, Options: []string{"East", "West"}},
+		},
+	}
+
+	contact := models.Contact{
+		CustomFields: []models.CustomContactField{
+			{Name: "followers", Value: "lots"},
+			{Name: "website", Value: "https://example.com"},
+			{Name: "region", Value: "North"},
+			{Name: "beat", Value: "Tech"},
+		},
+	}
+
+	rejectedCells := removeInvalidCustomFields(mediaList, &contact, 3)
+
+	wantCustomFields := []models.CustomContactField{
+		{Name: "website", Value: "https://example.com"},
+		{Name: "beat", Value: "Tech"},
+	}
+	if !reflect.DeepEqual(contact.CustomFields, wantCustomFields) {
+		t.Errorf("kept %+v, want %+v", contact.CustomFields, wantCustomFields)
+	}
+
+	if len(rejectedCells) != 2 {
+		t.Fatalf("rejected %+v, want the followers and region cells", rejectedCells)
+	}
+	if rejectedCells[0].Row != 3 || rejectedCells[0].Column != "Followers" || rejectedCells[0].Value != "lots" {
+		t.Errorf("rejected %+v, want the followers cell of row 3", rejectedCells[0])
+	}
+	if rejectedCells[1].Row != 3 || rejectedCells[1].Column != "Region" || rejectedCells[1].Value != "North" {
+		t.Errorf("rejected %+v, want the region cell of row 3", rejectedCells[1])
+	}
+}
+
+func TestInferFieldTypes(t *testing.T) {
+	mediaList := models.MediaList{
+		FieldsMap: []models.CustomFieldsMap{
+			{Value: "firstname"},
+			{Value: "followers", CustomField: true},
+			{Value: "homepage", CustomField: true},
+			{Value: "beat", CustomField: true},
+			{Value: "region", CustomField: true, Type: models.FieldTypeText},
+		},
+	}
+
+	contacts := []models.Contact{
+		{FirstName: "Jane", CustomFields: []models.CustomContactField{{Name: "followers", Value: "120"}, {Name: "homepage", Value: "https://example.com"}, {Name: "region", Value: "5"}}},
+		{FirstName: "John", CustomFields: []models.CustomContactField{{Name: "followers", Value: "3"}, {Name: "beat", Value: "Tech"}}},
+	}
+
+	if !needsFieldTypeMigration(mediaList) {
+		t.Errorf("fields without a type need to be migrated")
+	}
+
+	inferFieldTypes(&mediaList, contacts)
+
+	types := []string{}
+	for i := 0; i < len(mediaList.FieldsMap); i++ {
+		types = append(types, mediaList.FieldsMap[i].Type)
+	}
+	want := []string{models.FieldTypeText, models.FieldTypeNumber, models.FieldTypeURL, models.FieldTypeText, models.FieldTypeText}
+	if !reflect.DeepEqual(types, want) {
+		t.Errorf("inferred %v, want %v", types, want)
+	}
+
+	if needsFieldTypeMigration(mediaList) {
+		t.Errorf("fields that all have a type don't need to be migrated")
+	}
+}
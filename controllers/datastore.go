@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"reflect"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+
+	"github.com/qedus/nds"
+)
+
+/*
+* Private
+ */
+
+// The most entities the datastore takes in one put or delete
+var putBatchSize = 500
+
+/*
+* Private methods
+ */
+
+// Puts any number of entities. src is a slice of them, like for
+// nds.PutMulti. Returns the keys of the entities that were put, which are
+// complete for new ones, so on an error they are the batches before it.
+func putMultiInBatches(c context.Context, keys []*datastore.Key, src interface{}) ([]*datastore.Key, error) {
+	entities := reflect.ValueOf(src)
+	savedKeys := []*datastore.Key{}
+	for i := 0; i < len(keys); i += putBatchSize {
+		end := i + putBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		ks, err := nds.PutMulti(c, keys[i:end], entities.Slice(i, end).Interface())
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return savedKeys, err
+		}
+		savedKeys = append(savedKeys, ks...)
+	}
+	return savedKeys, nil
+}
+
+// Deletes any number of entities, putBatchSize at a time
+func deleteMultiInBatches(c context.Context, keys []*datastore.Key) error {
+	for i := 0; i < len(keys); i += putBatchSize {
+		end := i + putBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		err := nds.DeleteMulti(c, keys[i:end])
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"testing"
+
+	"google.golang.org/appengine/datastore"
+
+	"github.com/news-ai/tabulae/models"
+)
+
+func TestPutMultiInBatches(t *testing.T) {
+	inst, c, _, user := newTestRequest(t, "POST", "/", "")
+	defer inst.Close()
+
+	defaultPutBatchSize := putBatchSize
+	putBatchSize = 2
+	defer func() {
+		putBatchSize = defaultPutBatchSize
+	}()
+
+	keys := []*datastore.Key{}
+	revisions := []models.ContactRevision{}
+	for i := 0; i < 5; i++ {
+		revision := models.ContactRevision{ContactId: int64(i + 1)}
+		revision.CreatedBy = user.Id
+		keys = append(keys, revision.Key(c))
+		revisions = append(revisions, revision)
+	}
+
+	ks, err := putMultiInBatches(c, keys, revisions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ks) != len(revisions) {
+		t.Fatalf("put %v revisions, want %v", len(ks), len(revisions))
+	}
+
+	savedRevisions := make([]models.ContactRevision, len(ks))
+	err = datastore.GetMulti(c, ks, savedRevisions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(savedRevisions); i++ {
+		if savedRevisions[i].ContactId != revisions[i].ContactId {
+			t.Errorf("revision %v is for contact %v, want %v", i, savedRevisions[i].ContactId, revisions[i].ContactId)
+		}
+	}
+}
@@ -761,14 +761,22 @@ func CreateEmailTransition(c context.Context, r *http.Request) ([]models.Email,
 				}
 				return nil
 			}, nil)
+			if err != nil {
+				log.Errorf(c, "%v", err)
+				return []models.Email{}, nil, err
+			}
 
 			for i := 0; i < len(ks); i++ {
 				emails[i].Format(ks[i], "emails")
 				emailIds = append(emailIds, emails[i].Id)
 			}
 
-			sync.EmailResourceBulkSync(r, emailIds)
-			return emails, nil, err
+			err = sync.EmailResourceBulkSync(r, emailIds, sync.ActionCreate)
+			if err != nil {
+				log.Errorf(c, "%v", err)
+				return []models.Email{}, nil, err
+			}
+			return emails, nil, nil
 		} else {
 			firstHalfKeys := []*datastore.Key{}
 			secondHalfKeys := []*datastore.Key{}
@@ -852,7 +860,14 @@ func CreateEmailTransition(c context.Context, r *http.Request) ([]models.Email,
 				err = err4
 			}
 
-			sync.EmailResourceBulkSync(r, emailIds)
+			// The emails that were saved are synced even when some weren't
+			syncErr := sync.EmailResourceBulkSync(r, emailIds, sync.ActionCreate)
+			if syncErr != nil {
+				log.Errorf(c, "%v", syncErr)
+				if err == nil {
+					err = syncErr
+				}
+			}
 			return emails, nil, err
 		}
 	}
@@ -884,8 +899,10 @@ func CreateEmailTransition(c context.Context, r *http.Request) ([]models.Email,
 	email.IsSent = false
 
 	// Create email
-	_, err = email.Create(c, r, currentUser)
-	sync.ResourceSync(r, email.Id, "Email", "create")
+	err = saveAndSync(c, "Email", sync.ActionCreate, func(ctx context.Context) (int64, error) {
+		_, err := email.Create(ctx, r, currentUser)
+		return email.Id, err
+	})
 	if err != nil {
 		log.Errorf(c, "%v", err)
 		return []models.Email{}, nil, err
@@ -931,8 +948,14 @@ func UpdateEmail(c context.Context, r *http.Request, currentUser apiModels.User,
 		email.TemplateId = updatedEmail.TemplateId
 	}
 
-	email.Save(c)
-	sync.ResourceSync(r, email.Id, "Email", "create")
+	err := saveAndSync(c, "Email", sync.ActionUpdate, func(ctx context.Context) (int64, error) {
+		_, err := email.Save(ctx)
+		return email.Id, err
+	})
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return *email, nil, err
+	}
 	return *email, nil, nil
 }
 
@@ -1044,17 +1067,30 @@ func CancelAllScheduled(c context.Context, r *http.Request) ([]models.Email, int
 	}
 
 	emailIds := []int64{} // Validated email ids
+	contactIds := []int64{}
 	for i := 0; i < len(emails); i++ {
 		// If it has not been delivered and has a sentat date then we can cancel it
 		// and that sendAt date is in the future.
 		if !emails[i].Delievered && !emails[i].SendAt.IsZero() && emails[i].SendAt.After(time.Now()) {
 			emails[i].Cancel = true
-			emails[i].Save(c)
+			_, err = emails[i].Save(c)
+			if err != nil {
+				log.Errorf(c, "%v", err)
+				continue
+			}
 			emailIds = append(emailIds, emails[i].Id)
+			contactIds = append(contactIds, emails[i].ContactId)
 		}
 	}
 
-	sync.EmailResourceBulkSync(r, emailIds)
+	// Cancelled emails no longer count towards the contacts' engagement
+	rebuildContactEngagements(c, uniqueContactIds(contactIds))
+
+	err = sync.EmailResourceBulkSync(r, emailIds, sync.ActionUpdate)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Email{}, nil, 0, 0, err
+	}
 	return emails, nil, len(emails), 0, nil
 }
 
@@ -1070,6 +1106,7 @@ func BulkCancelEmail(c context.Context, r *http.Request) ([]models.Email, interf
 
 	emails := []models.Email{}
 	emailIds := []int64{} // Validated email ids
+	contactIds := []int64{}
 	for i := 0; i < len(cancelEmails.Emails); i++ {
 		email, err := getEmail(c, r, cancelEmails.Emails[i])
 		if err != nil {
@@ -1081,13 +1118,24 @@ func BulkCancelEmail(c context.Context, r *http.Request) ([]models.Email, interf
 		// and that sendAt date is in the future.
 		if !email.SendAt.IsZero() && email.SendAt.After(time.Now()) {
 			email.Cancel = true
-			email.Save(c)
+			_, err = email.Save(c)
+			if err != nil {
+				log.Errorf(c, "%v", err)
+				continue
+			}
 			emails = append(emails, email)
 			emailIds = append(emailIds, email.Id)
+			contactIds = append(contactIds, email.ContactId)
 		}
 	}
 
-	sync.EmailResourceBulkSync(r, emailIds)
+	rebuildContactEngagements(c, uniqueContactIds(contactIds))
+
+	err = sync.EmailResourceBulkSync(r, emailIds, sync.ActionUpdate)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Email{}, nil, 0, 0, err
+	}
 	return emails, nil, len(emails), 0, nil
 }
 
@@ -1102,8 +1150,15 @@ func CancelEmail(c context.Context, r *http.Request, id string) (models.Email, i
 	// and that sendAt date is in the future.
 	if !email.SendAt.IsZero() && email.SendAt.After(time.Now()) {
 		email.Cancel = true
-		email.Save(c)
-		sync.ResourceSync(r, email.Id, "Email", "create")
+		err = saveAndSync(c, "Email", sync.ActionUpdate, func(ctx context.Context) (int64, error) {
+			_, err := email.Save(ctx)
+			return email.Id, err
+		})
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return models.Email{}, nil, err
+		}
+		rebuildContactEngagements(c, uniqueContactIds([]int64{email.ContactId}))
 		return email, nil, nil
 	}
 
@@ -1118,13 +1173,19 @@ func ArchiveEmail(c context.Context, r *http.Request, id string) (models.Email,
 	}
 
 	email.Archived = true
-	email.Save(c)
+	err = saveAndSync(c, "Email", sync.ActionUpdate, func(ctx context.Context) (int64, error) {
+		_, err := email.Save(ctx)
+		return email.Id, err
+	})
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Email{}, nil, err
+	}
 
 	// Remove memcache object for the campaign
 	memcacheKey := GetEmailCampaignKey(email)
 	memcache.Delete(c, memcacheKey)
 
-	sync.ResourceSync(r, email.Id, "Email", "create")
 	return email, nil, nil
 }
 
@@ -1207,6 +1268,15 @@ func BulkSendEmail(c context.Context, r *http.Request) ([]models.Email, interfac
 			return nil
 		}, nil)
 
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return []models.Email{}, nil, 0, 0, err
+		}
+
+		for i := 0; i < len(updatedEmails); i++ {
+			recordEmailSent(c, updatedEmails[i])
+		}
+
 		// Delete a single memcache key since the emails should all have
 		// the same subject (or baseSubject)
 		if memcacheKey != "" {
@@ -1214,7 +1284,11 @@ func BulkSendEmail(c context.Context, r *http.Request) ([]models.Email, interfac
 		}
 
 		if len(emailIds) > 0 {
-			sync.SendEmailsToEmailService(r, emailIds)
+			err = sync.SendEmailsToEmailService(r, emailIds)
+			if err != nil {
+				log.Errorf(c, "%v", err)
+				return []models.Email{}, nil, 0, 0, err
+			}
 		}
 	}
 
@@ -1233,7 +1307,12 @@ func SendEmail(c context.Context, r *http.Request, id string) (models.Email, int
 		log.Errorf(c, "%v", err)
 		return models.Email{}, nil, err
 	}
-	singleEmail.Save(c)
+	_, err = singleEmail.Save(c)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Email{}, nil, err
+	}
+	recordEmailSent(c, singleEmail)
 
 	// Check if email has been scheduled or not
 	if email.SendAt.IsZero() || email.SendAt.Before(time.Now()) {
@@ -1245,7 +1324,11 @@ func SendEmail(c context.Context, r *http.Request, id string) (models.Email, int
 
 		// Sync email with email service if this is not a bulk email
 		emailIds := []int64{email.Id}
-		sync.SendEmailsToEmailService(r, emailIds)
+		err = sync.SendEmailsToEmailService(r, emailIds)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return models.Email{}, nil, err
+		}
 	}
 
 	return singleEmail, nil, nil
@@ -1265,6 +1348,9 @@ func MarkBounced(c context.Context, r *http.Request, e *models.Email, reason str
 	}
 
 	_, err = e.MarkBounced(c, reason)
+	if err == nil {
+		TriggerWebhooks(c, e.CreatedBy, e.TeamId, models.WebhookEmailBounced, webhookEmailData(*e, reason))
+	}
 	return e, err
 }
 
@@ -1276,7 +1362,14 @@ func MarkSpam(c context.Context, r *http.Request, e *models.Email) (*models.Emai
 
 func MarkClicked(c context.Context, r *http.Request, e *models.Email) (*models.Email, error) {
 	controllers.SetUser(c, r, e.CreatedBy)
+	firstClick := e.Clicked == 0 && e.SendGridClicked == 0
 	_, err := e.MarkClicked(c)
+	if err == nil && firstClick && e.Clicked > 0 {
+		recordEmailClicked(c, *e)
+	}
+	if err == nil {
+		TriggerEmailWebhooks(c, models.WebhookEmailClicked, []models.Email{*e})
+	}
 	return e, err
 }
 
@@ -1287,16 +1380,49 @@ func MarkDelivered(c context.Context, r *http.Request, e *models.Email) (*models
 
 func MarkOpened(c context.Context, r *http.Request, e *models.Email) (*models.Email, error) {
 	controllers.SetUser(c, r, e.CreatedBy)
+	firstOpen := e.Opened == 0 && e.SendGridOpened == 0
 	_, err := e.MarkOpened(c)
+	if err == nil && e.Opened > 0 {
+		recordEmailOpened(c, *e, firstOpen)
+		TriggerEmailWebhooks(c, models.WebhookEmailOpened, []models.Email{*e})
+	}
 	return e, err
 }
 
 func MarkSendgridOpen(c context.Context, r *http.Request, e *models.Email) (*models.Email, error) {
 	controllers.SetUser(c, r, e.CreatedBy)
+	firstOpen := e.Opened == 0 && e.SendGridOpened == 0
 	_, err := e.MarkSendgridOpened(c)
+	if err == nil {
+		recordEmailOpened(c, *e, firstOpen)
+		TriggerEmailWebhooks(c, models.WebhookEmailOpened, []models.Email{*e})
+	}
 	return e, err
 }
 
+// Unsubscribes the recipient of an email from the emails of its sender
+func MarkUnsubscribed(c context.Context, r *http.Request, e *models.Email) (*models.Email, error) {
+	if e.To == "" {
+		return e, nil
+	}
+
+	unsubscribe := models.ContactUnsubscribe{}
+	unsubscribe.CreatedBy = e.CreatedBy
+	unsubscribe.ListId = e.ListId
+	unsubscribe.ContactId = e.ContactId
+
+	unsubscribe.Email = e.To
+	unsubscribe.Unsubscribed = true
+	_, err := unsubscribe.Create(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return e, err
+	}
+
+	TriggerEmailWebhooks(c, models.WebhookEmailUnsubscribed, []models.Email{*e})
+	return e, nil
+}
+
 func MarkSendgridDrop(c context.Context, r *http.Request, e *models.Email) (*models.Email, error) {
 	controllers.SetUser(c, r, e.CreatedBy)
 	_, err := e.MarkSendgridDropped(c)
@@ -1333,104 +1459,11 @@ func GetEmailSearch(c context.Context, r *http.Request) (interface{}, interface{
 		return nil, nil, 0, 0, err
 	}
 
-	if strings.Contains(queryField, "date:") || strings.Contains(queryField, "subject:") || strings.Contains(queryField, "filter:") || strings.Contains(queryField, "baseSubject:") {
-		emailFilters := strings.Split(queryField, ",")
-		emailDate := ""
-		emailSubject := ""
-		emailBaseSubject := ""
-		emailFilter := ""
-
-		for i := 0; i < len(emailFilters); i++ {
-			if strings.Contains(emailFilters[i], "date:") {
-				emailDateArray := strings.Split(emailFilters[i], ":")
-				if len(emailDateArray) > 1 {
-					emailDate = strings.Join(emailDateArray[1:], ":")
-					emailDate = strings.Replace(emailDate, "\\", "", -1)
-
-					if last := len(emailDate) - 1; last >= 0 && emailDate[last] == '"' {
-						emailDate = emailDate[:last]
-					}
-
-					if emailDate[0] == '"' {
-						emailDate = emailDate[1:]
-					}
-				}
-			} else if strings.Contains(emailFilters[i], "filter:") {
-				emailFilterArray := strings.Split(emailFilters[i], ":")
-				if len(emailFilterArray) > 1 {
-					emailFilter = strings.Join(emailFilterArray[1:], ":")
-					emailFilter = strings.Replace(emailFilter, "\\", "", -1)
-
-					if last := len(emailFilter) - 1; last >= 0 && emailFilter[last] == '"' {
-						emailFilter = emailFilter[:last]
-					}
-
-					if emailFilter[0] == '"' {
-						emailFilter = emailFilter[1:]
-					}
-				}
-			} else if strings.Contains(emailFilters[i], "subject:") {
-				if len(emailFilters) > 2 {
-					emailSubjectSplit := strings.Split(queryField, "subject:")
-					emailFilters[i] = "subject:" + emailSubjectSplit[len(emailSubjectSplit)-1]
-				}
-				emailSubjectArray := strings.Split(emailFilters[i], ":")
-				if len(emailSubjectArray) > 1 {
-					log.Infof(c, "%v", emailSubjectArray)
-					// Recover the pieces when split by colon
-					emailSubject = strings.Join(emailSubjectArray[1:], ":")
-					emailSubject = strings.Replace(emailSubject, "\\", "", -1)
-
-					if last := len(emailSubject) - 1; last >= 0 && emailSubject[last] == '"' {
-						emailSubject = emailSubject[:last]
-					}
-
-					if emailSubject[0] == '"' {
-						emailSubject = emailSubject[1:]
-					}
-
-					log.Infof(c, "%v", emailSubject)
-				}
-			} else if strings.Contains(emailFilters[i], "baseSubject:") {
-				emailBaseSubjectArray := strings.Split(emailFilters[i], ":")
-				if len(emailBaseSubjectArray) > 1 {
-					// Recover the pieces when split by colon
-					emailBaseSubject = strings.Join(emailBaseSubjectArray[1:], ":")
-					emailBaseSubject = strings.Replace(emailBaseSubject, "\\", "", -1)
-
-					if last := len(emailBaseSubject) - 1; last >= 0 && emailBaseSubject[last] == '"' {
-						emailBaseSubject = emailBaseSubject[:last]
-					}
-
-					if emailBaseSubject[0] == '"' {
-						emailBaseSubject = emailBaseSubject[1:]
-					}
-				}
-			}
-		}
-
-		if emailDate != "" || emailSubject != "" || emailFilter != "" || emailBaseSubject != "" {
-			emails, count, total, err := search.SearchEmailsByQueryFields(c, r, user, emailDate, emailSubject, emailBaseSubject, emailFilter)
-
-			// Add includes
-			mediaLists := emailsToLists(c, r, emails)
-			contacts := emailsToContacts(c, r, emails)
-			includes := make([]interface{}, len(mediaLists)+len(contacts))
-			for i := 0; i < len(mediaLists); i++ {
-				includes[i] = mediaLists[i]
-			}
-
-			for i := 0; i < len(contacts); i++ {
-				includes[i+len(mediaLists)] = contacts[i]
-			}
-
-			return emails, includes, count, total, err
-		} else {
-			return nil, nil, 0, 0, errors.New("Please enter a valid date or subject")
-		}
-	}
-
 	emails, count, total, err := search.SearchEmailsByQuery(c, r, user, queryField)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return nil, nil, 0, 0, err
+	}
 
 	// Add includes
 	mediaLists := emailsToLists(c, r, emails)
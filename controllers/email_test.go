@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/news-ai/tabulae/sync"
+)
+
+func TestCreateEmailTransitionEvents(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		topic string
+	}{
+		{
+			name:  "one email",
+			body:  `{"to": "jane@example.com", "subject": "Hello"}`,
+			topic: sync.EmailChangeTopicID,
+		},
+		{
+			name:  "several emails",
+			body:  `[{"to": "jane@example.com", "subject": "Hello"}, {"to": "john@example.com", "subject": "Hello"}]`,
+			topic: sync.EmailBulkTopicID,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inst, c, r, _ := newTestRequest(t, "POST", "/api/emails", test.body)
+			defer inst.Close()
+			bus := newTestBus()
+
+			emails, _, err := CreateEmailTransition(c, r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			emailIds := []int64{}
+			for i := 0; i < len(emails); i++ {
+				emailIds = append(emailIds, emails[i].Id)
+			}
+
+			if messages := bus.Messages(""); len(messages) != 1 {
+				t.Fatalf("published %v messages, want 1", len(messages))
+			}
+
+			events := testEvents(t, bus, test.topic)
+			want := sync.NewResourceChanged("Email", sync.ActionCreate, emailIds)
+			if len(events) != 1 || !reflect.DeepEqual(events[0], want) {
+				t.Errorf("events on %v are %+v, want %+v", test.topic, events, want)
+			}
+		})
+	}
+}
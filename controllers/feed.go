@@ -231,7 +231,14 @@ func CreateFeed(c context.Context, r *http.Request) (models.Feed, interface{}, e
 	}
 
 	publication.Url = baseDomain
-	publication.Save(c)
+	err = saveAndSync(c, "Publication", sync.ActionUpdate, func(ctx context.Context) (int64, error) {
+		_, err := publication.Save(ctx)
+		return publication.Id, err
+	})
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Feed{}, nil, err
+	}
 
 	feed.PublicationId = publication.Id
 
@@ -243,7 +250,11 @@ func CreateFeed(c context.Context, r *http.Request) (models.Feed, interface{}, e
 	}
 
 	// Run new feed through pub/sub
-	sync.NewRSSFeedSync(r, feed.FeedURL, feed.PublicationId)
+	err = sync.NewRSSFeedSync(r, feed.FeedURL, feed.PublicationId)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Feed{}, nil, err
+	}
 
 	return feed, nil, nil
 }
@@ -0,0 +1,432 @@
+package controllers
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/log"
+
+	"github.com/pquerna/ffjson/ffjson"
+
+	"github.com/news-ai/api/controllers"
+
+	"github.com/news-ai/tabulae/models"
+	"github.com/news-ai/tabulae/sync"
+)
+
+/*
+* Private
+ */
+
+var (
+	listOperationUnion        = "union"
+	listOperationIntersection = "intersection"
+	listOperationDifference   = "difference"
+)
+
+// Which contact wins when the same person has different values for a custom
+// field in the lists being combined. Empty values never replace a value.
+var (
+	listConflictFirst  = "first"
+	listConflictLast   = "last"
+	listConflictNewest = "newest"
+)
+
+type combineListsDetails struct {
+	Operation string  `json:"operation"`
+	Lists     []int64 `json:"lists"`
+	Name      string  `json:"name"`
+	Conflict  string  `json:"conflict"`
+}
+
+// The contacts in the combined lists that are the same person. Contacts are
+// kept in the order of the lists they come from.
+type combinedContact struct {
+	Contacts []models.Contact
+	Lists    map[int64]bool
+}
+
+type contactsByNewest []models.Contact
+
+func (cn contactsByNewest) Len() int {
+	return len(cn)
+}
+
+func (cn contactsByNewest) Swap(i, j int) {
+	cn[i], cn[j] = cn[j], cn[i]
+}
+
+func (cn contactsByNewest) Less(i, j int) bool {
+	return cn[i].Updated.After(cn[j].Updated)
+}
+
+/*
+* Private methods
+ */
+
+// Contacts are the same person when they share a master contact or an email
+func contactMatchKeys(contact models.Contact) []string {
+	keys := []string{}
+	if contact.ParentContact != 0 {
+		keys = append(keys, "master:"+strconv.FormatInt(contact.ParentContact, 10))
+	}
+
+	email := strings.ToLower(strings.TrimSpace(contact.Email))
+	if email != "" {
+		keys = append(keys, "email:"+email)
+	}
+
+	if len(keys) == 0 {
+		keys = append(keys, "contact:"+strconv.FormatInt(contact.Id, 10))
+	}
+	return keys
+}
+
+// Groups the contacts of the lists by person. Contacts that share a key with
+// another contact are joined with it, so a contact with both an email and a
+// master contact can join the contacts that only have one of them.
+func groupContactsForLists(mediaLists []models.MediaList, contactsForLists [][]models.Contact) []*combinedContact {
+	contacts := []models.Contact{}
+	contactLists := []int64{}
+	for i := 0; i < len(contactsForLists); i++ {
+		for x := 0; x < len(contactsForLists[i]); x++ {
+			contacts = append(contacts, contactsForLists[i][x])
+			contactLists = append(contactLists, mediaLists[i].Id)
+		}
+	}
+
+	parents := make([]int, len(contacts))
+	firstWithKey := map[string]int{}
+	for i := 0; i < len(contacts); i++ {
+		parents[i] = i
+
+		keys := contactMatchKeys(contacts[i])
+		for x := 0; x < len(keys); x++ {
+			first, ok := firstWithKey[keys[x]]
+			if !ok {
+				firstWithKey[keys[x]] = i
+				continue
+			}
+
+			firstRoot := findParent(parents, first)
+			currentRoot := findParent(parents, i)
+			if firstRoot < currentRoot {
+				parents[currentRoot] = firstRoot
+			} else if currentRoot < firstRoot {
+				parents[firstRoot] = currentRoot
+			}
+		}
+	}
+
+	// Groups are kept in the order of their first contact
+	groups := []*combinedContact{}
+	groupsByRoot := map[int]*combinedContact{}
+	for i := 0; i < len(contacts); i++ {
+		root := findParent(parents, i)
+		group, ok := groupsByRoot[root]
+		if !ok {
+			group = &combinedContact{
+				Lists: map[int64]bool{},
+			}
+			groupsByRoot[root] = group
+			groups = append(groups, group)
+		}
+
+		group.Contacts = append(group.Contacts, contacts[i])
+		group.Lists[contactLists[i]] = true
+	}
+
+	return groups
+}
+
+func groupsForListOperation(operation string, mediaLists []models.MediaList, groups []*combinedContact) []*combinedContact {
+	selectedGroups := []*combinedContact{}
+	for i := 0; i < len(groups); i++ {
+		switch operation {
+		case listOperationUnion:
+			selectedGroups = append(selectedGroups, groups[i])
+		case listOperationIntersection:
+			if len(groups[i].Lists) == len(mediaLists) {
+				selectedGroups = append(selectedGroups, groups[i])
+			}
+		case listOperationDifference:
+			// Contacts of the first list that aren't in any of the others
+			if _, ok := groups[i].Lists[mediaLists[0].Id]; ok && len(groups[i].Lists) == 1 {
+				selectedGroups = append(selectedGroups, groups[i])
+			}
+		}
+	}
+	return selectedGroups
+}
+
+// Orders the contacts of a person so the one that wins conflicts is first
+func orderContactsForConflict(contacts []models.Contact, conflict string) []models.Contact {
+	orderedContacts := append([]models.Contact{}, contacts...)
+	switch conflict {
+	case listConflictLast:
+		for i, j := 0, len(orderedContacts)-1; i < j; i, j = i+1, j-1 {
+			orderedContacts[i], orderedContacts[j] = orderedContacts[j], orderedContacts[i]
+		}
+	case listConflictNewest:
+		sort.Stable(contactsByNewest(orderedContacts))
+	}
+	return orderedContacts
+}
+
+// Merges the contacts of a person into one. The winning contact is used as it
+// is, and custom fields and tags it doesn't have are filled in from the rest.
+func mergeCombinedContact(group *combinedContact, conflict string, fieldsMap []models.CustomFieldsMap) models.Contact {
+	orderedContacts := orderContactsForConflict(group.Contacts, conflict)
+	contact := orderedContacts[0]
+
+	customFieldValues := map[string]string{}
+	customFieldNames := []string{}
+	tags := []string{}
+	existingTags := map[string]bool{}
+	for i := 0; i < len(orderedContacts); i++ {
+		for x := 0; x < len(orderedContacts[i].CustomFields); x++ {
+			customField := orderedContacts[i].CustomFields[x]
+			if strings.TrimSpace(customField.Value) == "" {
+				continue
+			}
+
+			if _, ok := customFieldValues[customField.Name]; !ok {
+				customFieldValues[customField.Name] = customField.Value
+				customFieldNames = append(customFieldNames, customField.Name)
+			}
+		}
+
+		for x := 0; x < len(orderedContacts[i].Tags); x++ {
+			if _, ok := existingTags[orderedContacts[i].Tags[x]]; !ok {
+				existingTags[orderedContacts[i].Tags[x]] = true
+				tags = append(tags, orderedContacts[i].Tags[x])
+			}
+		}
+	}
+
+	customFields := map[string]models.CustomFieldsMap{}
+	for i := 0; i < len(fieldsMap); i++ {
+		if fieldsMap[i].CustomField && !fieldsMap[i].ReadOnly {
+			customFields[fieldsMap[i].Value] = fieldsMap[i]
+		}
+	}
+
+	contact.CustomFields = []models.CustomContactField{}
+	for i := 0; i < len(customFieldNames); i++ {
+		field, ok := customFields[customFieldNames[i]]
+		if !ok || field.ValidateValue(customFieldValues[customFieldNames[i]]) != nil {
+			continue
+		}
+
+		contact.CustomFields = append(contact.CustomFields, models.CustomContactField{
+			Name:  customFieldNames[i],
+			Value: customFieldValues[customFieldNames[i]],
+		})
+	}
+
+	contact.Tags = tags
+	return contact
+}
+
+func mergeFieldOptions(options []string, otherOptions []string) []string {
+	mergedOptions := append([]string{}, options...)
+	for i := 0; i < len(otherOptions); i++ {
+		if !fieldOptionInOptions(mergedOptions, otherOptions[i]) {
+			mergedOptions = append(mergedOptions, otherOptions[i])
+		}
+	}
+	return mergedOptions
+}
+
+func fieldOptionInOptions(options []string, option string) bool {
+	for i := 0; i < len(options); i++ {
+		if options[i] == option {
+			return true
+		}
+	}
+	return false
+}
+
+// Combines the columns of the lists. A field is only hidden if it is hidden
+// in every list, select fields get the options of every list, and fields
+// with different types in different lists become text fields.
+func combineFieldsMaps(operation string, mediaLists []models.MediaList) []models.CustomFieldsMap {
+	if operation == listOperationDifference {
+		return append([]models.CustomFieldsMap{}, mediaLists[0].FieldsMap...)
+	}
+
+	fieldsMap := []models.CustomFieldsMap{}
+	fieldPositions := map[string]int{}
+	for i := 0; i < len(mediaLists); i++ {
+		for x := 0; x < len(mediaLists[i].FieldsMap); x++ {
+			field := mediaLists[i].FieldsMap[x]
+
+			position, ok := fieldPositions[field.Value]
+			if !ok {
+				fieldPositions[field.Value] = len(fieldsMap)
+				fieldsMap = append(fieldsMap, field)
+				continue
+			}
+
+			fieldsMap[position].Hidden = fieldsMap[position].Hidden && field.Hidden
+			if !field.CustomField {
+				continue
+			}
+
+			if fieldsMap[position].Type == "" {
+				fieldsMap[position].Type = field.Type
+				fieldsMap[position].Options = field.Options
+			} else if field.Type != "" && field.Type != fieldsMap[position].Type {
+				fieldsMap[position].Type = models.FieldTypeText
+				fieldsMap[position].Options = nil
+			} else if field.Type == fieldsMap[position].Type {
+				fieldsMap[position].Options = mergeFieldOptions(fieldsMap[position].Options, field.Options)
+			}
+		}
+	}
+
+	return fieldsMap
+}
+
+/*
+* Public methods
+ */
+
+/*
+* Create methods
+ */
+
+// Makes a new list out of this list and the lists in "lists": the contacts in
+// any of them (union), in all of them (intersection), or in this list but
+// none of the others (difference). Contacts are matched by their master
+// contact or their email.
+func CombineMediaLists(c context.Context, r *http.Request, id string) (models.MediaList, interface{}, error) {
+	mediaList, _, err := GetMediaList(c, r, id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	user, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	buf, _ := ioutil.ReadAll(r.Body)
+	decoder := ffjson.NewDecoder()
+	var combineDetails combineListsDetails
+	err = decoder.Decode(buf, &combineDetails)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	if combineDetails.Operation != listOperationUnion && combineDetails.Operation != listOperationIntersection && combineDetails.Operation != listOperationDifference {
+		return models.MediaList{}, nil, errors.New("Operation has to be one of union, intersection or difference")
+	}
+
+	if combineDetails.Conflict == "" {
+		combineDetails.Conflict = listConflictFirst
+	}
+
+	if combineDetails.Conflict != listConflictFirst && combineDetails.Conflict != listConflictLast && combineDetails.Conflict != listConflictNewest {
+		return models.MediaList{}, nil, errors.New("Conflict has to be one of first, last or newest")
+	}
+
+	// Anyone who can see the lists can combine them into a list of their own
+	mediaLists := []models.MediaList{mediaList}
+	existingLists := map[int64]bool{
+		mediaList.Id: true,
+	}
+	for i := 0; i < len(combineDetails.Lists); i++ {
+		if _, ok := existingLists[combineDetails.Lists[i]]; ok {
+			continue
+		}
+
+		otherMediaList, err := getMediaList(c, r, combineDetails.Lists[i])
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return models.MediaList{}, nil, err
+		}
+
+		existingLists[otherMediaList.Id] = true
+		mediaLists = append(mediaLists, otherMediaList)
+	}
+
+	if len(mediaLists) < 2 {
+		return models.MediaList{}, nil, errors.New("At least two lists are needed")
+	}
+
+	contactsForLists := [][]models.Contact{}
+	for i := 0; i < len(mediaLists); i++ {
+		contacts, err := getContactsForMediaList(c, mediaLists[i])
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return models.MediaList{}, nil, err
+		}
+		contactsForLists = append(contactsForLists, contacts)
+	}
+
+	groups := groupContactsForLists(mediaLists, contactsForLists)
+	groups = groupsForListOperation(combineDetails.Operation, mediaLists, groups)
+
+	combinedMediaList := models.MediaList{}
+	combinedMediaList.Name = combineDetails.Name
+	if combinedMediaList.Name == "" {
+		listNames := []string{}
+		for i := 0; i < len(mediaLists); i++ {
+			listNames = append(listNames, mediaLists[i].Name)
+		}
+		combinedMediaList.Name = strings.Title(combineDetails.Operation) + " of " + strings.Join(listNames, ", ")
+	}
+
+	combinedMediaList.Client = mediaList.Client
+	combinedMediaList.ClientId = mediaList.ClientId
+	combinedMediaList.FieldsMap = combineFieldsMaps(combineDetails.Operation, mediaLists)
+	combinedMediaList.TeamId = user.TeamId
+	combinedMediaList.Contacts = []int64{}
+
+	err = validateFieldsMap(combinedMediaList.FieldsMap)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	_, err = combinedMediaList.Create(c, r, user)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	contacts := []models.Contact{}
+	for i := 0; i < len(groups); i++ {
+		contacts = append(contacts, mergeCombinedContact(groups[i], combineDetails.Conflict, combinedMediaList.FieldsMap))
+	}
+
+	// The merged contacts keep the ids they were merged from until they are
+	// copied so their feeds are copied with them
+	if len(contacts) > 0 {
+		newContacts, err := BatchCreateContactsForDuplicateList(c, r, contacts, combinedMediaList.Id)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return models.MediaList{}, nil, err
+		}
+		combinedMediaList.Contacts = newContacts
+	}
+
+	err = saveMediaListAndSync(c, &combinedMediaList, sync.ActionCreate, combinedMediaList.Contacts)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	combinedMediaList.Role = models.ListRoleOwner
+	return combinedMediaList, nil, nil
+}
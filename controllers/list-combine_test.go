@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/news-ai/tabulae/models"
+)
+
+func TestGroupContactsForLists(t *testing.T) {
+	mediaList := func(id int64) models.MediaList {
+		mediaList := models.MediaList{}
+		mediaList.Id = id
+		return mediaList
+	}
+
+	contact := func(id int64, email string, parentContact int64) models.Contact {
+		contact := models.Contact{Email: email, ParentContact: parentContact}
+		contact.Id = id
+		return contact
+	}
+
+	mediaLists := []models.MediaList{mediaList(10), mediaList(20), mediaList(30)}
+
+	tests := []struct {
+		name             string
+		contactsForLists [][]models.Contact
+		want             [][]int64
+		wantLists        []map[int64]bool
+	}{
+		{
+			name: "same email",
+			contactsForLists: [][]models.Contact{
+				{contact(1, "jane@example.com", 0)},
+				{contact(2, " Jane@Example.com", 0)},
+				{contact(3, "john@example.com", 0)},
+			},
+			want:      [][]int64{{1, 2}, {3}},
+			wantLists: []map[int64]bool{{10: true, 20: true}, {30: true}},
+		},
+		{
+			name: "same master contact",
+			contactsForLists: [][]models.Contact{
+				{contact(1, "jane@example.com", 100)},
+				{contact(2, "jane@work.example.com", 100)},
+				{},
+			},
+			want:      [][]int64{{1, 2}},
+			wantLists: []map[int64]bool{{10: true, 20: true}},
+		},
+		{
+			name: "joined through a contact with both",
+			contactsForLists: [][]models.Contact{
+				{contact(1, "jane@example.com", 0)},
+				{contact(2, "", 100)},
+				{contact(3, "jane@example.com", 100)},
+			},
+			want:      [][]int64{{1, 2, 3}},
+			wantLists: []map[int64]bool{{10: true, 20: true, 30: true}},
+		},
+		{
+			name: "contacts with nothing to match on",
+			contactsForLists: [][]models.Contact{
+				{contact(1, "", 0)},
+				{contact(2, "", 0)},
+				{contact(1, "", 0)},
+			},
+			want:      [][]int64{{1, 1}, {2}},
+			wantLists: []map[int64]bool{{10: true, 30: true}, {20: true}},
+		},
+	}
+
+	for _, test := range tests {
+		groups := groupContactsForLists(mediaLists, test.contactsForLists)
+		ids := [][]int64{}
+		lists := []map[int64]bool{}
+		for i := 0; i < len(groups); i++ {
+			groupIds := []int64{}
+			for x := 0; x < len(groups[i].Contacts); x++ {
+				groupIds = append(groupIds, groups[i].Contacts[x].Id)
+			}
+			ids = append(ids, groupIds)
+			lists = append(lists, groups[i].Lists)
+		}
+		if !reflect.DeepEqual(ids, test.want) || !reflect.DeepEqual(lists, test.wantLists) {
+			t.Errorf("%v: grouped %v in %v, want %v in %v", test.name, ids, lists, test.want, test.wantLists)
+		}
+	}
+}
+
+func TestGroupsForListOperation(t *testing.T) {
+	mediaLists := []models.MediaList{{}, {}}
+	mediaLists[0].Id = 10
+	mediaLists[1].Id = 20
+
+	onlyFirst := &combinedContact{Lists: map[int64]bool{10: true}}
+	both := &combinedContact{Lists: map[int64]bool{10: true, 20: true}}
+	onlySecond := &combinedContact{Lists: map[int64]bool{20: true}}
+	groups := []*combinedContact{onlyFirst, both, onlySecond}
+
+	tests := []struct {
+		operation string
+		want      []*combinedContact
+	}{
+		{operation: listOperationUnion, want: []*combinedContact{onlyFirst, both, onlySecond}},
+		{operation: listOperationIntersection, want: []*combinedContact{both}},
+		{operation: listOperationDifference, want: []*combinedContact{onlyFirst}},
+	}
+
+	for _, test := range tests {
+		selectedGroups := groupsForListOperation(test.operation, mediaLists, groups)
+		if !reflect.DeepEqual(selectedGroups, test.want) {
+			t.Errorf("%v: selected %v groups, want %v", test.operation, len(selectedGroups), len(test.want))
+		}
+	}
+}
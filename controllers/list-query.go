@@ -0,0 +1,436 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+
+	gcontext "github.com/gorilla/context"
+	"github.com/qedus/nds"
+
+	"github.com/news-ai/tabulae/models"
+)
+
+/*
+* Private
+ */
+
+// Fields that can be filtered and sorted on without being a column of the
+// media list, and their types
+var contactQueryFields = map[string]string{
+	"tags":          models.FieldTypeText,
+	"employers":     models.FieldTypeText,
+	"pastemployers": models.FieldTypeText,
+	"emailbounced":  models.FieldTypeBoolean,
+	"unsubscribed":  models.FieldTypeBoolean,
+	"lastcontacted": models.FieldTypeDate,
+}
+
+// Data from outside of the contacts that some fields need
+type contactQueryData struct {
+	unsubscribedEmails map[string]bool
+}
+
+type contactsByQuery struct {
+	contacts   []models.Contact
+	values     map[int64]string
+	field      models.CustomFieldsMap
+	descending bool
+}
+
+func (cq contactsByQuery) Len() int {
+	return len(cq.contacts)
+}
+
+func (cq contactsByQuery) Swap(i, j int) {
+	cq.contacts[i], cq.contacts[j] = cq.contacts[j], cq.contacts[i]
+}
+
+func (cq contactsByQuery) Less(i, j int) bool {
+	return compareContactPosition(cq.field, cq.descending, cq.values[cq.contacts[i].Id], cq.contacts[i].Id, cq.values[cq.contacts[j].Id], cq.contacts[j].Id) < 0
+}
+
+/*
+* Private methods
+ */
+
+// Orders contacts by the value of the sort field, then by id so that every
+// contact has a fixed position for cursors
+func compareContactPosition(field models.CustomFieldsMap, descending bool, firstValue string, firstId int64, secondValue string, secondId int64) int {
+	compare := field.CompareValues(firstValue, secondValue)
+	if descending {
+		compare = -compare
+	}
+
+	if compare != 0 {
+		return compare
+	}
+
+	if firstId < secondId {
+		return -1
+	} else if firstId > secondId {
+		return 1
+	}
+	return 0
+}
+
+func encodeContactCursor(value string, id int64) string {
+	return base64.URLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10) + ":" + value))
+}
+
+func decodeContactCursor(cursor string) (string, int64, error) {
+	decodedCursor, err := base64.URLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", 0, errors.New("Cursor is not valid")
+	}
+
+	cursorParts := strings.SplitN(string(decodedCursor), ":", 2)
+	if len(cursorParts) != 2 {
+		return "", 0, errors.New("Cursor is not valid")
+	}
+
+	id, err := strconv.ParseInt(cursorParts[0], 10, 64)
+	if err != nil {
+		return "", 0, errors.New("Cursor is not valid")
+	}
+
+	return cursorParts[1], id, nil
+}
+
+func contactQueryFromRequest(r *http.Request) (models.ContactQuery, error) {
+	contactQuery := models.ContactQuery{
+		Sort: r.URL.Query().Get("sort"),
+	}
+
+	filters := r.URL.Query()["filter"]
+	for i := 0; i < len(filters); i++ {
+		contactFilter, err := models.ParseContactFilter(filters[i])
+		if err != nil {
+			return models.ContactQuery{}, err
+		}
+		contactQuery.Filters = append(contactQuery.Filters, contactFilter)
+	}
+
+	return contactQuery, nil
+}
+
+func getContactQueryField(mediaList models.MediaList, name string) (models.CustomFieldsMap, error) {
+	field, ok := getFieldsMapField(mediaList, name)
+	if ok {
+		return field, nil
+	}
+
+	if fieldType, ok := contactQueryFields[name]; ok {
+		return models.CustomFieldsMap{
+			Name:     name,
+			Value:    name,
+			Type:     fieldType,
+			ReadOnly: name == "lastcontacted",
+		}, nil
+	}
+
+	// Engagement can be queried on lists from before it was a column
+	if fieldType, ok := models.EngagementFields[name]; ok {
+		return models.CustomFieldsMap{
+			Name:        name,
+			Value:       name,
+			CustomField: true,
+			Type:        fieldType,
+			ReadOnly:    true,
+		}, nil
+	}
+
+	// Smart lists query contacts from every list, so their custom fields
+	// don't have to be columns of the smart list
+	if mediaList.IsSmartList {
+		return models.CustomFieldsMap{
+			Name:        name,
+			Value:       name,
+			CustomField: true,
+			Type:        models.FieldTypeText,
+		}, nil
+	}
+
+	return models.CustomFieldsMap{}, errors.New("List does not have a field " + name)
+}
+
+func getUnsubscribedEmails(c context.Context, userId int64) (map[string]bool, error) {
+	unsubscribedEmails := map[string]bool{}
+
+	ks, err := datastore.NewQuery("ContactUnsubscribe").Filter("CreatedBy =", userId).Filter("Unsubscribed =", true).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return unsubscribedEmails, err
+	}
+
+	unsubscribedContacts := make([]models.ContactUnsubscribe, len(ks))
+	err = nds.GetMulti(c, ks, unsubscribedContacts)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return unsubscribedEmails, err
+	}
+
+	for i := 0; i < len(unsubscribedContacts); i++ {
+		unsubscribedEmails[strings.ToLower(unsubscribedContacts[i].Email)] = true
+	}
+
+	return unsubscribedEmails, nil
+}
+
+// Loads the data that the fields being filtered or sorted on need
+func getContactQueryData(c context.Context, userId int64, fields []models.CustomFieldsMap) contactQueryData {
+	data := contactQueryData{
+		unsubscribedEmails: map[string]bool{},
+	}
+
+	unsubscribedLoaded := false
+	for i := 0; i < len(fields); i++ {
+		switch fields[i].Value {
+		case "unsubscribed":
+			if !unsubscribedLoaded {
+				data.unsubscribedEmails, _ = getUnsubscribedEmails(c, userId)
+				unsubscribedLoaded = true
+			}
+		}
+	}
+
+	return data
+}
+
+func contactQueryValue(contact models.Contact, field string, data contactQueryData) string {
+	switch field {
+	case "tags":
+		return strings.Join(contact.Tags, ",")
+	case "emailbounced":
+		return strconv.FormatBool(contact.EmailBounced)
+	case "unsubscribed":
+		_, ok := data.unsubscribedEmails[strings.ToLower(contact.Email)]
+		return strconv.FormatBool(contact.Email != "" && ok)
+	}
+	return contact.FieldValue(field)
+}
+
+func matchesContactFilter(field models.CustomFieldsMap, filter models.ContactFilter, value string) bool {
+	switch filter.Operator {
+	case "eq":
+		return field.CompareValues(value, filter.Value) == 0
+	case "ne":
+		return field.CompareValues(value, filter.Value) != 0
+	case "contains":
+		return strings.Contains(strings.ToLower(value), strings.ToLower(filter.Value))
+	case "empty":
+		return value == ""
+	case "notempty":
+		return value != ""
+	case "gt", "after":
+		return value != "" && field.CompareValues(value, filter.Value) > 0
+	case "gte":
+		return value != "" && field.CompareValues(value, filter.Value) >= 0
+	case "lt", "before":
+		return value != "" && field.CompareValues(value, filter.Value) < 0
+	case "lte":
+		return value != "" && field.CompareValues(value, filter.Value) <= 0
+	case "in":
+		// Fields with many values match when any of them is in the filter
+		values := []string{value}
+		if filter.Field == "tags" || filter.Field == "employers" || filter.Field == "pastemployers" {
+			values = strings.Split(value, ",")
+		}
+
+		for i := 0; i < len(values); i++ {
+			for x := 0; x < len(filter.Values); x++ {
+				if values[i] != "" && field.CompareValues(values[i], filter.Values[x]) == 0 {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Read only fields are only filled in by ContactsToDefaultFields, and only
+// when they are shown. This fills them in on copies of the contacts so the
+// contacts that are returned aren't changed.
+func contactsWithReadOnlyFields(c context.Context, r *http.Request, mediaList models.MediaList, contacts []models.Contact, fields []models.CustomFieldsMap) []models.Contact {
+	readOnlyMediaList := mediaList
+	readOnlyMediaList.FieldsMap = []models.CustomFieldsMap{}
+	for i := 0; i < len(fields); i++ {
+		if fields[i].ReadOnly {
+			field := fields[i]
+			field.Hidden = false
+			readOnlyMediaList.FieldsMap = append(readOnlyMediaList.FieldsMap, field)
+		}
+	}
+
+	if len(readOnlyMediaList.FieldsMap) == 0 {
+		return contacts
+	}
+
+	contactsCopy := make([]models.Contact, len(contacts))
+	for i := 0; i < len(contacts); i++ {
+		contactsCopy[i] = contacts[i]
+		contactsCopy[i].CustomFields = append([]models.CustomContactField{}, contacts[i].CustomFields...)
+	}
+
+	contactsCopy, err := ContactsToDefaultFields(c, r, contactsCopy, readOnlyMediaList)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+	}
+	return contactsCopy
+}
+
+// Filters and sorts contacts of a media list. Every contact that is returned
+// has a cursor that can be used to get the contacts after it.
+func evaluateContactQuery(c context.Context, r *http.Request, mediaList models.MediaList, contacts []models.Contact, contactQuery models.ContactQuery) ([]models.Contact, error) {
+	contactQuery.Format()
+	sortFieldName, descending := contactQuery.SortField()
+
+	fields := []models.CustomFieldsMap{}
+	for i := 0; i < len(contactQuery.Filters); i++ {
+		err := contactQuery.Filters[i].Validate()
+		if err != nil {
+			return []models.Contact{}, err
+		}
+
+		field, err := getContactQueryField(mediaList, contactQuery.Filters[i].Field)
+		if err != nil {
+			return []models.Contact{}, err
+		}
+		fields = append(fields, field)
+	}
+
+	sortField := models.CustomFieldsMap{}
+	if sortFieldName != "" {
+		var err error
+		sortField, err = getContactQueryField(mediaList, sortFieldName)
+		if err != nil {
+			return []models.Contact{}, err
+		}
+	}
+
+	data := getContactQueryData(c, mediaList.CreatedBy, append(fields, sortField))
+	valueContacts := contactsWithReadOnlyFields(c, r, mediaList, contacts, append(fields, sortField))
+
+	filteredContacts := []models.Contact{}
+	sortValues := map[int64]string{}
+	for i := 0; i < len(contacts); i++ {
+		matches := true
+		for x := 0; x < len(contactQuery.Filters) && matches; x++ {
+			value := contactQueryValue(valueContacts[i], fields[x].Value, data)
+			matches = matchesContactFilter(fields[x], contactQuery.Filters[x], value)
+		}
+
+		if matches {
+			sortValues[contacts[i].Id] = contactQueryValue(valueContacts[i], sortField.Value, data)
+			filteredContacts = append(filteredContacts, contacts[i])
+		}
+	}
+
+	// Without a sort field contacts stay in the order of the list
+	if sortFieldName != "" {
+		sort.Stable(contactsByQuery{
+			contacts:   filteredContacts,
+			values:     sortValues,
+			field:      sortField,
+			descending: descending,
+		})
+	}
+
+	for i := 0; i < len(filteredContacts); i++ {
+		filteredContacts[i].Cursor = encodeContactCursor(sortValues[filteredContacts[i].Id], filteredContacts[i].Id)
+	}
+
+	return filteredContacts, nil
+}
+
+// Position in the filtered contacts of the first contact after a cursor
+func contactCursorPosition(mediaList models.MediaList, contacts []models.Contact, contactQuery models.ContactQuery, cursor string) (int, error) {
+	cursorValue, cursorId, err := decodeContactCursor(cursor)
+	if err != nil {
+		return 0, err
+	}
+
+	sortFieldName, descending := contactQuery.SortField()
+	if sortFieldName == "" {
+		for i := 0; i < len(contacts); i++ {
+			if contacts[i].Id == cursorId {
+				return i + 1, nil
+			}
+		}
+		return 0, errors.New("Cursor is no longer valid")
+	}
+
+	sortField, err := getContactQueryField(mediaList, sortFieldName)
+	if err != nil {
+		return 0, err
+	}
+
+	// Contacts are already sorted so this finds the first one past the cursor,
+	// even if the contact of the cursor has since changed or been removed
+	return sort.Search(len(contacts), func(i int) bool {
+		value, id, _ := decodeContactCursor(contacts[i].Cursor)
+		return compareContactPosition(sortField, descending, value, id, cursorValue, cursorId) > 0
+	}), nil
+}
+
+func queryContactsForList(c context.Context, r *http.Request, mediaList models.MediaList, contactQuery models.ContactQuery, withFacets bool) ([]models.Contact, interface{}, int, int, error) {
+	contacts, err := getContactsForMediaList(c, mediaList)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Contact{}, nil, 0, 0, err
+	}
+
+	contacts, err = evaluateContactQuery(c, r, mediaList, contacts, contactQuery)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Contact{}, nil, 0, 0, err
+	}
+
+	// Facets are counted over every contact that matches, before the page
+	// is taken
+	facets := ContactFacets{}
+	if withFacets {
+		facets = countContactFacets(c, mediaList, contacts)
+	}
+
+	total := len(contacts)
+	limit := gcontext.Get(r, "limit").(int)
+	startPosition := gcontext.Get(r, "offset").(int)
+
+	cursor := r.URL.Query().Get("cursor")
+	if cursor != "" {
+		startPosition, err = contactCursorPosition(mediaList, contacts, contactQuery, cursor)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return []models.Contact{}, nil, 0, 0, err
+		}
+	}
+
+	if startPosition > total {
+		startPosition = total
+	}
+
+	endPosition := startPosition + limit
+	if endPosition > total {
+		endPosition = total
+	}
+
+	contacts, err = ContactsToDefaultFields(c, r, contacts[startPosition:endPosition], mediaList)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+	}
+
+	publications := contactsToPublications(c, contacts)
+	if withFacets {
+		return contacts, includeContactFacets(publications, facets), len(contacts), total, nil
+	}
+	return contacts, publications, len(contacts), total, nil
+}
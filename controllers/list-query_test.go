@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/news-ai/tabulae/models"
+)
+
+func TestContactQueryFromRequest(t *testing.T) {
+	tests := []struct {
+		url     string
+		want    models.ContactQuery
+		wantErr bool
+	}{
+		{
+			url:  "/lists/1/contacts?sort=-followers",
+			want: models.ContactQuery{Sort: "-followers"},
+		},
+		{
+			url: "/lists/1/contacts?filter=beat:eq:Tech:Food&filter=tags:in:vip,%20press,&filter=email:empty",
+			want: models.ContactQuery{
+				Filters: []models.ContactFilter{
+					{Field: "beat", Operator: "eq", Value: "Tech:Food"},
+					{Field: "tags", Operator: "in", Value: "vip, press,", Values: []string{"vip", "press"}},
+					{Field: "email", Operator: "empty"},
+				},
+			},
+		},
+		{url: "/lists/1/contacts?filter=beat", wantErr: true},
+		{url: "/lists/1/contacts?filter=beat:like:Tech", wantErr: true},
+		{url: "/lists/1/contacts?filter=tags:in:,", wantErr: true},
+	}
+
+	for _, test := range tests {
+		r, err := http.NewRequest("GET", test.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		contactQuery, err := contactQueryFromRequest(r)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%v: error is %v, want an error %v", test.url, err, test.wantErr)
+			continue
+		}
+		if !test.wantErr && !reflect.DeepEqual(contactQuery, test.want) {
+			t.Errorf("%v: parsed %+v, want %+v", test.url, contactQuery, test.want)
+		}
+	}
+}
+
+func TestMatchesContactFilter(t *testing.T) {
+	number := models.CustomFieldsMap{Value: "followers", Type: models.FieldTypeNumber}
+	text := models.CustomFieldsMap{Value: "beat", Type: models.FieldTypeText}
+	tags := models.CustomFieldsMap{Value: "tags", Type: models.FieldTypeText}
+
+	tests := []struct {
+		name   string
+		field  models.CustomFieldsMap
+		filter models.ContactFilter
+		value  string
+		want   bool
+	}{
+		{name: "eq ignores case", field: text, filter: models.ContactFilter{Operator: "eq", Value: "tech"}, value: "Tech", want: true},
+		{name: "ne", field: text, filter: models.ContactFilter{Operator: "ne", Value: "tech"}, value: "Food", want: true},
+		{name: "contains", field: text, filter: models.ContactFilter{Operator: "contains", Value: "ECH"}, value: "Fintech", want: true},
+		{name: "empty", field: text, filter: models.ContactFilter{Operator: "empty"}, value: "", want: true},
+		{name: "notempty", field: text, filter: models.ContactFilter{Operator: "notempty"}, value: "", want: false},
+		{name: "gt compares numbers", field: number, filter: models.ContactFilter{Operator: "gt", Value: "9"}, value: "10", want: true},
+		{name: "gt skips empty values", field: number, filter: models.ContactFilter{Operator: "gt", Value: "9"}, value: "", want: false},
+		{name: "lte", field: number, filter: models.ContactFilter{Operator: "lte", Value: "1,000"}, value: "1000", want: true},
+		{name: "in", field: text, filter: models.ContactFilter{Field: "beat", Operator: "in", Values: []string{"food", "tech"}}, value: "Tech", want: true},
+		{name: "in any of the tags", field: tags, filter: models.ContactFilter{Field: "tags", Operator: "in", Values: []string{"vip"}}, value: "press,vip", want: true},
+		{name: "in none of the tags", field: tags, filter: models.ContactFilter{Field: "tags", Operator: "in", Values: []string{"vip"}}, value: "press", want: false},
+	}
+
+	for _, test := range tests {
+		matches := matchesContactFilter(test.field, test.filter, test.value)
+		if matches != test.want {
+			t.Errorf("%v: matches is %v, want %v", test.name, matches, test.want)
+		}
+	}
+}
+
+func TestEvaluateContactQuery(t *testing.T) {
+	mediaList := models.MediaList{
+		FieldsMap: []models.CustomFieldsMap{
+			{Value: "firstname", Type: models.FieldTypeText},
+			{Value: "followers", CustomField: true, Type: models.FieldTypeNumber},
+		},
+	}
+
+	contact := func(id int64, firstName string, followers string, tags []string) models.Contact {
+		contact := models.Contact{FirstName: firstName, Tags: tags}
+		if followers != "" {
+			contact.CustomFields = []models.CustomContactField{{Name: "followers", Value: followers}}
+		}
+		contact.Id = id
+		return contact
+	}
+
+	contacts := []models.Contact{
+		contact(1, "Jane", "120", []string{"vip"}),
+		contact(2, "John", "9", nil),
+		contact(3, "Janet", "", []string{"vip", "press"}),
+		contact(4, "Jim", "120", []string{"press"}),
+	}
+
+	tests := []struct {
+		name         string
+		contactQuery models.ContactQuery
+		want         []int64
+		wantErr      bool
+	}{
+		{
+			name:         "no query keeps the order of the list",
+			contactQuery: models.ContactQuery{},
+			want:         []int64{1, 2, 3, 4},
+		},
+		{
+			name:         "sorted by number, then by id",
+			contactQuery: models.ContactQuery{Sort: "followers"},
+			want:         []int64{2, 1, 4, 3},
+		},
+		{
+			name: "filtered and sorted",
+			contactQuery: models.ContactQuery{
+				Filters: []models.ContactFilter{{Field: "tags", Operator: "in", Value: "vip,press"}, {Field: "followers", Operator: "notempty"}},
+				Sort:    "firstname",
+			},
+			want: []int64{1, 4},
+		},
+		{
+			name:         "field the list doesn't have",
+			contactQuery: models.ContactQuery{Sort: "region"},
+			wantErr:      true,
+		},
+	}
+
+	for _, test := range tests {
+		filteredContacts, err := evaluateContactQuery(nil, nil, mediaList, contacts, test.contactQuery)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%v: error is %v, want an error %v", test.name, err, test.wantErr)
+			continue
+		}
+		if test.wantErr {
+			continue
+		}
+
+		ids := []int64{}
+		for i := 0; i < len(filteredContacts); i++ {
+			ids = append(ids, filteredContacts[i].Id)
+		}
+		if !reflect.DeepEqual(ids, test.want) {
+			t.Errorf("%v: got %v, want %v", test.name, ids, test.want)
+			continue
+		}
+
+		// Every contact continues from where the one before it is
+		for i := 0; i < len(filteredContacts); i++ {
+			position, err := contactCursorPosition(mediaList, filteredContacts, test.contactQuery, filteredContacts[i].Cursor)
+			if err != nil || position != i+1 {
+				t.Errorf("%v: cursor of %v is at %v (%v), want %v", test.name, filteredContacts[i].Id, position, err, i+1)
+			}
+		}
+	}
+}
+
+func TestDecodeContactCursor(t *testing.T) {
+	value, id, err := decodeContactCursor(encodeContactCursor("Jane: Doe", 5))
+	if err != nil || value != "Jane: Doe" || id != 5 {
+		t.Errorf("decoded %q and %v (%v), want %q and %v", value, id, err, "Jane: Doe", 5)
+	}
+
+	cursors := []string{"not a cursor!", "SmFuZQ==", "eDpKYW5l"}
+	for i := 0; i < len(cursors); i++ {
+		_, _, err := decodeContactCursor(cursors[i])
+		if err == nil {
+			t.Errorf("%q decoded without an error", cursors[i])
+		}
+	}
+}
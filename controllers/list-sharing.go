@@ -0,0 +1,337 @@
+package controllers
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+
+	"github.com/pquerna/ffjson/ffjson"
+	"github.com/qedus/nds"
+
+	"github.com/news-ai/api/controllers"
+	apiModels "github.com/news-ai/api/models"
+
+	"github.com/news-ai/tabulae/emails"
+	"github.com/news-ai/tabulae/models"
+	"github.com/news-ai/tabulae/sync"
+)
+
+/*
+* Private
+ */
+
+type shareListDetails struct {
+	UserId  int64  `json:"userid"`
+	TeamId  int64  `json:"teamid"`
+	Email   string `json:"email"`
+	Role    string `json:"role"`
+	Message string `json:"message"`
+}
+
+/*
+* Private methods
+ */
+
+// Contacts can be changed by the editors of their list. Access to master
+// contacts is checked when they are fetched.
+func requireContactRole(c context.Context, r *http.Request, contact models.Contact, role string) error {
+	if contact.IsMasterContact {
+		return nil
+	}
+
+	mediaList, err := getMediaList(c, r, contact.ListId)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	if !models.ListRoleAtLeast(mediaList.Role, role) {
+		return errors.New("You don't have permissions to edit these objects")
+	}
+	return nil
+}
+
+func isSameListShare(share models.ListShare, otherShare models.ListShare) bool {
+	if share.UserId != 0 || otherShare.UserId != 0 {
+		return share.UserId == otherShare.UserId
+	}
+
+	if share.TeamId != 0 || otherShare.TeamId != 0 {
+		return share.TeamId == otherShare.TeamId
+	}
+
+	return strings.ToLower(share.Email) == strings.ToLower(otherShare.Email)
+}
+
+func filterMediaListsSharedWith(c context.Context, field string, value interface{}) ([]models.MediaList, error) {
+	ks, err := datastore.NewQuery("MediaList").Filter(field, value).Filter("IsDeleted =", false).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.MediaList{}, err
+	}
+
+	mediaLists := make([]models.MediaList, len(ks))
+	err = nds.GetMulti(c, ks, mediaLists)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.MediaList{}, err
+	}
+
+	for i := 0; i < len(mediaLists); i++ {
+		mediaLists[i].Format(ks[i], "lists")
+	}
+
+	return mediaLists, nil
+}
+
+// Ids of the lists the user has a role on: the ones they created, the ones
+// of their team and the ones shared with them. Public lists are left out,
+// everyone can view those.
+func mediaListIdsForUser(c context.Context, user apiModels.User) ([]int64, error) {
+	queries := []*datastore.Query{
+		datastore.NewQuery("MediaList").Filter("CreatedBy =", user.Id),
+		datastore.NewQuery("MediaList").Filter("Shares.UserId =", user.Id),
+	}
+
+	if user.Email != "" {
+		queries = append(queries, datastore.NewQuery("MediaList").Filter("Shares.Email =", strings.ToLower(user.Email)))
+	}
+
+	if user.TeamId != 0 {
+		queries = append(queries, datastore.NewQuery("MediaList").Filter("TeamId =", user.TeamId))
+		queries = append(queries, datastore.NewQuery("MediaList").Filter("Shares.TeamId =", user.TeamId))
+	}
+
+	mediaListIds := []int64{}
+	existingLists := map[int64]bool{}
+	for i := 0; i < len(queries); i++ {
+		ks, err := queries[i].Filter("IsDeleted =", false).KeysOnly().GetAll(c, nil)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return []int64{}, err
+		}
+
+		for x := 0; x < len(ks); x++ {
+			if _, ok := existingLists[ks[x].IntID()]; !ok {
+				existingLists[ks[x].IntID()] = true
+				mediaListIds = append(mediaListIds, ks[x].IntID())
+			}
+		}
+	}
+
+	return mediaListIds, nil
+}
+
+/*
+* Public methods
+ */
+
+/*
+* Get methods
+ */
+
+// Lists other people shared with the user, directly, with their team or by
+// inviting their email
+func GetSharedMediaLists(c context.Context, r *http.Request) ([]models.MediaList, interface{}, int, int, error) {
+	user, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.MediaList{}, nil, 0, 0, err
+	}
+
+	mediaLists, err := filterMediaListsSharedWith(c, "Shares.UserId =", user.Id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.MediaList{}, nil, 0, 0, err
+	}
+
+	if user.Email != "" {
+		emailMediaLists, err := filterMediaListsSharedWith(c, "Shares.Email =", strings.ToLower(user.Email))
+		if err == nil {
+			mediaLists = append(mediaLists, emailMediaLists...)
+		}
+	}
+
+	if user.TeamId != 0 {
+		teamMediaLists, err := filterMediaListsSharedWith(c, "Shares.TeamId =", user.TeamId)
+		if err == nil {
+			mediaLists = append(mediaLists, teamMediaLists...)
+		}
+	}
+
+	sharedMediaLists := []models.MediaList{}
+	existingLists := map[int64]bool{}
+	for i := 0; i < len(mediaLists); i++ {
+		if _, ok := existingLists[mediaLists[i].Id]; ok || mediaLists[i].CreatedBy == user.Id {
+			continue
+		}
+
+		existingLists[mediaLists[i].Id] = true
+		mediaLists[i].Role = mediaLists[i].RoleForUser(user)
+		sharedMediaLists = append(sharedMediaLists, mediaLists[i])
+	}
+
+	return sharedMediaLists, nil, len(sharedMediaLists), 0, nil
+}
+
+/*
+* Update methods
+ */
+
+// Shares a list with a user, a team, or an email that gets an invitation.
+// Sharing with someone the list is already shared with changes their role.
+func ShareMediaList(c context.Context, r *http.Request, id string) (models.MediaList, interface{}, error) {
+	mediaList, _, err := GetMediaList(c, r, id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	err = requireMediaListRole(mediaList, models.ListRoleOwner)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	currentUser, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	buf, _ := ioutil.ReadAll(r.Body)
+	decoder := ffjson.NewDecoder()
+	var shareDetails shareListDetails
+	err = decoder.Decode(buf, &shareDetails)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	if !models.IsValidListRole(shareDetails.Role) {
+		return models.MediaList{}, nil, errors.New("Role has to be one of viewer, editor or owner")
+	}
+
+	share := models.ListShare{
+		UserId:    shareDetails.UserId,
+		TeamId:    shareDetails.TeamId,
+		Email:     strings.ToLower(strings.TrimSpace(shareDetails.Email)),
+		Role:      shareDetails.Role,
+		InvitedBy: currentUser.Id,
+	}
+
+	if share.UserId == 0 && share.TeamId == 0 && share.Email == "" {
+		return models.MediaList{}, nil, errors.New("Lists have to be shared with a user, a team or an email")
+	}
+
+	// People who already have an account are shared with directly
+	if share.UserId == 0 && share.Email != "" {
+		existingUser, err := controllers.GetUserByEmail(c, share.Email)
+		if err == nil && existingUser.Id != 0 {
+			share.UserId = existingUser.Id
+		}
+	}
+
+	if share.UserId != 0 && share.Email == "" {
+		sharedUser, _, err := controllers.GetUserById(c, r, share.UserId)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return models.MediaList{}, nil, errors.New("No user by this id")
+		}
+		share.Email = strings.ToLower(sharedUser.Email)
+	}
+
+	if share.UserId == mediaList.CreatedBy {
+		return models.MediaList{}, nil, errors.New("The list already belongs to this user")
+	}
+
+	isNewShare := true
+	for i := 0; i < len(mediaList.Shares); i++ {
+		if isSameListShare(mediaList.Shares[i], share) {
+			mediaList.Shares[i].Role = share.Role
+			isNewShare = false
+		}
+	}
+
+	if isNewShare {
+		mediaList.Shares = append(mediaList.Shares, share)
+	}
+
+	err = saveMediaListAndSync(c, &mediaList, sync.ActionUpdate, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	// Teams aren't sent an email, everyone else is told about the list
+	if isNewShare && share.Email != "" {
+		err = emails.ShareList(c, currentUser, share.Email, mediaList.Name, mediaList.Id, share.Role, shareDetails.Message)
+		if err != nil {
+			// The list is still shared even if the email doesn't go out
+			log.Errorf(c, "%v", err)
+		}
+	}
+
+	return mediaList, nil, nil
+}
+
+// Stops sharing a list with a user, team or email
+func UnshareMediaList(c context.Context, r *http.Request, id string) (models.MediaList, interface{}, error) {
+	mediaList, _, err := GetMediaList(c, r, id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	currentUser, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	buf, _ := ioutil.ReadAll(r.Body)
+	decoder := ffjson.NewDecoder()
+	var shareDetails shareListDetails
+	err = decoder.Decode(buf, &shareDetails)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	share := models.ListShare{
+		UserId: shareDetails.UserId,
+		TeamId: shareDetails.TeamId,
+		Email:  strings.TrimSpace(shareDetails.Email),
+	}
+
+	// Owners can remove anyone, everyone else can only leave the list
+	if mediaList.Role != models.ListRoleOwner && !(share.UserId == currentUser.Id && share.TeamId == 0) {
+		return models.MediaList{}, nil, errors.New("Forbidden")
+	}
+
+	shares := []models.ListShare{}
+	for i := 0; i < len(mediaList.Shares); i++ {
+		if !isSameListShare(mediaList.Shares[i], share) {
+			shares = append(shares, mediaList.Shares[i])
+		}
+	}
+
+	if len(shares) == len(mediaList.Shares) {
+		return models.MediaList{}, nil, errors.New("The list is not shared with them")
+	}
+
+	mediaList.Shares = shares
+	err = saveMediaListAndSync(c, &mediaList, sync.ActionUpdate, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	return mediaList, nil, nil
+}
@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	apiModels "github.com/news-ai/api/models"
+
+	"github.com/news-ai/tabulae/models"
+)
+
+func TestRoleForUser(t *testing.T) {
+	user := apiModels.User{Email: "Jane@Example.com", TeamId: 7}
+	user.Id = 1
+
+	tests := []struct {
+		name      string
+		mediaList models.MediaList
+		want      string
+	}{
+		{
+			name:      "not shared",
+			mediaList: models.MediaList{},
+			want:      "",
+		},
+		{
+			name:      "public",
+			mediaList: models.MediaList{PublicList: true},
+			want:      models.ListRoleViewer,
+		},
+		{
+			name:      "same team",
+			mediaList: models.MediaList{TeamId: 7, PublicList: true},
+			want:      models.ListRoleEditor,
+		},
+		{
+			name:      "shared with the user",
+			mediaList: models.MediaList{Shares: []models.ListShare{{UserId: 1, Role: models.ListRoleViewer}}},
+			want:      models.ListRoleViewer,
+		},
+		{
+			name:      "invited by email before signing up",
+			mediaList: models.MediaList{Shares: []models.ListShare{{Email: "jane@example.com", Role: models.ListRoleEditor}}},
+			want:      models.ListRoleEditor,
+		},
+		{
+			name:      "email of an invitation someone else accepted",
+			mediaList: models.MediaList{Shares: []models.ListShare{{UserId: 2, Email: "jane@example.com", Role: models.ListRoleEditor}}},
+			want:      "",
+		},
+		{
+			name: "highest of the shares",
+			mediaList: models.MediaList{
+				TeamId: 7,
+				Shares: []models.ListShare{{TeamId: 7, Role: models.ListRoleViewer}, {UserId: 1, Role: models.ListRoleOwner}},
+			},
+			want: models.ListRoleOwner,
+		},
+	}
+
+	for _, test := range tests {
+		role := test.mediaList.RoleForUser(user)
+		if role != test.want {
+			t.Errorf("%v: role is %q, want %q", test.name, role, test.want)
+		}
+	}
+
+	createdList := models.MediaList{}
+	createdList.CreatedBy = user.Id
+	if role := createdList.RoleForUser(user); role != models.ListRoleOwner {
+		t.Errorf("role on a list the user created is %q, want %q", role, models.ListRoleOwner)
+	}
+}
+
+func TestListRoleAtLeast(t *testing.T) {
+	tests := []struct {
+		role         string
+		requiredRole string
+		want         bool
+	}{
+		{role: models.ListRoleOwner, requiredRole: models.ListRoleEditor, want: true},
+		{role: models.ListRoleEditor, requiredRole: models.ListRoleEditor, want: true},
+		{role: models.ListRoleViewer, requiredRole: models.ListRoleEditor, want: false},
+		{role: "", requiredRole: models.ListRoleViewer, want: false},
+		{role: "admin", requiredRole: models.ListRoleViewer, want: false},
+	}
+
+	for _, test := range tests {
+		atLeast := models.ListRoleAtLeast(test.role, test.requiredRole)
+		if atLeast != test.want {
+			t.Errorf("ListRoleAtLeast(%q, %q) is %v, want %v", test.role, test.requiredRole, atLeast, test.want)
+		}
+	}
+}
+
+func TestIsSameListShare(t *testing.T) {
+	tests := []struct {
+		name       string
+		share      models.ListShare
+		otherShare models.ListShare
+		want       bool
+	}{
+		{name: "same user", share: models.ListShare{UserId: 1, Role: models.ListRoleViewer}, otherShare: models.ListShare{UserId: 1, Role: models.ListRoleEditor}, want: true},
+		{name: "user and their email", share: models.ListShare{UserId: 1, Email: "jane@example.com"}, otherShare: models.ListShare{Email: "jane@example.com"}, want: false},
+		{name: "same team", share: models.ListShare{TeamId: 7}, otherShare: models.ListShare{TeamId: 7}, want: true},
+		{name: "different teams", share: models.ListShare{TeamId: 7}, otherShare: models.ListShare{TeamId: 8}, want: false},
+		{name: "same email", share: models.ListShare{Email: "Jane@Example.com"}, otherShare: models.ListShare{Email: "jane@example.com"}, want: true},
+	}
+
+	for _, test := range tests {
+		same := isSameListShare(test.share, test.otherShare)
+		if same != test.want {
+			t.Errorf("%v: same is %v, want %v", test.name, same, test.want)
+		}
+	}
+}
+
+func TestMediaListIdsForUser(t *testing.T) {
+	inst, c, _, user := newTestRequest(t, "GET", "/", "")
+	defer inst.Close()
+
+	mediaLists := []models.MediaList{
+		{Name: "Created"},
+		{Name: "Shared", Shares: []models.ListShare{{UserId: user.Id, Role: models.ListRoleViewer}}},
+		{Name: "Invited", Shares: []models.ListShare{{Email: user.Email, Role: models.ListRoleEditor}}},
+		{Name: "Public", PublicList: true},
+		{Name: "Deleted", IsDeleted: true},
+	}
+	mediaLists[0].CreatedBy = user.Id
+	mediaLists[4].CreatedBy = user.Id
+	for i := 0; i < len(mediaLists); i++ {
+		_, err := mediaLists[i].Save(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mediaListIds, err := mediaListIdsForUser(c, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []int64{mediaLists[0].Id, mediaLists[1].Id, mediaLists[2].Id}
+	if !reflect.DeepEqual(mediaListIds, want) {
+		t.Errorf("lists are %v, want %v", mediaListIds, want)
+	}
+}
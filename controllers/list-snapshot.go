@@ -0,0 +1,748 @@
+package controllers
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+
+	"github.com/pquerna/ffjson/ffjson"
+	"github.com/qedus/nds"
+
+	"github.com/news-ai/api/controllers"
+
+	"github.com/news-ai/tabulae/models"
+	"github.com/news-ai/tabulae/sync"
+
+	"github.com/news-ai/web/utilities"
+)
+
+/*
+* Private
+ */
+
+// How many of the snapshots taken before changes are kept for each list.
+// Snapshots users take themselves are kept until the list is purged.
+var automaticSnapshotsKept = 20
+
+type createSnapshotDetails struct {
+	Name string `json:"name"`
+}
+
+type restoreSnapshotDetails struct {
+	NewList bool   `json:"newlist"`
+	Name    string `json:"name"`
+}
+
+type ListSnapshotChange struct {
+	ContactId int64  `json:"contactid"`
+	Field     string `json:"field"`
+	OldValue  string `json:"oldvalue"`
+	NewValue  string `json:"newvalue"`
+}
+
+// What changed between a snapshot and a later snapshot, or the list as it is
+// now when To is 0
+type ListSnapshotDiff struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+
+	AddedContacts   []int64 `json:"addedcontacts"`
+	RemovedContacts []int64 `json:"removedcontacts"`
+
+	AddedFields   []string `json:"addedfields"`
+	RemovedFields []string `json:"removedfields"`
+
+	Changes []ListSnapshotChange `json:"changes"`
+}
+
+/*
+* Private methods
+ */
+
+func getListSnapshot(c context.Context, r *http.Request, id int64) (models.ListSnapshot, models.MediaList, error) {
+	if id == 0 {
+		return models.ListSnapshot{}, models.MediaList{}, errors.New("datastore: no such entity")
+	}
+
+	var listSnapshot models.ListSnapshot
+	listSnapshotId := datastore.NewKey(c, "ListSnapshot", "", id, nil)
+	err := nds.Get(c, listSnapshotId, &listSnapshot)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.ListSnapshot{}, models.MediaList{}, err
+	}
+
+	if listSnapshot.Created.IsZero() {
+		return models.ListSnapshot{}, models.MediaList{}, errors.New("No list snapshot by this id")
+	}
+	listSnapshot.Format(listSnapshotId, "snapshots")
+
+	// Snapshots can be seen by anyone who can see their list
+	mediaList, err := getMediaList(c, r, listSnapshot.ListId)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.ListSnapshot{}, models.MediaList{}, err
+	}
+
+	return listSnapshot, mediaList, nil
+}
+
+func filterContactsForListSnapshot(c context.Context, snapshotId int64) (map[int64]map[string]string, error) {
+	ks, err := datastore.NewQuery("ListSnapshotContact").Filter("SnapshotId =", snapshotId).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return map[int64]map[string]string{}, err
+	}
+
+	snapshotContacts := make([]models.ListSnapshotContact, len(ks))
+	err = nds.GetMulti(c, ks, snapshotContacts)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return map[int64]map[string]string{}, err
+	}
+
+	values := map[int64]map[string]string{}
+	for i := 0; i < len(snapshotContacts); i++ {
+		values[snapshotContacts[i].ContactId] = snapshotContacts[i].ValuesMap()
+	}
+
+	return values, nil
+}
+
+// Only removing a field or changing its type can lose values, hiding and
+// renaming fields can't
+func fieldsMapChangesFields(fieldsMap []models.CustomFieldsMap, updatedFieldsMap []models.CustomFieldsMap) bool {
+	updatedTypes := map[string]string{}
+	for i := 0; i < len(updatedFieldsMap); i++ {
+		updatedTypes[updatedFieldsMap[i].Value] = updatedFieldsMap[i].Type
+	}
+
+	for i := 0; i < len(fieldsMap); i++ {
+		fieldType, ok := updatedTypes[fieldsMap[i].Value]
+		if !ok || fieldType != fieldsMap[i].Type {
+			return true
+		}
+	}
+	return false
+}
+
+// Saves the membership, field map and contact values of a list as they are
+// right now
+func snapshotMediaList(c context.Context, r *http.Request, mediaList models.MediaList, reason string, name string) (models.ListSnapshot, error) {
+	user, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.ListSnapshot{}, err
+	}
+
+	contacts, err := getContactsForMediaList(c, mediaList)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.ListSnapshot{}, err
+	}
+
+	listSnapshot := models.ListSnapshot{
+		ListId:    mediaList.Id,
+		Name:      name,
+		Reason:    reason,
+		Contacts:  []int64{},
+		FieldsMap: mediaList.FieldsMap,
+	}
+	for i := 0; i < len(contacts); i++ {
+		listSnapshot.Contacts = append(listSnapshot.Contacts, contacts[i].Id)
+	}
+
+	_, err = listSnapshot.Create(c, r, user)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.ListSnapshot{}, err
+	}
+
+	keys := []*datastore.Key{}
+	snapshotContacts := []models.ListSnapshotContact{}
+	for i := 0; i < len(contacts); i++ {
+		snapshotContact := models.NewListSnapshotContact(listSnapshot.Id, contacts[i])
+		snapshotContact.CreatedBy = user.Id
+		snapshotContact.Created = time.Now()
+		snapshotContact.Updated = time.Now()
+		keys = append(keys, snapshotContact.Key(c))
+		snapshotContacts = append(snapshotContacts, snapshotContact)
+	}
+
+	_, err = putMultiInBatches(c, keys, snapshotContacts)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.ListSnapshot{}, err
+	}
+
+	return listSnapshot, nil
+}
+
+// Deletes snapshots along with the contact values they kept
+func deleteListSnapshots(c context.Context, snapshotIds []int64) error {
+	keys := []*datastore.Key{}
+	for i := 0; i < len(snapshotIds); i++ {
+		ks, err := datastore.NewQuery("ListSnapshotContact").Filter("SnapshotId =", snapshotIds[i]).KeysOnly().GetAll(c, nil)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return err
+		}
+		keys = append(keys, ks...)
+		keys = append(keys, datastore.NewKey(c, "ListSnapshot", "", snapshotIds[i], nil))
+	}
+
+	return deleteMultiInBatches(c, keys)
+}
+
+// Deletes every snapshot of a list, for when the list is purged
+func deleteSnapshotsForList(c context.Context, listId int64) error {
+	ks, err := datastore.NewQuery("ListSnapshot").Filter("ListId =", listId).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	snapshotIds := []int64{}
+	for i := 0; i < len(ks); i++ {
+		snapshotIds = append(snapshotIds, ks[i].IntID())
+	}
+	return deleteListSnapshots(c, snapshotIds)
+}
+
+// Snapshots taken before changes that are past the automaticSnapshotsKept
+// most recent ones. listSnapshots are ordered from the most recent.
+func expiredAutomaticSnapshots(listSnapshots []models.ListSnapshot) []int64 {
+	snapshotIds := []int64{}
+	automaticSnapshots := 0
+	for i := 0; i < len(listSnapshots); i++ {
+		if listSnapshots[i].Reason == models.SnapshotReasonManual {
+			continue
+		}
+
+		automaticSnapshots++
+		if automaticSnapshots > automaticSnapshotsKept {
+			snapshotIds = append(snapshotIds, listSnapshots[i].Id)
+		}
+	}
+	return snapshotIds
+}
+
+func pruneAutomaticSnapshots(c context.Context, listId int64) error {
+	ks, err := datastore.NewQuery("ListSnapshot").Filter("ListId =", listId).Order("-Created").KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	listSnapshots := make([]models.ListSnapshot, len(ks))
+	err = nds.GetMulti(c, ks, listSnapshots)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	for i := 0; i < len(listSnapshots); i++ {
+		listSnapshots[i].Format(ks[i], "snapshots")
+	}
+
+	return deleteListSnapshots(c, expiredAutomaticSnapshots(listSnapshots))
+}
+
+// Snapshots taken before destructive operations. The operation still goes
+// ahead if the snapshot can't be taken.
+func snapshotMediaListBefore(c context.Context, r *http.Request, mediaList models.MediaList, reason string) {
+	if mediaList.Id == 0 || mediaList.IsSmartList {
+		return
+	}
+
+	_, err := snapshotMediaList(c, r, mediaList, reason, "")
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return
+	}
+
+	err = pruneAutomaticSnapshots(c, mediaList.Id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+	}
+}
+
+func diffListSnapshots(from models.ListSnapshot, fromValues map[int64]map[string]string, toContacts []int64, toFieldsMap []models.CustomFieldsMap, toValues map[int64]map[string]string) ListSnapshotDiff {
+	diff := ListSnapshotDiff{
+		From:            from.Id,
+		AddedContacts:   []int64{},
+		RemovedContacts: []int64{},
+		AddedFields:     []string{},
+		RemovedFields:   []string{},
+		Changes:         []ListSnapshotChange{},
+	}
+
+	fromContacts := map[int64]bool{}
+	for i := 0; i < len(from.Contacts); i++ {
+		fromContacts[from.Contacts[i]] = true
+	}
+
+	existingContacts := map[int64]bool{}
+	for i := 0; i < len(toContacts); i++ {
+		existingContacts[toContacts[i]] = true
+		if _, ok := fromContacts[toContacts[i]]; !ok {
+			diff.AddedContacts = append(diff.AddedContacts, toContacts[i])
+			continue
+		}
+
+		// Changed values of the contacts that are in both
+		previousValues := fromValues[toContacts[i]]
+		values := toValues[toContacts[i]]
+		fields := []string{}
+		for field := range values {
+			fields = append(fields, field)
+		}
+		for field := range previousValues {
+			if _, ok := values[field]; !ok {
+				fields = append(fields, field)
+			}
+		}
+		sort.Strings(fields)
+
+		for x := 0; x < len(fields); x++ {
+			if previousValues[fields[x]] != values[fields[x]] {
+				diff.Changes = append(diff.Changes, ListSnapshotChange{
+					ContactId: toContacts[i],
+					Field:     fields[x],
+					OldValue:  previousValues[fields[x]],
+					NewValue:  values[fields[x]],
+				})
+			}
+		}
+	}
+
+	for i := 0; i < len(from.Contacts); i++ {
+		if _, ok := existingContacts[from.Contacts[i]]; !ok {
+			diff.RemovedContacts = append(diff.RemovedContacts, from.Contacts[i])
+		}
+	}
+
+	fromFields := map[string]bool{}
+	for i := 0; i < len(from.FieldsMap); i++ {
+		fromFields[from.FieldsMap[i].Value] = true
+	}
+
+	existingFields := map[string]bool{}
+	for i := 0; i < len(toFieldsMap); i++ {
+		existingFields[toFieldsMap[i].Value] = true
+		if _, ok := fromFields[toFieldsMap[i].Value]; !ok {
+			diff.AddedFields = append(diff.AddedFields, toFieldsMap[i].Value)
+		}
+	}
+
+	for i := 0; i < len(from.FieldsMap); i++ {
+		if _, ok := existingFields[from.FieldsMap[i].Value]; !ok {
+			diff.RemovedFields = append(diff.RemovedFields, from.FieldsMap[i].Value)
+		}
+	}
+
+	return diff
+}
+
+// Puts the list back the way it was in the snapshot. Contacts that were
+// added since are moved to the trash, and contacts that have since been
+// removed for good are created again. Contacts that were moved to another
+// list stay there, and are created again for this list.
+func restoreListSnapshotInPlace(c context.Context, r *http.Request, listSnapshot models.ListSnapshot, mediaList models.MediaList) (models.MediaList, error) {
+	user, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, err
+	}
+
+	snapshotValues, err := filterContactsForListSnapshot(c, listSnapshot.Id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, err
+	}
+
+	// So the restore itself can be undone
+	snapshotMediaListBefore(c, r, mediaList, models.SnapshotReasonRestore)
+
+	snapshotContacts := map[int64]bool{}
+	for i := 0; i < len(listSnapshot.Contacts); i++ {
+		snapshotContacts[listSnapshot.Contacts[i]] = true
+	}
+
+	contacts := []models.Contact{}
+	revisions := []models.ContactRevision{}
+
+	// Contacts added after the snapshot
+	currentContacts, err := getContactsForMediaList(c, mediaList)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, err
+	}
+
+	for i := 0; i < len(currentContacts); i++ {
+		if _, ok := snapshotContacts[currentContacts[i].Id]; !ok {
+			currentContacts[i].IsDeleted = true
+			currentContacts[i].Deleted = time.Now()
+			currentContacts[i].Updated = time.Now()
+			contacts = append(contacts, currentContacts[i])
+		}
+	}
+
+	contactIds := []int64{}
+	createdContacts := []models.Contact{}
+	createdContactIds := []int64{}
+	for i := 0; i < len(listSnapshot.Contacts); i++ {
+		values := snapshotValues[listSnapshot.Contacts[i]]
+
+		contact := models.Contact{}
+		contactKey := datastore.NewKey(c, "Contact", "", listSnapshot.Contacts[i], nil)
+		err = nds.Get(c, contactKey, &contact)
+		if err != nil || contact.Created.IsZero() || (contact.ListId != 0 && contact.ListId != mediaList.Id) {
+			// The contact is gone so it is created again from the snapshot
+			contact = models.Contact{}
+			for field, value := range values {
+				contact.SetRevisionValue(field, value)
+			}
+			contact.ListId = mediaList.Id
+			contact.Normalize()
+			_, err = contact.Create(c, r, user)
+			if err != nil {
+				log.Errorf(c, "%v", err)
+				continue
+			}
+
+			contactIds = append(contactIds, contact.Id)
+			createdContacts = append(createdContacts, contact)
+			createdContactIds = append(createdContactIds, contact.Id)
+			continue
+		}
+		contact.Format(contactKey, "contacts")
+
+		previousContact := contact
+		previousContact.CustomFields = append([]models.CustomContactField{}, contact.CustomFields...)
+
+		currentValues := contact.RevisionValues()
+		for field, value := range currentValues {
+			if _, ok := values[field]; !ok && value != "" {
+				contact.SetRevisionValue(field, "")
+			}
+		}
+		for field, value := range values {
+			if currentValues[field] != value {
+				contact.SetRevisionValue(field, value)
+			}
+		}
+
+		contact.ListId = mediaList.Id
+		contact.IsDeleted = false
+		contact.Deleted = time.Time{}
+		contact.Updated = time.Now()
+
+		contacts = append(contacts, contact)
+		contactIds = append(contactIds, contact.Id)
+		revisions = append(revisions, contactRevisions(previousContact, contact, models.RevisionSourceSnapshot, user.Id)...)
+	}
+
+	_, err = saveContactsAndSync(c, contacts, queueListContactsSync(mediaList.Id))
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, err
+	}
+
+	err = saveContactRevisions(c, revisions)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+	}
+
+	mediaList.Contacts = contactIds
+	mediaList.FieldsMap = listSnapshot.FieldsMap
+	err = saveMediaListAndSync(c, &mediaList, sync.ActionUpdate, createdContactIds)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, err
+	}
+
+	TriggerContactCreatedWebhooks(c, createdContacts)
+
+	return mediaList, nil
+}
+
+// Creates a new list with the contacts of the snapshot as they were then
+func restoreListSnapshotToNewList(c context.Context, r *http.Request, listSnapshot models.ListSnapshot, mediaList models.MediaList, name string) (models.MediaList, error) {
+	user, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, err
+	}
+
+	snapshotValues, err := filterContactsForListSnapshot(c, listSnapshot.Id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, err
+	}
+
+	if name == "" {
+		name = mediaList.Name + " (" + listSnapshot.Created.Format("Jan 2, 2006 3:04 PM") + ")"
+	}
+
+	newMediaList := models.MediaList{
+		Name:      name,
+		Client:    mediaList.Client,
+		ClientId:  mediaList.ClientId,
+		FieldsMap: listSnapshot.FieldsMap,
+		Tags:      mediaList.Tags,
+		TeamId:    user.TeamId,
+		Contacts:  []int64{},
+	}
+	_, err = newMediaList.Create(c, r, user)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, err
+	}
+
+	// The ids of the snapshot contacts are kept so their feeds are copied
+	contacts := []models.Contact{}
+	for i := 0; i < len(listSnapshot.Contacts); i++ {
+		contact := models.Contact{}
+		for field, value := range snapshotValues[listSnapshot.Contacts[i]] {
+			contact.SetRevisionValue(field, value)
+		}
+		contact.Id = listSnapshot.Contacts[i]
+		contacts = append(contacts, contact)
+	}
+
+	newMediaList.Contacts, err = BatchCreateContactsForDuplicateList(c, r, contacts, newMediaList.Id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, err
+	}
+
+	err = saveMediaListAndSync(c, &newMediaList, sync.ActionCreate, newMediaList.Contacts)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, err
+	}
+
+	return newMediaList, nil
+}
+
+/*
+* Public methods
+ */
+
+/*
+* Get methods
+ */
+
+func GetSnapshotsForList(c context.Context, r *http.Request, id string) ([]models.ListSnapshot, interface{}, int, int, error) {
+	mediaList, _, err := GetMediaList(c, r, id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.ListSnapshot{}, nil, 0, 0, err
+	}
+
+	query := datastore.NewQuery("ListSnapshot").Filter("ListId =", mediaList.Id).Order("-Created")
+	query = controllers.ConstructQuery(query, r)
+	ks, err := query.KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.ListSnapshot{}, nil, 0, 0, err
+	}
+
+	listSnapshots := make([]models.ListSnapshot, len(ks))
+	err = nds.GetMulti(c, ks, listSnapshots)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.ListSnapshot{}, nil, 0, 0, err
+	}
+
+	for i := 0; i < len(listSnapshots); i++ {
+		listSnapshots[i].Format(ks[i], "snapshots")
+	}
+
+	return listSnapshots, nil, len(listSnapshots), 0, nil
+}
+
+func GetListSnapshot(c context.Context, r *http.Request, id string) (models.ListSnapshot, interface{}, error) {
+	currentId, err := utilities.StringIdToInt(id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.ListSnapshot{}, nil, err
+	}
+
+	listSnapshot, _, err := getListSnapshot(c, r, currentId)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.ListSnapshot{}, nil, err
+	}
+
+	return listSnapshot, nil, nil
+}
+
+// Compares a snapshot with a later one given by "against", or with the list
+// as it is now
+func GetListSnapshotDiff(c context.Context, r *http.Request, id string) (ListSnapshotDiff, interface{}, error) {
+	currentId, err := utilities.StringIdToInt(id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return ListSnapshotDiff{}, nil, err
+	}
+
+	listSnapshot, mediaList, err := getListSnapshot(c, r, currentId)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return ListSnapshotDiff{}, nil, err
+	}
+
+	fromValues, err := filterContactsForListSnapshot(c, listSnapshot.Id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return ListSnapshotDiff{}, nil, err
+	}
+
+	against := r.URL.Query().Get("against")
+	if against != "" {
+		againstId, err := utilities.StringIdToInt(against)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return ListSnapshotDiff{}, nil, err
+		}
+
+		againstSnapshot, _, err := getListSnapshot(c, r, againstId)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return ListSnapshotDiff{}, nil, err
+		}
+
+		if againstSnapshot.ListId != listSnapshot.ListId {
+			return ListSnapshotDiff{}, nil, errors.New("Snapshots are of different lists")
+		}
+
+		againstValues, err := filterContactsForListSnapshot(c, againstSnapshot.Id)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return ListSnapshotDiff{}, nil, err
+		}
+
+		diff := diffListSnapshots(listSnapshot, fromValues, againstSnapshot.Contacts, againstSnapshot.FieldsMap, againstValues)
+		diff.To = againstSnapshot.Id
+		return diff, nil, nil
+	}
+
+	contacts, err := getContactsForMediaList(c, mediaList)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return ListSnapshotDiff{}, nil, err
+	}
+
+	contactIds := []int64{}
+	values := map[int64]map[string]string{}
+	for i := 0; i < len(contacts); i++ {
+		contactIds = append(contactIds, contacts[i].Id)
+		values[contacts[i].Id] = models.NewListSnapshotContact(0, contacts[i]).ValuesMap()
+	}
+
+	return diffListSnapshots(listSnapshot, fromValues, contactIds, mediaList.FieldsMap, values), nil, nil
+}
+
+/*
+* Create methods
+ */
+
+func CreateSnapshotForList(c context.Context, r *http.Request, id string) (models.ListSnapshot, interface{}, error) {
+	mediaList, _, err := GetMediaList(c, r, id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.ListSnapshot{}, nil, err
+	}
+
+	err = requireMediaListRole(mediaList, models.ListRoleEditor)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.ListSnapshot{}, nil, err
+	}
+
+	buf, _ := ioutil.ReadAll(r.Body)
+	decoder := ffjson.NewDecoder()
+	var snapshotDetails createSnapshotDetails
+	if len(buf) > 0 {
+		err = decoder.Decode(buf, &snapshotDetails)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return models.ListSnapshot{}, nil, err
+		}
+	}
+
+	listSnapshot, err := snapshotMediaList(c, r, mediaList, models.SnapshotReasonManual, snapshotDetails.Name)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.ListSnapshot{}, nil, err
+	}
+
+	return listSnapshot, nil, nil
+}
+
+/*
+* Update methods
+ */
+
+// Restores a snapshot into its own list, or into a new list when "newlist"
+// is set
+func RestoreListSnapshot(c context.Context, r *http.Request, id string) (models.MediaList, interface{}, error) {
+	currentId, err := utilities.StringIdToInt(id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	listSnapshot, mediaList, err := getListSnapshot(c, r, currentId)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	buf, _ := ioutil.ReadAll(r.Body)
+	decoder := ffjson.NewDecoder()
+	var restoreDetails restoreSnapshotDetails
+	if len(buf) > 0 {
+		err = decoder.Decode(buf, &restoreDetails)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return models.MediaList{}, nil, err
+		}
+	}
+
+	if restoreDetails.NewList {
+		newMediaList, err := restoreListSnapshotToNewList(c, r, listSnapshot, mediaList, restoreDetails.Name)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return models.MediaList{}, nil, err
+		}
+		return newMediaList, nil, nil
+	}
+
+	err = requireMediaListRole(mediaList, models.ListRoleEditor)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	if mediaList.IsSmartList {
+		return models.MediaList{}, nil, errors.New("Contacts of a smart list come from its query")
+	}
+
+	mediaList, err = restoreListSnapshotInPlace(c, r, listSnapshot, mediaList)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	return mediaList, nil, nil
+}
@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/news-ai/tabulae/models"
+)
+
+func TestDiffListSnapshots(t *testing.T) {
+	from := models.ListSnapshot{
+		Contacts: []int64{1, 2, 3},
+		FieldsMap: []models.CustomFieldsMap{
+			{Value: "firstname"},
+			{Value: "beat"},
+		},
+	}
+	from.Id = 10
+	fromValues := map[int64]map[string]string{
+		1: {"firstname": "Jane", "beat": "Tech"},
+		2: {"firstname": "John"},
+		3: {"firstname": "Janet"},
+	}
+
+	tests := []struct {
+		name        string
+		toContacts  []int64
+		toFieldsMap []models.CustomFieldsMap
+		toValues    map[int64]map[string]string
+		want        ListSnapshotDiff
+	}{
+		{
+			name:        "nothing changed",
+			toContacts:  from.Contacts,
+			toFieldsMap: from.FieldsMap,
+			toValues:    fromValues,
+			want: ListSnapshotDiff{
+				From:            10,
+				AddedContacts:   []int64{},
+				RemovedContacts: []int64{},
+				AddedFields:     []string{},
+				RemovedFields:   []string{},
+				Changes:         []ListSnapshotChange{},
+			},
+		},
+		{
+			name:        "contacts added and removed",
+			toContacts:  []int64{1, 4, 3},
+			toFieldsMap: from.FieldsMap,
+			toValues: map[int64]map[string]string{
+				1: {"firstname": "Jane", "beat": "Tech"},
+				3: {"firstname": "Janet"},
+				4: {"firstname": "Jim"},
+			},
+			want: ListSnapshotDiff{
+				From:            10,
+				AddedContacts:   []int64{4},
+				RemovedContacts: []int64{2},
+				AddedFields:     []string{},
+				RemovedFields:   []string{},
+				Changes:         []ListSnapshotChange{},
+			},
+		},
+		{
+			name:        "values changed, set and cleared",
+			toContacts:  from.Contacts,
+			toFieldsMap: from.FieldsMap,
+			toValues: map[int64]map[string]string{
+				1: {"firstname": "Jane"},
+				2: {"firstname": "Johnny", "beat": "Food"},
+				3: {"firstname": "Janet"},
+			},
+			want: ListSnapshotDiff{
+				From:            10,
+				AddedContacts:   []int64{},
+				RemovedContacts: []int64{},
+				AddedFields:     []string{},
+				RemovedFields:   []string{},
+				Changes: []ListSnapshotChange{
+					{ContactId: 1, Field: "beat", OldValue: "Tech", NewValue: ""},
+					{ContactId: 2, Field: "beat", OldValue: "", NewValue: "Food"},
+					{ContactId: 2, Field: "firstname", OldValue: "John", NewValue: "Johnny"},
+				},
+			},
+		},
+		{
+			name:        "fields added and removed",
+			toContacts:  from.Contacts,
+			toFieldsMap: []models.CustomFieldsMap{{Value: "firstname"}, {Value: "region"}},
+			toValues:    fromValues,
+			want: ListSnapshotDiff{
+				From:            10,
+				AddedContacts:   []int64{},
+				RemovedContacts: []int64{},
+				AddedFields:     []string{"region"},
+				RemovedFields:   []string{"beat"},
+				Changes:         []ListSnapshotChange{},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		diff := diffListSnapshots(from, fromValues, test.toContacts, test.toFieldsMap, test.toValues)
+		if !reflect.DeepEqual(diff, test.want) {
+			t.Errorf("%v: diff is %+v, want %+v", test.name, diff, test.want)
+		}
+	}
+}
+
+func TestExpiredAutomaticSnapshots(t *testing.T) {
+	defaultAutomaticSnapshotsKept := automaticSnapshotsKept
+	automaticSnapshotsKept = 2
+	defer func() {
+		automaticSnapshotsKept = defaultAutomaticSnapshotsKept
+	}()
+
+	snapshot := func(id int64, reason string) models.ListSnapshot {
+		listSnapshot := models.ListSnapshot{Reason: reason}
+		listSnapshot.Id = id
+		return listSnapshot
+	}
+
+	tests := []struct {
+		name          string
+		listSnapshots []models.ListSnapshot
+		want          []int64
+	}{
+		{
+			name:          "fewer than are kept",
+			listSnapshots: []models.ListSnapshot{snapshot(1, models.SnapshotReasonUpload)},
+			want:          []int64{},
+		},
+		{
+			name: "oldest automatic snapshots",
+			listSnapshots: []models.ListSnapshot{
+				snapshot(5, models.SnapshotReasonMove),
+				snapshot(4, models.SnapshotReasonManual),
+				snapshot(3, models.SnapshotReasonUpload),
+				snapshot(2, models.SnapshotReasonContacts),
+				snapshot(1, models.SnapshotReasonManual),
+				snapshot(0, models.SnapshotReasonRestore),
+			},
+			want: []int64{2, 0},
+		},
+		{
+			name: "manual snapshots are kept",
+			listSnapshots: []models.ListSnapshot{
+				snapshot(3, models.SnapshotReasonManual),
+				snapshot(2, models.SnapshotReasonManual),
+				snapshot(1, models.SnapshotReasonManual),
+			},
+			want: []int64{},
+		},
+	}
+
+	for _, test := range tests {
+		snapshotIds := expiredAutomaticSnapshots(test.listSnapshots)
+		if !reflect.DeepEqual(snapshotIds, test.want) {
+			t.Errorf("%v: expired %v, want %v", test.name, snapshotIds, test.want)
+		}
+	}
+}
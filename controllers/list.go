@@ -10,6 +10,7 @@ import (
 
 	"golang.org/x/net/context"
 
+	"google.golang.org/appengine"
 	"google.golang.org/appengine/datastore"
 	"google.golang.org/appengine/log"
 	// "google.golang.org/appengine/memcache"
@@ -26,7 +27,6 @@ import (
 	"github.com/news-ai/tabulae/search"
 	"github.com/news-ai/tabulae/sync"
 
-	"github.com/news-ai/web/permissions"
 	"github.com/news-ai/web/utilities"
 )
 
@@ -37,8 +37,8 @@ import (
 var nonCustomHeaders = []string{"firstname", "lastname", "email", "employers", "pastemployers", "notes", "linkedin", "twitter", "instagram", "website", "blog", "phonenumber", "location"}
 var nonCustomHeadersName = []string{"First Name", "Last Name", "Email", "Employers", "Past Employers", "Notes", "Linkedin", "Twitter", "Instagram", "Website", "Blog", "Phone #", "Location"}
 
-var customHeaders = []string{"instagramfollowers", "instagramfollowing", "instagramlikes", "instagramcomments", "instagramposts", "twitterfollowers", "twitterfollowing", "twitterlikes", "twitterretweets", "twitterposts", "latestheadline", "lastcontacted", "publicationlastcontacted"}
-var customHeadersName = []string{"Instagram Followers", "Instagram Following", "Instagram Likes", "Instagram Comments", "Instagram Posts", "Twitter Followers", "Twitter Following", "Twitter Likes", "Twitter Retweets", "Twitter Posts", "Latest Headline", "Last Contacted", "Publication Last Contacted"}
+var customHeaders = []string{"instagramfollowers", "instagramfollowing", "instagramlikes", "instagramcomments", "instagramposts", "twitterfollowers", "twitterfollowing", "twitterlikes", "twitterretweets", "twitterposts", "latestheadline", "lastcontacted", "publicationlastcontacted", "emailssent", "openrate", "clickrate", "lastopened"}
+var customHeadersName = []string{"Instagram Followers", "Instagram Following", "Instagram Likes", "Instagram Comments", "Instagram Posts", "Twitter Followers", "Twitter Following", "Twitter Likes", "Twitter Retweets", "Twitter Posts", "Latest Headline", "Last Contacted", "Publication Last Contacted", "Emails Sent", "Open Rate", "Click Rate", "Last Opened"}
 
 type duplicateListDetails struct {
 	Name string `json:"name"`
@@ -48,6 +48,20 @@ type duplicateListDetails struct {
 * Private methods
  */
 
+func mediaListRoleForUser(mediaList models.MediaList, user apiModels.User) string {
+	if user.IsAdmin {
+		return models.ListRoleOwner
+	}
+	return mediaList.RoleForUser(user)
+}
+
+func requireMediaListRole(mediaList models.MediaList, role string) error {
+	if !models.ListRoleAtLeast(mediaList.Role, role) {
+		return errors.New("Forbidden")
+	}
+	return nil
+}
+
 /*
 * Get methods
  */
@@ -67,25 +81,26 @@ func getMediaListBasic(c context.Context, r *http.Request, id int64) (models.Med
 		return models.MediaList{}, err
 	}
 
-	if !mediaList.Created.IsZero() {
+	// Lists in the trash are only read when restoring them, by getDeletedMediaList
+	if !mediaList.Created.IsZero() && !mediaList.IsDeleted {
 		mediaList.Format(mediaListId, "lists")
 		mediaList.AddNewCustomFieldsMapToOldLists(c)
 
+		user, err := controllers.GetCurrentUser(c, r)
+		if err == nil {
+			mediaList.Role = mediaListRoleForUser(mediaList, user)
+		}
+
 		if !mediaList.PublicList {
-			user, err := controllers.GetCurrentUser(c, r)
 			if err != nil {
 				log.Errorf(c, "%v", err)
 				return models.MediaList{}, errors.New("Could not get user")
 			}
 
-			// 3 ways to check if user can access media list:
-			// 1. If admin
-			// 2. If created by user
-			// 3. If is within the user's team
-			if mediaList.CreatedBy != user.Id && !user.IsAdmin {
-				if mediaList.TeamId == 0 || user.TeamId == 0 || mediaList.TeamId != user.TeamId {
-					return models.MediaList{}, errors.New("Forbidden")
-				}
+			// Users need a role on the list: as its creator, a member of its
+			// team, someone it is shared with, or an admin
+			if mediaList.Role == "" {
+				return models.MediaList{}, errors.New("Forbidden")
 			}
 		}
 
@@ -109,30 +124,31 @@ func getMediaList(c context.Context, r *http.Request, id int64) (models.MediaLis
 		return models.MediaList{}, err
 	}
 
-	if !mediaList.Created.IsZero() {
+	// Lists in the trash are only read when restoring them, by getDeletedMediaList
+	if !mediaList.Created.IsZero() && !mediaList.IsDeleted {
 		mediaList.Format(mediaListId, "lists")
 		mediaList.AddNewCustomFieldsMapToOldLists(c)
 
+		user, err := controllers.GetCurrentUser(c, r)
+		if err == nil {
+			mediaList.Role = mediaListRoleForUser(mediaList, user)
+		}
+
 		if !mediaList.PublicList {
-			user, err := controllers.GetCurrentUser(c, r)
 			if err != nil {
 				log.Errorf(c, "%v", err)
 				return models.MediaList{}, errors.New("Could not get user")
 			}
 
-			// 3 ways to check if user can access media list:
-			// 1. If admin
-			// 2. If created by user
-			// 3. If is within the user's team
-			if mediaList.CreatedBy != user.Id && !user.IsAdmin {
-				if mediaList.TeamId == 0 || user.TeamId == 0 || mediaList.TeamId != user.TeamId {
-					return models.MediaList{}, errors.New("Forbidden")
-				}
+			// Users need a role on the list: as its creator, a member of its
+			// team, someone it is shared with, or an admin
+			if mediaList.Role == "" {
+				return models.MediaList{}, errors.New("Forbidden")
 			}
 
 			// If it is empty but there are still contacts by this list then populate them
 			// This is a data correction problem
-			if len(mediaList.Contacts) == 0 {
+			if len(mediaList.Contacts) == 0 && !mediaList.IsSmartList {
 				contacts, err := filterContactsForListId(c, r, mediaList.Id)
 				if err != nil {
 					return mediaList, nil
@@ -162,6 +178,7 @@ func getFieldsMap() []models.CustomFieldsMap {
 			Value:       nonCustomHeaders[i],
 			CustomField: false,
 			Hidden:      false,
+			Type:        models.FieldTypeText,
 		}
 		fieldsmap = append(fieldsmap, field)
 	}
@@ -194,8 +211,11 @@ func duplicateList(c context.Context, r *http.Request, id string, name string) (
 		return models.MediaList{}, nil, err
 	}
 
-	if mediaList.TeamId != user.TeamId && mediaList.CreatedBy != user.Id && !user.IsAdmin {
-		return models.MediaList{}, nil, errors.New("Forbidden")
+	// Anyone who can see a list can make their own copy of it
+	err = requireMediaListRole(mediaList, models.ListRoleViewer)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
 	}
 
 	if name == "" {
@@ -209,6 +229,11 @@ func duplicateList(c context.Context, r *http.Request, id string, name string) (
 	mediaList.Name = name
 	mediaList.Contacts = []int64{}
 	mediaList.PublicList = false
+
+	// Copies of smart lists keep the contacts they had and stop following
+	// the query
+	mediaList.IsSmartList = false
+	mediaList.SmartQuery = models.ContactQuery{}
 	mediaList.CreatedBy = user.Id
 	mediaList.Create(c, r, user)
 
@@ -229,9 +254,12 @@ func duplicateList(c context.Context, r *http.Request, id string, name string) (
 	}
 
 	mediaList.Contacts = newContacts
-	mediaList.Save(c)
+	err = saveMediaListAndSync(c, &mediaList, sync.ActionCreate, mediaList.Contacts)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
 
-	sync.ListUploadResourceBulkSync(r, mediaList.Id, mediaList.Contacts, []int64{})
 	return mediaList, nil, nil
 }
 
@@ -256,9 +284,7 @@ func GetMediaLists(c context.Context, r *http.Request, archived bool) ([]models.
 	// If the user is active then we can return their media lists
 	if user.IsActive {
 		query := datastore.NewQuery("MediaList").Filter("CreatedBy =", user.Id).Filter("Archived =", archived)
-		// if archived {
-		// 	query = query.Filter("IsDeleted =", false)
-		// }
+		query = query.Filter("IsDeleted =", false)
 		query = query.Filter("PublicList =", false)
 
 		query = controllers.ConstructQuery(query, r)
@@ -346,7 +372,7 @@ func GetMediaListsClients(c context.Context, r *http.Request) (interface{}, inte
 		return clients, nil, 0, 0, err
 	}
 
-	query := datastore.NewQuery("MediaList").Filter("CreatedBy =", user.Id).Filter("Archived =", false)
+	query := datastore.NewQuery("MediaList").Filter("CreatedBy =", user.Id).Filter("Archived =", false).Filter("IsDeleted =", false)
 	ks, err := query.KeysOnly().GetAll(c, nil)
 	if err != nil {
 		log.Errorf(c, "%v", err)
@@ -412,7 +438,7 @@ func GetPublicMediaLists(c context.Context, r *http.Request) ([]models.MediaList
 		return []models.MediaList{}, nil, 0, 0, err
 	}
 
-	query := datastore.NewQuery("MediaList").Filter("PublicList =", true).Filter("Archived =", false)
+	query := datastore.NewQuery("MediaList").Filter("PublicList =", true).Filter("Archived =", false).Filter("IsDeleted =", false)
 	query = controllers.ConstructQuery(query, r)
 	ks, err := query.KeysOnly().GetAll(c, nil)
 	if err != nil {
@@ -453,7 +479,7 @@ func GetTeamMediaLists(c context.Context, r *http.Request) ([]models.MediaList,
 		return []models.MediaList{}, nil, 0, 0, errors.New("You are not a part of a team")
 	}
 
-	query := datastore.NewQuery("MediaList").Filter("TeamId =", user.TeamId).Filter("Archived =", false)
+	query := datastore.NewQuery("MediaList").Filter("TeamId =", user.TeamId).Filter("Archived =", false).Filter("IsDeleted =", false)
 	query = controllers.ConstructQuery(query, r)
 	ks, err := query.KeysOnly().GetAll(c, nil)
 	if err != nil {
@@ -498,6 +524,13 @@ func GetMediaList(c context.Context, r *http.Request, id string) (models.MediaLi
 		mediaList.ReadOnly = true
 	}
 
+	if mediaList.IsSmartList && mediaList.RefreshOnRead {
+		err = refreshSmartList(c, r, &mediaList, nil)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
+	}
+
 	return mediaList, nil, nil
 }
 
@@ -521,6 +554,21 @@ func CreateMediaList(c context.Context, w http.ResponseWriter, r *http.Request)
 		return medialist, nil, err
 	}
 
+	err = validateFieldsMap(medialist.FieldsMap)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	if medialist.IsSmartList {
+		err = validateSmartQuery(medialist.SmartQuery)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return models.MediaList{}, nil, err
+		}
+		medialist.Contacts = []int64{}
+	}
+
 	// Initial values for fieldsmap
 	if len(medialist.FieldsMap) > 0 {
 		medialist.FieldsMap = append(getFieldsMap(), medialist.FieldsMap...)
@@ -531,13 +579,22 @@ func CreateMediaList(c context.Context, w http.ResponseWriter, r *http.Request)
 	medialist.TeamId = currentUser.TeamId
 
 	// Create media list
-	_, err = medialist.Create(c, r, currentUser)
+	err = saveAndSync(c, "List", sync.ActionCreate, func(ctx context.Context) (int64, error) {
+		_, err := medialist.Create(ctx, r, currentUser)
+		return medialist.Id, err
+	})
 	if err != nil {
 		log.Errorf(c, "%v", err)
 		return models.MediaList{}, nil, err
 	}
 
-	sync.ResourceSync(r, medialist.Id, "List", "create")
+	if medialist.IsSmartList {
+		err = refreshSmartList(c, r, &medialist, nil)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
+	}
+
 	return medialist, nil, nil
 }
 
@@ -553,6 +610,7 @@ func CreateSampleMediaList(c context.Context, r *http.Request, user apiModels.Us
 		Value:       "This is a custom column",
 		CustomField: true,
 		Hidden:      false,
+		Type:        models.FieldTypeText,
 	}
 	mediaList.FieldsMap = append(mediaList.FieldsMap, field)
 
@@ -610,7 +668,11 @@ func CreateSampleMediaList(c context.Context, r *http.Request, user apiModels.Us
 	contacts = append(contacts, fashionContact.Id)
 
 	mediaList.Contacts = contacts
-	mediaList.Save(c)
+	err = saveMediaListAndSync(c, &mediaList, sync.ActionCreate, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return mediaList, nil, err
+	}
 
 	// Create a fake feed
 	feed := models.Feed{}
@@ -628,7 +690,6 @@ func CreateSampleMediaList(c context.Context, r *http.Request, user apiModels.Us
 	fashionFeed.PublicationId = 5308689770610688
 	fashionFeed.Create(c, r, user)
 
-	sync.ResourceSync(r, mediaList.Id, "List", "create")
 	return mediaList, nil, nil
 }
 
@@ -645,16 +706,12 @@ func UpdateMediaList(c context.Context, r *http.Request, id string) (models.Medi
 	}
 
 	// Checking if the current user logged in can edit this particular id
-	user, err := controllers.GetCurrentUser(c, r)
+	err = requireMediaListRole(mediaList, models.ListRoleEditor)
 	if err != nil {
 		log.Errorf(c, "%v", err)
 		return models.MediaList{}, nil, err
 	}
 
-	if mediaList.TeamId != user.TeamId && mediaList.CreatedBy != user.Id && !user.IsAdmin {
-		return models.MediaList{}, nil, errors.New("Forbidden")
-	}
-
 	buf, _ := ioutil.ReadAll(r.Body)
 	decoder := ffjson.NewDecoder()
 	var updatedMediaList models.MediaList
@@ -664,6 +721,17 @@ func UpdateMediaList(c context.Context, r *http.Request, id string) (models.Medi
 		return models.MediaList{}, nil, err
 	}
 
+	if len(updatedMediaList.Contacts) > 0 && mediaList.IsSmartList {
+		return models.MediaList{}, nil, errors.New("Contacts of a smart list come from its query")
+	}
+
+	// Contacts and fields are replaced wholesale so they can be restored
+	if len(updatedMediaList.Contacts) > 0 {
+		snapshotMediaListBefore(c, r, mediaList, models.SnapshotReasonContacts)
+	} else if len(updatedMediaList.FieldsMap) > 0 && fieldsMapChangesFields(mediaList.FieldsMap, updatedMediaList.FieldsMap) {
+		snapshotMediaListBefore(c, r, mediaList, models.SnapshotReasonFieldsMap)
+	}
+
 	if len(updatedMediaList.Contacts) > 0 {
 		mediaList.Contacts = updatedMediaList.Contacts
 	} else {
@@ -685,7 +753,7 @@ func UpdateMediaList(c context.Context, r *http.Request, id string) (models.Medi
 	}
 
 	// Edge case for when you want to empty the list & there's only 1 contact
-	if len(mediaList.Contacts) == 1 {
+	if len(mediaList.Contacts) == 1 && !mediaList.IsSmartList {
 		// Get the single contact that the mediaList has
 		singleContact, err := getContact(c, r, mediaList.Contacts[0])
 		if err == nil {
@@ -699,11 +767,16 @@ func UpdateMediaList(c context.Context, r *http.Request, id string) (models.Medi
 
 	// Edge case for when you want to empty the list
 	contactsInList, err := filterContactsForListId(c, r, mediaList.Id)
-	if len(contactsInList) == 0 {
+	if len(contactsInList) == 0 && !mediaList.IsSmartList {
 		mediaList.Contacts = []int64{}
 	}
 
 	if len(updatedMediaList.FieldsMap) > 0 {
+		err = validateFieldsMap(updatedMediaList.FieldsMap)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return models.MediaList{}, nil, err
+		}
 		mediaList.FieldsMap = updatedMediaList.FieldsMap
 	}
 
@@ -726,14 +799,34 @@ func UpdateMediaList(c context.Context, r *http.Request, id string) (models.Medi
 		mediaList.Subscribed = false
 	}
 
-	_, mediaListSaveErr := mediaList.Save(c)
+	// Changing the query of a smart list changes its contacts
+	smartQueryChanged := false
+	if mediaList.IsSmartList {
+		if len(updatedMediaList.SmartQuery.Filters) > 0 {
+			err = validateSmartQuery(updatedMediaList.SmartQuery)
+			if err != nil {
+				log.Errorf(c, "%v", err)
+				return models.MediaList{}, nil, err
+			}
+			mediaList.SmartQuery = updatedMediaList.SmartQuery
+			smartQueryChanged = true
+		}
+		mediaList.RefreshOnRead = updatedMediaList.RefreshOnRead
+	}
 
-	// If there's a problem saving the document
-	if mediaListSaveErr != nil {
+	err = saveMediaListAndSync(c, &mediaList, sync.ActionUpdate, nil)
+	if err != nil {
 		log.Errorf(c, "%v", err)
-		mediaList.Save(c)
+		return models.MediaList{}, nil, err
 	}
-	sync.ResourceSync(r, mediaList.Id, "List", "create")
+
+	if smartQueryChanged {
+		err = refreshSmartList(c, r, &mediaList, nil)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
+	}
+
 	return mediaList, nil, nil
 }
 
@@ -757,8 +850,11 @@ func UpdateMediaListToPublic(c context.Context, r *http.Request, id string) (mod
 
 	mediaList.PublicList = !mediaList.PublicList
 
-	mediaList.Save(c)
-	sync.ResourceSync(r, mediaList.Id, "List", "create")
+	err = saveMediaListAndSync(c, &mediaList, sync.ActionUpdate, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
 	return mediaList, nil, nil
 }
 
@@ -780,15 +876,41 @@ func ReSyncMediaList(c context.Context, r *http.Request, id string) (models.Medi
 		return models.MediaList{}, nil, errors.New("Forbidden")
 	}
 
-	sync.ListUploadResourceBulkSync(r, mediaList.Id, mediaList.Contacts, []int64{})
-	sync.ResourceSync(r, mediaList.Id, "List", "create")
+	err = sync.ListUploadResourceBulkSync(r, mediaList.Id, mediaList.Contacts, []int64{})
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	err = sync.ResourceSync(r, mediaList.Id, "List", sync.ActionUpdate)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
 	return mediaList, nil, nil
 }
 
+// Saves a list made from an uploaded file in one transaction with the sync of
+// its contacts and publications
+func SaveUploadedMediaList(c context.Context, mediaList *models.MediaList, publicationIds []int64) error {
+	return saveAndSyncEvents(c, func(ctx context.Context) ([]sync.OutboxEvent, error) {
+		_, err := mediaList.Save(ctx)
+		if err != nil {
+			return []sync.OutboxEvent{}, err
+		}
+
+		event, err := sync.QueueListUploadSync(ctx, mediaList.Id, mediaList.Contacts, publicationIds)
+		return []sync.OutboxEvent{event}, err
+	})
+}
+
 /*
 * Action methods
  */
 
+// Contacts of a list, searched, filtered or sorted when the request asks for
+// it. With "facets" set the counts of what the matching contacts have are
+// included with their publications.
 func GetContactsForList(c context.Context, r *http.Request, id string) ([]models.Contact, interface{}, int, int, error) {
 	// Get the details of the current media list
 	mediaList, _, err := GetMediaList(c, r, id)
@@ -803,6 +925,14 @@ func GetContactsForList(c context.Context, r *http.Request, id string) ([]models
 		return []models.Contact{}, nil, 0, 0, err
 	}
 
+	return getContactsForList(c, r, mediaList, user)
+}
+
+// Facets are counted from the contacts that are already loaded, or by the
+// search index when there is a search
+func getContactsForList(c context.Context, r *http.Request, mediaList models.MediaList, user apiModels.User) ([]models.Contact, interface{}, int, int, error) {
+	withFacets := r.URL.Query().Get("facets") == "true"
+
 	queryField := gcontext.Get(r, "q").(string)
 	if queryField != "" {
 		contacts, total, err := search.SearchContactsByList(c, r, queryField, user, mediaList.CreatedBy, mediaList.Id)
@@ -811,7 +941,27 @@ func GetContactsForList(c context.Context, r *http.Request, id string) ([]models
 		}
 
 		publications := contactsToPublications(c, contacts)
-		return contacts, publications, len(contacts), total, nil
+		if !withFacets {
+			return contacts, publications, len(contacts), total, nil
+		}
+
+		facets, err := searchContactFacets(c, mediaList, user, queryField)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return []models.Contact{}, nil, 0, 0, err
+		}
+		return contacts, includeContactFacets(publications, facets), len(contacts), total, nil
+	}
+
+	// Sorting, filtering and cursors need every contact of the list
+	contactQuery, err := contactQueryFromRequest(r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Contact{}, nil, 0, 0, err
+	}
+
+	if !contactQuery.IsEmpty() || r.URL.Query().Get("cursor") != "" {
+		return queryContactsForList(c, r, mediaList, contactQuery, withFacets)
 	}
 
 	offset := gcontext.Get(r, "offset").(int)
@@ -882,13 +1032,24 @@ func GetContactsForList(c context.Context, r *http.Request, id string) ([]models
 	// 	return contacts, publications, len(contacts), len(mediaList.Contacts), nil
 	// }
 
+	// Contacts purged from the trash are left out
 	err = nds.GetMulti(c, subsetKeyIds, contacts)
-	if err != nil {
+	multiErr, isMultiErr := err.(appengine.MultiError)
+	if err != nil && !isMultiErr {
 		log.Errorf(c, "%v", err)
 		return []models.Contact{}, nil, 0, 0, err
 	}
 
+	existingContacts := []models.Contact{}
 	for i := 0; i < len(contacts); i++ {
+		if isMultiErr && multiErr[i] != nil {
+			if multiErr[i] != datastore.ErrNoSuchEntity {
+				log.Errorf(c, "%v", multiErr[i])
+				return []models.Contact{}, nil, 0, 0, multiErr[i]
+			}
+			continue
+		}
+
 		if contacts[i].ListId == 0 {
 			contacts[i].ListId = mediaList.Id
 			contacts[i].Save(c, r)
@@ -896,7 +1057,9 @@ func GetContactsForList(c context.Context, r *http.Request, id string) ([]models
 
 		contacts[i].Id = subsetIds[i]
 		contacts[i].Type = "contacts"
+		existingContacts = append(existingContacts, contacts[i])
 	}
+	contacts = existingContacts
 
 	contacts, err = ContactsToDefaultFields(c, r, contacts, mediaList)
 	if err != nil {
@@ -905,7 +1068,16 @@ func GetContactsForList(c context.Context, r *http.Request, id string) ([]models
 
 	// Add includes
 	publications := contactsToPublications(c, contacts)
-	return contacts, publications, len(contacts), len(mediaList.Contacts), nil
+	if !withFacets {
+		return contacts, publications, len(contacts), len(mediaList.Contacts), nil
+	}
+
+	facets, err := listContactFacets(c, mediaList)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Contact{}, nil, 0, 0, err
+	}
+	return contacts, includeContactFacets(publications, facets), len(contacts), len(mediaList.Contacts), nil
 }
 
 func GetEmailsForList(c context.Context, r *http.Request, id string) ([]models.Email, interface{}, int, int, error) {
@@ -1147,51 +1319,43 @@ func DeleteMediaList(c context.Context, r *http.Request, id string) (interface{}
 	}
 
 	// Double check permissions. Admins should not be able to delete.
-	if mediaList.TeamId != user.TeamId && !permissions.AccessToObject(mediaList.CreatedBy, user.Id) {
+	if mediaList.RoleForUser(user) != models.ListRoleOwner {
 		err = errors.New("Forbidden")
 		log.Errorf(c, "%v", err)
 		return nil, nil, err
 	}
 
-	contactIds := []int64{}
-	listId := mediaList.Id
-
-	contacts, err := GetContactsByIds(c, r, mediaList.Contacts)
-	if err != nil {
-		log.Errorf(c, "%v", err)
-		return nil, nil, err
-	}
-
-	// Delete contacts
-	keys := []*datastore.Key{}
-	for i := 0; i < len(contacts); i++ {
-		if contacts[i].ListId != 0 {
-			listId = contacts[i].ListId
+	// The contacts of a smart list belong to other lists, so only the list
+	// itself goes to the trash
+	deleted := time.Now()
+	if !mediaList.IsSmartList {
+		contacts, err := GetContactsByIds(c, r, mediaList.Contacts)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return nil, nil, err
 		}
 
-		contacts[i].IsDeleted = true
-		keys = append(keys, contacts[i].Key(c))
-		contactIds = append(contactIds, contacts[i].Id)
-	}
+		// Delete contacts. They stay in the trash along with the list.
+		for i := 0; i < len(contacts); i++ {
+			// Contacts that were already in the trash keep their own time
+			if !contacts[i].IsDeleted {
+				contacts[i].Deleted = deleted
+			}
+			contacts[i].IsDeleted = true
+		}
 
-	ks := []*datastore.Key{}
-	err = nds.RunInTransaction(c, func(ctx context.Context) error {
-		contextWithTimeout, _ := context.WithTimeout(c, time.Second*150)
-		ks, err = nds.PutMulti(contextWithTimeout, keys, contacts)
+		_, err = saveContactsAndSync(c, contacts, queueListContactsSync(mediaList.Id))
 		if err != nil {
 			log.Errorf(c, "%v", err)
-			return err
+			return nil, nil, err
 		}
-		return nil
-	}, nil)
-
-	// Pubsub to sync listid and contactids
-	sync.ListUploadResourceBulkSync(r, listId, contactIds, []int64{})
-
-	// Pubsub to remove ES contact
-	sync.ResourceSync(r, mediaList.Id, "List", "delete")
+	}
 
-	_, err = mediaList.Delete(c)
+	// The list is moved to the trash rather than deleted. It is removed for
+	// good by the purge trash task. Pubsub removes it from ES.
+	mediaList.IsDeleted = true
+	mediaList.Deleted = deleted
+	err = saveMediaListAndSync(c, &mediaList, sync.ActionDelete, nil)
 	if err != nil {
 		log.Errorf(c, "%v", err)
 		return nil, nil, err
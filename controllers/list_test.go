@@ -0,0 +1,72 @@
+package controllers
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/news-ai/tabulae/models"
+	"github.com/news-ai/tabulae/sync"
+)
+
+func TestDeleteMediaListEvents(t *testing.T) {
+	inst, c, r, user := newTestRequest(t, "DELETE", "/api/lists", "")
+	defer inst.Close()
+
+	mediaList := models.MediaList{Name: "Fashion"}
+	_, err := mediaList.Create(c, r, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contactIds := []int64{}
+	for i := 0; i < 2; i++ {
+		contact := models.Contact{
+			FirstName: "Jane",
+			Email:     "jane" + strconv.Itoa(i) + "@example.com",
+			ListId:    mediaList.Id,
+		}
+		_, err = contact.Create(c, r, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contactIds = append(contactIds, contact.Id)
+	}
+
+	mediaList.Contacts = contactIds
+	_, err = mediaList.Save(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bus := newTestBus()
+	_, _, err = DeleteMediaList(c, r, strconv.FormatInt(mediaList.Id, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		topic string
+		want  sync.Event
+	}{
+		{
+			topic: sync.ListChangeTopicID,
+			want:  sync.NewResourceChanged("List", sync.ActionDelete, []int64{mediaList.Id}),
+		},
+		{
+			topic: sync.ListUploadTopicID,
+			want:  sync.NewListUploaded(mediaList.Id, contactIds, []int64{}),
+		},
+	}
+
+	if messages := bus.Messages(""); len(messages) != len(tests) {
+		t.Fatalf("published %v messages, want %v", len(messages), len(tests))
+	}
+
+	for _, test := range tests {
+		events := testEvents(t, bus, test.topic)
+		if len(events) != 1 || !reflect.DeepEqual(events[0], test.want) {
+			t.Errorf("events on %v are %+v, want %+v", test.topic, events, test.want)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+
+	gcontext "github.com/gorilla/context"
+	"github.com/qedus/nds"
+
+	"github.com/news-ai/api/controllers"
+
+	"github.com/news-ai/tabulae/models"
+	"github.com/news-ai/tabulae/sync"
+
+	"github.com/news-ai/web/utilities"
+)
+
+/*
+* Private
+ */
+
+// A transaction can write to 25 entity groups, so contacts are saved 24 at a
+// time next to the outbox event of their sync
+var contactTransactionSize = 24
+
+/*
+* Private methods
+ */
+
+func requireOutboxAdmin(c context.Context, r *http.Request) error {
+	user, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	if !user.IsAdmin {
+		return errors.New("Forbidden")
+	}
+
+	return nil
+}
+
+// Saves a resource and writes its sync event in one transaction, so the event
+// exists if and only if the change does. The event is published once the
+// transaction commits; if that fails the outbox dispatcher publishes it later.
+func saveAndSync(c context.Context, resource string, method string, save func(ctx context.Context) (int64, error)) error {
+	return saveAndSyncEvents(c, func(ctx context.Context) ([]sync.OutboxEvent, error) {
+		resourceId, err := save(ctx)
+		if err != nil {
+			return []sync.OutboxEvent{}, err
+		}
+
+		event, err := sync.QueueResourceSync(ctx, resourceId, resource, method)
+		return []sync.OutboxEvent{event}, err
+	})
+}
+
+// Like saveAndSync for changes that write several entities or events, which
+// save queues with the sync.Queue functions. A transaction can only write to
+// 25 entity groups, so many contacts are saved with saveContactsAndSync.
+func saveAndSyncEvents(c context.Context, save func(ctx context.Context) ([]sync.OutboxEvent, error)) error {
+	events := []sync.OutboxEvent{}
+	err := nds.RunInTransaction(c, func(ctx context.Context) error {
+		var err error
+		events, err = save(ctx)
+		return err
+	}, &datastore.TransactionOptions{XG: true})
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	sync.DeliverOutboxEvents(c, events)
+	return nil
+}
+
+// Saves a list in one transaction with the sync of the list, when method is
+// set, and the sync of contacts of the list, when there are contactIds
+func saveMediaListAndSync(c context.Context, mediaList *models.MediaList, method string, contactIds []int64) error {
+	return saveAndSyncEvents(c, func(ctx context.Context) ([]sync.OutboxEvent, error) {
+		events := []sync.OutboxEvent{}
+		_, err := mediaList.Save(ctx)
+		if err != nil {
+			return events, err
+		}
+
+		if method != "" {
+			event, err := sync.QueueResourceSync(ctx, mediaList.Id, "List", method)
+			if err != nil {
+				return events, err
+			}
+			events = append(events, event)
+		}
+
+		if len(contactIds) > 0 {
+			event, err := sync.QueueListUploadSync(ctx, mediaList.Id, contactIds, []int64{})
+			if err != nil {
+				return events, err
+			}
+			events = append(events, event)
+		}
+
+		return events, nil
+	})
+}
+
+// Saves any number of contacts in transactions of contactTransactionSize.
+// Each transaction also writes the one outbox event queue makes for the ids
+// of its contacts, so the contacts of every transaction that commits are
+// synced even when a later one fails. New contacts get their ids. Returns how
+// many contacts were saved, which are the first ones.
+func saveContactsAndSync(c context.Context, contacts []models.Contact, queue func(ctx context.Context, contactIds []int64) (sync.OutboxEvent, error)) (int, error) {
+	events := []sync.OutboxEvent{}
+	saved := 0
+	var err error
+	for i := 0; i < len(contacts); i += contactTransactionSize {
+		end := i + contactTransactionSize
+		if end > len(contacts) {
+			end = len(contacts)
+		}
+
+		keys := []*datastore.Key{}
+		for x := i; x < end; x++ {
+			keys = append(keys, contacts[x].Key(c))
+		}
+
+		ks := []*datastore.Key{}
+		event := sync.OutboxEvent{}
+		err = nds.RunInTransaction(c, func(ctx context.Context) error {
+			var err error
+			ks, err = nds.PutMulti(ctx, keys, contacts[i:end])
+			if err != nil {
+				return err
+			}
+
+			contactIds := []int64{}
+			for x := 0; x < len(ks); x++ {
+				contactIds = append(contactIds, ks[x].IntID())
+			}
+
+			event, err = queue(ctx, contactIds)
+			return err
+		}, &datastore.TransactionOptions{XG: true})
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			break
+		}
+
+		for x := 0; x < len(ks); x++ {
+			contacts[i+x].Format(ks[x], "contacts")
+		}
+		events = append(events, event)
+		saved = end
+	}
+
+	sync.DeliverOutboxEvents(c, events)
+	return saved, err
+}
+
+// Syncs the contacts saveContactsAndSync saves as a change of the contacts
+func queueContactsSync(method string) func(ctx context.Context, contactIds []int64) (sync.OutboxEvent, error) {
+	return func(ctx context.Context, contactIds []int64) (sync.OutboxEvent, error) {
+		return sync.QueueResourceBulkSync(ctx, contactIds, "Contact", method)
+	}
+}
+
+// Syncs the contacts saveContactsAndSync saves as a change of the contacts of
+// a list
+func queueListContactsSync(listId int64) func(ctx context.Context, contactIds []int64) (sync.OutboxEvent, error) {
+	return func(ctx context.Context, contactIds []int64) (sync.OutboxEvent, error) {
+		return sync.QueueListUploadSync(ctx, listId, contactIds, []int64{})
+	}
+}
+
+/*
+* Get methods
+ */
+
+// Events in the outbox by status, dead ones by default
+func GetOutboxEvents(c context.Context, r *http.Request) ([]sync.OutboxEvent, interface{}, int, int, error) {
+	err := requireOutboxAdmin(c, r)
+	if err != nil {
+		return []sync.OutboxEvent{}, nil, 0, 0, err
+	}
+
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = sync.OutboxDead
+	}
+
+	if status != sync.OutboxPending && status != sync.OutboxDelivered && status != sync.OutboxDead {
+		return []sync.OutboxEvent{}, nil, 0, 0, errors.New("Status can be " + sync.OutboxPending + ", " + sync.OutboxDelivered + " or " + sync.OutboxDead)
+	}
+
+	offset := gcontext.Get(r, "offset").(int)
+	limit := gcontext.Get(r, "limit").(int)
+
+	events, err := sync.GetOutboxEvents(c, status, offset, limit)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []sync.OutboxEvent{}, nil, 0, 0, err
+	}
+
+	return events, nil, len(events), 0, nil
+}
+
+/*
+* Action methods
+ */
+
+func RetryOutboxEvent(c context.Context, r *http.Request, id string) (sync.OutboxEvent, interface{}, error) {
+	err := requireOutboxAdmin(c, r)
+	if err != nil {
+		return sync.OutboxEvent{}, nil, err
+	}
+
+	currentId, err := utilities.StringIdToInt(id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return sync.OutboxEvent{}, nil, err
+	}
+
+	event, err := sync.RetryOutboxEvent(c, currentId)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return sync.OutboxEvent{}, nil, err
+	}
+
+	return event, nil, nil
+}
+
+// Publishes the outbox events that are due. This runs from cron.
+func DispatchOutbox(c context.Context, r *http.Request) error {
+	delivered, failed, err := sync.DispatchOutbox(c)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	log.Infof(c, "Outbox: delivered %v, failed %v", delivered, failed)
+	return nil
+}
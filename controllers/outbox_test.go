@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/news-ai/tabulae/models"
+	"github.com/news-ai/tabulae/sync"
+)
+
+func TestSaveContactsAndSync(t *testing.T) {
+	inst, c, _, user := newTestRequest(t, "POST", "/", "")
+	defer inst.Close()
+
+	defaultContactTransactionSize := contactTransactionSize
+	contactTransactionSize = 2
+	defer func() {
+		contactTransactionSize = defaultContactTransactionSize
+	}()
+
+	contacts := []models.Contact{}
+	for i := 0; i < 5; i++ {
+		contact := models.Contact{Email: "jane" + strconv.Itoa(i) + "@example.com"}
+		contact.CreatedBy = user.Id
+		contacts = append(contacts, contact)
+	}
+
+	bus := newTestBus()
+	saved, err := saveContactsAndSync(c, contacts, queueContactsSync(sync.ActionCreate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved != len(contacts) {
+		t.Fatalf("saved %v contacts, want %v", saved, len(contacts))
+	}
+
+	// One event for each transaction, with the ids the contacts got
+	want := []sync.Event{
+		sync.NewResourceChanged("Contact", sync.ActionCreate, []int64{contacts[0].Id, contacts[1].Id}),
+		sync.NewResourceChanged("Contact", sync.ActionCreate, []int64{contacts[2].Id, contacts[3].Id}),
+		sync.NewResourceChanged("Contact", sync.ActionCreate, []int64{contacts[4].Id}),
+	}
+	events := testEvents(t, bus, sync.ContactBulkTopicID)
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events are %+v, want %+v", events, want)
+	}
+}
@@ -151,6 +151,10 @@ func GetPublication(c context.Context, id string) (models.Publication, interface
 	return publication, nil, nil
 }
 
+func GetPublicationsForContacts(c context.Context, contacts []models.Contact) []models.Publication {
+	return contactsToPublications(c, contacts)
+}
+
 func GetHeadlinesForPublication(c context.Context, r *http.Request, id string) (interface{}, interface{}, int, int, error) {
 	// Get the details of the current user
 	currentId, err := utilities.StringIdToInt(id)
@@ -238,12 +242,15 @@ func CreatePublication(c context.Context, w http.ResponseWriter, r *http.Request
 
 			presentPublication, _, err := FilterPublicationByNameAndUrl(c, publications[i].Name, publications[i].Url)
 			if err != nil {
-				_, err = publications[i].Create(c, r, currentUser)
+				newPublication := &publications[i]
+				err = saveAndSync(c, "Publication", sync.ActionCreate, func(ctx context.Context) (int64, error) {
+					_, err := newPublication.Create(ctx, r, currentUser)
+					return newPublication.Id, err
+				})
 				if err != nil {
 					log.Errorf(c, "%v", err)
 					return []models.Publication{}, nil, 0, 0, err
 				}
-				sync.ResourceSync(r, publications[i].Id, "Publication", "create")
 				newPublications = append(newPublications, publications[i])
 			} else {
 				newPublications = append(newPublications, presentPublication)
@@ -266,12 +273,14 @@ func CreatePublication(c context.Context, w http.ResponseWriter, r *http.Request
 			return models.Publication{}, nil, 0, 0, err
 		}
 		// Create publication
-		_, err = publication.Create(c, r, currentUser)
+		err = saveAndSync(c, "Publication", sync.ActionCreate, func(ctx context.Context) (int64, error) {
+			_, err := publication.Create(ctx, r, currentUser)
+			return publication.Id, err
+		})
 		if err != nil {
 			log.Errorf(c, "%v", err)
 			return models.Publication{}, nil, 0, 0, err
 		}
-		sync.ResourceSync(r, publication.Id, "Publication", "create")
 		return publication, nil, 1, 0, nil
 	}
 	return presentPublication, nil, 1, 0, nil
@@ -311,10 +320,16 @@ func UpdatePublication(c context.Context, r *http.Request, id string) (models.Pu
 	// If updated publication url is empty and the publication has not been verified
 	if updatedPublication.Url != "" && !publication.Verified {
 		publication.Url = updatedPublication.Url
-		publication.Save(c)
+		err = saveAndSync(c, "Publication", sync.ActionUpdate, func(ctx context.Context) (int64, error) {
+			_, err := publication.Save(ctx)
+			return publication.Id, err
+		})
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return models.Publication{}, nil, err
+		}
 	}
 
-	sync.ResourceSync(r, publication.Id, "Publication", "create")
 	return publication, nil, nil
 }
 
@@ -343,9 +358,15 @@ func VerifyPublication(c context.Context, r *http.Request, id string) (models.Pu
 	}
 
 	publication.Verified = true
-	publication.Save(c)
+	err = saveAndSync(c, "Publication", sync.ActionUpdate, func(ctx context.Context) (int64, error) {
+		_, err := publication.Save(ctx)
+		return publication.Id, err
+	})
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Publication{}, nil, err
+	}
 
-	sync.ResourceSync(r, publication.Id, "Publication", "create")
 	return publication, nil, nil
 }
 
@@ -387,13 +408,15 @@ func FindOrCreatePublication(c context.Context, r *http.Request, name string, ur
 
 		var newPublication models.Publication
 		newPublication.Name = name
-		_, err = newPublication.Create(c, r, currentUser)
+		err = saveAndSync(c, "Publication", sync.ActionCreate, func(ctx context.Context) (int64, error) {
+			_, err := newPublication.Create(ctx, r, currentUser)
+			return newPublication.Id, err
+		})
 		if err != nil {
 			log.Errorf(c, "%v", err)
 			return models.Publication{}, err
 		}
 
-		sync.ResourceSync(r, newPublication.Id, "Publication", "create")
 		return newPublication, nil
 	}
 
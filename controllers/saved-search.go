@@ -0,0 +1,593 @@
+package controllers
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+
+	gcontext "github.com/gorilla/context"
+	"github.com/pquerna/ffjson/ffjson"
+	"github.com/qedus/nds"
+
+	"github.com/news-ai/api/controllers"
+	apiModels "github.com/news-ai/api/models"
+	apiSearch "github.com/news-ai/api/search"
+
+	"github.com/news-ai/tabulae/emails"
+	"github.com/news-ai/tabulae/models"
+	"github.com/news-ai/tabulae/search"
+
+	"github.com/news-ai/web/utilities"
+)
+
+/*
+* Private
+ */
+
+// A result of a saved search, as it is shown in a digest
+type savedSearchResult struct {
+	Id       string
+	Title    string
+	Subtitle string
+	Url      string
+
+	// When the result last changed, if it is known
+	Updated time.Time
+}
+
+var (
+	// How many results of a search are kept to find new ones. Contacts past
+	// this can come back into the results when earlier ones stop matching,
+	// so only contacts that changed since the last run count as new.
+	savedSearchContactLimit  = 1000
+	savedSearchHeadlineLimit = 100
+
+	// How many new results of each search a digest shows
+	savedSearchDigestResults = 5
+
+	// How many alerting searches a run goes through. A user's searches
+	// are never split between runs, so they get a single digest.
+	savedSearchRunLimit = 100
+)
+
+// The fields of a saved search that can be changed. Alerts is only changed
+// when it is sent.
+type savedSearchUpdate struct {
+	Name   string `json:"name"`
+	Query  string `json:"query"`
+	ListId int64  `json:"listid"`
+	Alerts *bool  `json:"alerts"`
+}
+
+/*
+* Private methods
+ */
+
+func getSavedSearch(c context.Context, id int64) (models.SavedSearch, error) {
+	if id == 0 {
+		return models.SavedSearch{}, errors.New("datastore: no such entity")
+	}
+
+	var savedSearch models.SavedSearch
+	savedSearchId := datastore.NewKey(c, "SavedSearch", "", id, nil)
+
+	err := nds.Get(c, savedSearchId, &savedSearch)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.SavedSearch{}, err
+	}
+
+	if !savedSearch.Created.IsZero() {
+		savedSearch.Format(savedSearchId, "savedsearches")
+		return savedSearch, nil
+	}
+	return models.SavedSearch{}, errors.New("No saved search by this id")
+}
+
+// Saved searches can only be seen and changed by the user who saved them
+func getSavedSearchForUser(c context.Context, r *http.Request, id string) (models.SavedSearch, apiModels.User, error) {
+	currentId, err := utilities.StringIdToInt(id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.SavedSearch{}, apiModels.User{}, err
+	}
+
+	user, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.SavedSearch{}, apiModels.User{}, err
+	}
+
+	savedSearch, err := getSavedSearch(c, currentId)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.SavedSearch{}, apiModels.User{}, err
+	}
+
+	if savedSearch.CreatedBy != user.Id && !user.IsAdmin {
+		return models.SavedSearch{}, apiModels.User{}, errors.New("Forbidden")
+	}
+
+	return savedSearch, user, nil
+}
+
+// Searches of a list need the user to still have a role on the list. The
+// contacts of a list belong to whoever created the list.
+func savedSearchListOwner(c context.Context, savedSearch models.SavedSearch, user apiModels.User) (int64, error) {
+	if savedSearch.ListId == 0 {
+		return user.Id, nil
+	}
+
+	var mediaList models.MediaList
+	err := nds.Get(c, datastore.NewKey(c, "MediaList", "", savedSearch.ListId, nil), &mediaList)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return 0, err
+	}
+
+	if mediaList.IsDeleted || mediaListRoleForUser(mediaList, user) == "" {
+		return 0, errors.New("Forbidden")
+	}
+
+	return mediaList.CreatedBy, nil
+}
+
+// A headline matches a saved search when it has any of the words
+func headlineMatchesSearch(headline apiSearch.Headline, search string) bool {
+	text := strings.ToLower(headline.Title + " " + headline.Summary + " " + headline.Author)
+	words := strings.Fields(strings.ToLower(search))
+	for i := 0; i < len(words); i++ {
+		if strings.Contains(text, words[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func savedSearchResults(c context.Context, r *http.Request, savedSearch models.SavedSearch, user apiModels.User) ([]savedSearchResult, error) {
+	results := []savedSearchResult{}
+
+	ownerId, err := savedSearchListOwner(c, savedSearch, user)
+	if err != nil {
+		return results, err
+	}
+
+	switch savedSearch.Kind {
+	case models.SavedSearchContacts:
+		contacts, _, err := search.SearchSavedContacts(c, savedSearch.Query, ownerId, savedSearch.ListId, savedSearchContactLimit)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return results, err
+		}
+
+		for i := 0; i < len(contacts); i++ {
+			title := strings.TrimSpace(contacts[i].FirstName + " " + contacts[i].LastName)
+			if title == "" {
+				title = contacts[i].Email
+			}
+			results = append(results, savedSearchResult{
+				Id:       strconv.FormatInt(contacts[i].Id, 10),
+				Title:    title,
+				Subtitle: contacts[i].Email,
+				Updated:  contacts[i].Updated,
+			})
+		}
+	case models.SavedSearchHeadlines:
+		// Headlines come from the feeds of the list, or the feeds the user
+		// added
+		feeds := []models.Feed{}
+		if savedSearch.ListId != 0 {
+			feeds, err = GetFeedsByResourceId(c, r, "ListId", savedSearch.ListId)
+		} else {
+			feeds, err = GetFeedsByResourceId(c, r, "CreatedBy", user.Id)
+		}
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return results, err
+		}
+
+		if len(feeds) == 0 {
+			return results, nil
+		}
+
+		// The newest headlines of the feeds are searched
+		gcontext.Set(r, "offset", 0)
+		gcontext.Set(r, "limit", savedSearchHeadlineLimit)
+		headlines, _, err := apiSearch.SearchHeadlinesByResourceId(c, r, feeds, []string{})
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return results, err
+		}
+
+		for i := 0; i < len(headlines); i++ {
+			if !headlineMatchesSearch(headlines[i], savedSearch.Query) {
+				continue
+			}
+			results = append(results, savedSearchResult{
+				Id:       headlines[i].Url,
+				Title:    headlines[i].Title,
+				Subtitle: headlines[i].Author,
+				Url:      headlines[i].Url,
+			})
+		}
+	}
+
+	return results, nil
+}
+
+// The results of a saved search that weren't there when it last ran, and the
+// ids of all of its results. The first run has no new results, so that
+// everything isn't new at once.
+func findNewSavedSearchResults(c context.Context, r *http.Request, savedSearch models.SavedSearch, user apiModels.User) ([]savedSearchResult, []string, error) {
+	results, err := savedSearchResults(c, r, savedSearch, user)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []savedSearchResult{}, []string{}, err
+	}
+
+	lastResults := map[string]bool{}
+	for i := 0; i < len(savedSearch.LastResults); i++ {
+		lastResults[savedSearch.LastResults[i]] = true
+	}
+
+	newResults := []savedSearchResult{}
+	resultIds := []string{}
+	for i := 0; i < len(results); i++ {
+		resultIds = append(resultIds, results[i].Id)
+
+		if savedSearch.LastRun.IsZero() || lastResults[results[i].Id] {
+			continue
+		}
+
+		// Results that were past the limit last time, and haven't changed
+		// since, aren't new
+		if !results[i].Updated.IsZero() && results[i].Updated.Before(savedSearch.LastRun) {
+			continue
+		}
+
+		newResults = append(newResults, results[i])
+	}
+
+	return newResults, resultIds, nil
+}
+
+// Keeps the results of a run for the next one to compare with
+func recordSavedSearchRun(c context.Context, savedSearch *models.SavedSearch, resultIds []string, newResults int) error {
+	savedSearch.LastResults = resultIds
+	savedSearch.LastRun = time.Now()
+	savedSearch.LastNewResults = newResults
+	_, err := savedSearch.Save(c)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+	return nil
+}
+
+// Runs a saved search and keeps its results for the next run
+func runSavedSearch(c context.Context, r *http.Request, savedSearch *models.SavedSearch, user apiModels.User) ([]savedSearchResult, error) {
+	newResults, resultIds, err := findNewSavedSearchResults(c, r, *savedSearch, user)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []savedSearchResult{}, err
+	}
+
+	err = recordSavedSearchRun(c, savedSearch, resultIds, len(newResults))
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []savedSearchResult{}, err
+	}
+
+	return newResults, nil
+}
+
+func savedSearchDigest(savedSearch models.SavedSearch, newResults []savedSearchResult) emails.CampaignMonitorSavedSearch {
+	digest := emails.CampaignMonitorSavedSearch{
+		NAME:        savedSearch.Name,
+		KIND:        savedSearch.Kind,
+		NEW_RESULTS: strconv.Itoa(len(newResults)),
+	}
+
+	for i := 0; i < len(newResults) && i < savedSearchDigestResults; i++ {
+		digest.RESULTS = append(digest.RESULTS, emails.CampaignMonitorSavedSearchResult{
+			TITLE:    newResults[i].Title,
+			SUBTITLE: newResults[i].Subtitle,
+			URL:      newResults[i].Url,
+		})
+	}
+
+	return digest
+}
+
+/*
+* Public methods
+ */
+
+/*
+* Get methods
+ */
+
+func GetSavedSearch(c context.Context, r *http.Request, id string) (models.SavedSearch, interface{}, error) {
+	savedSearch, _, err := getSavedSearchForUser(c, r, id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.SavedSearch{}, nil, err
+	}
+
+	return savedSearch, nil, nil
+}
+
+func GetSavedSearches(c context.Context, r *http.Request) ([]models.SavedSearch, interface{}, int, int, error) {
+	user, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.SavedSearch{}, nil, 0, 0, err
+	}
+
+	query := datastore.NewQuery("SavedSearch").Filter("CreatedBy =", user.Id)
+	query = controllers.ConstructQuery(query, r)
+	ks, err := query.KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.SavedSearch{}, nil, 0, 0, err
+	}
+
+	savedSearches := make([]models.SavedSearch, len(ks))
+	err = nds.GetMulti(c, ks, savedSearches)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.SavedSearch{}, nil, 0, 0, err
+	}
+
+	for i := 0; i < len(savedSearches); i++ {
+	}
+
+	return savedSearches, nil, len(savedSearches), 0, nil
+}
+
+/*
+* Create methods
+ */
+
+func CreateSavedSearch(c context.Context, r *http.Request) (models.SavedSearch, interface{}, error) {
+	buf, _ := ioutil.ReadAll(r.Body)
+	decoder := ffjson.NewDecoder()
+	var savedSearch models.SavedSearch
+	err := decoder.Decode(buf, &savedSearch)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.SavedSearch{}, nil, err
+	}
+
+	currentUser, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.SavedSearch{}, nil, err
+	}
+
+	savedSearch.Normalize()
+	err = savedSearch.Validate()
+	if err != nil {
+		return models.SavedSearch{}, nil, err
+	}
+
+	if savedSearch.ListId != 0 {
+		_, err = getMediaList(c, r, savedSearch.ListId)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return models.SavedSearch{}, nil, err
+		}
+	}
+
+	savedSearch.LastResults = []string{}
+	savedSearch.LastRun = time.Time{}
+	_, err = savedSearch.Create(c, r, currentUser)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.SavedSearch{}, nil, err
+	}
+
+	// Results from now on are the new ones
+	_, err = runSavedSearch(c, r, &savedSearch, currentUser)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+	}
+
+	return savedSearch, nil, nil
+}
+
+/*
+* Update methods
+ */
+
+func UpdateSavedSearch(c context.Context, r *http.Request, id string) (models.SavedSearch, interface{}, error) {
+	savedSearch, user, err := getSavedSearchForUser(c, r, id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.SavedSearch{}, nil, err
+	}
+
+	decoder := ffjson.NewDecoder()
+	buf, _ := ioutil.ReadAll(r.Body)
+	var updatedSavedSearch savedSearchUpdate
+	err = decoder.Decode(buf, &updatedSavedSearch)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.SavedSearch{}, nil, err
+	}
+
+	searchChanged := false
+	if strings.TrimSpace(updatedSavedSearch.Query) != "" && strings.TrimSpace(updatedSavedSearch.Query) != savedSearch.Query {
+		savedSearch.Query = updatedSavedSearch.Query
+		searchChanged = true
+	}
+
+	if updatedSavedSearch.ListId != savedSearch.ListId && updatedSavedSearch.ListId != 0 {
+		_, err = getMediaList(c, r, updatedSavedSearch.ListId)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return models.SavedSearch{}, nil, err
+		}
+		savedSearch.ListId = updatedSavedSearch.ListId
+		searchChanged = true
+	}
+
+	utilities.UpdateIfNotBlank(&savedSearch.Name, updatedSavedSearch.Name)
+	if updatedSavedSearch.Alerts != nil {
+		savedSearch.Alerts = *updatedSavedSearch.Alerts
+	}
+
+	savedSearch.Normalize()
+	err = savedSearch.Validate()
+	if err != nil {
+		return models.SavedSearch{}, nil, err
+	}
+
+	// A different search starts over, so its results aren't all new
+	if searchChanged {
+		savedSearch.LastResults = []string{}
+		savedSearch.LastRun = time.Time{}
+		_, err = runSavedSearch(c, r, &savedSearch, user)
+		if err == nil {
+			return savedSearch, nil, nil
+		}
+		log.Errorf(c, "%v", err)
+	}
+
+	_, err = savedSearch.Save(c)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.SavedSearch{}, nil, err
+	}
+
+	return savedSearch, nil, nil
+}
+
+/*
+* Delete methods
+ */
+
+func DeleteSavedSearch(c context.Context, r *http.Request, id string) (interface{}, interface{}, error) {
+	savedSearch, _, err := getSavedSearchForUser(c, r, id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return nil, nil, err
+	}
+
+	err = nds.Delete(c, savedSearch.Key(c))
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return nil, nil, err
+	}
+
+	return nil, nil, nil
+}
+
+/*
+* Action methods
+ */
+
+// Runs a page of the saved searches that have alerts on, from the "cursor"
+// parameter, and sends each user one digest of the new results of all their
+// searches. The results of a run are only kept once the digest is sent, so a
+// digest that fails is sent again with the same results next time. The cursor
+// of the next page is returned, and is empty after the last page.
+func RunSavedSearches(c context.Context, r *http.Request) (string, error) {
+	query := datastore.NewQuery("SavedSearch").Filter("Alerts =", true).Order("CreatedBy")
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		decodedCursor, err := datastore.DecodeCursor(cursor)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return "", errors.New("Cursor is not valid")
+		}
+		query = query.Start(decodedCursor)
+	}
+
+	savedSearches := []models.SavedSearch{}
+	nextCursor := ""
+	t := query.Run(c)
+	for {
+		cursor, err := t.Cursor()
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return "", err
+		}
+
+		var savedSearch models.SavedSearch
+		k, err := t.Next(&savedSearch)
+		if err == datastore.Done {
+			break
+		}
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return "", err
+		}
+
+		// The next run starts at the first search of another user
+		if len(savedSearches) >= savedSearchRunLimit && savedSearch.CreatedBy != savedSearches[len(savedSearches)-1].CreatedBy {
+			nextCursor = cursor.String()
+			break
+		}
+
+		savedSearch.Format(k, "savedsearches")
+		savedSearches = append(savedSearches, savedSearch)
+	}
+
+	savedSearchesByUser := map[int64][]models.SavedSearch{}
+	for i := 0; i < len(savedSearches); i++ {
+		savedSearchesByUser[savedSearches[i].CreatedBy] = append(savedSearchesByUser[savedSearches[i].CreatedBy], savedSearches[i])
+	}
+
+	for userId, userSavedSearches := range savedSearchesByUser {
+		user, _, err := controllers.GetUserById(c, r, userId)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			continue
+		}
+
+		digests := []emails.CampaignMonitorSavedSearch{}
+		ran := []int{}
+		newResultCounts := make([]int, len(userSavedSearches))
+		resultIds := make([][]string, len(userSavedSearches))
+		for i := 0; i < len(userSavedSearches); i++ {
+			newResults, ids, err := findNewSavedSearchResults(c, r, userSavedSearches[i], user)
+			if err != nil {
+				log.Errorf(c, "%v", userSavedSearches[i].Id)
+				log.Errorf(c, "%v", err)
+				continue
+			}
+
+			ran = append(ran, i)
+			newResultCounts[i] = len(newResults)
+			resultIds[i] = ids
+			if len(newResults) > 0 {
+				digests = append(digests, savedSearchDigest(userSavedSearches[i], newResults))
+			}
+		}
+
+		if len(digests) > 0 {
+			err = emails.SavedSearchDigest(c, user, digests)
+			if err != nil {
+				log.Errorf(c, "%v", err)
+				continue
+			}
+		}
+
+		for i := 0; i < len(ran); i++ {
+			err = recordSavedSearchRun(c, &userSavedSearches[ran[i]], resultIds[ran[i]], newResultCounts[ran[i]])
+			if err != nil {
+				log.Errorf(c, "%v", err)
+			}
+		}
+	}
+
+	return nextCursor, nil
+}
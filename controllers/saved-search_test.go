@@ -0,0 +1,102 @@
+package controllers
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	apiSearch "github.com/news-ai/api/search"
+
+	"github.com/news-ai/tabulae/emails"
+	"github.com/news-ai/tabulae/models"
+)
+
+func TestHeadlineMatchesSearch(t *testing.T) {
+	headline := apiSearch.Headline{Title: "Startup raises a Series A", Summary: "The round was led by Acme", Author: "Jane Doe"}
+
+	tests := []struct {
+		name   string
+		search string
+		want   bool
+	}{
+		{name: "title", search: "series", want: true},
+		{name: "any of the words", search: "fintech acme", want: true},
+		{name: "author", search: "DOE", want: true},
+		{name: "no words match", search: "fintech crypto", want: false},
+		{name: "empty search", search: " ", want: false},
+	}
+
+	for _, test := range tests {
+		matches := headlineMatchesSearch(headline, test.search)
+		if matches != test.want {
+			t.Errorf("%v: matches is %v, want %v", test.name, matches, test.want)
+		}
+	}
+}
+
+func TestSavedSearchDigest(t *testing.T) {
+	defaultSavedSearchDigestResults := savedSearchDigestResults
+	savedSearchDigestResults = 2
+	defer func() {
+		savedSearchDigestResults = defaultSavedSearchDigestResults
+	}()
+
+	savedSearch := models.SavedSearch{Name: "Fintech", Kind: models.SavedSearchHeadlines}
+	newResults := []savedSearchResult{
+		{Title: "One", Subtitle: "Jane", Url: "https://example.com/1"},
+		{Title: "Two", Subtitle: "John", Url: "https://example.com/2"},
+		{Title: "Three", Subtitle: "Janet", Url: "https://example.com/3"},
+	}
+
+	want := emails.CampaignMonitorSavedSearch{
+		NAME:        "Fintech",
+		KIND:        models.SavedSearchHeadlines,
+		NEW_RESULTS: "3",
+		RESULTS: []emails.CampaignMonitorSavedSearchResult{
+			{TITLE: "One", SUBTITLE: "Jane", URL: "https://example.com/1"},
+			{TITLE: "Two", SUBTITLE: "John", URL: "https://example.com/2"},
+		},
+	}
+
+	digest := savedSearchDigest(savedSearch, newResults)
+	if !reflect.DeepEqual(digest, want) {
+		t.Errorf("digest is %+v, want %+v", digest, want)
+	}
+}
+
+func TestUpdateSavedSearchAlerts(t *testing.T) {
+	inst, c, r, user := newTestRequest(t, "PATCH", "/", "")
+	defer inst.Close()
+
+	savedSearch := models.SavedSearch{Name: "Fintech", Kind: models.SavedSearchHeadlines, Query: "fintech", Alerts: true}
+	_, err := savedSearch.Create(c, r, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := strconv.FormatInt(savedSearch.Id, 10)
+
+	tests := []struct {
+		name       string
+		body       string
+		wantName   string
+		wantAlerts bool
+	}{
+		{name: "alerts not sent", body: `{"name": "Fintech news"}`, wantName: "Fintech news", wantAlerts: true},
+		{name: "alerts turned off", body: `{"alerts": false}`, wantName: "Fintech news", wantAlerts: false},
+		{name: "alerts turned on", body: `{"alerts": true}`, wantName: "Fintech news", wantAlerts: true},
+	}
+
+	for _, test := range tests {
+		r.Body = ioutil.NopCloser(strings.NewReader(test.body))
+
+		updatedSavedSearch, _, err := UpdateSavedSearch(c, r, id)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		if updatedSavedSearch.Name != test.wantName || updatedSavedSearch.Alerts != test.wantAlerts {
+			t.Errorf("%v: updated to %v (alerts %v), want %v (alerts %v)", test.name, updatedSavedSearch.Name, updatedSavedSearch.Alerts, test.wantName, test.wantAlerts)
+		}
+	}
+}
@@ -0,0 +1,441 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+
+	"github.com/qedus/nds"
+
+	"github.com/news-ai/api/controllers"
+
+	"github.com/news-ai/tabulae/models"
+	"github.com/news-ai/tabulae/search"
+	"github.com/news-ai/tabulae/sync"
+)
+
+/*
+* Private
+ */
+
+// What a check or reindex of one kind found. A scan that stopped before the
+// end has a cursor to continue from. Documents are orphaned when their entity
+// no longer exists.
+type SearchIndexReport struct {
+	Type string `json:"type"`
+	Kind string `json:"kind"`
+
+	Checked  int     `json:"checked"`
+	Missing  []int64 `json:"missing"`
+	Stale    []int64 `json:"stale"`
+	Orphaned []int64 `json:"orphaned"`
+	Repaired int     `json:"repaired"`
+
+	Cursor string `json:"cursor,omitempty"`
+}
+
+var (
+	searchIndexKinds = []string{search.IndexContacts, search.IndexLists, search.IndexEmails, search.IndexPublications}
+
+	// The resources the sync events of each kind are for
+	searchIndexResources = map[string]string{
+		search.IndexContacts:     "Contact",
+		search.IndexLists:        "List",
+		search.IndexEmails:       "Email",
+		search.IndexPublications: "Publication",
+	}
+
+	// How many entities one request goes through, so it finishes in time
+	searchIndexScanLimit = 5000
+
+	// How many of the most recently updated entities a sample checks, since
+	// those are the ones whose sync messages could have been lost
+	searchIndexSampleSize = 500
+
+	// Changes this recent may still be on their way to search
+	searchIndexDelay = 10 * time.Minute
+)
+
+/*
+* Private methods
+ */
+
+func isSearchIndexKind(kind string) bool {
+	for i := 0; i < len(searchIndexKinds); i++ {
+		if searchIndexKinds[i] == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func requireSearchIndexAdmin(c context.Context, r *http.Request, kind string) error {
+	user, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	if !user.IsAdmin {
+		return errors.New("Forbidden")
+	}
+
+	if !isSearchIndexKind(kind) {
+		return errors.New("Search can be checked for Contact, MediaList, Email or Publication")
+	}
+
+	return nil
+}
+
+// Goes through the keys of a kind from a cursor. The cursor that is returned
+// is empty when every key has been read.
+func scanSearchIndexKeys(c context.Context, kind string, cursor string, limit int) ([]*datastore.Key, string, error) {
+	query := datastore.NewQuery(kind).KeysOnly()
+	if cursor != "" {
+		decodedCursor, err := datastore.DecodeCursor(cursor)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return []*datastore.Key{}, "", errors.New("Cursor is not valid")
+		}
+		query = query.Start(decodedCursor)
+	}
+
+	keys := []*datastore.Key{}
+	t := query.Run(c)
+	for len(keys) < limit {
+		key, err := t.Next(nil)
+		if err == datastore.Done {
+			return keys, "", nil
+		}
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return keys, "", err
+		}
+		keys = append(keys, key)
+	}
+
+	nextCursor, err := t.Cursor()
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return keys, "", err
+	}
+	return keys, nextCursor.String(), nil
+}
+
+func sampleSearchIndexKeys(c context.Context, kind string) ([]*datastore.Key, error) {
+	keys, err := datastore.NewQuery(kind).Order("-Updated").Limit(searchIndexSampleSize).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []*datastore.Key{}, err
+	}
+	return keys, nil
+}
+
+func newSearchIndexReport(kind string, checked int) SearchIndexReport {
+	return SearchIndexReport{
+		Type:     "searchindex",
+		Kind:     kind,
+		Checked:  checked,
+		Missing:  []int64{},
+		Stale:    []int64{},
+		Orphaned: []int64{},
+	}
+}
+
+// When each entity was last updated in the datastore. Entities that don't
+// exist are left out.
+func searchIndexEntityUpdates(c context.Context, kind string, keys []*datastore.Key) (map[int64]time.Time, error) {
+	updated := map[int64]time.Time{}
+
+	var err error
+	switch kind {
+	case search.IndexContacts:
+		contacts := make([]models.Contact, len(keys))
+		err = nds.GetMulti(c, keys, contacts)
+		for i := 0; i < len(contacts); i++ {
+			updated[keys[i].IntID()] = contacts[i].Updated
+		}
+	case search.IndexLists:
+		mediaLists := make([]models.MediaList, len(keys))
+		err = nds.GetMulti(c, keys, mediaLists)
+		for i := 0; i < len(mediaLists); i++ {
+			updated[keys[i].IntID()] = mediaLists[i].Updated
+		}
+	case search.IndexEmails:
+		emails := make([]models.Email, len(keys))
+		err = nds.GetMulti(c, keys, emails)
+		for i := 0; i < len(emails); i++ {
+			updated[keys[i].IntID()] = emails[i].Updated
+		}
+	case search.IndexPublications:
+		publications := make([]models.Publication, len(keys))
+		err = nds.GetMulti(c, keys, publications)
+		for i := 0; i < len(publications); i++ {
+			updated[keys[i].IntID()] = publications[i].Updated
+		}
+	}
+
+	if multiErr, ok := err.(appengine.MultiError); ok {
+		for i := 0; i < len(multiErr); i++ {
+			if multiErr[i] == datastore.ErrNoSuchEntity {
+				delete(updated, keys[i].IntID())
+			} else if multiErr[i] != nil {
+				log.Errorf(c, "%v", multiErr[i])
+				return map[int64]time.Time{}, multiErr[i]
+			}
+		}
+		err = nil
+	}
+
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return map[int64]time.Time{}, err
+	}
+	return updated, nil
+}
+
+// Sends entities through the sync topics again, which index them from the
+// datastore on create and update and remove them on delete. The events are
+// written to the outbox in a few writes and the outbox dispatcher publishes
+// them, so a request doesn't publish thousands of events itself.
+func resyncSearchIndex(c context.Context, kind string, ids []int64, method string) error {
+	_, err := sync.QueueResourceSyncs(c, ids, searchIndexResources[kind], method)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+	return nil
+}
+
+// Compares entities with their documents in search. Documents are missing
+// when they aren't indexed, and stale when the entity changed after they were
+// indexed. Recent changes are left alone since they may still be syncing.
+func checkSearchIndex(c context.Context, kind string, keys []*datastore.Key, repair bool) (SearchIndexReport, error) {
+	report := newSearchIndexReport(kind, len(keys))
+
+	settled := time.Now().Add(-searchIndexDelay)
+	for i := 0; i < len(keys); i += 500 {
+		end := i + 500
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		updated, err := searchIndexEntityUpdates(c, kind, keys[i:end])
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return report, err
+		}
+
+		ids := []int64{}
+		for x := i; x < end; x++ {
+			ids = append(ids, keys[x].IntID())
+		}
+
+		indexed, err := search.GetIndexed(c, kind, ids)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return report, err
+		}
+
+		for x := 0; x < len(ids); x++ {
+			entityUpdated, ok := updated[ids[x]]
+			if !ok || entityUpdated.After(settled) {
+				continue
+			}
+
+			indexedUpdated, ok := indexed[ids[x]]
+			if !ok {
+				report.Missing = append(report.Missing, ids[x])
+			} else if entityUpdated.After(indexedUpdated.Add(searchIndexDelay)) {
+				report.Stale = append(report.Stale, ids[x])
+			}
+		}
+	}
+
+	if repair {
+		repairIds := append(append([]int64{}, report.Missing...), report.Stale...)
+		err := resyncSearchIndex(c, kind, repairIds, sync.ActionUpdate)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return report, err
+		}
+		report.Repaired = len(repairIds)
+	}
+
+	return report, nil
+}
+
+// Goes through the documents in search from the id of the cursor, and finds
+// the ones whose entity is gone. Repairing them removes them from search. The
+// cursor that is returned is empty when every document has been read.
+func checkSearchIndexOrphans(c context.Context, kind string, cursor string, repair bool) (SearchIndexReport, string, error) {
+	after := int64(0)
+	if cursor != "" {
+		var err error
+		after, err = strconv.ParseInt(cursor, 10, 64)
+		if err != nil {
+			return SearchIndexReport{}, "", errors.New("Cursor is not valid")
+		}
+	}
+
+	ids, err := search.GetIndexedIds(c, kind, after, searchIndexScanLimit)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return SearchIndexReport{}, "", err
+	}
+
+	report := newSearchIndexReport(kind, len(ids))
+	for i := 0; i < len(ids); i += 500 {
+		end := i + 500
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		keys := []*datastore.Key{}
+		for x := i; x < end; x++ {
+			keys = append(keys, datastore.NewKey(c, kind, "", ids[x], nil))
+		}
+
+		updated, err := searchIndexEntityUpdates(c, kind, keys)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return report, "", err
+		}
+
+		for x := i; x < end; x++ {
+			if _, ok := updated[ids[x]]; !ok {
+				report.Orphaned = append(report.Orphaned, ids[x])
+			}
+		}
+	}
+
+	if repair {
+		err = resyncSearchIndex(c, kind, report.Orphaned, sync.ActionDelete)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return report, "", err
+		}
+		report.Repaired = len(report.Orphaned)
+	}
+
+	nextCursor := ""
+	if len(ids) == searchIndexScanLimit {
+		nextCursor = strconv.FormatInt(ids[len(ids)-1], 10)
+	}
+	return report, nextCursor, nil
+}
+
+/*
+* Public methods
+ */
+
+/*
+* Action methods
+ */
+
+// Sends every entity of a kind to search again, from the cursor of the last
+// request. Each request goes through searchIndexScanLimit entities.
+func ReindexSearch(c context.Context, r *http.Request) (SearchIndexReport, interface{}, error) {
+	kind := r.URL.Query().Get("kind")
+	err := requireSearchIndexAdmin(c, r, kind)
+	if err != nil {
+		return SearchIndexReport{}, nil, err
+	}
+
+	keys, cursor, err := scanSearchIndexKeys(c, kind, r.URL.Query().Get("cursor"), searchIndexScanLimit)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return SearchIndexReport{}, nil, err
+	}
+
+	ids := []int64{}
+	for i := 0; i < len(keys); i++ {
+		ids = append(ids, keys[i].IntID())
+	}
+
+	err = resyncSearchIndex(c, kind, ids, sync.ActionUpdate)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return SearchIndexReport{}, nil, err
+	}
+
+	report := newSearchIndexReport(kind, len(ids))
+	report.Repaired = len(ids)
+	report.Cursor = cursor
+	return report, nil, nil
+}
+
+// Checks a kind against search. By default it samples the most recently
+// updated entities; with "mode" set to "scan" it goes through every entity
+// from the cursor of the last request, and with "mode" set to "orphans" it
+// goes through the documents in search instead. With "repair" set the
+// missing and stale documents are synced again, and orphaned ones removed.
+func CheckSearchIndex(c context.Context, r *http.Request) (SearchIndexReport, interface{}, error) {
+	kind := r.URL.Query().Get("kind")
+	err := requireSearchIndexAdmin(c, r, kind)
+	if err != nil {
+		return SearchIndexReport{}, nil, err
+	}
+
+	repair := r.URL.Query().Get("repair") == "true"
+	if r.URL.Query().Get("mode") == "orphans" {
+		report, cursor, err := checkSearchIndexOrphans(c, kind, r.URL.Query().Get("cursor"), repair)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return SearchIndexReport{}, nil, err
+		}
+
+		report.Cursor = cursor
+		return report, nil, nil
+	}
+
+	keys := []*datastore.Key{}
+	cursor := ""
+	if r.URL.Query().Get("mode") == "scan" {
+		keys, cursor, err = scanSearchIndexKeys(c, kind, r.URL.Query().Get("cursor"), searchIndexScanLimit)
+	} else {
+		keys, err = sampleSearchIndexKeys(c, kind)
+	}
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return SearchIndexReport{}, nil, err
+	}
+
+	report, err := checkSearchIndex(c, kind, keys, repair)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return SearchIndexReport{}, nil, err
+	}
+
+	report.Cursor = cursor
+	return report, nil, nil
+}
+
+// Samples every kind and repairs what is missing or stale. This runs from
+// cron, so it only logs what it found.
+func CheckSearchIndexes(c context.Context, r *http.Request) error {
+	for i := 0; i < len(searchIndexKinds); i++ {
+		keys, err := sampleSearchIndexKeys(c, searchIndexKinds[i])
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return err
+		}
+
+		report, err := checkSearchIndex(c, searchIndexKinds[i], keys, true)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return err
+		}
+
+		log.Infof(c, "%v: checked %v, missing %v, stale %v, repaired %v", report.Kind, report.Checked, len(report.Missing), len(report.Stale), report.Repaired)
+	}
+
+	return nil
+}
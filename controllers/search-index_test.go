@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+
+	"github.com/qedus/nds"
+
+	"github.com/news-ai/tabulae/models"
+	"github.com/news-ai/tabulae/search"
+	"github.com/news-ai/tabulae/sync"
+)
+
+func TestCheckSearchIndex(t *testing.T) {
+	inst, c, _, _ := newTestRequest(t, "GET", "/", "")
+	defer inst.Close()
+
+	putContact := func(updated time.Time) models.Contact {
+		contact := models.Contact{}
+		contact.Updated = updated
+		k, err := nds.Put(c, datastore.NewIncompleteKey(c, "Contact", nil), &contact)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contact.Id = k.IntID()
+		return contact
+	}
+
+	indexed := putContact(time.Now().Add(-2 * time.Hour))
+	stale := putContact(time.Now().Add(-1 * time.Hour))
+	missing := putContact(time.Now().Add(-2 * time.Hour))
+	recent := putContact(time.Now())
+
+	// The document of the stale contact is from before it last changed, and
+	// the orphaned document has no contact left
+	staleDocument := stale
+	staleDocument.Updated = time.Now().Add(-3 * time.Hour)
+	orphanedDocument := models.Contact{}
+	orphanedDocument.Id = recent.Id + 1000
+
+	memoryBackend := search.NewMemoryBackend()
+	memoryBackend.AddContacts(indexed, staleDocument, orphanedDocument)
+	search.SetBackend(memoryBackend)
+
+	keys := []*datastore.Key{}
+	cursor := ""
+	for {
+		pageKeys, nextCursor, err := scanSearchIndexKeys(c, search.IndexContacts, cursor, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pageKeys) > 3 {
+			t.Fatalf("read %v keys, want at most 3", len(pageKeys))
+		}
+		keys = append(keys, pageKeys...)
+		if nextCursor == "" {
+			break
+		}
+		cursor = nextCursor
+	}
+	if len(keys) != 4 {
+		t.Fatalf("scanned %v keys, want 4", len(keys))
+	}
+
+	report, err := checkSearchIndex(c, search.IndexContacts, keys, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Checked != 4 || !reflect.DeepEqual(report.Missing, []int64{missing.Id}) || !reflect.DeepEqual(report.Stale, []int64{stale.Id}) {
+		t.Errorf("checked %v with %v missing and %v stale, want 4 with %v missing and %v stale", report.Checked, report.Missing, report.Stale, missing.Id, stale.Id)
+	}
+
+	report, cursor, err = checkSearchIndexOrphans(c, search.IndexContacts, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Checked != 3 || !reflect.DeepEqual(report.Orphaned, []int64{orphanedDocument.Id}) || cursor != "" {
+		t.Errorf("checked %v with %v orphaned and cursor %q, want 3 with %v orphaned", report.Checked, report.Orphaned, cursor, orphanedDocument.Id)
+	}
+
+	_, _, err = checkSearchIndexOrphans(c, search.IndexContacts, "next", false)
+	if err == nil {
+		t.Errorf("an invalid cursor was accepted")
+	}
+
+	// Repairing syncs the missing and stale contacts again, and removes the
+	// orphaned document
+	bus := newTestBus()
+	report, err = checkSearchIndex(c, search.IndexContacts, keys, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Repaired != 2 {
+		t.Errorf("repaired %v contacts, want 2", report.Repaired)
+	}
+
+	report, _, err = checkSearchIndexOrphans(c, search.IndexContacts, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Repaired != 1 {
+		t.Errorf("repaired %v documents, want 1", report.Repaired)
+	}
+
+	_, _, err = sync.DispatchOutbox(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []sync.Event{
+		sync.NewResourceChanged("Contact", sync.ActionUpdate, []int64{missing.Id, stale.Id}),
+		sync.NewResourceChanged("Contact", sync.ActionDelete, []int64{orphanedDocument.Id}),
+	}
+	events := testEvents(t, bus, sync.ContactBulkTopicID)
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("events are %+v, want %+v", events, want)
+	}
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/log"
+
+	gcontext "github.com/gorilla/context"
+
+	"github.com/news-ai/api/controllers"
+
+	"github.com/news-ai/tabulae/search"
+)
+
+/*
+* Public methods
+ */
+
+/*
+* Get methods
+ */
+
+// Searches everything the user can see, grouped by type. With "typeahead"
+// set the search is treated as the start of what the user is typing and only
+// the first few results of each type are returned.
+func GetSearch(c context.Context, r *http.Request) ([]search.SearchGroup, interface{}, int, int, error) {
+	user, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []search.SearchGroup{}, nil, 0, 0, err
+	}
+
+	queryField := gcontext.Get(r, "q").(string)
+	typeahead := r.URL.Query().Get("typeahead") == "true"
+
+	// Contacts, lists and emails are searched in the lists the user has a
+	// role on
+	mediaListIds, err := mediaListIdsForUser(c, user)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []search.SearchGroup{}, nil, 0, 0, err
+	}
+
+	groups, err := search.SearchEverything(c, user, mediaListIds, queryField, typeahead, gcontext.Get(r, "limit").(int))
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []search.SearchGroup{}, nil, 0, 0, err
+	}
+
+	total := 0
+	for i := 0; i < len(groups); i++ {
+		total += groups[i].Total
+	}
+
+	return groups, nil, len(groups), total, nil
+}
@@ -0,0 +1,257 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+
+	"github.com/qedus/nds"
+
+	"github.com/news-ai/tabulae/models"
+	"github.com/news-ai/tabulae/sync"
+)
+
+/*
+* Private methods
+ */
+
+func validateSmartQuery(smartQuery models.ContactQuery) error {
+	smartQuery.Format()
+	if len(smartQuery.Filters) == 0 {
+		return errors.New("Smart lists need at least one filter")
+	}
+
+	for i := 0; i < len(smartQuery.Filters); i++ {
+		err := smartQuery.Filters[i].Validate()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Employers one of which every contact matching the query has, from its
+// first "eq" or "in" filter on employers. Empty when there is no such filter.
+func smartQueryEmployers(smartQuery models.ContactQuery) []int64 {
+	smartQuery.Format()
+	for i := 0; i < len(smartQuery.Filters); i++ {
+		if smartQuery.Filters[i].Field != "employers" {
+			continue
+		}
+
+		values := []string{}
+		switch smartQuery.Filters[i].Operator {
+		case "eq":
+			values = []string{smartQuery.Filters[i].Value}
+		case "in":
+			values = smartQuery.Filters[i].Values
+		default:
+			continue
+		}
+
+		employers := []int64{}
+		for x := 0; x < len(values); x++ {
+			employer, err := strconv.ParseInt(strings.TrimSpace(values[x]), 10, 64)
+			if err != nil {
+				return []int64{}
+			}
+			employers = append(employers, employer)
+		}
+		return employers
+	}
+	return []int64{}
+}
+
+// The contacts a smart list can pick from. When its query has a filter on
+// employers only the contacts at one of them are read. Tags aren't filtered
+// on here since tags saved before they were normalized wouldn't be found.
+func filterContactsForSmartList(c context.Context, userId int64, smartQuery models.ContactQuery) ([]models.Contact, error) {
+	query := datastore.NewQuery("Contact").Filter("CreatedBy =", userId).Filter("IsMasterContact =", false).Filter("IsDeleted =", false).KeysOnly()
+
+	ks := []*datastore.Key{}
+	employers := smartQueryEmployers(smartQuery)
+	if len(employers) == 0 {
+		var err error
+		ks, err = query.GetAll(c, nil)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return []models.Contact{}, err
+		}
+	}
+
+	readContacts := map[int64]bool{}
+	for i := 0; i < len(employers); i++ {
+		employerKeys, err := query.Filter("Employers =", employers[i]).GetAll(c, nil)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return []models.Contact{}, err
+		}
+
+		for x := 0; x < len(employerKeys); x++ {
+			if _, ok := readContacts[employerKeys[x].IntID()]; !ok {
+				readContacts[employerKeys[x].IntID()] = true
+				ks = append(ks, employerKeys[x])
+			}
+		}
+	}
+
+	contacts := make([]models.Contact, len(ks))
+	err := nds.GetMulti(c, ks, contacts)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Contact{}, err
+	}
+
+	for i := 0; i < len(contacts); i++ {
+		contacts[i].Format(ks[i], "contacts")
+	}
+
+	return contacts, nil
+}
+
+// When the same person is in more than one list only the most recently
+// updated of their contacts is kept. Contacts stay in their order.
+func uniqueSmartListContacts(contacts []models.Contact) []models.Contact {
+	latestForMaster := map[int64]int{}
+	for i := 0; i < len(contacts); i++ {
+		if contacts[i].ParentContact == 0 {
+			continue
+		}
+
+		if index, ok := latestForMaster[contacts[i].ParentContact]; !ok || contacts[i].Updated.After(contacts[index].Updated) {
+			latestForMaster[contacts[i].ParentContact] = i
+		}
+	}
+
+	uniqueContacts := []models.Contact{}
+	for i := 0; i < len(contacts); i++ {
+		if contacts[i].ParentContact != 0 && latestForMaster[contacts[i].ParentContact] != i {
+			continue
+		}
+		uniqueContacts = append(uniqueContacts, contacts[i])
+	}
+
+	return uniqueContacts
+}
+
+// Evaluates the query of a smart list and sets its contacts to the ones that
+// match it. The list is only saved when its contacts changed, so reading a
+// list that is refreshed on read doesn't write it every time. userContacts
+// keeps the contacts of each user that were read, so refreshing many lists
+// reads them once. It can be nil.
+func refreshSmartList(c context.Context, r *http.Request, mediaList *models.MediaList, userContacts map[int64][]models.Contact) error {
+	if !mediaList.IsSmartList {
+		return errors.New("Media list is not a smart list")
+	}
+
+	// Lists filtered on employers read less than every contact of the user,
+	// so only the other lists share them
+	employers := smartQueryEmployers(mediaList.SmartQuery)
+	contacts, ok := userContacts[mediaList.CreatedBy]
+	if !ok || len(employers) > 0 {
+		var err error
+		contacts, err = filterContactsForSmartList(c, mediaList.CreatedBy, mediaList.SmartQuery)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return err
+		}
+
+		if userContacts != nil && len(employers) == 0 {
+			userContacts[mediaList.CreatedBy] = contacts
+		}
+	}
+
+	contacts, err := evaluateContactQuery(c, r, *mediaList, contacts, mediaList.SmartQuery)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+	contacts = uniqueSmartListContacts(contacts)
+
+	contactIds := []int64{}
+	for i := 0; i < len(contacts); i++ {
+		contactIds = append(contactIds, contacts[i].Id)
+	}
+
+	if int64sEqual(mediaList.Contacts, contactIds) {
+		return nil
+	}
+
+	mediaList.Contacts = contactIds
+	mediaList.SmartRefreshed = time.Now()
+	err = saveMediaListAndSync(c, mediaList, sync.ActionUpdate, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	return nil
+}
+
+/*
+* Public methods
+ */
+
+/*
+* Update methods
+ */
+
+func RefreshSmartList(c context.Context, r *http.Request, id string) (models.MediaList, interface{}, error) {
+	mediaList, _, err := GetMediaList(c, r, id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	err = requireMediaListRole(mediaList, models.ListRoleEditor)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	// Lists refreshed on read already are
+	if !mediaList.RefreshOnRead {
+		err = refreshSmartList(c, r, &mediaList, nil)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return models.MediaList{}, nil, err
+		}
+	}
+
+	return mediaList, nil, nil
+}
+
+// Refreshes every smart list that isn't refreshed when it is read
+func RefreshSmartLists(c context.Context, r *http.Request) error {
+	ks, err := datastore.NewQuery("MediaList").Filter("IsSmartList =", true).Filter("IsDeleted =", false).Filter("RefreshOnRead =", false).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	mediaLists := make([]models.MediaList, len(ks))
+	err = nds.GetMulti(c, ks, mediaLists)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	userContacts := map[int64][]models.Contact{}
+	for i := 0; i < len(mediaLists); i++ {
+		mediaLists[i].Format(ks[i], "lists")
+		err = refreshSmartList(c, r, &mediaLists[i], userContacts)
+		if err != nil {
+			log.Errorf(c, "%v", mediaLists[i].Id)
+			log.Errorf(c, "%v", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/news-ai/tabulae/models"
+)
+
+func TestSmartQueryEmployers(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters []models.ContactFilter
+		want    []int64
+	}{
+		{
+			name:    "no filters",
+			filters: []models.ContactFilter{},
+			want:    []int64{},
+		},
+		{
+			name:    "eq",
+			filters: []models.ContactFilter{{Field: "employers", Operator: "eq", Value: "5"}},
+			want:    []int64{5},
+		},
+		{
+			name:    "in",
+			filters: []models.ContactFilter{{Field: "employers", Operator: "in", Value: "5, 6"}},
+			want:    []int64{5, 6},
+		},
+		{
+			name: "first filter on employers",
+			filters: []models.ContactFilter{
+				{Field: "tags", Operator: "eq", Value: "tech"},
+				{Field: "employers", Operator: "notempty"},
+				{Field: "employers", Operator: "eq", Value: "7"},
+			},
+			want: []int64{7},
+		},
+		{
+			name:    "operator that can match other employers",
+			filters: []models.ContactFilter{{Field: "employers", Operator: "ne", Value: "5"}},
+			want:    []int64{},
+		},
+		{
+			name:    "not an id",
+			filters: []models.ContactFilter{{Field: "employers", Operator: "in", Value: "5,times"}},
+			want:    []int64{},
+		},
+		{
+			name:    "past employers",
+			filters: []models.ContactFilter{{Field: "pastemployers", Operator: "eq", Value: "5"}},
+			want:    []int64{},
+		},
+	}
+
+	for _, test := range tests {
+		employers := smartQueryEmployers(models.ContactQuery{Filters: test.filters})
+		if !reflect.DeepEqual(employers, test.want) {
+			t.Errorf("%v: employers are %v, want %v", test.name, employers, test.want)
+		}
+	}
+}
+
+func TestUniqueSmartListContacts(t *testing.T) {
+	contact := func(id int64, parentContact int64, updated int) models.Contact {
+		contact := models.Contact{ParentContact: parentContact}
+		contact.Id = id
+		contact.Updated = time.Date(2017, time.January, updated, 12, 0, 0, 0, time.UTC)
+		return contact
+	}
+
+	tests := []struct {
+		name     string
+		contacts []models.Contact
+		want     []int64
+	}{
+		{
+			name:     "no masters",
+			contacts: []models.Contact{contact(1, 0, 1), contact(2, 0, 2)},
+			want:     []int64{1, 2},
+		},
+		{
+			name:     "latest of the same person",
+			contacts: []models.Contact{contact(1, 10, 1), contact(2, 0, 1), contact(3, 10, 3), contact(4, 10, 2)},
+			want:     []int64{2, 3},
+		},
+		{
+			name:     "different people",
+			contacts: []models.Contact{contact(1, 10, 1), contact(2, 11, 1)},
+			want:     []int64{1, 2},
+		},
+		{
+			name:     "updated at the same time",
+			contacts: []models.Contact{contact(1, 10, 1), contact(2, 10, 1)},
+			want:     []int64{1},
+		},
+	}
+
+	for _, test := range tests {
+		contacts := uniqueSmartListContacts(test.contacts)
+		ids := []int64{}
+		for i := 0; i < len(contacts); i++ {
+			ids = append(ids, contacts[i].Id)
+		}
+		if !reflect.DeepEqual(ids, test.want) {
+			t.Errorf("%v: kept %v, want %v", test.name, ids, test.want)
+		}
+	}
+}
@@ -0,0 +1,384 @@
+package controllers
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+
+	"github.com/pquerna/ffjson/ffjson"
+	"github.com/qedus/nds"
+
+	"github.com/news-ai/api/controllers"
+
+	apiModels "github.com/news-ai/api/models"
+
+	"github.com/news-ai/tabulae/models"
+	"github.com/news-ai/tabulae/sync"
+)
+
+/*
+* Private
+ */
+
+type renameTagDetails struct {
+	Tag    string `json:"tag"`
+	NewTag string `json:"newtag"`
+	Team   bool   `json:"team"`
+}
+
+type mergeTagsDetails struct {
+	Tags []string `json:"tags"`
+	Into string   `json:"into"`
+	Team bool     `json:"team"`
+}
+
+// How many contacts and lists use a tag
+type TagUsage struct {
+	Tag      string `json:"tag"`
+	Contacts int    `json:"contacts"`
+	Lists    int    `json:"lists"`
+}
+
+type tagsByUsage []TagUsage
+
+func (tu tagsByUsage) Len() int {
+	return len(tu)
+}
+
+func (tu tagsByUsage) Swap(i, j int) {
+	tu[i], tu[j] = tu[j], tu[i]
+}
+
+func (tu tagsByUsage) Less(i, j int) bool {
+	if tu[i].Contacts+tu[i].Lists != tu[j].Contacts+tu[j].Lists {
+		return tu[i].Contacts+tu[i].Lists > tu[j].Contacts+tu[j].Lists
+	}
+	return tu[i].Tag < tu[j].Tag
+}
+
+/*
+* Private methods
+ */
+
+// Tags belong to the user, or to their team when team is set
+func tagsQuery(kind string, user apiModels.User, team bool) (*datastore.Query, error) {
+	query := datastore.NewQuery(kind).Filter("IsDeleted =", false)
+	if team {
+		if user.TeamId == 0 {
+			return nil, errors.New("User is not on a team")
+		}
+		return query.Filter("TeamId =", user.TeamId), nil
+	}
+	return query.Filter("CreatedBy =", user.Id), nil
+}
+
+func getContactsForTags(c context.Context, user apiModels.User, team bool) ([]models.Contact, error) {
+	query, err := tagsQuery("Contact", user, team)
+	if err != nil {
+		return []models.Contact{}, err
+	}
+
+	ks, err := query.KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Contact{}, err
+	}
+
+	contacts := make([]models.Contact, len(ks))
+	err = nds.GetMulti(c, ks, contacts)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Contact{}, err
+	}
+
+	for i := 0; i < len(contacts); i++ {
+		contacts[i].Format(ks[i], "contacts")
+	}
+
+	return contacts, nil
+}
+
+func getMediaListsForTags(c context.Context, user apiModels.User, team bool) ([]models.MediaList, error) {
+	query, err := tagsQuery("MediaList", user, team)
+	if err != nil {
+		return []models.MediaList{}, err
+	}
+
+	ks, err := query.KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.MediaList{}, err
+	}
+
+	mediaLists := make([]models.MediaList, len(ks))
+	err = nds.GetMulti(c, ks, mediaLists)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.MediaList{}, err
+	}
+
+	for i := 0; i < len(mediaLists); i++ {
+		mediaLists[i].Format(ks[i], "lists")
+	}
+
+	return mediaLists, nil
+}
+
+// Replaces the tags in oldTags with newTag. An empty newTag removes them.
+// Returns if anything changed.
+func replaceTags(tags []string, oldTags map[string]bool, newTag string) ([]string, bool) {
+	replacedTags := []string{}
+	changed := false
+	for i := 0; i < len(tags); i++ {
+		tag := models.NormalizeTag(tags[i])
+		if _, ok := oldTags[tag]; ok {
+			changed = true
+			if newTag == "" {
+				continue
+			}
+			tag = newTag
+		}
+		replacedTags = append(replacedTags, tag)
+	}
+	return models.NormalizeTags(replacedTags), changed
+}
+
+// Replaces tags on every contact and list of the user or their team, and
+// syncs the ones that changed so searching by tag keeps working. Only the
+// lists the user can edit, and their contacts, are changed.
+func replaceTagsEverywhere(c context.Context, r *http.Request, oldTags []string, newTag string, team bool) (TagUsage, error) {
+	user, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return TagUsage{}, err
+	}
+
+	oldTagsMap := map[string]bool{}
+	for i := 0; i < len(oldTags); i++ {
+		tag := models.NormalizeTag(oldTags[i])
+		if tag != "" && tag != newTag {
+			oldTagsMap[tag] = true
+		}
+	}
+
+	if len(oldTagsMap) == 0 {
+		return TagUsage{}, errors.New("No tags to change")
+	}
+
+	contacts, err := getContactsForTags(c, user, team)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return TagUsage{}, err
+	}
+
+	canEditLists := map[int64]bool{}
+	changedContacts := []models.Contact{}
+	revisions := []models.ContactRevision{}
+	for i := 0; i < len(contacts); i++ {
+		tags, changed := replaceTags(contacts[i].Tags, oldTagsMap, newTag)
+		if !changed {
+			continue
+		}
+
+		// Master contacts can only be changed by the people who made them
+		if contacts[i].IsMasterContact {
+			if contacts[i].CreatedBy != user.Id {
+				continue
+			}
+		} else {
+			canEdit, ok := canEditLists[contacts[i].ListId]
+			if !ok {
+				canEdit = requireContactRole(c, r, contacts[i], models.ListRoleEditor) == nil
+				canEditLists[contacts[i].ListId] = canEdit
+			}
+
+			if !canEdit {
+				continue
+			}
+		}
+
+		previousContact := contacts[i]
+		contacts[i].Tags = tags
+		contacts[i].Updated = time.Now()
+		changedContacts = append(changedContacts, contacts[i])
+		revisions = append(revisions, contactRevisions(previousContact, contacts[i], models.RevisionSourceTags, user.Id)...)
+	}
+
+	_, err = saveContactsAndSync(c, changedContacts, queueContactsSync(sync.ActionUpdate))
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return TagUsage{}, err
+	}
+
+	err = saveContactRevisions(c, revisions)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+	}
+
+	mediaLists, err := getMediaListsForTags(c, user, team)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return TagUsage{}, err
+	}
+
+	changedLists := 0
+	for i := 0; i < len(mediaLists); i++ {
+		tags, changed := replaceTags(mediaLists[i].Tags, oldTagsMap, newTag)
+		if !changed {
+			continue
+		}
+
+		mediaLists[i].Role = mediaListRoleForUser(mediaLists[i], user)
+		if requireMediaListRole(mediaLists[i], models.ListRoleEditor) != nil {
+			continue
+		}
+
+		mediaLists[i].Tags = tags
+		err = saveMediaListAndSync(c, &mediaLists[i], sync.ActionUpdate, nil)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return TagUsage{}, err
+		}
+		changedLists++
+	}
+
+	TriggerContactUpdatedWebhooks(c, changedContacts)
+
+	tagUsage := TagUsage{
+		Tag:      newTag,
+		Contacts: len(changedContacts),
+		Lists:    changedLists,
+	}
+	return tagUsage, nil
+}
+
+/*
+* Public methods
+ */
+
+/*
+* Get methods
+ */
+
+// Every tag of the user's contacts and lists, or their team's when "team" is
+// set, with how often they are used
+func GetTags(c context.Context, r *http.Request) ([]TagUsage, interface{}, int, int, error) {
+	user, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []TagUsage{}, nil, 0, 0, err
+	}
+
+	team := r.URL.Query().Get("team") == "true"
+
+	contacts, err := getContactsForTags(c, user, team)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []TagUsage{}, nil, 0, 0, err
+	}
+
+	mediaLists, err := getMediaListsForTags(c, user, team)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []TagUsage{}, nil, 0, 0, err
+	}
+
+	usage := map[string]*TagUsage{}
+	tagUsage := func(tag string) *TagUsage {
+		if _, ok := usage[tag]; !ok {
+			usage[tag] = &TagUsage{
+				Tag: tag,
+			}
+		}
+		return usage[tag]
+	}
+
+	for i := 0; i < len(contacts); i++ {
+		tags := models.NormalizeTags(contacts[i].Tags)
+		for x := 0; x < len(tags); x++ {
+			tagUsage(tags[x]).Contacts++
+		}
+	}
+
+	for i := 0; i < len(mediaLists); i++ {
+		tags := models.NormalizeTags(mediaLists[i].Tags)
+		for x := 0; x < len(tags); x++ {
+			tagUsage(tags[x]).Lists++
+		}
+	}
+
+	tags := []TagUsage{}
+	for _, tag := range usage {
+		tags = append(tags, *tag)
+	}
+	sort.Sort(tagsByUsage(tags))
+
+	return tags, nil, len(tags), 0, nil
+}
+
+/*
+* Update methods
+ */
+
+// Renames a tag on every contact and list
+func RenameTag(c context.Context, r *http.Request) (TagUsage, interface{}, error) {
+	buf, _ := ioutil.ReadAll(r.Body)
+	decoder := ffjson.NewDecoder()
+	var renameDetails renameTagDetails
+	err := decoder.Decode(buf, &renameDetails)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return TagUsage{}, nil, err
+	}
+
+	newTag := models.NormalizeTag(renameDetails.NewTag)
+	if newTag == "" {
+		return TagUsage{}, nil, errors.New("Tags can't be renamed to nothing")
+	}
+
+	tagUsage, err := replaceTagsEverywhere(c, r, []string{renameDetails.Tag}, newTag, renameDetails.Team)
+	return tagUsage, nil, err
+}
+
+// Merges several tags into one. The tag merged into doesn't have to be one of
+// the tags being merged.
+func MergeTags(c context.Context, r *http.Request) (TagUsage, interface{}, error) {
+	buf, _ := ioutil.ReadAll(r.Body)
+	decoder := ffjson.NewDecoder()
+	var mergeDetails mergeTagsDetails
+	err := decoder.Decode(buf, &mergeDetails)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return TagUsage{}, nil, err
+	}
+
+	into := models.NormalizeTag(mergeDetails.Into)
+	if into == "" {
+		return TagUsage{}, nil, errors.New("Tags have to be merged into a tag")
+	}
+
+	tagUsage, err := replaceTagsEverywhere(c, r, mergeDetails.Tags, into, mergeDetails.Team)
+	return tagUsage, nil, err
+}
+
+/*
+* Delete methods
+ */
+
+// Removes a tag from every contact and list
+func DeleteTag(c context.Context, r *http.Request, tag string) (interface{}, interface{}, error) {
+	team := r.URL.Query().Get("team") == "true"
+	_, err := replaceTagsEverywhere(c, r, []string{tag}, "", team)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return nil, nil, err
+	}
+	return nil, nil, nil
+}
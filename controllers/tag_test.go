@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReplaceTags(t *testing.T) {
+	tests := []struct {
+		name        string
+		tags        []string
+		oldTags     map[string]bool
+		newTag      string
+		want        []string
+		wantChanged bool
+	}{
+		{
+			name:        "rename",
+			tags:        []string{"Tech", "fashion"},
+			oldTags:     map[string]bool{"tech": true},
+			newTag:      "technology",
+			want:        []string{"technology", "fashion"},
+			wantChanged: true,
+		},
+		{
+			name:        "delete",
+			tags:        []string{"Tech", "fashion"},
+			oldTags:     map[string]bool{"tech": true},
+			want:        []string{"fashion"},
+			wantChanged: true,
+		},
+		{
+			name:        "merge into a tag the contact has",
+			tags:        []string{"tech", "technology"},
+			oldTags:     map[string]bool{"tech": true},
+			newTag:      "technology",
+			want:        []string{"technology"},
+			wantChanged: true,
+		},
+		{
+			name:        "merge several tags",
+			tags:        []string{"a", "b", "c"},
+			oldTags:     map[string]bool{"a": true, "b": true},
+			newTag:      "z",
+			want:        []string{"z", "c"},
+			wantChanged: true,
+		},
+		{
+			name:        "spacing and case",
+			tags:        []string{"  Tech  News "},
+			oldTags:     map[string]bool{"tech news": true},
+			newTag:      "news",
+			want:        []string{"news"},
+			wantChanged: true,
+		},
+		{
+			name:    "other tags",
+			tags:    []string{"fashion"},
+			oldTags: map[string]bool{"tech": true},
+			newTag:  "technology",
+			want:    []string{"fashion"},
+		},
+		{
+			name:    "no tags",
+			oldTags: map[string]bool{"tech": true},
+			newTag:  "technology",
+			want:    []string{},
+		},
+	}
+
+	for _, test := range tests {
+		tags, changed := replaceTags(test.tags, test.oldTags, test.newTag)
+		if !reflect.DeepEqual(tags, test.want) || changed != test.wantChanged {
+			t.Errorf("%v: tags are %v and changed is %v, want %v and %v", test.name, tags, changed, test.want, test.wantChanged)
+		}
+	}
+}
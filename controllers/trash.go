@@ -0,0 +1,501 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+
+	"github.com/qedus/nds"
+
+	"github.com/news-ai/api/controllers"
+
+	"github.com/news-ai/tabulae/models"
+	"github.com/news-ai/tabulae/sync"
+
+	"github.com/news-ai/web/permissions"
+	"github.com/news-ai/web/utilities"
+)
+
+/*
+* Private
+ */
+
+var (
+	// How long deleted lists and contacts can be restored for
+	trashRetention = time.Hour * 24 * 30
+
+	// Expired lists and contacts are purged this many at a time, and at most
+	// trashPurgeBatches times a run. The rest are left for the next run.
+	trashPurgeBatchSize = 500
+	trashPurgeBatches   = 10
+)
+
+type Trash struct {
+	Lists    []models.MediaList `json:"lists"`
+	Contacts []models.Contact   `json:"contacts"`
+}
+
+/*
+* Private methods
+ */
+
+func getDeletedMediaList(c context.Context, r *http.Request, id int64) (models.MediaList, error) {
+	var mediaList models.MediaList
+	mediaListId := datastore.NewKey(c, "MediaList", "", id, nil)
+	err := nds.Get(c, mediaListId, &mediaList)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, err
+	}
+
+	if mediaList.Created.IsZero() || !mediaList.IsDeleted {
+		return models.MediaList{}, errors.New("No deleted media list by this id")
+	}
+	mediaList.Format(mediaListId, "lists")
+
+	user, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, errors.New("Could not get user")
+	}
+
+	// Only owners of a list can restore it
+	if mediaList.RoleForUser(user) != models.ListRoleOwner {
+		return models.MediaList{}, errors.New("Forbidden")
+	}
+
+	return mediaList, nil
+}
+
+func filterDeletedContactsForListId(c context.Context, listId int64) ([]models.Contact, error) {
+	ks, err := datastore.NewQuery("Contact").Filter("ListId =", listId).Filter("IsDeleted =", true).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Contact{}, err
+	}
+
+	contacts := make([]models.Contact, len(ks))
+	err = nds.GetMulti(c, ks, contacts)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Contact{}, err
+	}
+
+	for i := 0; i < len(contacts); i++ {
+		contacts[i].Format(ks[i], "contacts")
+	}
+
+	return contacts, nil
+}
+
+// Takes the ids of contacts out of the lists they are in, so the lists don't
+// point at them once they are purged. Lists in the trash are left alone, since
+// restoring them only brings back the contacts that still exist.
+func removeContactsFromMediaLists(c context.Context, contacts []models.Contact) error {
+	removedContacts := map[int64]map[int64]bool{}
+	for i := 0; i < len(contacts); i++ {
+		if contacts[i].ListId == 0 {
+			continue
+		}
+		if _, ok := removedContacts[contacts[i].ListId]; !ok {
+			removedContacts[contacts[i].ListId] = map[int64]bool{}
+		}
+		removedContacts[contacts[i].ListId][contacts[i].Id] = true
+	}
+
+	for listId, contactIds := range removedContacts {
+		var mediaList models.MediaList
+		mediaListId := datastore.NewKey(c, "MediaList", "", listId, nil)
+		err := nds.Get(c, mediaListId, &mediaList)
+		if err == datastore.ErrNoSuchEntity {
+			continue
+		}
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return err
+		}
+
+		if mediaList.IsDeleted {
+			continue
+		}
+		mediaList.Format(mediaListId, "lists")
+
+		remainingContacts := []int64{}
+		for i := 0; i < len(mediaList.Contacts); i++ {
+			if _, ok := contactIds[mediaList.Contacts[i]]; !ok {
+				remainingContacts = append(remainingContacts, mediaList.Contacts[i])
+			}
+		}
+
+		if len(remainingContacts) == len(mediaList.Contacts) {
+			continue
+		}
+
+		mediaList.Contacts = remainingContacts
+		err = saveMediaListAndSync(c, &mediaList, sync.ActionUpdate, nil)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Purges a batch of expired contacts. Returns how many were purged.
+func purgeExpiredContacts(c context.Context) (int, error) {
+	contacts, err := FilterExpiredContacts(c, trashPurgeBatchSize)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return 0, err
+	}
+
+	err = removeContactsFromMediaLists(c, contacts)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return 0, err
+	}
+
+	keys := []*datastore.Key{}
+	for i := 0; i < len(contacts); i++ {
+		keys = append(keys, contacts[i].Key(c))
+	}
+
+	err = nds.DeleteMulti(c, keys)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return 0, err
+	}
+
+	return len(contacts), nil
+}
+
+// Purges a batch of expired lists and their snapshots. Returns how many were
+// purged.
+func purgeExpiredMediaLists(c context.Context) (int, error) {
+	mediaLists, err := FilterExpiredMediaLists(c, trashPurgeBatchSize)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return 0, err
+	}
+
+	keys := []*datastore.Key{}
+	for i := 0; i < len(mediaLists); i++ {
+		err = deleteSnapshotsForList(c, mediaLists[i].Id)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return 0, err
+		}
+		keys = append(keys, mediaLists[i].BaseKey(c, "MediaList"))
+	}
+
+	err = nds.DeleteMulti(c, keys)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return 0, err
+	}
+
+	return len(mediaLists), nil
+}
+
+/*
+* Public methods
+ */
+
+/*
+* Get methods
+ */
+
+// Gets the lists and contacts the user deleted that can still be restored
+func GetTrash(c context.Context, r *http.Request) (Trash, interface{}, error) {
+	user, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return Trash{}, nil, err
+	}
+
+	cutoff := time.Now().Add(-trashRetention)
+	trash := Trash{
+		Lists:    []models.MediaList{},
+		Contacts: []models.Contact{},
+	}
+
+	ks, err := datastore.NewQuery("MediaList").Filter("CreatedBy =", user.Id).Filter("IsDeleted =", true).Filter("Deleted >", cutoff).Order("-Deleted").KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return Trash{}, nil, err
+	}
+
+	trash.Lists = make([]models.MediaList, len(ks))
+	err = nds.GetMulti(c, ks, trash.Lists)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return Trash{}, nil, err
+	}
+
+	deletedLists := map[int64]bool{}
+	for i := 0; i < len(trash.Lists); i++ {
+		trash.Lists[i].Format(ks[i], "lists")
+		deletedLists[trash.Lists[i].Id] = true
+	}
+
+	ks, err = datastore.NewQuery("Contact").Filter("CreatedBy =", user.Id).Filter("IsDeleted =", true).Filter("Deleted >", cutoff).Order("-Deleted").KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return Trash{}, nil, err
+	}
+
+	contacts := make([]models.Contact, len(ks))
+	err = nds.GetMulti(c, ks, contacts)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return Trash{}, nil, err
+	}
+
+	// Contacts of deleted lists come back with their list
+	for i := 0; i < len(contacts); i++ {
+		contacts[i].Format(ks[i], "contacts")
+		if _, ok := deletedLists[contacts[i].ListId]; !ok {
+			trash.Contacts = append(trash.Contacts, contacts[i])
+		}
+	}
+
+	return trash, nil, nil
+}
+
+// Lists that have been in the trash for longer than the retention period
+func FilterExpiredMediaLists(c context.Context, limit int) ([]models.MediaList, error) {
+	cutoff := time.Now().Add(-trashRetention)
+	ks, err := datastore.NewQuery("MediaList").Filter("IsDeleted =", true).Filter("Deleted >", time.Time{}).Filter("Deleted <", cutoff).Limit(limit).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.MediaList{}, err
+	}
+
+	mediaLists := make([]models.MediaList, len(ks))
+	err = nds.GetMulti(c, ks, mediaLists)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.MediaList{}, err
+	}
+
+	for i := 0; i < len(mediaLists); i++ {
+		mediaLists[i].Format(ks[i], "lists")
+	}
+
+	return mediaLists, nil
+}
+
+// Contacts that have been in the trash for longer than the retention period
+func FilterExpiredContacts(c context.Context, limit int) ([]models.Contact, error) {
+	cutoff := time.Now().Add(-trashRetention)
+	ks, err := datastore.NewQuery("Contact").Filter("IsDeleted =", true).Filter("Deleted >", time.Time{}).Filter("Deleted <", cutoff).Limit(limit).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Contact{}, err
+	}
+
+	contacts := make([]models.Contact, len(ks))
+	err = nds.GetMulti(c, ks, contacts)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Contact{}, err
+	}
+
+	for i := 0; i < len(contacts); i++ {
+		contacts[i].Format(ks[i], "contacts")
+	}
+
+	return contacts, nil
+}
+
+/*
+* Delete methods
+ */
+
+// Permanently deletes the contacts and lists that have been in the trash for
+// longer than the retention period. Contacts go first, so they are taken out
+// of their lists before the lists go.
+func PurgeTrash(c context.Context) error {
+	for i := 0; i < trashPurgeBatches; i++ {
+		purged, err := purgeExpiredContacts(c)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return err
+		}
+		if purged < trashPurgeBatchSize {
+			break
+		}
+	}
+
+	for i := 0; i < trashPurgeBatches; i++ {
+		purged, err := purgeExpiredMediaLists(c)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return err
+		}
+		if purged < trashPurgeBatchSize {
+			break
+		}
+	}
+
+	return nil
+}
+
+/*
+* Update methods
+ */
+
+// Brings a list back from the trash along with the contacts that were
+// deleted with it
+func RestoreMediaList(c context.Context, r *http.Request, id string) (models.MediaList, interface{}, error) {
+	currentId, err := utilities.StringIdToInt(id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	mediaList, err := getDeletedMediaList(c, r, currentId)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	// Smart lists were deleted without their contacts, and pick them again
+	if mediaList.IsSmartList {
+		mediaList.IsDeleted = false
+		mediaList.Deleted = time.Time{}
+		err = saveMediaListAndSync(c, &mediaList, sync.ActionCreate, nil)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return models.MediaList{}, nil, err
+		}
+
+		err = refreshSmartList(c, r, &mediaList, nil)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return models.MediaList{}, nil, err
+		}
+		return mediaList, nil, nil
+	}
+
+	contacts, err := filterDeletedContactsForListId(c, mediaList.Id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	// Contacts deleted on their own before the list stay in the trash
+	restoredContacts := []models.Contact{}
+	restored := map[int64]bool{}
+	for i := 0; i < len(contacts); i++ {
+		if contacts[i].Deleted.Before(mediaList.Deleted) {
+			continue
+		}
+
+		contacts[i].IsDeleted = false
+		contacts[i].Deleted = time.Time{}
+		contacts[i].Updated = time.Now()
+		restoredContacts = append(restoredContacts, contacts[i])
+		restored[contacts[i].Id] = true
+	}
+
+	_, err = saveContactsAndSync(c, restoredContacts, queueListContactsSync(mediaList.Id))
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	// Rebuild the contacts of the list in their original order
+	contactIds := []int64{}
+	for i := 0; i < len(mediaList.Contacts); i++ {
+		if _, ok := restored[mediaList.Contacts[i]]; ok {
+			contactIds = append(contactIds, mediaList.Contacts[i])
+			delete(restored, mediaList.Contacts[i])
+		}
+	}
+	for i := 0; i < len(restoredContacts); i++ {
+		if _, ok := restored[restoredContacts[i].Id]; ok {
+			contactIds = append(contactIds, restoredContacts[i].Id)
+		}
+	}
+
+	mediaList.Contacts = contactIds
+	mediaList.IsDeleted = false
+	mediaList.Deleted = time.Time{}
+	err = saveMediaListAndSync(c, &mediaList, sync.ActionCreate, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, nil, err
+	}
+
+	return mediaList, nil, nil
+}
+
+// Brings a single contact back from the trash
+func RestoreContact(c context.Context, r *http.Request, id string) (models.Contact, interface{}, error) {
+	currentId, err := utilities.StringIdToInt(id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Contact{}, nil, err
+	}
+
+	contact, err := getContact(c, r, currentId)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Contact{}, nil, err
+	}
+
+	user, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Contact{}, nil, err
+	}
+
+	if !contact.IsDeleted {
+		return models.Contact{}, nil, errors.New("Contact is not deleted")
+	}
+
+	// Double check permissions. Admins should not be able to restore.
+	if !permissions.AccessToObject(contact.CreatedBy, user.Id) {
+		return models.Contact{}, nil, errors.New("Forbidden")
+	}
+
+	contact.IsDeleted = false
+	contact.Deleted = time.Time{}
+	err = saveAndSync(c, "Contact", sync.ActionCreate, func(ctx context.Context) (int64, error) {
+		_, err := contact.Save(ctx, r)
+		return contact.Id, err
+	})
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Contact{}, nil, err
+	}
+
+	// Add it back to its list if it was taken out
+	mediaList, err := getMediaList(c, r, contact.ListId)
+	if err == nil {
+		contactExists := false
+		for i := 0; i < len(mediaList.Contacts); i++ {
+			if mediaList.Contacts[i] == contact.Id {
+				contactExists = true
+			}
+		}
+
+		if !contactExists {
+			mediaList.Contacts = append(mediaList.Contacts, contact.Id)
+			err = saveMediaListAndSync(c, &mediaList, sync.ActionUpdate, nil)
+			if err != nil {
+				log.Errorf(c, "%v", err)
+				return models.Contact{}, nil, err
+			}
+		}
+	}
+
+	return contact, nil, nil
+}
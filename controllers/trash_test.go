@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+
+	"github.com/qedus/nds"
+
+	"github.com/news-ai/tabulae/models"
+	"github.com/news-ai/tabulae/sync"
+)
+
+func TestPurgeTrash(t *testing.T) {
+	inst, c, r, user := newTestRequest(t, "POST", "/", "")
+	defer inst.Close()
+	newTestBus()
+
+	mediaList := models.MediaList{Name: "Launch"}
+	mediaList.CreatedBy = user.Id
+	mediaList.Created = time.Now()
+	_, err := mediaList.Save(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired := models.Contact{Email: "expired@example.com", ListId: mediaList.Id, IsDeleted: true, Deleted: time.Now().Add(-trashRetention - time.Hour)}
+	deleted := models.Contact{Email: "deleted@example.com", ListId: mediaList.Id, IsDeleted: true, Deleted: time.Now()}
+	active := models.Contact{Email: "active@example.com", ListId: mediaList.Id}
+	contacts := []models.Contact{expired, deleted, active}
+	for i := 0; i < len(contacts); i++ {
+		contacts[i].CreatedBy = user.Id
+	}
+	_, err = saveContactsAndSync(c, contacts, queueContactsSync(sync.ActionCreate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, deleted, active = contacts[0], contacts[1], contacts[2]
+
+	mediaList.Contacts = []int64{expired.Id, deleted.Id, active.Id}
+	_, err = mediaList.Save(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = PurgeTrash(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var purgedContact models.Contact
+	err = nds.Get(c, expired.Key(c), &purgedContact)
+	if err != datastore.ErrNoSuchEntity {
+		t.Errorf("getting the expired contact returned %v, want %v", err, datastore.ErrNoSuchEntity)
+	}
+
+	var savedMediaList models.MediaList
+	err = nds.Get(c, mediaList.BaseKey(c, "MediaList"), &savedMediaList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{deleted.Id, active.Id}; !reflect.DeepEqual(savedMediaList.Contacts, want) {
+		t.Errorf("list has contacts %v, want %v", savedMediaList.Contacts, want)
+	}
+
+	// Lists that still point at a purged contact can be read
+	existingContacts, err := GetContactsByIds(c, r, []int64{expired.Id, active.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(existingContacts) != 1 || existingContacts[0].Id != active.Id {
+		t.Errorf("got contacts %+v, want only %v", existingContacts, active.Id)
+	}
+}
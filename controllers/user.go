@@ -29,14 +29,15 @@ func RegisterUser(r *http.Request, user models.User) (models.User, bool, error)
 		}
 
 		// Add the user to datastore
-		_, err = user.Create(c, r)
+		err = saveAndSync(c, "User", sync.ActionCreate, func(ctx context.Context) (int64, error) {
+			_, err := user.Create(ctx, r)
+			return user.Id, err
+		})
 		if err != nil {
 			log.Errorf(c, "%v", err)
 			return user, false, err
 		}
 
-		sync.ResourceSync(r, user.Id, "User", "create")
-
 		// Set the user
 		gcontext.Set(r, "user", user)
 		controllers.Update(c, r, &user)
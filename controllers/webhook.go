@@ -0,0 +1,748 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+	"google.golang.org/appengine/urlfetch"
+
+	gcontext "github.com/gorilla/context"
+	"github.com/pquerna/ffjson/ffjson"
+	"github.com/qedus/nds"
+
+	"github.com/news-ai/api/controllers"
+	apiModels "github.com/news-ai/api/models"
+
+	"github.com/news-ai/tabulae/models"
+
+	"github.com/news-ai/web/utilities"
+)
+
+/*
+* Private
+ */
+
+// What is posted to a webhook
+type webhookPayload struct {
+	Event   string      `json:"event"`
+	Created time.Time   `json:"created"`
+	Data    interface{} `json:"data"`
+}
+
+// The parts of an email a webhook gets. The body is left out.
+type webhookEmail struct {
+	Id        int64     `json:"id"`
+	ListId    int64     `json:"listid"`
+	ContactId int64     `json:"contactid"`
+	To        string    `json:"to"`
+	Subject   string    `json:"subject"`
+	SendAt    time.Time `json:"sendat"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+// Webhooks are looked up by the user and team an event is for
+type webhookOwner struct {
+	UserId int64
+	TeamId int64
+}
+
+// An event for the webhooks of a user and their team
+type webhookEvent struct {
+	webhookOwner
+
+	Data interface{}
+}
+
+type webhookList struct {
+	Id             int64   `json:"id"`
+	Name           string  `json:"name"`
+	ContactIds     []int64 `json:"contactids"`
+	PublicationIds []int64 `json:"publicationids"`
+}
+
+// The fields of a webhook that can be changed. Active is only changed when
+// it is sent.
+type webhookUpdate struct {
+	Name         string   `json:"name"`
+	Url          string   `json:"url"`
+	Events       []string `json:"events"`
+	Active       *bool    `json:"active"`
+	RotateSecret bool     `json:"rotatesecret"`
+}
+
+var (
+	// Failed deliveries are tried again, waiting twice as long each time
+	webhookMaxAttempts = 8
+	webhookBackoff     = time.Minute
+	webhookMaxBackoff  = 12 * time.Hour
+
+	webhookTimeout = 10 * time.Second
+
+	// How many deliveries one dispatch sends
+	webhookBatchSize = 100
+
+	// How long delivery logs are kept
+	webhookDeliveryRetention = 30 * 24 * time.Hour
+)
+
+/*
+* Private methods
+ */
+
+func getWebhook(c context.Context, id int64) (models.Webhook, error) {
+	if id == 0 {
+		return models.Webhook{}, errors.New("datastore: no such entity")
+	}
+
+	var webhook models.Webhook
+	webhookId := datastore.NewKey(c, "Webhook", "", id, nil)
+
+	err := nds.Get(c, webhookId, &webhook)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Webhook{}, err
+	}
+
+	if !webhook.Created.IsZero() {
+		webhook.Format(webhookId, "webhooks")
+		return webhook, nil
+	}
+	return models.Webhook{}, errors.New("No webhook by this id")
+}
+
+// Webhooks can be seen and changed by the user who created them, and by the
+// team when they are for the team
+func getWebhookForUser(c context.Context, r *http.Request, id string) (models.Webhook, apiModels.User, error) {
+	currentId, err := utilities.StringIdToInt(id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Webhook{}, apiModels.User{}, err
+	}
+
+	user, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Webhook{}, apiModels.User{}, err
+	}
+
+	webhook, err := getWebhook(c, currentId)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Webhook{}, apiModels.User{}, err
+	}
+
+	teamWebhook := webhook.TeamId != 0 && webhook.TeamId == user.TeamId
+	if webhook.CreatedBy != user.Id && !teamWebhook && !user.IsAdmin {
+		return models.Webhook{}, apiModels.User{}, errors.New("Forbidden")
+	}
+
+	return webhook, user, nil
+}
+
+func generateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+// The hex HMAC-SHA256 of the timestamp, a dot and the payload, keyed with the
+// secret of the webhook. Receivers compute the same to check the payload came
+// from us, and check the timestamp is recent so it can't be replayed.
+func webhookSignature(secret string, timestamp string, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func webhookBackoffFor(attempts int) time.Duration {
+	backoff := webhookBackoff
+	for i := 1; i < attempts && backoff < webhookMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > webhookMaxBackoff {
+		backoff = webhookMaxBackoff
+	}
+	return backoff
+}
+
+// The active webhooks of the user and of their team that get the event
+func webhooksForEvent(c context.Context, userId int64, teamId int64, event string) ([]models.Webhook, error) {
+	queries := []*datastore.Query{
+		datastore.NewQuery("Webhook").Filter("CreatedBy =", userId).Filter("Active =", true),
+	}
+	if teamId != 0 {
+		queries = append(queries, datastore.NewQuery("Webhook").Filter("TeamId =", teamId).Filter("Active =", true))
+	}
+
+	webhooks := []models.Webhook{}
+	seen := map[int64]bool{}
+	for i := 0; i < len(queries); i++ {
+		ks, err := queries[i].KeysOnly().GetAll(c, nil)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return []models.Webhook{}, err
+		}
+
+		queryWebhooks := make([]models.Webhook, len(ks))
+		err = nds.GetMulti(c, ks, queryWebhooks)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return []models.Webhook{}, err
+		}
+
+		for x := 0; x < len(queryWebhooks); x++ {
+			queryWebhooks[x].Format(ks[x], "webhooks")
+			if _, ok := seen[queryWebhooks[x].Id]; ok || !queryWebhooks[x].HasEvent(event) {
+				continue
+			}
+			seen[queryWebhooks[x].Id] = true
+			webhooks = append(webhooks, queryWebhooks[x])
+		}
+	}
+
+	return webhooks, nil
+}
+
+func buildWebhookDelivery(c context.Context, webhook models.Webhook, event string, data interface{}) (models.WebhookDelivery, error) {
+	payload, err := json.Marshal(webhookPayload{
+		Event:   event,
+		Created: time.Now(),
+		Data:    data,
+	})
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.WebhookDelivery{}, err
+	}
+
+	delivery := models.WebhookDelivery{
+		WebhookId:   webhook.Id,
+		Event:       event,
+		Payload:     string(payload),
+		Status:      models.WebhookDeliveryPending,
+		NextAttempt: time.Now(),
+	}
+	delivery.CreatedBy = webhook.CreatedBy
+	return delivery, nil
+}
+
+func newWebhookDelivery(c context.Context, webhook models.Webhook, event string, data interface{}) (models.WebhookDelivery, error) {
+	delivery, err := buildWebhookDelivery(c, webhook, event, data)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+
+	_, err = delivery.Create(c)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.WebhookDelivery{}, err
+	}
+	return delivery, nil
+}
+
+// Posts the payload of a delivery, signed, and records the response code
+func postWebhook(c context.Context, webhook models.Webhook, delivery *models.WebhookDelivery) error {
+	contextWithTimeout, cancel := context.WithTimeout(c, webhookTimeout)
+	defer cancel()
+	client := urlfetch.Client(contextWithTimeout)
+
+	req, err := http.NewRequest("POST", webhook.Url, bytes.NewBufferString(delivery.Payload))
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Tabulae-Webhooks")
+	req.Header.Set("X-Tabulae-Event", delivery.Event)
+	req.Header.Set("X-Tabulae-Delivery", strconv.FormatInt(delivery.Id, 10))
+	req.Header.Set("X-Tabulae-Timestamp", timestamp)
+	req.Header.Set("X-Tabulae-Signature", "sha256="+webhookSignature(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	delivery.ResponseCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("The webhook responded with " + resp.Status)
+	}
+	return nil
+}
+
+// Posts a delivery to its webhook and records how it went. Deliveries that
+// fail are tried again later until they have had maxAttempts.
+func deliverWebhook(c context.Context, webhook models.Webhook, delivery *models.WebhookDelivery, maxAttempts int) error {
+	// Webhooks made before their urls were checked aren't sent to either
+	sendErr := webhook.ValidateUrl()
+	if sendErr == nil {
+		sendErr = postWebhook(c, webhook, delivery)
+	}
+
+	delivery.Attempts += 1
+	if sendErr == nil {
+		delivery.Status = models.WebhookDeliveryDelivered
+		delivery.Delivered = time.Now()
+		delivery.LastError = ""
+	} else {
+		delivery.LastError = sendErr.Error()
+		delivery.NextAttempt = time.Now().Add(webhookBackoffFor(delivery.Attempts))
+		if delivery.Attempts >= maxAttempts {
+			delivery.Status = models.WebhookDeliveryFailed
+		}
+	}
+
+	_, err := delivery.Save(c)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	return sendErr
+}
+
+func purgeWebhookDeliveries(c context.Context) error {
+	ks, err := datastore.NewQuery("WebhookDelivery").Filter("Created <", time.Now().Add(-webhookDeliveryRetention)).Limit(500).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	err = nds.DeleteMulti(c, ks)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+	return nil
+}
+
+// Queues deliveries of the event. The webhooks of each user and team are
+// looked up once, so events for many resources of the same user cost one
+// lookup, and the deliveries are written together. Errors are only logged.
+func triggerWebhookEvents(c context.Context, event string, events []webhookEvent) {
+	deliveries := []models.WebhookDelivery{}
+	ownerWebhooks := map[webhookOwner][]models.Webhook{}
+	for i := 0; i < len(events); i++ {
+		webhooks, ok := ownerWebhooks[events[i].webhookOwner]
+		if !ok {
+			var err error
+			webhooks, err = webhooksForEvent(c, events[i].UserId, events[i].TeamId, event)
+			if err != nil {
+				log.Errorf(c, "%v", err)
+			}
+			ownerWebhooks[events[i].webhookOwner] = webhooks
+		}
+
+		for x := 0; x < len(webhooks); x++ {
+			delivery, err := buildWebhookDelivery(c, webhooks[x], event, events[i].Data)
+			if err != nil {
+				continue
+			}
+			delivery.Created = time.Now()
+			delivery.Updated = time.Now()
+			deliveries = append(deliveries, delivery)
+		}
+	}
+
+	if len(deliveries) == 0 {
+		return
+	}
+
+	keys := []*datastore.Key{}
+	for i := 0; i < len(deliveries); i++ {
+		keys = append(keys, deliveries[i].Key(c))
+	}
+
+	_, err := putMultiInBatches(c, keys, deliveries)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+	}
+}
+
+// The events for contacts that were changed together, for their owners
+func contactWebhookEvents(contacts []models.Contact) []webhookEvent {
+	events := []webhookEvent{}
+	for i := 0; i < len(contacts); i++ {
+		events = append(events, webhookEvent{
+			webhookOwner: webhookOwner{UserId: contacts[i].CreatedBy, TeamId: contacts[i].TeamId},
+			Data:         contacts[i],
+		})
+	}
+	return events
+}
+
+func webhookEmailData(email models.Email, reason string) webhookEmail {
+	return webhookEmail{
+		Id:        email.Id,
+		ListId:    email.ListId,
+		ContactId: email.ContactId,
+		To:        email.To,
+		Subject:   email.Subject,
+		SendAt:    email.SendAt,
+		Reason:    reason,
+	}
+}
+
+/*
+* Public methods
+ */
+
+// Queues the event for every webhook of the user and their team that gets
+// it. Deliveries are sent by DispatchWebhooks, so the change that caused the
+// event doesn't wait for them. Errors are only logged.
+func TriggerWebhooks(c context.Context, userId int64, teamId int64, event string, data interface{}) {
+	triggerWebhookEvents(c, event, []webhookEvent{{
+		webhookOwner: webhookOwner{UserId: userId, TeamId: teamId},
+		Data:         data,
+	}})
+}
+
+func TriggerEmailWebhooks(c context.Context, event string, emails []models.Email) {
+	events := []webhookEvent{}
+	for i := 0; i < len(emails); i++ {
+		events = append(events, webhookEvent{
+			webhookOwner: webhookOwner{UserId: emails[i].CreatedBy, TeamId: emails[i].TeamId},
+			Data:         webhookEmailData(emails[i], ""),
+		})
+	}
+	triggerWebhookEvents(c, event, events)
+}
+
+// Queues contact.created for contacts that were created together, like by
+// an upload or a copy
+func TriggerContactCreatedWebhooks(c context.Context, contacts []models.Contact) {
+	triggerWebhookEvents(c, models.WebhookContactCreated, contactWebhookEvents(contacts))
+}
+
+// Queues contact.updated for contacts that were changed together, like by a
+// bulk edit or a merge
+func TriggerContactUpdatedWebhooks(c context.Context, contacts []models.Contact) {
+	triggerWebhookEvents(c, models.WebhookContactUpdated, contactWebhookEvents(contacts))
+}
+
+func TriggerListUploadedWebhooks(c context.Context, mediaList models.MediaList, publicationIds []int64) {
+	TriggerWebhooks(c, mediaList.CreatedBy, mediaList.TeamId, models.WebhookListUploaded, webhookList{
+		Id:             mediaList.Id,
+		Name:           mediaList.Name,
+		ContactIds:     mediaList.Contacts,
+		PublicationIds: publicationIds,
+	})
+}
+
+/*
+* Get methods
+ */
+
+func GetWebhook(c context.Context, r *http.Request, id string) (models.Webhook, interface{}, error) {
+	webhook, _, err := getWebhookForUser(c, r, id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Webhook{}, nil, err
+	}
+
+	return webhook, nil, nil
+}
+
+// The webhooks of the user, or of their team with "team" set
+func GetWebhooks(c context.Context, r *http.Request) ([]models.Webhook, interface{}, int, int, error) {
+	user, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Webhook{}, nil, 0, 0, err
+	}
+
+	query := datastore.NewQuery("Webhook").Filter("CreatedBy =", user.Id)
+	if r.URL.Query().Get("team") == "true" {
+		if user.TeamId == 0 {
+			return []models.Webhook{}, nil, 0, 0, nil
+		}
+		query = datastore.NewQuery("Webhook").Filter("TeamId =", user.TeamId)
+	}
+
+	query = controllers.ConstructQuery(query, r)
+	ks, err := query.KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Webhook{}, nil, 0, 0, err
+	}
+
+	webhooks := make([]models.Webhook, len(ks))
+	err = nds.GetMulti(c, ks, webhooks)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Webhook{}, nil, 0, 0, err
+	}
+
+	for i := 0; i < len(webhooks); i++ {
+		webhooks[i].Format(ks[i], "webhooks")
+	}
+
+	return webhooks, nil, len(webhooks), 0, nil
+}
+
+// The delivery log of a webhook, newest first
+func GetWebhookDeliveries(c context.Context, r *http.Request, id string) ([]models.WebhookDelivery, interface{}, int, int, error) {
+	webhook, _, err := getWebhookForUser(c, r, id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.WebhookDelivery{}, nil, 0, 0, err
+	}
+
+	offset := gcontext.Get(r, "offset").(int)
+	limit := gcontext.Get(r, "limit").(int)
+
+	ks, err := datastore.NewQuery("WebhookDelivery").Filter("WebhookId =", webhook.Id).Order("-Created").Offset(offset).Limit(limit).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.WebhookDelivery{}, nil, 0, 0, err
+	}
+
+	deliveries := make([]models.WebhookDelivery, len(ks))
+	err = nds.GetMulti(c, ks, deliveries)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.WebhookDelivery{}, nil, 0, 0, err
+	}
+
+	for i := 0; i < len(deliveries); i++ {
+		deliveries[i].Format(ks[i], "webhookdeliveries")
+	}
+
+	return deliveries, nil, len(deliveries), 0, nil
+}
+
+/*
+* Create methods
+ */
+
+func CreateWebhook(c context.Context, r *http.Request) (models.Webhook, interface{}, error) {
+	buf, _ := ioutil.ReadAll(r.Body)
+	decoder := ffjson.NewDecoder()
+	var webhook models.Webhook
+	err := decoder.Decode(buf, &webhook)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Webhook{}, nil, err
+	}
+
+	currentUser, err := controllers.GetCurrentUser(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Webhook{}, nil, err
+	}
+
+	webhook.Normalize()
+	err = webhook.Validate()
+	if err != nil {
+		return models.Webhook{}, nil, err
+	}
+
+	if webhook.TeamId != 0 && webhook.TeamId != currentUser.TeamId {
+		return models.Webhook{}, nil, errors.New("Forbidden")
+	}
+
+	webhook.Secret, err = generateWebhookSecret()
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Webhook{}, nil, err
+	}
+	webhook.ShownSecret = webhook.Secret
+
+	webhook.Active = true
+	_, err = webhook.Create(c, r, currentUser)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Webhook{}, nil, err
+	}
+
+	return webhook, nil, nil
+}
+
+/*
+* Update methods
+ */
+
+func UpdateWebhook(c context.Context, r *http.Request, id string) (models.Webhook, interface{}, error) {
+	webhook, _, err := getWebhookForUser(c, r, id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Webhook{}, nil, err
+	}
+
+	decoder := ffjson.NewDecoder()
+	buf, _ := ioutil.ReadAll(r.Body)
+	var updatedWebhook webhookUpdate
+	err = decoder.Decode(buf, &updatedWebhook)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Webhook{}, nil, err
+	}
+
+	utilities.UpdateIfNotBlank(&webhook.Name, updatedWebhook.Name)
+	utilities.UpdateIfNotBlank(&webhook.Url, updatedWebhook.Url)
+
+	if len(updatedWebhook.Events) > 0 {
+		webhook.Events = updatedWebhook.Events
+	}
+
+	if updatedWebhook.Active != nil {
+		webhook.Active = *updatedWebhook.Active
+	}
+
+	if updatedWebhook.RotateSecret {
+		webhook.Secret, err = generateWebhookSecret()
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return models.Webhook{}, nil, err
+		}
+		webhook.ShownSecret = webhook.Secret
+	}
+
+	webhook.Normalize()
+	err = webhook.Validate()
+	if err != nil {
+		return models.Webhook{}, nil, err
+	}
+
+	_, err = webhook.Save(c)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.Webhook{}, nil, err
+	}
+
+	return webhook, nil, nil
+}
+
+/*
+* Delete methods
+ */
+
+func DeleteWebhook(c context.Context, r *http.Request, id string) (interface{}, interface{}, error) {
+	webhook, _, err := getWebhookForUser(c, r, id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return nil, nil, err
+	}
+
+	err = nds.Delete(c, webhook.Key(c))
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return nil, nil, err
+	}
+
+	return nil, nil, nil
+}
+
+/*
+* Action methods
+ */
+
+// Sends a test event to the webhook right away and returns how it went, so
+// the user can check their endpoint and its signature checking
+func TestWebhook(c context.Context, r *http.Request, id string) (models.WebhookDelivery, interface{}, error) {
+	webhook, user, err := getWebhookForUser(c, r, id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.WebhookDelivery{}, nil, err
+	}
+
+	delivery, err := newWebhookDelivery(c, webhook, models.WebhookTest, map[string]interface{}{
+		"webhookid": webhook.Id,
+		"name":      webhook.Name,
+		"userid":    user.Id,
+	})
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.WebhookDelivery{}, nil, err
+	}
+
+	// A test is only sent once
+	err = deliverWebhook(c, webhook, &delivery, 1)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+	}
+
+	delivery.Type = "webhookdeliveries"
+	return delivery, nil, nil
+}
+
+// Sends the deliveries that are due and removes old delivery logs. This runs
+// from cron.
+func DispatchWebhooks(c context.Context, r *http.Request) error {
+	ks, err := datastore.NewQuery("WebhookDelivery").Filter("Status =", models.WebhookDeliveryPending).Filter("NextAttempt <=", time.Now()).Order("NextAttempt").Limit(webhookBatchSize).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	deliveries := make([]models.WebhookDelivery, len(ks))
+	err = nds.GetMulti(c, ks, deliveries)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	webhookKeys := []*datastore.Key{}
+	for i := 0; i < len(deliveries); i++ {
+		deliveries[i].Format(ks[i], "webhookdeliveries")
+		webhookKeys = append(webhookKeys, datastore.NewKey(c, "Webhook", "", deliveries[i].WebhookId, nil))
+	}
+
+	webhooks := make([]models.Webhook, len(webhookKeys))
+	err = nds.GetMulti(c, webhookKeys, webhooks)
+	multiErr, isMultiErr := err.(appengine.MultiError)
+	if err != nil && !isMultiErr {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	delivered := 0
+	failed := 0
+	for i := 0; i < len(deliveries); i++ {
+		// Deliveries of webhooks that were deleted or turned off aren't sent
+		if (isMultiErr && multiErr[i] != nil) || !webhooks[i].Active {
+			deliveries[i].Status = models.WebhookDeliveryFailed
+			deliveries[i].LastError = "The webhook was deleted or turned off"
+			_, err = deliveries[i].Save(c)
+			if err != nil {
+				log.Errorf(c, "%v", err)
+			}
+			failed += 1
+			continue
+		}
+
+		webhooks[i].Format(webhookKeys[i], "webhooks")
+		err = deliverWebhook(c, webhooks[i], &deliveries[i], webhookMaxAttempts)
+		if err != nil {
+			failed += 1
+			continue
+		}
+		delivered += 1
+	}
+
+	err = purgeWebhookDeliveries(c)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+	}
+
+	log.Infof(c, "Webhooks: delivered %v, failed %v", delivered, failed)
+	return nil
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/appengine/datastore"
+
+	"github.com/news-ai/tabulae/models"
+)
+
+func TestWebhookSignature(t *testing.T) {
+	payload := `{"event":"webhook.test"}`
+
+	tests := []struct {
+		secret    string
+		timestamp string
+		want      string
+	}{
+		{
+			secret:    "secret",
+			timestamp: "1500000000",
+			want:      "a60935ce3b028a6ef44c8efdbeea552d9fd3a8d7b6dfc0155a23e438ddcf0f99",
+		},
+		{
+			secret:    "other",
+			timestamp: "1500000000",
+			want:      "499a50f850239e746ca1a77d931cf8c0eceb183875a3b4a7ec2c50256348d1f5",
+		},
+		{
+			secret:    "secret",
+			timestamp: "1500000001",
+			want:      "ebeaadee07544655651c38e237b14adb45977bab6a53d6cac0feab569e8bba51",
+		},
+	}
+
+	for _, test := range tests {
+		signature := webhookSignature(test.secret, test.timestamp, payload)
+		if signature != test.want {
+			t.Errorf("webhookSignature(%q, %q) is %v, want %v", test.secret, test.timestamp, signature, test.want)
+		}
+	}
+}
+
+func TestWebhookBackoffFor(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 0, want: time.Minute},
+		{attempts: 1, want: time.Minute},
+		{attempts: 2, want: 2 * time.Minute},
+		{attempts: 3, want: 4 * time.Minute},
+		{attempts: 8, want: 128 * time.Minute},
+		{attempts: 10, want: 512 * time.Minute},
+		{attempts: 11, want: 12 * time.Hour},
+		{attempts: 100, want: 12 * time.Hour},
+	}
+
+	for _, test := range tests {
+		backoff := webhookBackoffFor(test.attempts)
+		if backoff != test.want {
+			t.Errorf("webhookBackoffFor(%v) is %v, want %v", test.attempts, backoff, test.want)
+		}
+	}
+}
+
+func TestTriggerContactCreatedWebhooks(t *testing.T) {
+	inst, c, r, user := newTestRequest(t, "POST", "/", "")
+	defer inst.Close()
+
+	defaultPutBatchSize := putBatchSize
+	putBatchSize = 2
+	defer func() {
+		putBatchSize = defaultPutBatchSize
+	}()
+
+	created := models.Webhook{Name: "Created", Url: "https://example.com/created", Events: []string{models.WebhookContactCreated}, Active: true}
+	updated := models.Webhook{Name: "Updated", Url: "https://example.com/updated", Events: []string{models.WebhookContactUpdated}, Active: true}
+	webhooks := []models.Webhook{created, updated}
+	for i := 0; i < len(webhooks); i++ {
+		_, err := webhooks[i].Create(c, r, user)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	contacts := []models.Contact{{Email: "jane@example.com"}, {Email: "john@example.com"}, {Email: "janet@example.com"}}
+	for i := 0; i < len(contacts); i++ {
+		contacts[i].Id = int64(i + 1)
+		contacts[i].CreatedBy = user.Id
+	}
+	TriggerContactCreatedWebhooks(c, contacts)
+
+	deliveries := []models.WebhookDelivery{}
+	_, err := datastore.NewQuery("WebhookDelivery").GetAll(c, &deliveries)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deliveries) != len(contacts) {
+		t.Fatalf("queued %v deliveries, want %v", len(deliveries), len(contacts))
+	}
+	for i := 0; i < len(deliveries); i++ {
+		if deliveries[i].WebhookId != webhooks[0].Id || deliveries[i].Event != models.WebhookContactCreated || deliveries[i].Status != models.WebhookDeliveryPending {
+			t.Errorf("queued %+v, want a pending %v delivery to webhook %v", deliveries[i], models.WebhookContactCreated, webhooks[0].Id)
+		}
+	}
+}
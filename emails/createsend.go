@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -57,6 +58,20 @@ type CampaignMonitorInviteUserEmail struct {
 	AddRecipientsToList bool `json:"AddRecipientsToList"`
 }
 
+type CampaignMonitorShareListEmail struct {
+	To   []string `json:"To"`
+	Data struct {
+		LIST_NAME             string `json:"LIST_NAME"`
+		LIST_ID               string `json:"LIST_ID"`
+		ROLE                  string `json:"ROLE"`
+		NEWUSER_EMAIL         string `json:"NEWUSER_EMAIL"`
+		PERSONAL_MESSAGE      string `json:"PERSONAL_MESSAGE"`
+		CURRENTUSER_FULL_NAME string `json:"CURRENTUSER_FULL_NAME"`
+		CURRENTUSER_EMAIL     string `json:"CURRENTUSER_EMAIL"`
+	} `json:"Data"`
+	AddRecipientsToList bool `json:"AddRecipientsToList"`
+}
+
 type CampaignMonitorConfirmationEmail struct {
 	To   []string `json:"To"`
 	Data struct {
@@ -76,6 +91,29 @@ type CampaignMonitorPremiumEmail struct {
 	AddRecipientsToList bool `json:"AddRecipientsToList"`
 }
 
+// A saved search in a digest, with the first of its new results
+type CampaignMonitorSavedSearch struct {
+	NAME        string                             `json:"NAME"`
+	KIND        string                             `json:"KIND"`
+	NEW_RESULTS string                             `json:"NEW_RESULTS"`
+	RESULTS     []CampaignMonitorSavedSearchResult `json:"RESULTS"`
+}
+
+type CampaignMonitorSavedSearchResult struct {
+	TITLE    string `json:"TITLE"`
+	SUBTITLE string `json:"SUBTITLE"`
+	URL      string `json:"URL"`
+}
+
+type CampaignMonitorSavedSearchDigestEmail struct {
+	To   []string `json:"To"`
+	Data struct {
+		NEW_RESULTS string                       `json:"NEW_RESULTS"`
+		SEARCHES    []CampaignMonitorSavedSearch `json:"SEARCHES"`
+	} `json:"Data"`
+	AddRecipientsToList bool `json:"AddRecipientsToList"`
+}
+
 func ConfirmUserAccount(c context.Context, user apiModels.User, confirmationCode string) error {
 	apiKey := os.Getenv("CAMPAIGNMONITOR_API_KEY")
 	confirmationEmailId := "a609aac8-cde6-4830-92ba-215ee48c4195"
@@ -332,3 +370,105 @@ func InviteUser(c context.Context, currentUser apiModels.User, userEmail, userRe
 
 	return errors.New("Error happened when sending email")
 }
+
+// Tells someone a media list was shared with them. The smart email is set
+// up in Campaign Monitor and its id is configured per environment.
+func ShareList(c context.Context, currentUser apiModels.User, userEmail, listName string, listId int64, role, personalMessage string) error {
+	apiKey := os.Getenv("CAMPAIGNMONITOR_API_KEY")
+	shareListEmailId := os.Getenv("CAMPAIGNMONITOR_SHARE_LIST_EMAIL_ID")
+	if shareListEmailId == "" {
+		return errors.New("No email is set up for sharing lists")
+	}
+
+	contextWithTimeout, _ := context.WithTimeout(c, time.Second*15)
+	client := urlfetch.Client(contextWithTimeout)
+
+	shareEmail := CampaignMonitorShareListEmail{}
+	shareEmail.To = append(shareEmail.To, userEmail)
+	shareEmail.AddRecipientsToList = false
+
+	shareEmail.Data.LIST_NAME = listName
+	shareEmail.Data.LIST_ID = strconv.FormatInt(listId, 10)
+	shareEmail.Data.ROLE = role
+	shareEmail.Data.NEWUSER_EMAIL = userEmail
+	shareEmail.Data.PERSONAL_MESSAGE = personalMessage
+	shareEmail.Data.CURRENTUSER_EMAIL = currentUser.Email
+	shareEmail.Data.CURRENTUSER_FULL_NAME = strings.Join([]string{currentUser.FirstName, currentUser.LastName}, " ")
+
+	ShareListEmail, err := json.Marshal(shareEmail)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+	shareListEmailJson := bytes.NewReader(ShareListEmail)
+
+	postUrl := "https://api.createsend.com/api/v3.1/transactional/smartEmail/" + shareListEmailId + "/send"
+
+	req, _ := http.NewRequest("POST", postUrl, shareListEmailJson)
+	req.SetBasicAuth(apiKey, "x")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 201 || resp.StatusCode == 202 || resp.StatusCode == 200 {
+		return nil
+	}
+
+	return errors.New("Error happened when sending email")
+}
+
+// Sends a user the new results of their saved searches. The smart email is
+// set up in Campaign Monitor and its id is configured per environment.
+func SavedSearchDigest(c context.Context, user apiModels.User, searches []CampaignMonitorSavedSearch) error {
+	apiKey := os.Getenv("CAMPAIGNMONITOR_API_KEY")
+	digestEmailId := os.Getenv("CAMPAIGNMONITOR_SAVED_SEARCH_EMAIL_ID")
+	if digestEmailId == "" {
+		return errors.New("No email is set up for saved search digests")
+	}
+
+	contextWithTimeout, _ := context.WithTimeout(c, time.Second*15)
+	client := urlfetch.Client(contextWithTimeout)
+
+	digestEmail := CampaignMonitorSavedSearchDigestEmail{}
+
+	userEmail := user.FirstName + " " + user.LastName + " <" + user.Email + " >"
+	digestEmail.To = append(digestEmail.To, userEmail)
+	digestEmail.AddRecipientsToList = false
+
+	newResults := 0
+	for i := 0; i < len(searches); i++ {
+		searchResults, _ := strconv.Atoi(searches[i].NEW_RESULTS)
+		newResults += searchResults
+	}
+	digestEmail.Data.NEW_RESULTS = strconv.Itoa(newResults)
+	digestEmail.Data.SEARCHES = searches
+
+	DigestEmail, err := json.Marshal(digestEmail)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+	digestEmailJson := bytes.NewReader(DigestEmail)
+
+	postUrl := "https://api.createsend.com/api/v3.1/transactional/smartEmail/" + digestEmailId + "/send"
+
+	req, _ := http.NewRequest("POST", postUrl, digestEmailJson)
+	req.SetBasicAuth(apiKey, "x")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 201 || resp.StatusCode == 202 || resp.StatusCode == 200 {
+		return nil
+	}
+
+	return errors.New("Error happened when sending email")
+}
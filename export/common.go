@@ -0,0 +1,79 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/news-ai/web/utilities"
+)
+
+var (
+	formatCSV   = "csv"
+	formatXLSX  = "xlsx"
+	formatVCard = "vcf"
+
+	// Number of contacts read from datastore at a time while exporting
+	exportChunkSize = 100
+)
+
+// rowWriter writes one row at a time so a whole export never has to be
+// held in memory.
+type rowWriter interface {
+	WriteRow(row []string) error
+	Close() error
+}
+
+type csvRowWriter struct {
+	writer *csv.Writer
+}
+
+func (cw *csvRowWriter) WriteRow(row []string) error {
+	err := cw.writer.Write(row)
+	if err != nil {
+		return err
+	}
+
+	// Push each row out to the client instead of buffering the file
+	cw.writer.Flush()
+	return cw.writer.Error()
+}
+
+func (cw *csvRowWriter) Close() error {
+	cw.writer.Flush()
+	return cw.writer.Error()
+}
+
+func newRowWriter(w io.Writer, format string, sheetName string) (rowWriter, error) {
+	if format == formatCSV {
+		return &csvRowWriter{writer: csv.NewWriter(w)}, nil
+	}
+	return newXLSXRowWriter(w, sheetName)
+}
+
+func getFormat(r *http.Request) string {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == formatCSV {
+		return formatCSV
+	}
+	return formatXLSX
+}
+
+func setDownloadHeaders(w http.ResponseWriter, name string, format string) {
+	fileName := strings.ToLower(utilities.RemoveSpecialCharacters(name))
+	fileName = strings.Replace(strings.TrimSpace(fileName), " ", "-", -1)
+	if fileName == "" {
+		fileName = "export"
+	}
+
+	switch format {
+	case formatCSV:
+		w.Header().Set("Content-Type", "text/csv")
+	case formatVCard:
+		w.Header().Set("Content-Type", "text/vcard")
+	default:
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+fileName+"."+format+"\"")
+}
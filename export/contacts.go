@@ -0,0 +1,153 @@
+package export
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/log"
+
+	"github.com/pquerna/ffjson/ffjson"
+
+	"github.com/news-ai/tabulae/controllers"
+	"github.com/news-ai/tabulae/models"
+
+	"github.com/news-ai/web/utilities"
+)
+
+var contactColumns = []string{"firstname", "lastname", "email", "employers", "pastemployers", "notes", "linkedin", "twitter", "instagram", "website", "blog", "phonenumber", "location"}
+var contactColumnsName = []string{"First Name", "Last Name", "Email", "Employers", "Past Employers", "Notes", "Linkedin", "Twitter", "Instagram", "Website", "Blog", "Phone #", "Location"}
+
+/*
+* Private methods
+ */
+
+func getContactFormat(r *http.Request) string {
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == formatCSV {
+		return formatCSV
+	}
+	return formatVCard
+}
+
+func writeContacts(c context.Context, w http.ResponseWriter, r *http.Request, contacts []models.Contact, name string) error {
+	format := getContactFormat(r)
+	names := publicationNames(c, contacts)
+
+	setDownloadHeaders(w, name, format)
+
+	if format == formatVCard {
+		writer := vCardWriter{writer: w}
+		for i := 0; i < len(contacts); i++ {
+			err := writer.WriteContact(contacts[i], names)
+			if err != nil {
+				log.Errorf(c, "%v", err)
+				return err
+			}
+		}
+		return nil
+	}
+
+	// Custom fields are added as columns in the order they are first seen
+	customColumns := []string{}
+	customColumnExists := map[string]bool{}
+	for i := 0; i < len(contacts); i++ {
+		for x := 0; x < len(contacts[i].CustomFields); x++ {
+			customFieldName := contacts[i].CustomFields[x].Name
+			if _, ok := customColumnExists[customFieldName]; !ok {
+				customColumns = append(customColumns, customFieldName)
+				customColumnExists[customFieldName] = true
+			}
+		}
+	}
+
+	writer, err := newRowWriter(w, format, name)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	header := append([]string{}, contactColumnsName...)
+	header = append(header, "Tags")
+	header = append(header, customColumns...)
+	err = writer.WriteRow(header)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	for i := 0; i < len(contacts); i++ {
+		row := []string{}
+		for x := 0; x < len(contactColumns); x++ {
+			row = append(row, contactFieldValue(contacts[i], contactColumns[x], names))
+		}
+		row = append(row, strings.Join(contacts[i].Tags, ", "))
+		for x := 0; x < len(customColumns); x++ {
+			row = append(row, contactFieldValue(contacts[i], customColumns[x], names))
+		}
+
+		err = writer.WriteRow(row)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return err
+		}
+	}
+
+	return writer.Close()
+}
+
+/*
+* Public methods
+ */
+
+// Exports a single contact as a vCard or CSV
+func ContactExport(c context.Context, w http.ResponseWriter, r *http.Request, id string) error {
+	currentId, err := utilities.StringIdToInt(id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	contact, err := controllers.GetContactById(c, r, currentId)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	name := strings.TrimSpace(contact.FirstName + " " + contact.LastName)
+	if name == "" {
+		name = "contact"
+	}
+
+	return writeContacts(c, w, r, []models.Contact{contact}, name)
+}
+
+// Exports a selection of contacts sent as {"ids": [...]}. Contacts the
+// user can't access are left out of the export.
+func ContactsExport(c context.Context, w http.ResponseWriter, r *http.Request) error {
+	buf, _ := ioutil.ReadAll(r.Body)
+	decoder := ffjson.NewDecoder()
+	var contactIds models.ContactIdsArray
+	err := decoder.Decode(buf, &contactIds)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	contacts := []models.Contact{}
+	for i := 0; i < len(contactIds.ContactIds); i++ {
+		contact, err := controllers.GetContactById(c, r, contactIds.ContactIds[i])
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			continue
+		}
+
+		if !contact.IsDeleted {
+			contacts = append(contacts, contact)
+		}
+	}
+
+	return writeContacts(c, w, r, contacts, "contacts")
+}
@@ -0,0 +1,137 @@
+package export
+
+import (
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/log"
+
+	"github.com/news-ai/tabulae/controllers"
+	"github.com/news-ai/tabulae/models"
+)
+
+/*
+* Private methods
+ */
+
+// Columns that are shown to the user, in the same order as the list
+func mediaListColumns(mediaList models.MediaList) []models.CustomFieldsMap {
+	columns := []models.CustomFieldsMap{}
+	for i := 0; i < len(mediaList.FieldsMap); i++ {
+		if !mediaList.FieldsMap[i].Hidden {
+			columns = append(columns, mediaList.FieldsMap[i])
+		}
+	}
+	return columns
+}
+
+func publicationNames(c context.Context, contacts []models.Contact) map[int64]string {
+	names := map[int64]string{}
+	publications := controllers.GetPublicationsForContacts(c, contacts)
+	for i := 0; i < len(publications); i++ {
+		names[publications[i].Id] = publications[i].Name
+	}
+	return names
+}
+
+func employerNames(employers []int64, names map[int64]string) string {
+	employerNames := []string{}
+	for i := 0; i < len(employers); i++ {
+		if name, ok := names[employers[i]]; ok && name != "" {
+			employerNames = append(employerNames, name)
+		}
+	}
+	return strings.Join(employerNames, ", ")
+}
+
+// Employers are written by their names, everything else like it is shown in
+// the list
+func contactFieldValue(contact models.Contact, field string, names map[int64]string) string {
+	switch field {
+	case "employers":
+		return employerNames(contact.Employers, names)
+	case "pastemployers":
+		return employerNames(contact.PastEmployers, names)
+	}
+	return contact.FieldValue(field)
+}
+
+/*
+* Public methods
+ */
+
+// Writes every contact in a media list to the response in the
+// order and with the names of the list's FieldsMap. Contacts are
+// read and written in chunks so large lists are never held in memory.
+func MediaListExport(c context.Context, w http.ResponseWriter, r *http.Request, id string) error {
+	mediaList, _, err := controllers.GetMediaList(c, r, id)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	format := getFormat(r)
+	columns := mediaListColumns(mediaList)
+
+	header := []string{}
+	for i := 0; i < len(columns); i++ {
+		header = append(header, columns[i].Name)
+	}
+
+	setDownloadHeaders(w, mediaList.Name, format)
+	writer, err := newRowWriter(w, format, mediaList.Name)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	err = writer.WriteRow(header)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	for start := 0; start < len(mediaList.Contacts); start += exportChunkSize {
+		end := start + exportChunkSize
+		if end > len(mediaList.Contacts) {
+			end = len(mediaList.Contacts)
+		}
+
+		contacts, err := controllers.GetContactsByIds(c, r, mediaList.Contacts[start:end])
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return err
+		}
+
+		activeContacts := []models.Contact{}
+		for i := 0; i < len(contacts); i++ {
+			if !contacts[i].IsDeleted {
+				activeContacts = append(activeContacts, contacts[i])
+			}
+		}
+
+		// Adds the read-only columns (twitterfollowers, latestheadline, lastcontacted)
+		activeContacts, err = controllers.ContactsToDefaultFields(c, r, activeContacts, mediaList)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
+
+		names := publicationNames(c, activeContacts)
+		for i := 0; i < len(activeContacts); i++ {
+			row := make([]string, len(columns))
+			for x := 0; x < len(columns); x++ {
+				row[x] = contactFieldValue(activeContacts[i], columns[x].Value, names)
+			}
+
+			err = writer.WriteRow(row)
+			if err != nil {
+				log.Errorf(c, "%v", err)
+				return err
+			}
+		}
+	}
+
+	return writer.Close()
+}
@@ -0,0 +1,58 @@
+package export
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/news-ai/tabulae/models"
+)
+
+func TestMediaListColumns(t *testing.T) {
+	mediaList := models.MediaList{
+		FieldsMap: []models.CustomFieldsMap{
+			{Value: "firstname"},
+			{Value: "email", Hidden: true},
+			{Value: "beat", CustomField: true},
+			{Value: "instagramfollowers", ReadOnly: true},
+		},
+	}
+
+	columns := mediaListColumns(mediaList)
+	values := []string{}
+	for i := 0; i < len(columns); i++ {
+		values = append(values, columns[i].Value)
+	}
+
+	want := []string{"firstname", "beat", "instagramfollowers"}
+	if !reflect.DeepEqual(values, want) {
+		t.Errorf("columns are %v, want %v", values, want)
+	}
+}
+
+func TestContactFieldValue(t *testing.T) {
+	names := map[int64]string{1: "Acme", 2: "", 3: "Globex"}
+	contact := models.Contact{
+		FirstName:     "Jane",
+		Employers:     []int64{1, 2, 4},
+		PastEmployers: []int64{3, 1},
+		CustomFields:  []models.CustomContactField{{Name: "beat", Value: "Tech"}},
+	}
+
+	tests := []struct {
+		field string
+		want  string
+	}{
+		{field: "firstname", want: "Jane"},
+		{field: "employers", want: "Acme"},
+		{field: "pastemployers", want: "Globex, Acme"},
+		{field: "beat", want: "Tech"},
+		{field: "region", want: ""},
+	}
+
+	for _, test := range tests {
+		value := contactFieldValue(contact, test.field, names)
+		if value != test.want {
+			t.Errorf("contactFieldValue(%v) is %q, want %q", test.field, value, test.want)
+		}
+	}
+}
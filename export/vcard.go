@@ -0,0 +1,123 @@
+package export
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/news-ai/tabulae/models"
+)
+
+var (
+	// Lines longer than this are folded (RFC 6350 section 3.2)
+	vCardLineLength = 75
+
+	vCardEscaper = strings.NewReplacer("\\", "\\\\", ",", "\\,", ";", "\\;", "\r\n", "\\n", "\n", "\\n")
+)
+
+type vCardWriter struct {
+	writer io.Writer
+}
+
+func vCardEscape(value string) string {
+	return vCardEscaper.Replace(value)
+}
+
+// Writes a single content line, folding it when it is over the line length.
+// Folding never splits a multi-byte character.
+func (vw *vCardWriter) writeLine(line string) error {
+	for len(line) > vCardLineLength {
+		cut := vCardLineLength
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+
+		_, err := io.WriteString(vw.writer, line[:cut]+"\r\n ")
+		if err != nil {
+			return err
+		}
+		line = line[cut:]
+	}
+
+	_, err := io.WriteString(vw.writer, line+"\r\n")
+	return err
+}
+
+func (vw *vCardWriter) writeProperty(name string, value string) error {
+	if value == "" {
+		return nil
+	}
+	return vw.writeLine(name + ":" + vCardEscape(value))
+}
+
+func (vw *vCardWriter) WriteContact(contact models.Contact, names map[int64]string) error {
+	lines := []string{"BEGIN:VCARD", "VERSION:4.0"}
+
+	fullName := strings.TrimSpace(contact.FirstName + " " + contact.LastName)
+	if fullName == "" {
+		fullName = contact.Email
+	}
+	lines = append(lines, "FN:"+vCardEscape(fullName))
+	lines = append(lines, "N:"+vCardEscape(contact.LastName)+";"+vCardEscape(contact.FirstName)+";;;")
+
+	for i := 0; i < len(lines); i++ {
+		err := vw.writeLine(lines[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	properties := [][]string{
+		{"EMAIL", contact.Email},
+		{"TEL", contact.PhoneNumber},
+		{"URL", contact.Website},
+		{"URL", contact.Blog},
+		{"PHOTO", contact.ImageURL},
+		{"NOTE", contact.Notes},
+	}
+
+	for i := 0; i < len(contact.Employers); i++ {
+		if name, ok := names[contact.Employers[i]]; ok && name != "" {
+			properties = append(properties, []string{"ORG", name})
+		}
+	}
+
+	if contact.LinkedIn != "" {
+		properties = append(properties, []string{"X-SOCIALPROFILE;TYPE=linkedin", contact.LinkedIn})
+	}
+	if contact.Twitter != "" {
+		properties = append(properties, []string{"X-SOCIALPROFILE;TYPE=twitter", "https://twitter.com/" + contact.Twitter})
+	}
+	if contact.Instagram != "" {
+		properties = append(properties, []string{"X-SOCIALPROFILE;TYPE=instagram", "https://instagram.com/" + contact.Instagram})
+	}
+
+	for i := 0; i < len(properties); i++ {
+		err := vw.writeProperty(properties[i][0], properties[i][1])
+		if err != nil {
+			return err
+		}
+	}
+
+	// Location is free-form so it goes in the ADR label with an empty structure
+	if contact.Location != "" {
+		label := strings.NewReplacer("\"", "'", "\r\n", " ", "\n", " ").Replace(contact.Location)
+		err := vw.writeLine("ADR;LABEL=\"" + label + "\":;;;;;;")
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(contact.Tags) > 0 {
+		tags := []string{}
+		for i := 0; i < len(contact.Tags); i++ {
+			tags = append(tags, vCardEscape(contact.Tags[i]))
+		}
+		err := vw.writeLine("CATEGORIES:" + strings.Join(tags, ","))
+		if err != nil {
+			return err
+		}
+	}
+
+	return vw.writeLine("END:VCARD")
+}
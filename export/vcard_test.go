@@ -0,0 +1,99 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/news-ai/tabulae/models"
+)
+
+func TestVCardEscape(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "Jane", want: "Jane"},
+		{value: "Doe, Jane; PR", want: "Doe\\, Jane\\; PR"},
+		{value: "C:\\notes", want: "C:\\\\notes"},
+		{value: "One\r\nTwo\nThree", want: "One\\nTwo\\nThree"},
+	}
+
+	for _, test := range tests {
+		value := vCardEscape(test.value)
+		if value != test.want {
+			t.Errorf("vCardEscape(%q) is %q, want %q", test.value, value, test.want)
+		}
+	}
+}
+
+func TestVCardWriteLine(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{
+			name: "short line",
+			line: "FN:Jane Doe",
+			want: "FN:Jane Doe\r\n",
+		},
+		{
+			name: "folded line",
+			line: "NOTE:" + strings.Repeat("a", 80),
+			want: "NOTE:" + strings.Repeat("a", 70) + "\r\n " + strings.Repeat("a", 10) + "\r\n",
+		},
+		{
+			name: "multi-byte character at the fold",
+			line: "NOTE:" + strings.Repeat("a", 69) + "éé",
+			want: "NOTE:" + strings.Repeat("a", 69) + "\r\n éé\r\n",
+		},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		vw := vCardWriter{writer: &buf}
+		err := vw.writeLine(test.line)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+		if buf.String() != test.want {
+			t.Errorf("%v: wrote %q, want %q", test.name, buf.String(), test.want)
+		}
+	}
+}
+
+func TestVCardWriteContact(t *testing.T) {
+	contact := models.Contact{
+		FirstName: "Jane",
+		LastName:  "Doe",
+		Email:     "jane@example.com",
+		Employers: []int64{1, 2},
+		Twitter:   "jane",
+		Location:  "New York, \"NY\"",
+		Tags:      []string{"tech", "food, drink"},
+	}
+
+	var buf bytes.Buffer
+	vw := vCardWriter{writer: &buf}
+	err := vw.WriteContact(contact, map[int64]string{1: "Acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"BEGIN:VCARD",
+		"VERSION:4.0",
+		"FN:Jane Doe",
+		"N:Doe;Jane;;;",
+		"EMAIL:jane@example.com",
+		"ORG:Acme",
+		"X-SOCIALPROFILE;TYPE=twitter:https://twitter.com/jane",
+		"ADR;LABEL=\"New York, 'NY'\":;;;;;;",
+		"CATEGORIES:tech,food\\, drink",
+		"END:VCARD",
+	}, "\r\n") + "\r\n"
+	if buf.String() != want {
+		t.Errorf("wrote %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,157 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+
+	xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/></Relationships>`
+
+	xlsxWorkbookStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="`
+	xlsxWorkbookEnd = `" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+	xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+	xlsxSheetEnd = `</sheetData></worksheet>`
+)
+
+// xlsxRowWriter streams a single sheet workbook. The sheet is written
+// as rows come in using inline strings, and the workbook parts that
+// reference it are added to the archive when it is closed.
+type xlsxRowWriter struct {
+	archive   *zip.Writer
+	sheet     *bufio.Writer
+	sheetName string
+	rows      int
+}
+
+func newXLSXRowWriter(w io.Writer, sheetName string) (*xlsxRowWriter, error) {
+	archive := zip.NewWriter(w)
+	sheet, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	xw := &xlsxRowWriter{
+		archive:   archive,
+		sheet:     bufio.NewWriter(sheet),
+		sheetName: xlsxSheetName(sheetName),
+	}
+
+	_, err = xw.sheet.WriteString(xlsxSheetStart)
+	if err != nil {
+		return nil, err
+	}
+	return xw, nil
+}
+
+func (xw *xlsxRowWriter) WriteRow(row []string) error {
+	xw.rows++
+	xw.sheet.WriteString(`<row r="` + strconv.Itoa(xw.rows) + `">`)
+	for i := 0; i < len(row); i++ {
+		if row[i] == "" {
+			continue
+		}
+
+		xw.sheet.WriteString(`<c r="` + xlsxColumnName(i) + strconv.Itoa(xw.rows) + `" t="inlineStr"><is><t xml:space="preserve">`)
+		err := xml.EscapeText(xw.sheet, []byte(row[i]))
+		if err != nil {
+			return err
+		}
+		xw.sheet.WriteString(`</t></is></c>`)
+	}
+	_, err := xw.sheet.WriteString(`</row>`)
+	if err != nil {
+		return err
+	}
+
+	// Flush through to the archive every so often so the sheet is
+	// streamed to the client as it is built
+	if xw.rows%exportChunkSize == 0 {
+		return xw.sheet.Flush()
+	}
+	return nil
+}
+
+func (xw *xlsxRowWriter) Close() error {
+	_, err := xw.sheet.WriteString(xlsxSheetEnd)
+	if err != nil {
+		return err
+	}
+
+	err = xw.sheet.Flush()
+	if err != nil {
+		return err
+	}
+
+	var sheetName bytes.Buffer
+	xml.EscapeText(&sheetName, []byte(xw.sheetName))
+
+	parts := []struct {
+		Name    string
+		Content string
+	}{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/workbook.xml", xlsxWorkbookStart + sheetName.String() + xlsxWorkbookEnd},
+	}
+
+	for i := 0; i < len(parts); i++ {
+		part, err := xw.archive.Create(parts[i].Name)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.WriteString(part, parts[i].Content)
+		if err != nil {
+			return err
+		}
+	}
+
+	return xw.archive.Close()
+}
+
+// Converts a zero based column index into a spreadsheet column (A, B, ..., AA)
+func xlsxColumnName(index int) string {
+	name := ""
+	for index >= 0 {
+		name = string(rune('A'+index%26)) + name
+		index = index/26 - 1
+	}
+	return name
+}
+
+// Sheet names can't be longer than 31 characters or contain []:*?/\
+func xlsxSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune("[]:*?/\\", r) {
+			return -1
+		}
+		return r
+	}, name)
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "Sheet1"
+	}
+
+	if runes := []rune(name); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+	return name
+}
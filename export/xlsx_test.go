@@ -0,0 +1,109 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestXLSXColumnName(t *testing.T) {
+	tests := []struct {
+		index int
+		want  string
+	}{
+		{index: 0, want: "A"},
+		{index: 25, want: "Z"},
+		{index: 26, want: "AA"},
+		{index: 51, want: "AZ"},
+		{index: 702, want: "AAA"},
+	}
+
+	for _, test := range tests {
+		name := xlsxColumnName(test.index)
+		if name != test.want {
+			t.Errorf("xlsxColumnName(%v) is %v, want %v", test.index, name, test.want)
+		}
+	}
+}
+
+func TestXLSXSheetName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "Launch", want: "Launch"},
+		{name: "Q1: Press [draft]", want: "Q1 Press draft"},
+		{name: " */? ", want: "Sheet1"},
+		{name: "A list with a name longer than a sheet can have", want: "A list with a name longer than "},
+	}
+
+	for _, test := range tests {
+		name := xlsxSheetName(test.name)
+		if name != test.want {
+			t.Errorf("xlsxSheetName(%q) is %q, want %q", test.name, name, test.want)
+		}
+	}
+}
+
+func TestXLSXRowWriter(t *testing.T) {
+	var buf bytes.Buffer
+	xw, err := newXLSXRowWriter(&buf, "Launch")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := [][]string{{"First Name", "Notes"}, {"Jane", "Likes <b> & \"quotes\""}, {"", "No name"}}
+	for i := 0; i < len(rows); i++ {
+		err = xw.WriteRow(rows[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = xw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parts := map[string]string{}
+	for i := 0; i < len(archive.File); i++ {
+		file, err := archive.File[i].Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(file)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts[archive.File[i].Name] = string(content)
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/_rels/workbook.xml.rels", "xl/workbook.xml", "xl/worksheets/sheet1.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("workbook has no %v", name)
+		}
+	}
+
+	if !strings.Contains(parts["xl/workbook.xml"], `"Launch"`) {
+		t.Errorf("workbook doesn't name the sheet Launch: %v", parts["xl/workbook.xml"])
+	}
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	wants := []string{
+		`<c r="A1" t="inlineStr"><is><t xml:space="preserve">First Name</t></is></c>`,
+		`<t xml:space="preserve">Likes &lt;b&gt; &amp; &#34;quotes&#34;</t>`,
+		`<row r="3"><c r="B3"`,
+	}
+	for i := 0; i < len(wants); i++ {
+		if !strings.Contains(sheet, wants[i]) {
+			t.Errorf("sheet doesn't have %v: %v", wants[i], sheet)
+		}
+	}
+}
@@ -190,13 +190,14 @@ func HandleFileUploadHeaders(c context.Context, r *http.Request, id string) (int
 	}
 
 	// Import the file
-	_, err = parse.ExcelHeadersToListModel(r, byteFile, file.FileName, file.HeaderNames, file.Order, file.ListId, contentType)
+	_, rejectedCells, err := parse.ExcelHeadersToListModel(r, byteFile, file.FileName, file.HeaderNames, file.Order, file.ListId, contentType)
 	if err != nil {
 		return nil, nil, err
 	}
 
 	// Return the file
 	file.Imported = true
+	file.RejectedCells = rejectedCells
 	val, err := file.Save(c)
 	if err != nil {
 		return nil, nil, err
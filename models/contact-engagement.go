@@ -0,0 +1,118 @@
+package models
+
+import (
+	"strconv"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+
+	apiModels "github.com/news-ai/api/models"
+
+	"github.com/qedus/nds"
+)
+
+// How a contact responds to the emails sent to them. Every contact has at
+// most one, keyed by the contact's id, and it is kept up to date by the
+// email trackers.
+type ContactEngagement struct {
+	apiModels.Base
+
+	ContactId int64 `json:"contactid" apiModel:"Contact"`
+
+	EmailsSent    int `json:"emailssent"`
+	EmailsOpened  int `json:"emailsopened"`
+	EmailsClicked int `json:"emailsclicked"`
+
+	LastContacted time.Time `json:"lastcontacted"`
+	LastOpened    time.Time `json:"lastopened"`
+}
+
+// Read only columns of a media list that come from the contact's engagement,
+// and their types
+var EngagementFields = map[string]string{
+	"emailssent":    FieldTypeNumber,
+	"emailsopened":  FieldTypeNumber,
+	"emailsclicked": FieldTypeNumber,
+	"openrate":      FieldTypeNumber,
+	"clickrate":     FieldTypeNumber,
+	"lastopened":    FieldTypeDate,
+}
+
+/*
+* Private methods
+ */
+
+// Percentage of the sent emails, rounded to one decimal
+func engagementRate(count int, sent int) float64 {
+	if sent == 0 {
+		return 0
+	}
+	rate := float64(count) / float64(sent) * 100
+	return float64(int(rate*10+0.5)) / 10
+}
+
+func engagementDate(date time.Time) string {
+	if date.IsZero() {
+		return ""
+	}
+	return date.Format(time.RFC3339)
+}
+
+/*
+* Public methods
+ */
+
+func (ce *ContactEngagement) Key(c context.Context) *datastore.Key {
+	return datastore.NewKey(c, "ContactEngagement", "", ce.ContactId, nil)
+}
+
+func (ce *ContactEngagement) OpenRate() float64 {
+	return engagementRate(ce.EmailsOpened, ce.EmailsSent)
+}
+
+func (ce *ContactEngagement) ClickRate() float64 {
+	return engagementRate(ce.EmailsClicked, ce.EmailsSent)
+}
+
+// The value of one of the EngagementFields, or of lastcontacted
+func (ce *ContactEngagement) FieldValue(field string) string {
+	switch field {
+	case "emailssent":
+		return strconv.Itoa(ce.EmailsSent)
+	case "emailsopened":
+		return strconv.Itoa(ce.EmailsOpened)
+	case "emailsclicked":
+		return strconv.Itoa(ce.EmailsClicked)
+	case "openrate":
+		return strconv.FormatFloat(ce.OpenRate(), 'f', -1, 64)
+	case "clickrate":
+		return strconv.FormatFloat(ce.ClickRate(), 'f', -1, 64)
+	case "lastcontacted":
+		return engagementDate(ce.LastContacted)
+	case "lastopened":
+		return engagementDate(ce.LastOpened)
+	}
+	return ""
+}
+
+/*
+* Update methods
+ */
+
+func (ce *ContactEngagement) Save(c context.Context) (*ContactEngagement, error) {
+	if ce.Created.IsZero() {
+		ce.Created = time.Now()
+	}
+	ce.Updated = time.Now()
+
+	k, err := nds.Put(c, ce.Key(c), ce)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return nil, err
+	}
+	ce.Id = k.IntID()
+	return ce, nil
+}
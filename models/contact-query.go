@@ -0,0 +1,124 @@
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+var contactFilterOperators = map[string]bool{
+	"eq":       true,
+	"ne":       true,
+	"contains": true,
+	"empty":    true,
+	"notempty": true,
+	"in":       true,
+	"gt":       true,
+	"gte":      true,
+	"lt":       true,
+	"lte":      true,
+	"before":   true,
+	"after":    true,
+}
+
+// A single condition on a column of a media list, or on one of tags,
+// employers, emailbounced or unsubscribed
+type ContactFilter struct {
+	Field    string   `json:"field"`
+	Operator string   `json:"operator"`
+	Value    string   `json:"value"`
+	Values   []string `json:"values" datastore:"-"`
+}
+
+// Filters that all have to match, and the column to sort by. Sorting by a
+// column prefixed with "-" is descending.
+type ContactQuery struct {
+	Filters []ContactFilter `json:"filters"`
+	Sort    string          `json:"sort"`
+}
+
+/*
+* Private methods
+ */
+
+func splitFilterValues(value string) []string {
+	values := []string{}
+	splitValues := strings.Split(value, ",")
+	for i := 0; i < len(splitValues); i++ {
+		if strings.TrimSpace(splitValues[i]) != "" {
+			values = append(values, strings.TrimSpace(splitValues[i]))
+		}
+	}
+	return values
+}
+
+/*
+* Public methods
+ */
+
+// Parses a filter written as <field>:<operator>:<value>. Values of the "in"
+// operator are comma separated, and "empty" and "notempty" take no value.
+func ParseContactFilter(filter string) (ContactFilter, error) {
+	filterParts := strings.SplitN(filter, ":", 3)
+	if len(filterParts) < 2 {
+		return ContactFilter{}, errors.New("Filters have to be <field>:<operator>:<value>")
+	}
+
+	contactFilter := ContactFilter{
+		Field:    filterParts[0],
+		Operator: filterParts[1],
+	}
+
+	if len(filterParts) == 3 {
+		contactFilter.Value = filterParts[2]
+	}
+
+	if contactFilter.Operator == "in" {
+		contactFilter.Values = splitFilterValues(contactFilter.Value)
+	}
+
+	return contactFilter, contactFilter.Validate()
+}
+
+func (cf *ContactFilter) Validate() error {
+	if cf.Field == "" {
+		return errors.New("Filters need a field")
+	}
+
+	if _, ok := contactFilterOperators[cf.Operator]; !ok {
+		return errors.New("Filter operator " + cf.Operator + " is not supported")
+	}
+
+	if cf.Operator == "in" && len(cf.Values) == 0 {
+		return errors.New("Filter " + cf.Field + " needs values")
+	}
+
+	return nil
+}
+
+// Column being sorted on and whether it is descending
+func (cq *ContactQuery) SortField() (string, bool) {
+	if strings.HasPrefix(cq.Sort, "-") {
+		return strings.TrimPrefix(cq.Sort, "-"), true
+	}
+	return cq.Sort, false
+}
+
+// Values of "in" filters are kept comma separated in Value as well, since
+// they can't be stored in the datastore as a list inside a list
+func (cq *ContactQuery) Format() {
+	for i := 0; i < len(cq.Filters); i++ {
+		if cq.Filters[i].Operator != "in" {
+			continue
+		}
+
+		if len(cq.Filters[i].Values) > 0 {
+			cq.Filters[i].Value = strings.Join(cq.Filters[i].Values, ",")
+		} else {
+			cq.Filters[i].Values = splitFilterValues(cq.Filters[i].Value)
+		}
+	}
+}
+
+func (cq *ContactQuery) IsEmpty() bool {
+	return len(cq.Filters) == 0 && cq.Sort == ""
+}
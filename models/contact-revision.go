@@ -0,0 +1,204 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+
+	apiModels "github.com/news-ai/api/models"
+
+	"github.com/qedus/nds"
+)
+
+var (
+	RevisionSourceUser       = "user"
+	RevisionSourceImport     = "import"
+	RevisionSourceEnrichment = "enrichment"
+	RevisionSourceSocial     = "social"
+	RevisionSourceMaster     = "master"
+	RevisionSourceMerge      = "merge"
+	RevisionSourceRevert     = "revert"
+	RevisionSourceSnapshot   = "snapshot"
+	RevisionSourceBulkEdit   = "bulkedit"
+	RevisionSourceTags       = "tags"
+)
+
+var (
+	// Custom fields are tracked as "customfields.<name>"
+	customFieldRevisionPrefix = "customfields."
+
+	// Fields tracked with their FieldValue. Tags are tracked as a JSON array.
+	revisionFields = []string{"firstname", "lastname", "email", "notes", "employers", "pastemployers", "linkedin", "twitter", "instagram", "website", "blog", "location", "phonenumber"}
+)
+
+type ContactRevision struct {
+	apiModels.Base
+
+	ContactId int64 `json:"contactid" apiModel:"Contact"`
+
+	Field    string `json:"field"`
+	OldValue string `json:"oldvalue" datastore:",noindex"`
+	NewValue string `json:"newvalue" datastore:",noindex"`
+
+	// Who or what made the change
+	Source string `json:"source"`
+}
+
+/*
+* Public methods
+ */
+
+func (cr *ContactRevision) Key(c context.Context) *datastore.Key {
+	return cr.BaseKey(c, "ContactRevision")
+}
+
+/*
+* Create methods
+ */
+
+func (cr *ContactRevision) Create(c context.Context, r *http.Request, currentUser apiModels.User) (*ContactRevision, error) {
+	cr.CreatedBy = currentUser.Id
+	cr.Created = time.Now()
+
+	_, err := cr.Save(c)
+	return cr, err
+}
+
+/*
+* Update methods
+ */
+
+// Function to save a new contact revision into App Engine
+func (cr *ContactRevision) Save(c context.Context) (*ContactRevision, error) {
+	cr.Updated = time.Now()
+
+	k, err := nds.Put(c, cr.BaseKey(c, "ContactRevision"), cr)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return nil, err
+	}
+	cr.Id = k.IntID()
+	return cr, nil
+}
+
+/*
+* Action methods
+ */
+
+func int64sToString(values []int64) string {
+	stringValues := []string{}
+	for i := 0; i < len(values); i++ {
+		stringValues = append(stringValues, strconv.FormatInt(values[i], 10))
+	}
+	return strings.Join(stringValues, ",")
+}
+
+func stringToInt64s(value string) ([]int64, error) {
+	values := []int64{}
+	if value == "" {
+		return values, nil
+	}
+
+	stringValues := strings.Split(value, ",")
+	for i := 0; i < len(stringValues); i++ {
+		intValue, err := strconv.ParseInt(stringValues[i], 10, 64)
+		if err != nil {
+			return []int64{}, err
+		}
+		values = append(values, intValue)
+	}
+	return values, nil
+}
+
+// Tags of a revision are stored as JSON, so tags can have commas in them
+func stringToTags(value string) ([]string, error) {
+	tags := []string{}
+	if value == "" {
+		return tags, nil
+	}
+
+	err := json.Unmarshal([]byte(value), &tags)
+	if err != nil {
+		return []string{}, err
+	}
+	return tags, nil
+}
+
+// The values of a contact that are tracked by revisions, keyed by field name
+func (ct *Contact) RevisionValues() map[string]string {
+	values := map[string]string{}
+	for i := 0; i < len(revisionFields); i++ {
+		values[revisionFields[i]] = ct.FieldValue(revisionFields[i])
+	}
+
+	tags, _ := json.Marshal(ct.Tags)
+	if ct.Tags == nil {
+		tags = []byte("[]")
+	}
+	values["tags"] = string(tags)
+
+	for i := 0; i < len(ct.CustomFields); i++ {
+		values[customFieldRevisionPrefix+ct.CustomFields[i].Name] = ct.CustomFields[i].Value
+	}
+
+	return values
+}
+
+// Sets a tracked field back to a value from a revision
+func (ct *Contact) SetRevisionValue(field string, value string) error {
+	var err error
+
+	switch field {
+	case "firstname":
+		ct.FirstName = value
+	case "lastname":
+		ct.LastName = value
+	case "email":
+		ct.Email = value
+	case "notes":
+		ct.Notes = value
+	case "employers":
+		ct.Employers, err = stringToInt64s(value)
+	case "pastemployers":
+		ct.PastEmployers, err = stringToInt64s(value)
+	case "linkedin":
+		ct.LinkedIn = value
+	case "twitter":
+		ct.Twitter = value
+	case "instagram":
+		ct.Instagram = value
+	case "website":
+		ct.Website = value
+	case "blog":
+		ct.Blog = value
+	case "location":
+		ct.Location = value
+	case "phonenumber":
+		ct.PhoneNumber = value
+	case "tags":
+		ct.Tags, err = stringToTags(value)
+	default:
+		if !strings.HasPrefix(field, customFieldRevisionPrefix) {
+			return errors.New("Field can not be reverted")
+		}
+
+		name := strings.TrimPrefix(field, customFieldRevisionPrefix)
+		for i := 0; i < len(ct.CustomFields); i++ {
+			if ct.CustomFields[i].Name == name {
+				ct.CustomFields[i].Value = value
+				return nil
+			}
+		}
+		ct.CustomFields = append(ct.CustomFields, CustomContactField{Name: name, Value: value})
+	}
+
+	return err
+}
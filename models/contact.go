@@ -72,8 +72,16 @@ type Contact struct {
 	IsMasterContact bool  `json:"ismastercontact"`
 	ParentContact   int64 `json:"parent" apiModel:"Contact"`
 
-	IsDeleted bool `json:"isdeleted"`
-	ReadOnly  bool `json:"readonly" datastore:"-"`
+	// Identity fields this contact keeps for its list instead of following
+	// its master contact
+	Overrides []string `json:"overrides" datastore:",noindex"`
+
+	IsDeleted bool      `json:"isdeleted"`
+	Deleted   time.Time `json:"deleted"`
+	ReadOnly  bool      `json:"readonly" datastore:"-"`
+
+	// Position of the contact in a filtered or sorted list
+	Cursor string `json:"cursor,omitempty" datastore:"-"`
 
 	ImageURL string `json:"imageurl"`
 
@@ -93,6 +101,47 @@ func (ct *Contact) Key(c context.Context) *datastore.Key {
 	return ct.BaseKey(c, "Contact")
 }
 
+// Value of a field of the contact as text, like it is shown in a column of
+// a media list. Custom fields are matched by their name.
+func (ct *Contact) FieldValue(field string) string {
+	switch field {
+	case "firstname":
+		return ct.FirstName
+	case "lastname":
+		return ct.LastName
+	case "email":
+		return ct.Email
+	case "employers":
+		return int64sToString(ct.Employers)
+	case "pastemployers":
+		return int64sToString(ct.PastEmployers)
+	case "notes":
+		return ct.Notes
+	case "linkedin":
+		return ct.LinkedIn
+	case "twitter":
+		return ct.Twitter
+	case "instagram":
+		return ct.Instagram
+	case "website":
+		return ct.Website
+	case "blog":
+		return ct.Blog
+	case "phonenumber":
+		return ct.PhoneNumber
+	case "location":
+		return ct.Location
+	}
+
+	for i := 0; i < len(ct.CustomFields); i++ {
+		if ct.CustomFields[i].Name == field {
+			return ct.CustomFields[i].Value
+		}
+	}
+
+	return ""
+}
+
 /*
 * Create methods
  */
@@ -127,6 +176,19 @@ func (ct *Contact) Save(c context.Context, r *http.Request) (*Contact, error) {
 	return ct, nil
 }
 
+/*
+* Delete methods
+ */
+
+func (ct *Contact) Delete(c context.Context) (*Contact, error) {
+	err := nds.Delete(c, ct.BaseKey(c, "Contact"))
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return nil, err
+	}
+	return ct, nil
+}
+
 /*
 * Normalization methods
  */
@@ -170,6 +232,8 @@ func (ct *Contact) Normalize() (*Contact, error) {
 	ct.FirstName = strings.TrimSpace(ct.FirstName)
 	ct.LastName = strings.TrimSpace(ct.LastName)
 
+	ct.Tags = NormalizeTags(ct.Tags)
+
 	return ct, nil
 }
 
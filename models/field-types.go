@@ -0,0 +1,258 @@
+package models
+
+import (
+	"errors"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	FieldTypeText        = "Text"
+	FieldTypeNumber      = "Number"
+	FieldTypeDate        = "Date"
+	FieldTypeURL         = "URL"
+	FieldTypeEmail       = "Email"
+	FieldTypeSelect      = "Select"
+	FieldTypeMultiSelect = "MultiSelect"
+	FieldTypeBoolean     = "Boolean"
+)
+
+var fieldTypes = map[string]bool{
+	FieldTypeText:        true,
+	FieldTypeNumber:      true,
+	FieldTypeDate:        true,
+	FieldTypeURL:         true,
+	FieldTypeEmail:       true,
+	FieldTypeSelect:      true,
+	FieldTypeMultiSelect: true,
+	FieldTypeBoolean:     true,
+}
+
+// Layouts accepted for date fields, tried in order
+var fieldDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02",
+	"01/02/2006",
+	"1/2/2006",
+	"Jan 2, 2006",
+	"January 2, 2006",
+}
+
+var fieldBooleanValues = map[string]bool{
+	"true":  true,
+	"yes":   true,
+	"1":     true,
+	"false": false,
+	"no":    false,
+	"0":     false,
+}
+
+/*
+* Private methods
+ */
+
+func parseFieldDate(value string) (time.Time, error) {
+	for i := 0; i < len(fieldDateLayouts); i++ {
+		date, err := time.Parse(fieldDateLayouts[i], value)
+		if err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, errors.New("Not a valid date")
+}
+
+func parseFieldNumber(value string) (float64, error) {
+	return strconv.ParseFloat(strings.Replace(value, ",", "", -1), 64)
+}
+
+func parseFieldBoolean(value string) (bool, error) {
+	if boolean, ok := fieldBooleanValues[strings.ToLower(value)]; ok {
+		return boolean, nil
+	}
+	return false, errors.New("Not a valid boolean")
+}
+
+func isFieldURL(value string) bool {
+	parsedURL, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+	return (parsedURL.Scheme == "http" || parsedURL.Scheme == "https") && parsedURL.Host != ""
+}
+
+func isFieldEmail(value string) bool {
+	address, err := mail.ParseAddress(value)
+	return err == nil && address.Address == value
+}
+
+func fieldOptionExists(options []string, value string) bool {
+	for i := 0; i < len(options); i++ {
+		if options[i] == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Multi select values are stored comma separated
+func splitMultiSelectValue(value string) []string {
+	values := []string{}
+	splitValues := strings.Split(value, ",")
+	for i := 0; i < len(splitValues); i++ {
+		trimmedValue := strings.TrimSpace(splitValues[i])
+		if trimmedValue != "" {
+			values = append(values, trimmedValue)
+		}
+	}
+	return values
+}
+
+/*
+* Public methods
+ */
+
+func IsValidFieldType(fieldType string) bool {
+	_, ok := fieldTypes[fieldType]
+	return ok
+}
+
+// Checks that a value can be stored in a field. Empty values are always
+// allowed, and fields without a type are treated as text.
+func (cfm *CustomFieldsMap) ValidateValue(value string) error {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+
+	var err error
+	switch cfm.Type {
+	case FieldTypeNumber:
+		_, err = parseFieldNumber(value)
+	case FieldTypeDate:
+		_, err = parseFieldDate(value)
+	case FieldTypeBoolean:
+		_, err = parseFieldBoolean(value)
+	case FieldTypeURL:
+		if !isFieldURL(value) {
+			err = errors.New("Not a valid URL")
+		}
+	case FieldTypeEmail:
+		if !isFieldEmail(value) {
+			err = errors.New("Not a valid email")
+		}
+	case FieldTypeSelect:
+		if !fieldOptionExists(cfm.Options, value) {
+			err = errors.New("Not one of the field options")
+		}
+	case FieldTypeMultiSelect:
+		values := splitMultiSelectValue(value)
+		for i := 0; i < len(values); i++ {
+			if !fieldOptionExists(cfm.Options, values[i]) {
+				err = errors.New("Not one of the field options")
+			}
+		}
+	}
+
+	if err != nil {
+		return errors.New(cfm.Name + ": " + err.Error())
+	}
+	return nil
+}
+
+// The values a contact has in a field. Multi select fields can have many,
+// other fields have one unless they are empty.
+func (cfm *CustomFieldsMap) FieldValues(value string) []string {
+	if cfm.Type == FieldTypeMultiSelect {
+		return splitMultiSelectValue(value)
+	}
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return []string{}
+	}
+	return []string{value}
+}
+
+// Compares two values of a field by its type. Returns -1, 0 or 1. Values that
+// don't parse sort after the ones that do.
+func (cfm *CustomFieldsMap) CompareValues(first string, second string) int {
+	switch cfm.Type {
+	case FieldTypeNumber:
+		firstNumber, firstErr := parseFieldNumber(first)
+		secondNumber, secondErr := parseFieldNumber(second)
+		if firstErr == nil && secondErr == nil {
+			if firstNumber < secondNumber {
+				return -1
+			} else if firstNumber > secondNumber {
+				return 1
+			}
+			return 0
+		} else if firstErr == nil {
+			return -1
+		} else if secondErr == nil {
+			return 1
+		}
+	case FieldTypeDate:
+		firstDate, firstErr := parseFieldDate(first)
+		secondDate, secondErr := parseFieldDate(second)
+		if firstErr == nil && secondErr == nil {
+			if firstDate.Before(secondDate) {
+				return -1
+			} else if firstDate.After(secondDate) {
+				return 1
+			}
+			return 0
+		} else if firstErr == nil {
+			return -1
+		} else if secondErr == nil {
+			return 1
+		}
+	case FieldTypeBoolean:
+		firstBoolean, _ := parseFieldBoolean(first)
+		secondBoolean, _ := parseFieldBoolean(second)
+		if firstBoolean == secondBoolean {
+			return 0
+		} else if !firstBoolean {
+			return -1
+		}
+		return 1
+	}
+
+	return strings.Compare(strings.ToLower(first), strings.ToLower(second))
+}
+
+// Guesses the type of a field from the values contacts already have in it.
+// A type is only picked when every non-empty value fits it.
+func InferFieldType(values []string) string {
+	nonEmptyValues := []string{}
+	for i := 0; i < len(values); i++ {
+		if strings.TrimSpace(values[i]) != "" {
+			nonEmptyValues = append(nonEmptyValues, strings.TrimSpace(values[i]))
+		}
+	}
+
+	if len(nonEmptyValues) == 0 {
+		return FieldTypeText
+	}
+
+	candidates := []string{FieldTypeNumber, FieldTypeBoolean, FieldTypeDate, FieldTypeEmail, FieldTypeURL}
+	for i := 0; i < len(candidates); i++ {
+		field := CustomFieldsMap{Type: candidates[i]}
+		matches := true
+		for x := 0; x < len(nonEmptyValues); x++ {
+			if field.ValidateValue(nonEmptyValues[x]) != nil {
+				matches = false
+				break
+			}
+		}
+
+		if matches {
+			return candidates[i]
+		}
+	}
+
+	return FieldTypeText
+}
@@ -28,6 +28,17 @@ type File struct {
 
 	Imported   bool `json:"imported"`
 	FileExists bool `json:"fileexists"`
+
+	// Values that were left out of the import because they didn't fit the
+	// type of their column
+	RejectedCells []RejectedCell `json:"rejectedcells" datastore:",noindex"`
+}
+
+type RejectedCell struct {
+	Row    int    `json:"row"` // Row of the contact in the upload, starting at 1
+	Column string `json:"column"`
+	Value  string `json:"value"`
+	Error  string `json:"error"`
 }
 
 type FileOrder struct {
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"strings"
+
+	apiModels "github.com/news-ai/api/models"
+)
+
+var (
+	ListRoleViewer = "viewer"
+	ListRoleEditor = "editor"
+	ListRoleOwner  = "owner"
+)
+
+var listRoleRanks = map[string]int{
+	ListRoleViewer: 1,
+	ListRoleEditor: 2,
+	ListRoleOwner:  3,
+}
+
+// A user or team a media list is shared with. Invitations to people who
+// don't have an account yet only have an Email until they sign up.
+type ListShare struct {
+	UserId int64  `json:"userid"`
+	TeamId int64  `json:"teamid"`
+	Email  string `json:"email"`
+	Role   string `json:"role"`
+
+	InvitedBy int64 `json:"invitedby"`
+}
+
+/*
+* Private methods
+ */
+
+func higherListRole(role string, otherRole string) string {
+	if listRoleRanks[otherRole] > listRoleRanks[role] {
+		return otherRole
+	}
+	return role
+}
+
+/*
+* Public methods
+ */
+
+func IsValidListRole(role string) bool {
+	_, ok := listRoleRanks[role]
+	return ok
+}
+
+// Whether a role gives at least the access of another role. No role gives
+// no access.
+func ListRoleAtLeast(role string, requiredRole string) bool {
+	return role != "" && listRoleRanks[role] >= listRoleRanks[requiredRole]
+}
+
+func (ls *ListShare) Matches(user apiModels.User) bool {
+	if ls.UserId != 0 && ls.UserId == user.Id {
+		return true
+	}
+
+	if ls.TeamId != 0 && ls.TeamId == user.TeamId {
+		return true
+	}
+
+	return ls.UserId == 0 && ls.Email != "" && strings.ToLower(ls.Email) == strings.ToLower(user.Email)
+}
+
+// The role a user has on a media list. Lists the user created are theirs,
+// members of the list's team can edit it, and public lists can be viewed by
+// anyone. Admins are handled by the caller.
+func (ml *MediaList) RoleForUser(user apiModels.User) string {
+	if ml.CreatedBy == user.Id {
+		return ListRoleOwner
+	}
+
+	role := ""
+	if ml.TeamId != 0 && ml.TeamId == user.TeamId {
+		role = ListRoleEditor
+	}
+
+	if ml.PublicList {
+		role = higherListRole(role, ListRoleViewer)
+	}
+
+	for i := 0; i < len(ml.Shares); i++ {
+		if ml.Shares[i].Matches(user) {
+			role = higherListRole(role, ml.Shares[i].Role)
+		}
+	}
+
+	return role
+}
@@ -0,0 +1,144 @@
+package models
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+
+	apiModels "github.com/news-ai/api/models"
+
+	"github.com/qedus/nds"
+)
+
+var (
+	SnapshotReasonManual     = "manual"
+	SnapshotReasonUpload     = "upload"
+	SnapshotReasonBulkDelete = "bulkdelete"
+	SnapshotReasonMove       = "move"
+	SnapshotReasonFieldsMap  = "fieldsmap"
+	SnapshotReasonContacts   = "contacts"
+	SnapshotReasonRestore    = "restore"
+)
+
+// The membership and field map of a media list at a point in time. The
+// values of its contacts are kept in ListSnapshotContact.
+type ListSnapshot struct {
+	apiModels.Base
+
+	ListId int64  `json:"listid" apiModel:"List"`
+	Name   string `json:"name"`
+
+	// Why the snapshot was taken, manual snapshots are the only ones a user
+	// asked for
+	Reason string `json:"reason"`
+
+	Contacts  []int64           `json:"contacts" datastore:",noindex" apiModel:"Contact"`
+	FieldsMap []CustomFieldsMap `json:"fieldsmap" datastore:",noindex"`
+}
+
+type ListSnapshotValue struct {
+	Field string `json:"field"`
+	Value string `json:"value"`
+}
+
+// The values a contact had when a snapshot of its list was taken. Values are
+// keyed the same way as contact revisions.
+type ListSnapshotContact struct {
+	apiModels.Base
+
+	SnapshotId int64 `json:"snapshotid"`
+	ContactId  int64 `json:"contactid" apiModel:"Contact"`
+
+	Values []ListSnapshotValue `json:"values" datastore:",noindex"`
+}
+
+/*
+* Public methods
+ */
+
+func (ls *ListSnapshot) Key(c context.Context) *datastore.Key {
+	return ls.BaseKey(c, "ListSnapshot")
+}
+
+func (lsc *ListSnapshotContact) Key(c context.Context) *datastore.Key {
+	return lsc.BaseKey(c, "ListSnapshotContact")
+}
+
+/*
+* Create methods
+ */
+
+func (ls *ListSnapshot) Create(c context.Context, r *http.Request, currentUser apiModels.User) (*ListSnapshot, error) {
+	ls.CreatedBy = currentUser.Id
+	ls.Created = time.Now()
+
+	_, err := ls.Save(c)
+	return ls, err
+}
+
+/*
+* Update methods
+ */
+
+// Function to save a new list snapshot into App Engine
+func (ls *ListSnapshot) Save(c context.Context) (*ListSnapshot, error) {
+	ls.Updated = time.Now()
+	storeFieldsMapOptions(ls.FieldsMap)
+
+	k, err := nds.Put(c, ls.BaseKey(c, "ListSnapshot"), ls)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return nil, err
+	}
+	ls.Format(k, "snapshots")
+	return ls, nil
+}
+
+func (ls *ListSnapshot) Format(key *datastore.Key, modelType string) {
+	ls.Type = modelType
+	ls.Id = key.IntID()
+	loadFieldsMapOptions(ls.FieldsMap)
+}
+
+/*
+* Action methods
+ */
+
+// Takes the non-empty values of a contact for a snapshot
+func NewListSnapshotContact(snapshotId int64, contact Contact) ListSnapshotContact {
+	values := contact.RevisionValues()
+
+	fields := []string{}
+	for field, value := range values {
+		if value != "" {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	snapshotContact := ListSnapshotContact{
+		SnapshotId: snapshotId,
+		ContactId:  contact.Id,
+	}
+	for i := 0; i < len(fields); i++ {
+		snapshotContact.Values = append(snapshotContact.Values, ListSnapshotValue{
+			Field: fields[i],
+			Value: values[fields[i]],
+		})
+	}
+
+	return snapshotContact
+}
+
+func (lsc *ListSnapshotContact) ValuesMap() map[string]string {
+	values := map[string]string{}
+	for i := 0; i < len(lsc.Values); i++ {
+		values[lsc.Values[i].Field] = lsc.Values[i].Value
+	}
+	return values
+}
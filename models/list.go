@@ -23,7 +23,14 @@ type CustomFieldsMap struct {
 	Internal    bool   `json:"internal" datastore:"-"`
 	ReadOnly    bool   `json:"readonly" datastore:"-"`
 	Description string `json:"description" datastore:"-"`
-	Type        string `json:"type" datastore:"-"`
+
+	// Type of the values in the field, and the choices for select fields.
+	// Fields without a type are treated as text until they are migrated.
+	// The datastore can't store a list inside FieldsMap, so the choices are
+	// stored one per line in StoredOptions.
+	Type          string   `json:"type"`
+	Options       []string `json:"options" datastore:"-"`
+	StoredOptions string   `json:"-" datastore:",noindex"`
 }
 
 type MediaList struct {
@@ -38,7 +45,7 @@ type MediaList struct {
 
 	FieldsMap []CustomFieldsMap `json:"fieldsmap" datastore:",noindex"`
 
-	Tags []string `json:"tags" datastore:",noindex"`
+	Tags []string `json:"tags"`
 
 	CustomFields []string `json:"-" datastore:",noindex"`
 	Fields       []string `json:"-" datastore:",noindex"`
@@ -52,7 +59,20 @@ type MediaList struct {
 	Archived   bool `json:"archived"`
 	Subscribed bool `json:"subscribed"`
 
-	IsDeleted bool `json:"isdeleted"`
+	IsDeleted bool      `json:"isdeleted"`
+	Deleted   time.Time `json:"deleted"`
+
+	// Who else the list is shared with, and the role the current user has
+	Shares []ListShare `json:"shares"`
+	Role   string      `json:"role" datastore:"-"`
+
+	// Smart lists get their contacts from a saved query over all of the
+	// user's contacts. They are refreshed on a schedule, or every time they
+	// are read if RefreshOnRead is set.
+	IsSmartList    bool         `json:"issmartlist"`
+	SmartQuery     ContactQuery `json:"smartquery" datastore:",noindex"`
+	RefreshOnRead  bool         `json:"refreshonread"`
+	SmartRefreshed time.Time    `json:"smartrefreshed"`
 }
 
 /*
@@ -73,6 +93,29 @@ var fieldsMapValueToDescription = map[string]string{
 	"twitterposts":     "Updated on a daily basis",
 
 	"latestheadline": "Updated on a daily basis",
+
+	"emailssent": "Updated as emails are sent",
+	"openrate":   "Updated as emails are opened",
+	"clickrate":  "Updated as emails are clicked",
+	"lastopened": "Updated as emails are opened",
+}
+
+/*
+* Private methods
+ */
+
+func storeFieldsMapOptions(fieldsMap []CustomFieldsMap) {
+	for i := 0; i < len(fieldsMap); i++ {
+		fieldsMap[i].StoredOptions = strings.Join(fieldsMap[i].Options, "\n")
+	}
+}
+
+func loadFieldsMapOptions(fieldsMap []CustomFieldsMap) {
+	for i := 0; i < len(fieldsMap); i++ {
+		if fieldsMap[i].StoredOptions != "" {
+			fieldsMap[i].Options = strings.Split(fieldsMap[i].StoredOptions, "\n")
+		}
+	}
 }
 
 /*
@@ -111,6 +154,11 @@ func (ml *MediaList) AddNewCustomFieldsMapToOldLists(c context.Context) {
 
 		"latestheadline": true,
 		"lastcontacted":  true,
+
+		"emailssent": true,
+		"openrate":   true,
+		"clickrate":  true,
+		"lastopened": true,
 	}
 
 	newDefaultFieldsMap := map[string]bool{
@@ -134,6 +182,11 @@ func (ml *MediaList) AddNewCustomFieldsMapToOldLists(c context.Context) {
 
 		"lastcontacted": "Last Contacted",
 
+		"emailssent": "Emails Sent",
+		"openrate":   "Open Rate",
+		"clickrate":  "Click Rate",
+		"lastopened": "Last Opened",
+
 		"firstname":     "First Name",
 		"lastname":      "Last Name",
 		"email":         "Email",
@@ -174,6 +227,12 @@ func (ml *MediaList) AddNewCustomFieldsMapToOldLists(c context.Context) {
 			}
 		}
 
+		if _, ok := EngagementFields[ml.FieldsMap[i].Value]; ok {
+			if _, ok := newFieldsMap[ml.FieldsMap[i].Value]; ok {
+				newFieldsMap[ml.FieldsMap[i].Value] = false
+			}
+		}
+
 		if _, ok := newDefaultFieldsMap[ml.FieldsMap[i].Value]; ok {
 			newDefaultFieldsMap[ml.FieldsMap[i].Value] = false
 		}
@@ -231,6 +290,10 @@ func (ml *MediaList) AddNewCustomFieldsMapToOldLists(c context.Context) {
 func (ml *MediaList) Save(c context.Context) (*MediaList, error) {
 	// Update the Updated time
 	ml.Updated = time.Now()
+	ml.Tags = NormalizeTags(ml.Tags)
+	ml.SmartQuery.Format()
+
+	storeFieldsMapOptions(ml.FieldsMap)
 
 	k, err := nds.Put(c, ml.BaseKey(c, "MediaList"), ml)
 	if err != nil {
@@ -244,6 +307,8 @@ func (ml *MediaList) Save(c context.Context) (*MediaList, error) {
 func (ml *MediaList) Format(key *datastore.Key, modelType string) {
 	ml.Type = modelType
 	ml.Id = key.IntID()
+	ml.SmartQuery.Format()
+	loadFieldsMapOptions(ml.FieldsMap)
 
 	// Add descriptions on runtime
 	for i := 0; i < len(ml.FieldsMap); i++ {
@@ -264,7 +329,18 @@ func (ml *MediaList) Format(key *datastore.Key, modelType string) {
 		}
 
 		if ml.FieldsMap[i].Value == "lastcontacted" {
-			ml.FieldsMap[i].Type = "Date"
+			ml.FieldsMap[i].Type = FieldTypeDate
+		}
+
+		// Social counts are always numbers
+		if ml.FieldsMap[i].ReadOnly && ml.FieldsMap[i].Value != "latestheadline" && ml.FieldsMap[i].Value != "lastcontacted" {
+			ml.FieldsMap[i].Type = FieldTypeNumber
+		}
+
+		// Engagement comes from the emails sent to the contact
+		if fieldType, ok := EngagementFields[ml.FieldsMap[i].Value]; ok && ml.FieldsMap[i].CustomField {
+			ml.FieldsMap[i].ReadOnly = true
+			ml.FieldsMap[i].Type = fieldType
 		}
 
 		// If this particular value exists in fieldsMapValueToDescription then add description
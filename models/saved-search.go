@@ -0,0 +1,103 @@
+package models
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+
+	apiModels "github.com/news-ai/api/models"
+
+	"github.com/qedus/nds"
+)
+
+var (
+	SavedSearchContacts  = "contacts"
+	SavedSearchHeadlines = "headlines"
+)
+
+// A contact or headline search the user saved, like "reporters covering
+// fintech in London". With alerts on, the user gets a digest of the results
+// that are new since the search last ran.
+type SavedSearch struct {
+	apiModels.Base
+
+	Name  string `json:"name"`
+	Kind  string `json:"kind"`
+	Query string `json:"query"`
+
+	// Searches can be kept to one list, to its contacts or to the headlines
+	// of its feeds
+	ListId int64 `json:"listid" apiModel:"MediaList"`
+
+	Alerts bool `json:"alerts"`
+
+	// Ids of the results of the last run, to find the new ones
+	LastResults    []string  `json:"-" datastore:",noindex"`
+	LastRun        time.Time `json:"lastrun"`
+	LastNewResults int       `json:"lastnewresults"`
+}
+
+/*
+* Public methods
+ */
+
+func (ss *SavedSearch) Key(c context.Context) *datastore.Key {
+	return ss.BaseKey(c, "SavedSearch")
+}
+
+func (ss *SavedSearch) Normalize() {
+	ss.Name = strings.TrimSpace(ss.Name)
+	ss.Kind = strings.ToLower(strings.TrimSpace(ss.Kind))
+	ss.Query = strings.TrimSpace(ss.Query)
+
+	if ss.Name == "" {
+		ss.Name = ss.Query
+	}
+}
+
+func (ss *SavedSearch) Validate() error {
+	if ss.Kind != SavedSearchContacts && ss.Kind != SavedSearchHeadlines {
+		return errors.New("A saved search is for " + SavedSearchContacts + " or " + SavedSearchHeadlines)
+	}
+
+	if ss.Query == "" {
+		return errors.New("A saved search needs a query")
+	}
+
+	return nil
+}
+
+/*
+* Create methods
+ */
+
+func (ss *SavedSearch) Create(c context.Context, r *http.Request, currentUser apiModels.User) (*SavedSearch, error) {
+	ss.CreatedBy = currentUser.Id
+	ss.Created = time.Now()
+
+	_, err := ss.Save(c)
+	return ss, err
+}
+
+/*
+* Update methods
+ */
+
+func (ss *SavedSearch) Save(c context.Context) (*SavedSearch, error) {
+	// Update the Updated time
+	ss.Updated = time.Now()
+
+	k, err := nds.Put(c, ss.BaseKey(c, "SavedSearch"), ss)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return nil, err
+	}
+	ss.Id = k.IntID()
+	return ss, nil
+}
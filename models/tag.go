@@ -0,0 +1,33 @@
+package models
+
+import (
+	"strings"
+)
+
+/*
+* Public methods
+ */
+
+// Tags are compared lower case, with the whitespace around and inside them
+// collapsed, so "Tech ", "tech" and "TECH" are the same tag
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.Join(strings.Fields(tag), " "))
+}
+
+// Normalizes every tag, leaving out empty and repeated ones
+func NormalizeTags(tags []string) []string {
+	normalizedTags := []string{}
+	existingTags := map[string]bool{}
+	for i := 0; i < len(tags); i++ {
+		tag := NormalizeTag(tags[i])
+		if tag == "" {
+			continue
+		}
+
+		if _, ok := existingTags[tag]; !ok {
+			existingTags[tag] = true
+			normalizedTags = append(normalizedTags, tag)
+		}
+	}
+	return normalizedTags
+}
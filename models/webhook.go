@@ -0,0 +1,277 @@
+package models
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+
+	apiModels "github.com/news-ai/api/models"
+
+	"github.com/qedus/nds"
+)
+
+var (
+	WebhookContactCreated    = "contact.created"
+	WebhookContactUpdated    = "contact.updated"
+	WebhookListUploaded      = "list.uploaded"
+	WebhookEmailSent         = "email.sent"
+	WebhookEmailOpened       = "email.opened"
+	WebhookEmailClicked      = "email.clicked"
+	WebhookEmailBounced      = "email.bounced"
+	WebhookEmailUnsubscribed = "email.unsubscribed"
+
+	// Sent when the user tests a webhook
+	WebhookTest = "webhook.test"
+
+	WebhookEvents = []string{
+		WebhookContactCreated,
+		WebhookContactUpdated,
+		WebhookListUploaded,
+		WebhookEmailSent,
+		WebhookEmailOpened,
+		WebhookEmailClicked,
+		WebhookEmailBounced,
+		WebhookEmailUnsubscribed,
+	}
+
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+
+	// Domains webhooks can't be sent to, with their subdomains. Internal has
+	// the metadata server, metadata.google.internal.
+	webhookBlockedDomains = []string{"localhost", "local", "internal"}
+
+	// Addresses webhooks can't be sent to: unspecified, private, shared,
+	// loopback and link-local, which has the metadata server at
+	// 169.254.169.254
+	webhookBlockedNetworks = []string{
+		"0.0.0.0/8",
+		"10.0.0.0/8",
+		"100.64.0.0/10",
+		"127.0.0.0/8",
+		"169.254.0.0/16",
+		"172.16.0.0/12",
+		"192.168.0.0/16",
+		"::/128",
+		"::1/128",
+		"fc00::/7",
+		"fe80::/10",
+	}
+)
+
+// A url events are posted to, like a CRM or a Slack bot. Every payload is
+// signed with the secret of the webhook.
+type Webhook struct {
+	apiModels.Base
+
+	Name   string   `json:"name"`
+	Url    string   `json:"url"`
+	Events []string `json:"events"`
+
+	// Webhooks of a team get the events of everyone in the team, otherwise
+	// only the events of the user who created the webhook
+	TeamId int64 `json:"teamid"`
+
+	// The secret is only sent back when the webhook is made and when it is
+	// rotated, in ShownSecret
+	Secret      string `json:"-" datastore:",noindex"`
+	ShownSecret string `json:"secret,omitempty" datastore:"-"`
+
+	Active bool `json:"active"`
+}
+
+// One event sent to a webhook, and how sending it went
+type WebhookDelivery struct {
+	apiModels.Base
+
+	WebhookId int64  `json:"webhookid" apiModel:"Webhook"`
+	Event     string `json:"event"`
+	Payload   string `json:"payload" datastore:",noindex"`
+
+	Status       string    `json:"status"`
+	Attempts     int       `json:"attempts"`
+	NextAttempt  time.Time `json:"nextattempt"`
+	ResponseCode int       `json:"responsecode"`
+	LastError    string    `json:"lasterror" datastore:",noindex"`
+	Delivered    time.Time `json:"delivered"`
+}
+
+/*
+* Private methods
+ */
+
+func isBlockedWebhookIP(ip net.IP) bool {
+	if ip.IsMulticast() {
+		return true
+	}
+
+	for i := 0; i < len(webhookBlockedNetworks); i++ {
+		_, network, err := net.ParseCIDR(webhookBlockedNetworks[i])
+		if err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+* Public methods
+ */
+
+func (w *Webhook) Key(c context.Context) *datastore.Key {
+	return w.BaseKey(c, "Webhook")
+}
+
+func (wd *WebhookDelivery) Key(c context.Context) *datastore.Key {
+	return wd.BaseKey(c, "WebhookDelivery")
+}
+
+func (w *Webhook) Normalize() {
+	w.Name = strings.TrimSpace(w.Name)
+	w.Url = strings.TrimSpace(w.Url)
+
+	events := []string{}
+	seen := map[string]bool{}
+	for i := 0; i < len(w.Events); i++ {
+		event := strings.ToLower(strings.TrimSpace(w.Events[i]))
+		if _, ok := seen[event]; ok || event == "" {
+			continue
+		}
+		seen[event] = true
+		events = append(events, event)
+	}
+	w.Events = events
+
+	if w.Name == "" {
+		w.Name = w.Url
+	}
+}
+
+// Webhooks are only sent over https to public servers, so they can't be used
+// to reach the servers the app runs next to
+func (w *Webhook) ValidateUrl() error {
+	webhookUrl, err := url.Parse(w.Url)
+	if err != nil || webhookUrl.Scheme != "https" || webhookUrl.Host == "" {
+		return errors.New("A webhook needs an https url")
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(webhookUrl.Hostname()), ".")
+	if ip := net.ParseIP(host); ip != nil {
+		if isBlockedWebhookIP(ip) {
+			return errors.New("Webhooks can't be sent to private addresses")
+		}
+		return nil
+	}
+
+	// Names need a top level domain, which never starts with a digit. This
+	// also leaves out addresses written in other ways, like 0x7f.1.
+	labels := strings.Split(host, ".")
+	topLevelDomain := labels[len(labels)-1]
+	if len(labels) < 2 || topLevelDomain == "" || (topLevelDomain[0] >= '0' && topLevelDomain[0] <= '9') {
+		return errors.New("A webhook needs the public name or address of a server")
+	}
+
+	for i := 0; i < len(webhookBlockedDomains); i++ {
+		if host == webhookBlockedDomains[i] || strings.HasSuffix(host, "."+webhookBlockedDomains[i]) {
+			return errors.New("Webhooks can't be sent to " + host)
+		}
+	}
+
+	return nil
+}
+
+func (w *Webhook) Validate() error {
+	err := w.ValidateUrl()
+	if err != nil {
+		return err
+	}
+
+	if len(w.Events) == 0 {
+		return errors.New("A webhook needs at least one event")
+	}
+
+	for i := 0; i < len(w.Events); i++ {
+		if !IsWebhookEvent(w.Events[i]) {
+			return errors.New(w.Events[i] + " is not an event webhooks can get")
+		}
+	}
+
+	return nil
+}
+
+// If the webhook gets the event
+func (w *Webhook) HasEvent(event string) bool {
+	for i := 0; i < len(w.Events); i++ {
+		if w.Events[i] == event {
+			return true
+		}
+	}
+	return false
+}
+
+func IsWebhookEvent(event string) bool {
+	for i := 0; i < len(WebhookEvents); i++ {
+		if WebhookEvents[i] == event {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+* Create methods
+ */
+
+func (w *Webhook) Create(c context.Context, r *http.Request, currentUser apiModels.User) (*Webhook, error) {
+	w.CreatedBy = currentUser.Id
+	w.Created = time.Now()
+
+	_, err := w.Save(c)
+	return w, err
+}
+
+func (wd *WebhookDelivery) Create(c context.Context) (*WebhookDelivery, error) {
+	wd.Created = time.Now()
+
+	_, err := wd.Save(c)
+	return wd, err
+}
+
+/*
+* Update methods
+ */
+
+func (w *Webhook) Save(c context.Context) (*Webhook, error) {
+	// Update the Updated time
+	w.Updated = time.Now()
+
+	k, err := nds.Put(c, w.BaseKey(c, "Webhook"), w)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return nil, err
+	}
+	w.Id = k.IntID()
+	return w, nil
+}
+
+func (wd *WebhookDelivery) Save(c context.Context) (*WebhookDelivery, error) {
+	// Update the Updated time
+	wd.Updated = time.Now()
+
+	k, err := nds.Put(c, wd.BaseKey(c, "WebhookDelivery"), wd)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return nil, err
+	}
+	wd.Id = k.IntID()
+	return wd, nil
+}
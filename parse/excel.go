@@ -9,7 +9,6 @@ import (
 
 	"github.com/news-ai/tabulae/controllers"
 	"github.com/news-ai/tabulae/models"
-	"github.com/news-ai/tabulae/sync"
 
 	"github.com/news-ai/goexcel"
 	"github.com/news-ai/web/utilities"
@@ -25,21 +24,21 @@ func FileToExcelHeader(r *http.Request, file []byte, contentType string) ([]goex
 	return goexcel.FileToExcelHeader(c, r, file, contentType)
 }
 
-func ExcelHeadersToListModel(r *http.Request, file []byte, fileName string, headerNames []string, headers []string, mediaListid int64, contentType string) (models.MediaList, error) {
+func ExcelHeadersToListModel(r *http.Request, file []byte, fileName string, headerNames []string, headers []string, mediaListid int64, contentType string) (models.MediaList, []models.RejectedCell, error) {
 	c := appengine.NewContext(r)
 
 	// Batch get all the contacts
 	contacts, customFields, err := goexcel.HeadersToListModel(c, r, file, headers, contentType)
 	if err != nil {
 		log.Errorf(c, "%v", err)
-		return models.MediaList{}, err
+		return models.MediaList{}, []models.RejectedCell{}, err
 	}
 
 	// Batch create all the contact
-	contactIds, publicationIds, err := controllers.BatchCreateContactsForExcelUpload(c, r, contacts, mediaListid)
+	contactIds, publicationIds, rejectedCells, err := controllers.BatchCreateContactsForExcelUpload(c, r, contacts, mediaListid)
 	if err != nil {
 		log.Errorf(c, "%v", err)
-		return models.MediaList{}, err
+		return models.MediaList{}, []models.RejectedCell{}, err
 	}
 
 	if len(headers) != len(headerNames) {
@@ -47,7 +46,7 @@ func ExcelHeadersToListModel(r *http.Request, file []byte, fileName string, head
 		log.Infof(c, "%v", headerNames)
 
 		headerError := errors.New("Length of headers does not match length of header names")
-		return models.MediaList{}, headerError
+		return models.MediaList{}, []models.RejectedCell{}, headerError
 	}
 
 	// Create a media list
@@ -62,14 +61,30 @@ func ExcelHeadersToListModel(r *http.Request, file []byte, fileName string, head
 				customField.Value = headers[i]
 				customField.CustomField = true
 				customField.Hidden = false
+
+				// Guess the type of the new column from what was uploaded
+				values := []string{}
+				for x := 0; x < len(contacts); x++ {
+					for y := 0; y < len(contacts[x].CustomFields); y++ {
+						if contacts[x].CustomFields[y].Name == headers[i] {
+							values = append(values, contacts[x].CustomFields[y].Value)
+						}
+					}
+				}
+				customField.Type = models.InferFieldType(values)
+
 				mediaList.FieldsMap = append(mediaList.FieldsMap, customField)
 			}
 		}
 	}
 
 	// Save the media list
-	mediaList.Save(c)
-	sync.ListUploadResourceBulkSync(r, mediaList.Id, mediaList.Contacts, publicationIds)
+	err = controllers.SaveUploadedMediaList(c, &mediaList, publicationIds)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return models.MediaList{}, []models.RejectedCell{}, err
+	}
+	controllers.TriggerListUploadedWebhooks(c, mediaList, publicationIds)
 
-	return mediaList, nil
+	return mediaList, rejectedCells, nil
 }
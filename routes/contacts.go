@@ -12,6 +12,7 @@ import (
 	"github.com/pquerna/ffjson/ffjson"
 
 	"github.com/news-ai/tabulae/controllers"
+	"github.com/news-ai/tabulae/export"
 
 	"github.com/news-ai/web/api"
 	nError "github.com/news-ai/web/errors"
@@ -52,6 +53,20 @@ func handleContactAction(c context.Context, r *http.Request, id string, action s
 			return api.BaseResponseHandler(val, included, count, total, err, r)
 		case "enrich":
 			return api.BaseSingleResponseHandler(controllers.EnrichContact(c, r, id))
+		case "master":
+			return api.BaseSingleResponseHandler(controllers.GetMasterContact(c, r, id))
+		case "engagement":
+			return api.BaseSingleResponseHandler(controllers.GetEngagementForContact(c, r, id))
+		case "history":
+			val, included, count, total, err := controllers.GetContactHistory(c, r, id)
+			return api.BaseResponseHandler(val, included, count, total, err, r)
+		}
+	case "POST":
+		switch action {
+		case "history":
+			return api.BaseSingleResponseHandler(controllers.RevertContactField(c, r, id))
+		case "restore":
+			return api.BaseSingleResponseHandler(controllers.RestoreContact(c, r, id))
 		}
 	}
 	return nil, errors.New("method not implemented")
@@ -60,16 +75,24 @@ func handleContactAction(c context.Context, r *http.Request, id string, action s
 func handleContact(c context.Context, r *http.Request, id string) (interface{}, error) {
 	switch r.Method {
 	case "GET":
+		if id == "duplicates" {
+			val, included, count, total, err := controllers.GetDuplicateContacts(c, r)
+			return api.BaseResponseHandler(val, included, count, total, err, r)
+		}
 		return api.BaseSingleResponseHandler(controllers.GetContact(c, r, id))
 	case "PATCH":
 		return api.BaseSingleResponseHandler(controllers.UpdateSingleContact(c, r, id))
 	case "POST":
-		if id == "copy" {
+		if id == "merge" {
+			return api.BaseSingleResponseHandler(controllers.MergeContacts(c, r))
+		} else if id == "copy" {
 			val, included, count, total, err := controllers.CopyContacts(c, r)
 			return api.BaseResponseHandler(val, included, count, total, err, r)
 		} else if id == "bulkdelete" {
 			val, included, count, total, err := controllers.BulkDeleteContacts(c, r)
 			return api.BaseResponseHandler(val, included, count, total, err, r)
+		} else if id == "bulkedit" {
+			return api.BaseSingleResponseHandler(controllers.BulkEditContacts(c, r))
 		}
 	case "DELETE":
 		return api.BaseSingleResponseHandler(controllers.DeleteContact(c, r, id))
@@ -113,6 +136,16 @@ func ContactHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params
 	w.Header().Set("Content-Type", "application/json")
 	c := appengine.NewContext(r)
 	id := ps.ByName("id")
+
+	// Exports are streamed to the client rather than encoded as JSON
+	if r.Method == "POST" && id == "export" {
+		err := export.ContactsExport(c, w, r)
+		if err != nil {
+			nError.ReturnError(w, http.StatusInternalServerError, "Contact handling error", err.Error())
+		}
+		return
+	}
+
 	val, err := handleContact(c, r, id)
 
 	if err == nil {
@@ -130,6 +163,15 @@ func ContactActionHandler(w http.ResponseWriter, r *http.Request, ps httprouter.
 	c := appengine.NewContext(r)
 	id := ps.ByName("id")
 	action := ps.ByName("action")
+
+	if r.Method == "GET" && action == "export" {
+		err := export.ContactExport(c, w, r, id)
+		if err != nil {
+			nError.ReturnError(w, http.StatusInternalServerError, "Contact handling error", err.Error())
+		}
+		return
+	}
+
 	val, err := handleContactAction(c, r, id, action)
 
 	if err == nil {
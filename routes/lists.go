@@ -12,6 +12,7 @@ import (
 	"github.com/pquerna/ffjson/ffjson"
 
 	"github.com/news-ai/tabulae/controllers"
+	"github.com/news-ai/tabulae/export"
 	"github.com/news-ai/tabulae/files"
 
 	"github.com/news-ai/web/api"
@@ -41,6 +42,9 @@ func handleMediaListActions(c context.Context, r *http.Request, id string, actio
 		case "emails":
 			val, included, count, total, err := controllers.GetEmailsForList(c, r, id)
 			return api.BaseResponseHandler(val, included, count, total, err, r)
+		case "snapshots":
+			val, included, count, total, err := controllers.GetSnapshotsForList(c, r, id)
+			return api.BaseResponseHandler(val, included, count, total, err, r)
 		case "public":
 			return api.BaseSingleResponseHandler(controllers.UpdateMediaListToPublic(c, r, id))
 		case "resync":
@@ -56,6 +60,18 @@ func handleMediaListActions(c context.Context, r *http.Request, id string, actio
 			return api.BaseSingleResponseHandler(controllers.GetInstagramTimeseriesForList(c, r, id))
 		case "duplicate":
 			return api.BaseSingleResponseHandler(controllers.DuplicateList(c, r, id))
+		case "restore":
+			return api.BaseSingleResponseHandler(controllers.RestoreMediaList(c, r, id))
+		case "refresh":
+			return api.BaseSingleResponseHandler(controllers.RefreshSmartList(c, r, id))
+		case "snapshots":
+			return api.BaseSingleResponseHandler(controllers.CreateSnapshotForList(c, r, id))
+		case "share":
+			return api.BaseSingleResponseHandler(controllers.ShareMediaList(c, r, id))
+		case "unshare":
+			return api.BaseSingleResponseHandler(controllers.UnshareMediaList(c, r, id))
+		case "combine":
+			return api.BaseSingleResponseHandler(controllers.CombineMediaLists(c, r, id))
 		}
 	}
 	return nil, errors.New("method not implemented")
@@ -76,6 +92,9 @@ func handleMediaList(c context.Context, r *http.Request, id string) (interface{}
 		} else if id == "team" {
 			val, included, count, total, err := controllers.GetTeamMediaLists(c, r)
 			return api.BaseResponseHandler(val, included, count, total, err, r)
+		} else if id == "shared" {
+			val, included, count, total, err := controllers.GetSharedMediaLists(c, r)
+			return api.BaseResponseHandler(val, included, count, total, err, r)
 		}
 		return api.BaseSingleResponseHandler(controllers.GetMediaList(c, r, id))
 	case "PATCH":
@@ -137,6 +156,15 @@ func MediaListActionHandler(w http.ResponseWriter, r *http.Request, ps httproute
 	id := ps.ByName("id")
 	action := ps.ByName("action")
 
+	// Exports are streamed to the client rather than encoded as JSON
+	if r.Method == "GET" && action == "export" {
+		err := export.MediaListExport(c, w, r, id)
+		if err != nil {
+			nError.ReturnError(w, http.StatusInternalServerError, errMediaListHandling, err.Error())
+		}
+		return
+	}
+
 	val, err := handleMediaListActions(c, r, id, action)
 	if err == nil {
 		err = ffjson.NewEncoder(w).Encode(val)
@@ -0,0 +1,72 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pquerna/ffjson/ffjson"
+
+	"github.com/news-ai/tabulae/controllers"
+
+	"github.com/news-ai/web/api"
+	nError "github.com/news-ai/web/errors"
+)
+
+var (
+	errOutboxHandling = "Outbox handling error"
+)
+
+func handleOutboxEvent(c context.Context, r *http.Request, id string) (interface{}, error) {
+	switch r.Method {
+	case "POST":
+		return api.BaseSingleResponseHandler(controllers.RetryOutboxEvent(c, r, id))
+	}
+	return nil, errors.New("method not implemented")
+}
+
+func handleOutboxEvents(c context.Context, r *http.Request) (interface{}, error) {
+	switch r.Method {
+	case "GET":
+		val, included, count, total, err := controllers.GetOutboxEvents(c, r)
+		return api.BaseResponseHandler(val, included, count, total, err, r)
+	}
+	return nil, errors.New("method not implemented")
+}
+
+// Handler for when an admin wants the events in the outbox.
+func OutboxEventsHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	c := appengine.NewContext(r)
+	val, err := handleOutboxEvents(c, r)
+
+	if err == nil {
+		err = ffjson.NewEncoder(w).Encode(val)
+	}
+
+	if err != nil {
+		nError.ReturnError(w, http.StatusInternalServerError, errOutboxHandling, err.Error())
+	}
+	return
+}
+
+// Handler for when an admin retries an event at /outbox/<id>.
+func OutboxEventHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	c := appengine.NewContext(r)
+	id := ps.ByName("id")
+	val, err := handleOutboxEvent(c, r, id)
+
+	if err == nil {
+		err = ffjson.NewEncoder(w).Encode(val)
+	}
+
+	if err != nil {
+		nError.ReturnError(w, http.StatusInternalServerError, errOutboxHandling, err.Error())
+	}
+	return
+}
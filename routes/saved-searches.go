@@ -0,0 +1,78 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pquerna/ffjson/ffjson"
+
+	"github.com/news-ai/tabulae/controllers"
+
+	"github.com/news-ai/web/api"
+	nError "github.com/news-ai/web/errors"
+)
+
+var (
+	errSavedSearchHandling = "Saved search handling error"
+)
+
+func handleSavedSearch(c context.Context, r *http.Request, id string) (interface{}, error) {
+	switch r.Method {
+	case "GET":
+		return api.BaseSingleResponseHandler(controllers.GetSavedSearch(c, r, id))
+	case "PATCH":
+		return api.BaseSingleResponseHandler(controllers.UpdateSavedSearch(c, r, id))
+	case "DELETE":
+		return api.BaseSingleResponseHandler(controllers.DeleteSavedSearch(c, r, id))
+	}
+	return nil, errors.New("method not implemented")
+}
+
+func handleSavedSearches(c context.Context, r *http.Request) (interface{}, error) {
+	switch r.Method {
+	case "GET":
+		val, included, count, total, err := controllers.GetSavedSearches(c, r)
+		return api.BaseResponseHandler(val, included, count, total, err, r)
+	case "POST":
+		return api.BaseSingleResponseHandler(controllers.CreateSavedSearch(c, r))
+	}
+	return nil, errors.New("method not implemented")
+}
+
+// Handler for when the user wants all their saved searches.
+func SavedSearchesHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	c := appengine.NewContext(r)
+	val, err := handleSavedSearches(c, r)
+
+	if err == nil {
+		err = ffjson.NewEncoder(w).Encode(val)
+	}
+
+	if err != nil {
+		nError.ReturnError(w, http.StatusInternalServerError, errSavedSearchHandling, err.Error())
+	}
+	return
+}
+
+// Handler for when there is a key present after /savedsearches/<id> route.
+func SavedSearchHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	c := appengine.NewContext(r)
+	id := ps.ByName("id")
+	val, err := handleSavedSearch(c, r, id)
+
+	if err == nil {
+		err = ffjson.NewEncoder(w).Encode(val)
+	}
+
+	if err != nil {
+		nError.ReturnError(w, http.StatusInternalServerError, errSavedSearchHandling, err.Error())
+	}
+	return
+}
@@ -0,0 +1,81 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pquerna/ffjson/ffjson"
+
+	"github.com/news-ai/tabulae/controllers"
+
+	"github.com/news-ai/web/api"
+	nError "github.com/news-ai/web/errors"
+)
+
+var (
+	errSearchHandling = "Search handling error"
+)
+
+func handleSearch(c context.Context, r *http.Request) (interface{}, error) {
+	switch r.Method {
+	case "GET":
+		val, included, count, total, err := controllers.GetSearch(c, r)
+		return api.BaseResponseHandler(val, included, count, total, err, r)
+	}
+	return nil, errors.New("method not implemented")
+}
+
+func handleSearchAction(c context.Context, r *http.Request, action string) (interface{}, error) {
+	switch r.Method {
+	case "GET":
+		if action == "check" {
+			return api.BaseSingleResponseHandler(controllers.CheckSearchIndex(c, r))
+		}
+	case "POST":
+		if action == "reindex" {
+			return api.BaseSingleResponseHandler(controllers.ReindexSearch(c, r))
+		} else if action == "check" {
+			return api.BaseSingleResponseHandler(controllers.CheckSearchIndex(c, r))
+		}
+	}
+	return nil, errors.New("method not implemented")
+}
+
+// Handler for when the user searches everything, like from the command bar.
+func SearchHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	c := appengine.NewContext(r)
+	val, err := handleSearch(c, r)
+
+	if err == nil {
+		err = ffjson.NewEncoder(w).Encode(val)
+	}
+
+	if err != nil {
+		nError.ReturnError(w, http.StatusInternalServerError, errSearchHandling, err.Error())
+	}
+	return
+}
+
+// Handler for when there is an action after /search/<action> route. Admins
+// reindex and check search from here.
+func SearchActionHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	c := appengine.NewContext(r)
+	action := ps.ByName("action")
+	val, err := handleSearchAction(c, r, action)
+
+	if err == nil {
+		err = ffjson.NewEncoder(w).Encode(val)
+	}
+
+	if err != nil {
+		nError.ReturnError(w, http.StatusInternalServerError, errSearchHandling, err.Error())
+	}
+	return
+}
@@ -0,0 +1,81 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pquerna/ffjson/ffjson"
+
+	"github.com/news-ai/tabulae/controllers"
+
+	"github.com/news-ai/web/api"
+	nError "github.com/news-ai/web/errors"
+)
+
+var (
+	errSnapshotHandling = "Snapshot handling error"
+)
+
+func handleSnapshotActions(c context.Context, r *http.Request, id string, action string) (interface{}, error) {
+	switch r.Method {
+	case "GET":
+		switch action {
+		case "diff":
+			return api.BaseSingleResponseHandler(controllers.GetListSnapshotDiff(c, r, id))
+		}
+	case "POST":
+		switch action {
+		case "restore":
+			return api.BaseSingleResponseHandler(controllers.RestoreListSnapshot(c, r, id))
+		}
+	}
+	return nil, errors.New("method not implemented")
+}
+
+func handleSnapshot(c context.Context, r *http.Request, id string) (interface{}, error) {
+	switch r.Method {
+	case "GET":
+		return api.BaseSingleResponseHandler(controllers.GetListSnapshot(c, r, id))
+	}
+	return nil, errors.New("method not implemented")
+}
+
+// Handler for when there is a key present after /snapshots/<id> route.
+func SnapshotHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	c := appengine.NewContext(r)
+	id := ps.ByName("id")
+	val, err := handleSnapshot(c, r, id)
+
+	if err == nil {
+		err = ffjson.NewEncoder(w).Encode(val)
+	}
+
+	if err != nil {
+		nError.ReturnError(w, http.StatusInternalServerError, errSnapshotHandling, err.Error())
+	}
+	return
+}
+
+// Handler for when the user wants to perform an action on a snapshot
+func SnapshotActionHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	c := appengine.NewContext(r)
+	id := ps.ByName("id")
+	action := ps.ByName("action")
+	val, err := handleSnapshotActions(c, r, id, action)
+
+	if err == nil {
+		err = ffjson.NewEncoder(w).Encode(val)
+	}
+
+	if err != nil {
+		nError.ReturnError(w, http.StatusInternalServerError, errSnapshotHandling, err.Error())
+	}
+	return
+}
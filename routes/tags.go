@@ -0,0 +1,78 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pquerna/ffjson/ffjson"
+
+	"github.com/news-ai/tabulae/controllers"
+
+	"github.com/news-ai/web/api"
+	nError "github.com/news-ai/web/errors"
+)
+
+var (
+	errTagHandling = "Tag handling error"
+)
+
+func handleTag(c context.Context, r *http.Request, id string) (interface{}, error) {
+	switch r.Method {
+	case "POST":
+		if id == "rename" {
+			return api.BaseSingleResponseHandler(controllers.RenameTag(c, r))
+		} else if id == "merge" {
+			return api.BaseSingleResponseHandler(controllers.MergeTags(c, r))
+		}
+	case "DELETE":
+		return api.BaseSingleResponseHandler(controllers.DeleteTag(c, r, id))
+	}
+	return nil, errors.New("method not implemented")
+}
+
+func handleTags(c context.Context, r *http.Request) (interface{}, error) {
+	switch r.Method {
+	case "GET":
+		val, included, count, total, err := controllers.GetTags(c, r)
+		return api.BaseResponseHandler(val, included, count, total, err, r)
+	}
+	return nil, errors.New("method not implemented")
+}
+
+// Handler for when the user wants all the tags.
+func TagsHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	c := appengine.NewContext(r)
+	val, err := handleTags(c, r)
+
+	if err == nil {
+		err = ffjson.NewEncoder(w).Encode(val)
+	}
+
+	if err != nil {
+		nError.ReturnError(w, http.StatusInternalServerError, errTagHandling, err.Error())
+	}
+	return
+}
+
+// Handler for when there is a key present after /tags/<id> route.
+func TagHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	c := appengine.NewContext(r)
+	id := ps.ByName("id")
+	val, err := handleTag(c, r, id)
+
+	if err == nil {
+		err = ffjson.NewEncoder(w).Encode(val)
+	}
+
+	if err != nil {
+		nError.ReturnError(w, http.StatusInternalServerError, errTagHandling, err.Error())
+	}
+	return
+}
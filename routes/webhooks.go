@@ -0,0 +1,113 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pquerna/ffjson/ffjson"
+
+	"github.com/news-ai/tabulae/controllers"
+
+	"github.com/news-ai/web/api"
+	nError "github.com/news-ai/web/errors"
+)
+
+var (
+	errWebhookHandling = "Webhook handling error"
+)
+
+func handleWebhookActions(c context.Context, r *http.Request, id string, action string) (interface{}, error) {
+	switch r.Method {
+	case "GET":
+		switch action {
+		case "deliveries":
+			val, included, count, total, err := controllers.GetWebhookDeliveries(c, r, id)
+			return api.BaseResponseHandler(val, included, count, total, err, r)
+		}
+	case "POST":
+		switch action {
+		case "test":
+			return api.BaseSingleResponseHandler(controllers.TestWebhook(c, r, id))
+		}
+	}
+	return nil, errors.New("method not implemented")
+}
+
+func handleWebhook(c context.Context, r *http.Request, id string) (interface{}, error) {
+	switch r.Method {
+	case "GET":
+		return api.BaseSingleResponseHandler(controllers.GetWebhook(c, r, id))
+	case "PATCH":
+		return api.BaseSingleResponseHandler(controllers.UpdateWebhook(c, r, id))
+	case "DELETE":
+		return api.BaseSingleResponseHandler(controllers.DeleteWebhook(c, r, id))
+	}
+	return nil, errors.New("method not implemented")
+}
+
+func handleWebhooks(c context.Context, r *http.Request) (interface{}, error) {
+	switch r.Method {
+	case "GET":
+		val, included, count, total, err := controllers.GetWebhooks(c, r)
+		return api.BaseResponseHandler(val, included, count, total, err, r)
+	case "POST":
+		return api.BaseSingleResponseHandler(controllers.CreateWebhook(c, r))
+	}
+	return nil, errors.New("method not implemented")
+}
+
+// Handler for when the user wants all their webhooks.
+func WebhooksHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	c := appengine.NewContext(r)
+	val, err := handleWebhooks(c, r)
+
+	if err == nil {
+		err = ffjson.NewEncoder(w).Encode(val)
+	}
+
+	if err != nil {
+		nError.ReturnError(w, http.StatusInternalServerError, errWebhookHandling, err.Error())
+	}
+	return
+}
+
+// Handler for when there is a key present after /webhooks/<id> route.
+func WebhookHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	c := appengine.NewContext(r)
+	id := ps.ByName("id")
+	val, err := handleWebhook(c, r, id)
+
+	if err == nil {
+		err = ffjson.NewEncoder(w).Encode(val)
+	}
+
+	if err != nil {
+		nError.ReturnError(w, http.StatusInternalServerError, errWebhookHandling, err.Error())
+	}
+	return
+}
+
+// Handler for when the user wants the deliveries of a webhook or to test it
+func WebhookActionHandler(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	c := appengine.NewContext(r)
+	id := ps.ByName("id")
+	action := ps.ByName("action")
+	val, err := handleWebhookActions(c, r, id, action)
+
+	if err == nil {
+		err = ffjson.NewEncoder(w).Encode(val)
+	}
+
+	if err != nil {
+		nError.ReturnError(w, http.StatusInternalServerError, errWebhookHandling, err.Error())
+	}
+	return
+}
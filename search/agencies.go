@@ -2,7 +2,6 @@ package search
 
 import (
 	"net/http"
-	"net/url"
 
 	"golang.org/x/net/context"
 
@@ -11,41 +10,24 @@ import (
 	"google.golang.org/appengine/log"
 
 	"github.com/news-ai/api/models"
-
-	elastic "github.com/news-ai/elastic-appengine"
-)
-
-var (
-	elasticAgency *elastic.Elastic
 )
 
 func SearchAgency(c context.Context, r *http.Request, search string) ([]models.Agency, int, error) {
-	search = url.QueryEscape(search)
-	search = "q=data.Name:" + search
-
-	offset := gcontext.Get(r, "offset").(int)
-	limit := gcontext.Get(r, "limit").(int)
+	query := AgencyQuery{
+		From: gcontext.Get(r, "offset").(int),
+		Size: gcontext.Get(r, "limit").(int),
+		Name: search,
+	}
 
-	hits, err := elasticAgency.Query(c, offset, limit, search)
+	agencies, total, err := backend.Agencies(c, query)
 	if err != nil {
 		log.Errorf(c, "%v", err)
 		return []models.Agency{}, 0, err
 	}
 
-	agencyHits := hits.Hits
-	agencies := []models.Agency{}
-	for i := 0; i < len(agencyHits); i++ {
-		rawAgency := agencyHits[i].Source.Data
-		rawMap := rawAgency.(map[string]interface{})
-		agency := models.Agency{}
-		err := agency.FillStruct(rawMap)
-		if err != nil {
-			log.Errorf(c, "%v", err)
-		}
-
-		agency.Type = "agencies"
-		agencies = append(agencies, agency)
+	for i := 0; i < len(agencies); i++ {
+		agencies[i].Type = "agencies"
 	}
 
-	return agencies, hits.Total, nil
+	return agencies, total, nil
 }
@@ -0,0 +1,194 @@
+package search
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+
+	apiModels "github.com/news-ai/api/models"
+
+	"github.com/news-ai/tabulae/models"
+)
+
+// A search backend answers the searches of the controllers. Elasticsearch is
+// used in production and the MemoryBackend when there is no Elasticsearch.
+//
+// Every search returns the page of results asked for, and how many results
+// there are in total.
+type Backend interface {
+	Contacts(c context.Context, query ContactQuery) ([]models.Contact, int, error)
+	ContactTerms(c context.Context, query ContactQuery, aggregations []TermsAggregation) (map[string][]TermsBucket, int, error)
+	Lists(c context.Context, query ListQuery) ([]models.MediaList, int, error)
+	Emails(c context.Context, query EmailQuery) ([]models.Email, int, error)
+	EmailLogs(c context.Context, query EmailLogQuery) ([]interface{}, int, error)
+	EmailTimeseries(c context.Context, query EmailTimeseriesQuery) ([]interface{}, int, error)
+	EmailCampaigns(c context.Context, query EmailCampaignQuery) ([]EmailCampaignRequest, int, error)
+	Publications(c context.Context, query PublicationQuery) ([]models.Publication, int, error)
+	Agencies(c context.Context, query AgencyQuery) ([]apiModels.Agency, int, error)
+
+	// When each of the entities of a kind was last updated in the index.
+	// Entities that aren't indexed are left out.
+	Indexed(c context.Context, kind string, ids []int64) (map[int64]time.Time, error)
+
+	// Ids of the documents of a kind that come after an id, in order, to go
+	// through everything that is indexed
+	IndexedIds(c context.Context, kind string, after int64, size int) ([]int64, error)
+}
+
+// Filters of a contact search. Filters that are empty aren't used. Master
+// contacts are never returned, only the contacts on lists are.
+type ContactQuery struct {
+	From int
+	Size int
+
+	CreatedBy int64
+	ListId    int64
+
+	// Contacts on any of these lists
+	ListIds []int64
+
+	// Contacts with any of the words
+	Match string
+
+	// Contacts with all of the words, the last one only being the start of a
+	// word. Used when the user is still typing.
+	Prefix bool
+
+	Tag         string
+	Publication string // Id of one of the employers
+
+	ExcludeDeleted bool
+}
+
+// Counts the values a field has over every contact matching a query, by
+// the name of the aggregation. The field is employers, tags, location,
+// emailbounced, email, or customfields. followed by the name of a custom
+// field.
+type TermsAggregation struct {
+	Name  string
+	Field string
+
+	// How many of the most common values are kept
+	Size int
+
+	// Only these values are counted when it is set
+	Include []string
+}
+
+// A value of a field and how many contacts have it. Most common values come
+// first.
+type TermsBucket struct {
+	Value string
+	Count int
+}
+
+// Filters of a media list search. Archived lists are never returned.
+type ListQuery struct {
+	From int
+	Size int
+
+	CreatedBy int64
+
+	// Only these lists
+	Ids []int64
+
+	// Lists with this word
+	Term string
+
+	// Lists with any of the words, or all of them as a prefix like in a
+	// ContactQuery
+	Match  string
+	Prefix bool
+
+	Client string
+	Tag    string
+
+	// Newest lists first, otherwise the best matches are first
+	SortByCreated bool
+}
+
+// Filters of an email search. Only emails that were sent and not cancelled
+// are returned, and the newest ones come first.
+type EmailQuery struct {
+	From int
+	Size int
+
+	CreatedBy int64
+
+	// Emails sent from any of these lists are returned too, whoever sent them
+	ListIds []int64
+
+	Subject     string
+	BaseSubject string
+
+	// Emails created between these times, formatted as 2006-01-02T15:04:05
+	CreatedFrom string
+	CreatedTo   string
+
+	ExcludeArchived bool
+	OnlyDelivered   bool
+
+	// Parsed from a search with ParseEmailQuery
+	Condition *EmailCondition
+}
+
+type EmailLogQuery struct {
+	From int
+	Size int
+
+	EmailId int64
+}
+
+// Newest first
+type EmailTimeseriesQuery struct {
+	From int
+	Size int
+
+	UserId int64
+}
+
+// Newest first
+type EmailCampaignQuery struct {
+	From int
+	Size int
+
+	UserId int64
+}
+
+// Publications with any of the words in their name, or the start of the
+// name when it is a prefix
+type PublicationQuery struct {
+	From int
+	Size int
+
+	Name   string
+	Prefix bool
+}
+
+// Agencies with any of the words in their name, or the start of the name
+// when it is a prefix
+type AgencyQuery struct {
+	From int
+	Size int
+
+	Name   string
+	Prefix bool
+}
+
+var (
+	// Datastore kinds that are indexed
+	IndexContacts     = "Contact"
+	IndexLists        = "MediaList"
+	IndexEmails       = "Email"
+	IndexPublications = "Publication"
+
+	backend Backend
+
+	// Custom fields are aggregated by this prefix and their name
+	TermsCustomFieldPrefix = "customfields."
+)
+
+// Replaces the backend searches go to. Tests set a MemoryBackend here.
+func SetBackend(searchBackend Backend) {
+	backend = searchBackend
+}
@@ -12,14 +12,7 @@ import (
 	"google.golang.org/appengine/memcache"
 
 	"github.com/news-ai/api/models"
-	apiSearch "github.com/news-ai/api/search"
 	tabulaeModels "github.com/news-ai/tabulae/models"
-
-	elastic "github.com/news-ai/elastic-appengine"
-)
-
-var (
-	elasticEmailCampaign *elastic.Elastic
 )
 
 type EmailCampaignResponse struct {
@@ -60,29 +53,14 @@ func (ec *EmailCampaignRequest) FillStruct(m map[string]interface{}) error {
 	return nil
 }
 
-func searchEmailCampaigns(c context.Context, r *http.Request, elasticQuery interface{}, user models.User) (interface{}, int, int, error) {
-	hits, err := elasticEmailCampaign.QueryStruct(c, elasticQuery)
+func searchEmailCampaigns(c context.Context, r *http.Request, query EmailCampaignQuery, user models.User) (interface{}, int, int, error) {
+	// Get all email campaigns
+	emailCampaigns, total, err := backend.EmailCampaigns(c, query)
 	if err != nil {
 		log.Errorf(c, "%v", err)
 		return nil, 0, 0, err
 	}
 
-	// Get all email campaigns
-	emailCampaigns := []EmailCampaignRequest{}
-	for i := 0; i < len(hits.Hits); i++ {
-		rawEmailCampaign := hits.Hits[i].Source.Data
-		rawMap := rawEmailCampaign.(map[string]interface{})
-		emailCampaign := EmailCampaignRequest{}
-		err := emailCampaign.FillStruct(rawMap)
-		if err != nil {
-			log.Errorf(c, "%v", err)
-			continue
-		}
-
-		emailCampaign.Id = hits.Hits[i].ID
-		emailCampaigns = append(emailCampaigns, emailCampaign)
-	}
-
 	// Get all emails for each of the campaigns
 	emailCampaignsResponse := []EmailCampaignResponse{}
 	for i := 0; i < len(emailCampaigns); i++ {
@@ -220,26 +198,15 @@ func searchEmailCampaigns(c context.Context, r *http.Request, elasticQuery inter
 		emailCampaignsResponse = append(emailCampaignsResponse, emailCampaign)
 	}
 
-	return emailCampaignsResponse, len(emailCampaignsResponse), hits.Total, nil
+	return emailCampaignsResponse, len(emailCampaignsResponse), total, nil
 }
 
 func SearchEmailCampaignsByDate(c context.Context, r *http.Request, user models.User) (interface{}, int, int, error) {
-	offset := gcontext.Get(r, "offset").(int)
-	limit := gcontext.Get(r, "limit").(int)
-
-	elasticQuery := elastic.ElasticQueryWithSort{}
-	elasticQuery.Size = limit
-	elasticQuery.From = offset
-
-	elasticUserIdQuery := apiSearch.ElasticUserIdQuery{}
-	elasticUserIdQuery.Term.UserId = user.Id
-
-	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticUserIdQuery)
-
-	elasticDateQuery := apiSearch.ElasticSortDataQuery{}
-	elasticDateQuery.Date.Order = "desc"
-	elasticDateQuery.Date.Mode = "avg"
-	elasticQuery.Sort = append(elasticQuery.Sort, elasticDateQuery)
+	query := EmailCampaignQuery{
+		From:   gcontext.Get(r, "offset").(int),
+		Size:   gcontext.Get(r, "limit").(int),
+		UserId: user.Id,
+	}
 
-	return searchEmailCampaigns(c, r, elasticQuery, user)
+	return searchEmailCampaigns(c, r, query, user)
 }
@@ -2,8 +2,6 @@ package search
 
 import (
 	"net/http"
-	// "net/url"
-	// "strconv"
 
 	"golang.org/x/net/context"
 
@@ -12,39 +10,25 @@ import (
 	"google.golang.org/appengine/log"
 
 	apiModels "github.com/news-ai/api/models"
-	apiSearch "github.com/news-ai/api/search"
 
-	elastic "github.com/news-ai/elastic-appengine"
 	"github.com/news-ai/tabulae/models"
 )
 
-var (
-	elasticContact *elastic.Elastic
-)
+func searchContact(c context.Context, r *http.Request, query ContactQuery) ([]models.Contact, int, error) {
+	query.From = gcontext.Get(r, "offset").(int)
+	query.Size = gcontext.Get(r, "limit").(int)
 
-func searchContact(c context.Context, elasticQuery elastic.ElasticQuery) ([]models.Contact, int, error) {
-	hits, err := elasticContact.QueryStruct(c, elasticQuery)
+	contacts, total, err := backend.Contacts(c, query)
 	if err != nil {
 		log.Errorf(c, "%v", err)
 		return []models.Contact{}, 0, err
 	}
 
-	contactHits := hits.Hits
-	contacts := []models.Contact{}
-	for i := 0; i < len(contactHits); i++ {
-		rawContact := contactHits[i].Source.Data
-		rawMap := rawContact.(map[string]interface{})
-		contact := models.Contact{}
-		err := contact.FillStruct(rawMap)
-		if err != nil {
-			log.Errorf(c, "%v", err)
-		}
-
-		contact.Type = "contacts"
-		contacts = append(contacts, contact)
+	for i := 0; i < len(contacts); i++ {
+		contacts[i].Type = "contacts"
 	}
 
-	return contacts, hits.Total, nil
+	return contacts, total, nil
 }
 
 func SearchContacts(c context.Context, r *http.Request, search string, userId int64) ([]models.Contact, int, error) {
@@ -52,23 +36,25 @@ func SearchContacts(c context.Context, r *http.Request, search string, userId in
 		return []models.Contact{}, 0, nil
 	}
 
-	offset := gcontext.Get(r, "offset").(int)
-	limit := gcontext.Get(r, "limit").(int)
-
-	elasticQuery := elastic.ElasticQuery{}
-	elasticQuery.Size = limit
-	elasticQuery.From = offset
-
-	elasticCreatedByQuery := apiSearch.ElasticCreatedByQuery{}
-	elasticCreatedByQuery.Term.CreatedBy = userId
+	query := ContactQuery{
+		CreatedBy: userId,
+		Match:     search,
+	}
+	return searchContact(c, r, query)
+}
 
-	elasticMatchQuery := elastic.ElasticMatchQuery{}
-	elasticMatchQuery.Match.All = search
+func contactsByListQuery(search string, user apiModels.User, userId int64, listId int64) ContactQuery {
+	query := ContactQuery{
+		ListId:         listId,
+		Match:          search,
+		ExcludeDeleted: true,
+	}
 
-	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticCreatedByQuery)
-	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticMatchQuery)
+	if !user.IsAdmin {
+		query.CreatedBy = userId
+	}
 
-	return searchContact(c, elasticQuery)
+	return query
 }
 
 func SearchContactsByList(c context.Context, r *http.Request, search string, user apiModels.User, userId int64, listId int64) ([]models.Contact, int, error) {
@@ -76,56 +62,80 @@ func SearchContactsByList(c context.Context, r *http.Request, search string, use
 		return []models.Contact{}, 0, nil
 	}
 
-	offset := gcontext.Get(r, "offset").(int)
-	limit := gcontext.Get(r, "limit").(int)
+	return searchContact(c, r, contactsByListQuery(search, user, userId, listId))
+}
 
-	elasticQuery := elastic.ElasticQuery{}
-	elasticQuery.Size = limit
-	elasticQuery.From = offset
+// Pages through every match of a query, up to a limit
+func searchAllContacts(c context.Context, query ContactQuery, limit int) ([]models.Contact, int, error) {
+	query.Size = 500
 
-	if !user.IsAdmin {
-		elasticCreatedByQuery := apiSearch.ElasticCreatedByQuery{}
-		elasticCreatedByQuery.Term.CreatedBy = userId
-		elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticCreatedByQuery)
+	allContacts := []models.Contact{}
+	total := 0
+	for query.From < limit {
+		contacts, searchTotal, err := backend.Contacts(c, query)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return []models.Contact{}, 0, err
+		}
+
+		for i := 0; i < len(contacts); i++ {
+			contacts[i].Type = "contacts"
+		}
+		allContacts = append(allContacts, contacts...)
+		total = searchTotal
+
+		query.From += query.Size
+		if len(contacts) < query.Size || query.From >= total {
+			break
+		}
 	}
 
-	elasticListIdQuery := apiSearch.ElasticListIdQuery{}
-	elasticListIdQuery.Term.ListId = listId
+	return allContacts, total, nil
+}
 
-	elasticIsDeletedQuery := apiSearch.ElasticIsDeletedQuery{}
-	elasticIsDeletedQuery.Term.IsDeleted = false
+// Counts the values of fields over every contact of a list that matches the
+// search, and how many contacts match
+func SearchContactTermsByList(c context.Context, search string, user apiModels.User, userId int64, listId int64, aggregations []TermsAggregation) (map[string][]TermsBucket, int, error) {
+	if listId == 0 || search == "" {
+		return map[string][]TermsBucket{}, 0, nil
+	}
 
-	elasticMatchQuery := elastic.ElasticMatchQuery{}
-	elasticMatchQuery.Match.All = search
+	terms, total, err := backend.ContactTerms(c, contactsByListQuery(search, user, userId, listId), aggregations)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return map[string][]TermsBucket{}, 0, err
+	}
+	return terms, total, nil
+}
 
-	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticListIdQuery)
-	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticIsDeletedQuery)
-	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticMatchQuery)
+// Contacts of the user that match a saved search, in one of their lists when
+// listId is set. Only the first limit matches are returned.
+func SearchSavedContacts(c context.Context, search string, userId int64, listId int64, limit int) ([]models.Contact, int, error) {
+	if userId == 0 || search == "" {
+		return []models.Contact{}, 0, nil
+	}
 
-	return searchContact(c, elasticQuery)
+	query := ContactQuery{
+		CreatedBy:      userId,
+		ListId:         listId,
+		Match:          search,
+		ExcludeDeleted: true,
+	}
+	return searchAllContacts(c, query, limit)
 }
 
 func SearchContactsByTag(c context.Context, r *http.Request, tag string, userId int64) ([]models.Contact, int, error) {
+	// Tags are stored normalized
+	tag = models.NormalizeTag(tag)
 	if tag == "" {
 		return []models.Contact{}, 0, nil
 	}
 
-	offset := gcontext.Get(r, "offset").(int)
-	limit := gcontext.Get(r, "limit").(int)
-
-	elasticQuery := elastic.ElasticQuery{}
-	elasticQuery.Size = limit
-	elasticQuery.From = offset
-
-	elasticCreatedByQuery := apiSearch.ElasticCreatedByQuery{}
-	elasticCreatedByQuery.Term.CreatedBy = userId
-	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticCreatedByQuery)
-
-	elasticTagQuery := apiSearch.ElasticTagQuery{}
-	elasticTagQuery.Term.Tag = tag
-	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticTagQuery)
-
-	return searchContact(c, elasticQuery)
+	query := ContactQuery{
+		CreatedBy: userId,
+		Tag:       tag,
+	}
+	return searchContact(c, r, query)
 }
 
 func SearchContactsByPublicationId(c context.Context, r *http.Request, publicationId string, userId int64) ([]models.Contact, int, error) {
@@ -133,22 +143,11 @@ func SearchContactsByPublicationId(c context.Context, r *http.Request, publicati
 		return []models.Contact{}, 0, nil
 	}
 
-	offset := gcontext.Get(r, "offset").(int)
-	limit := gcontext.Get(r, "limit").(int)
-
-	elasticQuery := elastic.ElasticQuery{}
-	elasticQuery.Size = limit
-	elasticQuery.From = offset
-
-	elasticCreatedByQuery := apiSearch.ElasticCreatedByQuery{}
-	elasticCreatedByQuery.Term.CreatedBy = userId
-	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticCreatedByQuery)
-
-	elasticEmployersQuery := apiSearch.ElasticEmployersQuery{}
-	elasticEmployersQuery.Term.Employers = publicationId
-	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticEmployersQuery)
-
-	return searchContact(c, elasticQuery)
+	query := ContactQuery{
+		CreatedBy:   userId,
+		Publication: publicationId,
+	}
+	return searchContact(c, r, query)
 }
 
 func SearchContactsByFieldSelector(c context.Context, r *http.Request, fieldSelector string, query string, userId int64) ([]models.Contact, int, error) {
@@ -0,0 +1,905 @@
+package search
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/log"
+	"google.golang.org/appengine/urlfetch"
+
+	apiModels "github.com/news-ai/api/models"
+	apiSearch "github.com/news-ai/api/search"
+
+	elastic "github.com/news-ai/elastic-appengine"
+	"github.com/news-ai/tabulae/models"
+)
+
+// Searches the Elasticsearch indexes
+type ElasticBackend struct {
+	agency          *elastic.Elastic
+	publication     *elastic.Elastic
+	contact         *elastic.Elastic
+	list            *elastic.Elastic
+	emailLog        *elastic.Elastic
+	emailTimeseries *elastic.Elastic
+	emails          *elastic.Elastic
+	emailCampaign   *elastic.Elastic
+}
+
+// Elasticsearch queries for the conditions of email searches
+type elasticBool struct {
+	Bool elasticBoolClauses `json:"bool"`
+}
+
+type elasticBoolClauses struct {
+	Must               []interface{} `json:"must,omitempty"`
+	Should             []interface{} `json:"should,omitempty"`
+	MustNot            []interface{} `json:"must_not,omitempty"`
+	MinimumShouldMatch int           `json:"minimum_should_match,omitempty"`
+}
+
+type elasticMatchPhrasePrefix struct {
+	MatchPhrasePrefix map[string]string `json:"match_phrase_prefix"`
+}
+
+type elasticIds struct {
+	Ids struct {
+		Values []string `json:"values"`
+	} `json:"ids"`
+}
+
+type elasticTerm struct {
+	Term map[string]interface{} `json:"term"`
+}
+
+type elasticTerms struct {
+	Terms map[string]interface{} `json:"terms"`
+}
+
+type elasticRange struct {
+	Range map[string]map[string]interface{} `json:"range"`
+}
+
+// A search that only counts the values of fields
+type elasticAggregationQuery struct {
+	Size         int                    `json:"size"`
+	Query        interface{}            `json:"query"`
+	Aggregations map[string]interface{} `json:"aggs"`
+}
+
+type elasticAggregationResponse struct {
+	Hits struct {
+		Total int `json:"total"`
+	} `json:"hits"`
+	Aggregations map[string]elasticAggregationResult `json:"aggregations"`
+}
+
+// Terms aggregations have buckets. Custom fields are nested, so their
+// buckets are two aggregations down.
+type elasticAggregationResult struct {
+	Buckets []elasticTermsBucket `json:"buckets"`
+	Field   struct {
+		Values struct {
+			Buckets []elasticTermsBucket `json:"buckets"`
+		} `json:"values"`
+	} `json:"field"`
+}
+
+type elasticTermsBucket struct {
+	Key         json.Number `json:"key"`
+	KeyAsString string      `json:"key_as_string"`
+	DocCount    int         `json:"doc_count"`
+}
+
+// A page of the ids of an index, in order. Documents keep the id of their
+// entity in data.Id, which unlike _id can be sorted and compared.
+type elasticIdsQuery struct {
+	Size   int           `json:"size"`
+	Source bool          `json:"_source"`
+	Query  elasticRange  `json:"query"`
+	Sort   []elasticSort `json:"sort"`
+}
+
+type elasticSort map[string]map[string]string
+
+type elasticIdsResponse struct {
+	Hits struct {
+		Hits []struct {
+			ID string `json:"_id"`
+		} `json:"hits"`
+	} `json:"hits"`
+}
+
+// Characters escapeLucene escapes
+var luceneSpecialCharacters = "+-=&|><!(){}[]^\"~*?:\\/ "
+
+// Fields of the contact documents that terms aggregations count
+var elasticContactTermsFields = map[string]string{
+	"employers":    "data.Employers",
+	"tags":         "data.Tags",
+	"location":     "data.Location",
+	"emailbounced": "data.EmailBounced",
+	"email":        "data.Email",
+}
+
+// Fields of the email documents that conditions compare
+var elasticEmailFields = map[string]string{
+	"to":          "data.To",
+	"from":        "data.FromEmail",
+	"subject":     "data.Subject",
+	"basesubject": "data.BaseSubject",
+	"list":        "data.ListId",
+	"opened":      "data.Opened",
+	"clicked":     "data.Clicked",
+	"bounced":     "data.Bounced",
+	"archived":    "data.Archived",
+	"created":     "data.Created",
+	"sendat":      "data.SendAt",
+}
+
+/*
+* Private methods
+ */
+
+// Values are compared as the type they are indexed as
+func elasticEmailValue(field string, value string) interface{} {
+	switch field {
+	case "list", "opened", "clicked":
+		number, _ := strconv.ParseInt(value, 10, 64)
+		return number
+	case "bounced", "archived":
+		return value == "true"
+	}
+	return value
+}
+
+func elasticEmailCondition(condition EmailCondition) interface{} {
+	switch condition.Operator {
+	case EmailConditionAnd, EmailConditionOr, EmailConditionNot:
+		clauses := []interface{}{}
+		for i := 0; i < len(condition.Conditions); i++ {
+			clauses = append(clauses, elasticEmailCondition(condition.Conditions[i]))
+		}
+
+		query := elasticBool{}
+		if condition.Operator == EmailConditionAnd {
+			query.Bool.Must = clauses
+		} else if condition.Operator == EmailConditionOr {
+			query.Bool.Should = clauses
+			query.Bool.MinimumShouldMatch = 1
+		} else {
+			query.Bool.MustNot = clauses
+		}
+		return query
+	}
+
+	if condition.Comparison == EmailComparisonMatch {
+		elasticMatchQuery := elastic.ElasticMatchQuery{}
+		elasticMatchQuery.Match.All = condition.Value
+		return elasticMatchQuery
+	} else if condition.Comparison == EmailComparisonPrefix {
+		return elasticMatchPrefix(condition.Value)
+	}
+
+	field := elasticEmailFields[condition.Field]
+	value := elasticEmailValue(condition.Field, condition.Value)
+	if condition.Comparison == EmailComparisonEqual {
+		return elasticTerm{
+			Term: map[string]interface{}{
+				field: value,
+			},
+		}
+	}
+
+	return elasticRange{
+		Range: map[string]map[string]interface{}{
+			field: {
+				condition.Comparison: value,
+			},
+		},
+	}
+}
+
+func (eb *ElasticBackend) index(kind string) (*elastic.Elastic, error) {
+	switch kind {
+	case IndexContacts:
+		return eb.contact, nil
+	case IndexLists:
+		return eb.list, nil
+	case IndexEmails:
+		return eb.emails, nil
+	case IndexPublications:
+		return eb.publication, nil
+	}
+	return nil, errors.New("There is no index for " + kind)
+}
+
+func elasticMatchPrefix(search string) elasticMatchPhrasePrefix {
+	return elasticMatchPhrasePrefix{
+		MatchPhrasePrefix: map[string]string{
+			"_all": search,
+		},
+	}
+}
+
+func elasticListIdsQuery(listIds []int64) elasticTerms {
+	return elasticTerms{
+		Terms: map[string]interface{}{
+			"data.ListId": listIds,
+		},
+	}
+}
+
+// Escapes the characters that mean something in a Lucene query string, and
+// spaces so a name with several words stays one term
+func escapeLucene(search string) string {
+	escaped := ""
+	for _, character := range search {
+		if strings.ContainsRune(luceneSpecialCharacters, character) {
+			escaped += "\\"
+		}
+		escaped += string(character)
+	}
+	return escaped
+}
+
+// Query strings that search the name of publications and agencies
+func elasticNameQueryString(name string, prefix bool) string {
+	search := "q=data.Name:" + url.QueryEscape(escapeLucene(name))
+	if prefix {
+		search += "*"
+	}
+	return search
+}
+
+// The query of a contact search, without the page
+func elasticContactQuery(query ContactQuery) elastic.ElasticQuery {
+	elasticQuery := elastic.ElasticQuery{}
+
+	if query.CreatedBy != 0 {
+		elasticCreatedByQuery := apiSearch.ElasticCreatedByQuery{}
+		elasticCreatedByQuery.Term.CreatedBy = query.CreatedBy
+		elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticCreatedByQuery)
+	}
+
+	if query.ListId != 0 {
+		elasticListIdQuery := apiSearch.ElasticListIdQuery{}
+		elasticListIdQuery.Term.ListId = query.ListId
+		elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticListIdQuery)
+	}
+
+	if len(query.ListIds) > 0 {
+		elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticListIdsQuery(query.ListIds))
+	}
+
+	if query.ExcludeDeleted {
+		elasticIsDeletedQuery := apiSearch.ElasticIsDeletedQuery{}
+		elasticIsDeletedQuery.Term.IsDeleted = false
+		elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticIsDeletedQuery)
+	}
+
+	// Contacts indexed before they had the field count as not being masters
+	elasticNotMasterQuery := elasticBool{}
+	elasticNotMasterQuery.Bool.MustNot = []interface{}{
+		elasticTerm{
+			Term: map[string]interface{}{
+				"data.IsMasterContact": true,
+			},
+		},
+	}
+	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticNotMasterQuery)
+
+	if query.Match != "" && query.Prefix {
+		elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticMatchPrefix(query.Match))
+	} else if query.Match != "" {
+		elasticMatchQuery := elastic.ElasticMatchQuery{}
+		elasticMatchQuery.Match.All = query.Match
+		elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticMatchQuery)
+	}
+
+	if query.Tag != "" {
+		elasticTagQuery := apiSearch.ElasticTagQuery{}
+		elasticTagQuery.Term.Tag = query.Tag
+		elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticTagQuery)
+	}
+
+	if query.Publication != "" {
+		elasticEmployersQuery := apiSearch.ElasticEmployersQuery{}
+		elasticEmployersQuery.Term.Employers = query.Publication
+		elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticEmployersQuery)
+	}
+
+	return elasticQuery
+}
+
+func elasticTermsAggregation(field string, aggregation TermsAggregation) map[string]interface{} {
+	terms := map[string]interface{}{
+		"field": field,
+		"size":  aggregation.Size,
+	}
+	if len(aggregation.Include) > 0 {
+		terms["include"] = aggregation.Include
+	}
+	return map[string]interface{}{
+		"terms": terms,
+	}
+}
+
+// Custom fields are a nested list of names and values, so the values of one
+// field are counted within the custom fields that have its name
+func elasticContactAggregation(aggregation TermsAggregation) (interface{}, error) {
+	if strings.HasPrefix(aggregation.Field, TermsCustomFieldPrefix) {
+		name := strings.TrimPrefix(aggregation.Field, TermsCustomFieldPrefix)
+		return map[string]interface{}{
+			"nested": map[string]string{
+				"path": "data.CustomFields",
+			},
+			"aggs": map[string]interface{}{
+				"field": map[string]interface{}{
+					"filter": elasticTerm{
+						Term: map[string]interface{}{
+							"data.CustomFields.Name": name,
+						},
+					},
+					"aggs": map[string]interface{}{
+						"values": elasticTermsAggregation("data.CustomFields.Value", aggregation),
+					},
+				},
+			},
+		}, nil
+	}
+
+	field, ok := elasticContactTermsFields[aggregation.Field]
+	if !ok {
+		return nil, errors.New("Contacts can't be counted by " + aggregation.Field)
+	}
+	return elasticTermsAggregation(field, aggregation), nil
+}
+
+// Numbers and booleans are keyed by a number, with the value as it was
+// indexed in key_as_string
+func elasticTermsBuckets(elasticBuckets []elasticTermsBucket) []TermsBucket {
+	buckets := []TermsBucket{}
+	for i := 0; i < len(elasticBuckets); i++ {
+		value := elasticBuckets[i].KeyAsString
+		if value == "" {
+			value = elasticBuckets[i].Key.String()
+		}
+		buckets = append(buckets, TermsBucket{
+			Value: value,
+			Count: elasticBuckets[i].DocCount,
+		})
+	}
+	return buckets
+}
+
+// The elastic library only decodes hits, so searches that need more of the
+// response are posted to the index directly
+func elasticSearch(c context.Context, index *elastic.Elastic, query interface{}, response interface{}) error {
+	body, err := json.Marshal(query)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	contextWithTimeout, cancel := context.WithTimeout(c, time.Second*30)
+	defer cancel()
+	client := urlfetch.Client(contextWithTimeout)
+	searchUrl := strings.TrimRight(index.BaseURL, "/") + "/" + index.Index + "/" + index.Type + "/_search"
+
+	req, _ := http.NewRequest("POST", searchUrl, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("Elasticsearch search failed with " + resp.Status)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	err = decoder.Decode(response)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+	return nil
+}
+
+func newElastic(baseURL string, index string, elasticType string) *elastic.Elastic {
+	elasticIndex := elastic.Elastic{}
+	elasticIndex.BaseURL = baseURL
+	elasticIndex.Index = index
+	elasticIndex.Type = elasticType
+	return &elasticIndex
+}
+
+/*
+* Public methods
+ */
+
+func NewElasticBackend(baseURL string) *ElasticBackend {
+	return &ElasticBackend{
+		agency:          newElastic(baseURL, "agencies", "agency"),
+		publication:     newElastic(baseURL, "publications", "publication"),
+		contact:         newElastic(baseURL, "contacts", "contact"),
+		list:            newElastic(baseURL, "lists", "list"),
+		emailLog:        newElastic(baseURL, "emails", "log"),
+		emailTimeseries: newElastic(baseURL, "timeseries", "useremail2"),
+		emails:          newElastic(baseURL, "emails2", "email"),
+		emailCampaign:   newElastic(baseURL, "emails", "campaign1"),
+	}
+}
+
+func (eb *ElasticBackend) Contacts(c context.Context, query ContactQuery) ([]models.Contact, int, error) {
+	elasticQuery := elasticContactQuery(query)
+	elasticQuery.Size = query.Size
+	elasticQuery.From = query.From
+
+	hits, err := eb.contact.QueryStruct(c, elasticQuery)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Contact{}, 0, err
+	}
+
+	contactHits := hits.Hits
+	contacts := []models.Contact{}
+	for i := 0; i < len(contactHits); i++ {
+		rawContact := contactHits[i].Source.Data
+		rawMap := rawContact.(map[string]interface{})
+		contact := models.Contact{}
+		err := contact.FillStruct(rawMap)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
+		contacts = append(contacts, contact)
+	}
+
+	return contacts, hits.Total, nil
+}
+
+func (eb *ElasticBackend) ContactTerms(c context.Context, query ContactQuery, aggregations []TermsAggregation) (map[string][]TermsBucket, int, error) {
+	aggregationQuery := elasticAggregationQuery{
+		Query:        elasticContactQuery(query).Query,
+		Aggregations: map[string]interface{}{},
+	}
+	for i := 0; i < len(aggregations); i++ {
+		elasticAggregation, err := elasticContactAggregation(aggregations[i])
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return map[string][]TermsBucket{}, 0, err
+		}
+		aggregationQuery.Aggregations[aggregations[i].Name] = elasticAggregation
+	}
+
+	response := elasticAggregationResponse{}
+	err := elasticSearch(c, eb.contact, aggregationQuery, &response)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return map[string][]TermsBucket{}, 0, err
+	}
+
+	terms := map[string][]TermsBucket{}
+	for i := 0; i < len(aggregations); i++ {
+		result := response.Aggregations[aggregations[i].Name]
+		if strings.HasPrefix(aggregations[i].Field, TermsCustomFieldPrefix) {
+			terms[aggregations[i].Name] = elasticTermsBuckets(result.Field.Values.Buckets)
+		} else {
+			terms[aggregations[i].Name] = elasticTermsBuckets(result.Buckets)
+		}
+	}
+
+	return terms, response.Hits.Total, nil
+}
+
+func (eb *ElasticBackend) Lists(c context.Context, query ListQuery) ([]models.MediaList, int, error) {
+	must := []interface{}{}
+
+	if query.CreatedBy != 0 {
+		elasticCreatedByQuery := apiSearch.ElasticCreatedByQuery{}
+		elasticCreatedByQuery.Term.CreatedBy = query.CreatedBy
+		must = append(must, elasticCreatedByQuery)
+	}
+
+	if len(query.Ids) > 0 {
+		elasticIdsQuery := elasticIds{}
+		for i := 0; i < len(query.Ids); i++ {
+			elasticIdsQuery.Ids.Values = append(elasticIdsQuery.Ids.Values, strconv.FormatInt(query.Ids[i], 10))
+		}
+		must = append(must, elasticIdsQuery)
+	}
+
+	elasticArchivedQuery := apiSearch.ElasticArchivedQuery{}
+	elasticArchivedQuery.Term.Archived = false
+	must = append(must, elasticArchivedQuery)
+
+	if query.Client != "" {
+		elasticClientQuery := apiSearch.ElasticClientQuery{}
+		elasticClientQuery.Term.Client = query.Client
+		must = append(must, elasticClientQuery)
+	}
+
+	if query.Tag != "" {
+		elasticTagQuery := apiSearch.ElasticTagQuery{}
+		elasticTagQuery.Term.Tag = query.Tag
+		must = append(must, elasticTagQuery)
+	}
+
+	if query.Term != "" {
+		elasticAllQuery := apiSearch.ElasticAllQuery{}
+		elasticAllQuery.Term.All = query.Term
+		must = append(must, elasticAllQuery)
+	}
+
+	if query.Match != "" && query.Prefix {
+		must = append(must, elasticMatchPrefix(query.Match))
+	} else if query.Match != "" {
+		elasticMatchQuery := elastic.ElasticMatchQuery{}
+		elasticMatchQuery.Match.All = query.Match
+		must = append(must, elasticMatchQuery)
+	}
+
+	var elasticQuery interface{}
+	if query.SortByCreated {
+		sortedQuery := elastic.ElasticQueryMustWithSort{}
+		sortedQuery.Size = query.Size
+		sortedQuery.From = query.From
+		sortedQuery.Query.Bool.Must = must
+		sortedQuery.MinScore = float32(0.5)
+
+		elasticCreatedQuery := apiSearch.ElasticSortDataCreatedQuery{}
+		elasticCreatedQuery.DataCreated.Order = "desc"
+		elasticCreatedQuery.DataCreated.Mode = "avg"
+		sortedQuery.Sort = append(sortedQuery.Sort, elasticCreatedQuery)
+		elasticQuery = sortedQuery
+	} else {
+		scoredQuery := elastic.ElasticQueryMust{}
+		scoredQuery.Size = query.Size
+		scoredQuery.From = query.From
+		scoredQuery.Query.Bool.Must = must
+		scoredQuery.MinScore = float32(0.5)
+		elasticQuery = scoredQuery
+	}
+
+	hits, err := eb.list.QueryStruct(c, elasticQuery)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.MediaList{}, 0, err
+	}
+
+	listHits := hits.Hits
+	lists := []models.MediaList{}
+	for i := 0; i < len(listHits); i++ {
+		rawList := listHits[i].Source.Data
+		rawMap := rawList.(map[string]interface{})
+		list := models.MediaList{}
+		err := list.FillStruct(rawMap)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
+		lists = append(lists, list)
+	}
+
+	return lists, hits.Total, nil
+}
+
+func (eb *ElasticBackend) Emails(c context.Context, query EmailQuery) ([]models.Email, int, error) {
+	elasticQuery := elastic.ElasticQueryWithSort{}
+	elasticQuery.Size = query.Size
+	elasticQuery.From = query.From
+
+	elasticCreatedByQuery := apiSearch.ElasticCreatedByQuery{}
+	elasticCreatedByQuery.Term.CreatedBy = query.CreatedBy
+
+	elasticIsSentQuery := apiSearch.ElasticIsSentQuery{}
+	elasticIsSentQuery.Term.IsSent = true
+
+	elasticCancelQuery := apiSearch.ElasticCancelQuery{}
+	elasticCancelQuery.Term.Cancel = false
+
+	if len(query.ListIds) > 0 {
+		elasticCreatedByOrListQuery := elasticBool{}
+		elasticCreatedByOrListQuery.Bool.Should = []interface{}{elasticCreatedByQuery, elasticListIdsQuery(query.ListIds)}
+		elasticCreatedByOrListQuery.Bool.MinimumShouldMatch = 1
+		elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticCreatedByOrListQuery)
+	} else {
+		elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticCreatedByQuery)
+	}
+	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticIsSentQuery)
+	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticCancelQuery)
+
+	if query.ExcludeArchived {
+		elasticArchivedQuery := apiSearch.ElasticArchivedQuery{}
+		elasticArchivedQuery.Term.Archived = false
+		elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticArchivedQuery)
+	}
+
+	if query.OnlyDelivered {
+		elasticDelieveredQuery := apiSearch.ElasticDelieveredQuery{}
+		elasticDelieveredQuery.Term.Delievered = true
+		elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticDelieveredQuery)
+	}
+
+	if query.CreatedFrom != "" || query.CreatedTo != "" {
+		elasticCreatedFilterQuery := apiSearch.ElasticCreatedRangeQuery{}
+		elasticCreatedFilterQuery.Range.DataCreated.From = query.CreatedFrom
+		elasticCreatedFilterQuery.Range.DataCreated.To = query.CreatedTo
+		elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticCreatedFilterQuery)
+	}
+
+	if query.BaseSubject != "" {
+		elasticBaseSubjectQuery := apiSearch.ElasticBaseSubjectQuery{}
+		elasticBaseSubjectQuery.Term.BaseSubject = query.BaseSubject
+		elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticBaseSubjectQuery)
+	} else if query.Subject != "" {
+		elasticSubjectQuery := apiSearch.ElasticSubjectQuery{}
+		elasticSubjectQuery.Term.Subject = query.Subject
+		elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticSubjectQuery)
+	}
+
+	if query.Condition != nil {
+		elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticEmailCondition(*query.Condition))
+	}
+
+	elasticCreatedQuery := apiSearch.ElasticSortDataCreatedQuery{}
+	elasticCreatedQuery.DataCreated.Order = "desc"
+	elasticCreatedQuery.DataCreated.Mode = "avg"
+	elasticQuery.Sort = append(elasticQuery.Sort, elasticCreatedQuery)
+
+	hits, err := eb.emails.QueryStruct(c, elasticQuery)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Email{}, 0, err
+	}
+
+	emailHits := hits.Hits
+	emails := []models.Email{}
+	for i := 0; i < len(emailHits); i++ {
+		rawEmail := emailHits[i].Source.Data
+		rawMap := rawEmail.(map[string]interface{})
+		email := models.Email{}
+		err := email.FillStruct(rawMap)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
+		emails = append(emails, email)
+	}
+
+	return emails, hits.Total, nil
+}
+
+func (eb *ElasticBackend) EmailLogs(c context.Context, query EmailLogQuery) ([]interface{}, int, error) {
+	elasticQuery := elastic.ElasticQuery{}
+	elasticQuery.Size = query.Size
+	elasticQuery.From = query.From
+
+	elasticEmailIdQuery := apiSearch.ElasticEmailIdQuery{}
+	elasticEmailIdQuery.Term.EmailId = query.EmailId
+	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticEmailIdQuery)
+
+	hits, err := eb.emailLog.QueryStruct(c, elasticQuery)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return nil, 0, err
+	}
+
+	emailLogHits := []interface{}{}
+	for i := 0; i < len(hits.Hits); i++ {
+		emailLogHits = append(emailLogHits, hits.Hits[i].Source.Data)
+	}
+
+	return emailLogHits, hits.Total, nil
+}
+
+func (eb *ElasticBackend) EmailTimeseries(c context.Context, query EmailTimeseriesQuery) ([]interface{}, int, error) {
+	elasticQuery := elastic.ElasticQueryWithSort{}
+	elasticQuery.Size = query.Size
+	elasticQuery.From = query.From
+
+	elasticUserIdQuery := apiSearch.ElasticUserIdQuery{}
+	elasticUserIdQuery.Term.UserId = query.UserId
+	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticUserIdQuery)
+
+	elasticDateQuery := apiSearch.ElasticSortDataQuery{}
+	elasticDateQuery.Date.Order = "desc"
+	elasticDateQuery.Date.Mode = "avg"
+	elasticQuery.Sort = append(elasticQuery.Sort, elasticDateQuery)
+
+	hits, err := eb.emailTimeseries.QueryStruct(c, elasticQuery)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return nil, 0, err
+	}
+
+	emailTimeseriesHits := []interface{}{}
+	for i := 0; i < len(hits.Hits); i++ {
+		emailTimeseriesHits = append(emailTimeseriesHits, hits.Hits[i].Source.Data)
+	}
+
+	return emailTimeseriesHits, hits.Total, nil
+}
+
+func (eb *ElasticBackend) EmailCampaigns(c context.Context, query EmailCampaignQuery) ([]EmailCampaignRequest, int, error) {
+	elasticQuery := elastic.ElasticQueryWithSort{}
+	elasticQuery.Size = query.Size
+	elasticQuery.From = query.From
+
+	elasticUserIdQuery := apiSearch.ElasticUserIdQuery{}
+	elasticUserIdQuery.Term.UserId = query.UserId
+	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticUserIdQuery)
+
+	elasticDateQuery := apiSearch.ElasticSortDataQuery{}
+	elasticDateQuery.Date.Order = "desc"
+	elasticDateQuery.Date.Mode = "avg"
+	elasticQuery.Sort = append(elasticQuery.Sort, elasticDateQuery)
+
+	hits, err := eb.emailCampaign.QueryStruct(c, elasticQuery)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return nil, 0, err
+	}
+
+	emailCampaigns := []EmailCampaignRequest{}
+	for i := 0; i < len(hits.Hits); i++ {
+		rawEmailCampaign := hits.Hits[i].Source.Data
+		rawMap := rawEmailCampaign.(map[string]interface{})
+		emailCampaign := EmailCampaignRequest{}
+		err := emailCampaign.FillStruct(rawMap)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			continue
+		}
+
+		emailCampaign.Id = hits.Hits[i].ID
+		emailCampaigns = append(emailCampaigns, emailCampaign)
+	}
+
+	return emailCampaigns, hits.Total, nil
+}
+
+func (eb *ElasticBackend) Publications(c context.Context, query PublicationQuery) ([]models.Publication, int, error) {
+	search := elasticNameQueryString(query.Name, query.Prefix)
+	hits, err := eb.publication.Query(c, query.From, query.Size, search)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []models.Publication{}, 0, err
+	}
+
+	publicationHits := hits.Hits
+	publications := []models.Publication{}
+	for i := 0; i < len(publicationHits); i++ {
+		rawPublication := publicationHits[i].Source.Data
+		rawMap := rawPublication.(map[string]interface{})
+		publication := models.Publication{}
+		err := publication.FillStruct(rawMap)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
+		publications = append(publications, publication)
+	}
+
+	return publications, hits.Total, nil
+}
+
+func (eb *ElasticBackend) Agencies(c context.Context, query AgencyQuery) ([]apiModels.Agency, int, error) {
+	search := elasticNameQueryString(query.Name, query.Prefix)
+	hits, err := eb.agency.Query(c, query.From, query.Size, search)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []apiModels.Agency{}, 0, err
+	}
+
+	agencyHits := hits.Hits
+	agencies := []apiModels.Agency{}
+	for i := 0; i < len(agencyHits); i++ {
+		rawAgency := agencyHits[i].Source.Data
+		rawMap := rawAgency.(map[string]interface{})
+		agency := apiModels.Agency{}
+		err := agency.FillStruct(rawMap)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
+		agencies = append(agencies, agency)
+	}
+
+	return agencies, hits.Total, nil
+}
+
+func (eb *ElasticBackend) IndexedIds(c context.Context, kind string, after int64, size int) ([]int64, error) {
+	index, err := eb.index(kind)
+	if err != nil {
+		return []int64{}, err
+	}
+
+	idsQuery := elasticIdsQuery{
+		Size: size,
+		Query: elasticRange{
+			Range: map[string]map[string]interface{}{
+				"data.Id": {
+					"gt": after,
+				},
+			},
+		},
+		Sort: []elasticSort{
+			{
+				"data.Id": {
+					"order": "asc",
+				},
+			},
+		},
+	}
+
+	response := elasticIdsResponse{}
+	err = elasticSearch(c, index, idsQuery, &response)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []int64{}, err
+	}
+
+	ids := []int64{}
+	for i := 0; i < len(response.Hits.Hits); i++ {
+		id, err := strconv.ParseInt(response.Hits.Hits[i].ID, 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (eb *ElasticBackend) Indexed(c context.Context, kind string, ids []int64) (map[int64]time.Time, error) {
+	indexed := map[int64]time.Time{}
+	if len(ids) == 0 {
+		return indexed, nil
+	}
+
+	index, err := eb.index(kind)
+	if err != nil {
+		return indexed, err
+	}
+
+	elasticIdsQuery := elasticIds{}
+	for i := 0; i < len(ids); i++ {
+		elasticIdsQuery.Ids.Values = append(elasticIdsQuery.Ids.Values, strconv.FormatInt(ids[i], 10))
+	}
+
+	elasticQuery := elastic.ElasticQuery{}
+	elasticQuery.Size = len(ids)
+	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticIdsQuery)
+
+	hits, err := index.QueryStruct(c, elasticQuery)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return indexed, err
+	}
+
+	for i := 0; i < len(hits.Hits); i++ {
+		id, err := strconv.ParseInt(hits.Hits[i].ID, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		// Documents without an update time are counted as indexed but old
+		updated := time.Time{}
+		if rawMap, ok := hits.Hits[i].Source.Data.(map[string]interface{}); ok {
+			if rawUpdated, ok := rawMap["Updated"].(string); ok {
+				updated, _ = time.Parse(time.RFC3339Nano, rawUpdated)
+			}
+		}
+		indexed[id] = updated
+	}
+
+	return indexed, nil
+}
@@ -0,0 +1,481 @@
+package search
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/news-ai/web/utilities"
+)
+
+// A condition on emails. It is either a comparison of a field with a value,
+// or the "and", "or" or "not" of other conditions.
+type EmailCondition struct {
+	Operator   string           `json:"operator,omitempty"`
+	Conditions []EmailCondition `json:"conditions,omitempty"`
+
+	Field      string `json:"field,omitempty"`
+	Comparison string `json:"comparison,omitempty"`
+	Value      string `json:"value,omitempty"`
+}
+
+// A mistake in an email search, and where in the search it is
+type QuerySyntaxError struct {
+	Position int
+	Message  string
+}
+
+func (qse *QuerySyntaxError) Error() string {
+	return "Invalid search at character " + strconv.Itoa(qse.Position+1) + ": " + qse.Message
+}
+
+var (
+	EmailConditionAnd = "and"
+	EmailConditionOr  = "or"
+	EmailConditionNot = "not"
+
+	EmailComparisonEqual        = "eq"
+	EmailComparisonGreater      = "gt"
+	EmailComparisonGreaterEqual = "gte"
+	EmailComparisonLess         = "lt"
+	EmailComparisonLessEqual    = "lte"
+	EmailComparisonMatch        = "match"
+	EmailComparisonPrefix       = "prefix"
+)
+
+var emailQueryFieldNames = "to, from, subject, basesubject, list, opened, clicked, bounced, archived, date, status and filter"
+
+type emailQueryToken struct {
+	Kind     string // text, field, and, or, not, (, )
+	Field    string
+	Value    string
+	Position int
+}
+
+type emailQueryParser struct {
+	tokens   []emailQueryToken
+	position int
+	length   int
+}
+
+/*
+* Private methods
+ */
+
+func isEmailQuerySeparator(r rune) bool {
+	return unicode.IsSpace(r) || r == ',' || r == '(' || r == ')' || r == '"'
+}
+
+// Reads a quoted value that starts at position. A backslash escapes the
+// character after it.
+func readQuotedValue(query []rune, position int) (string, int, error) {
+	value := []rune{}
+	for i := position + 1; i < len(query); i++ {
+		if query[i] == '\\' && i+1 < len(query) {
+			i++
+			value = append(value, query[i])
+			continue
+		}
+		if query[i] == '"' {
+			return string(value), i + 1, nil
+		}
+		value = append(value, query[i])
+	}
+	return "", position, &QuerySyntaxError{position, "the quote is never closed"}
+}
+
+// Splits a search into words, quoted text, fields with their values,
+// operators and parentheses. Commas separate words like spaces do.
+func tokenizeEmailQuery(query string) ([]emailQueryToken, error) {
+	runes := []rune(query)
+	tokens := []emailQueryToken{}
+
+	for i := 0; i < len(runes); {
+		switch {
+		case unicode.IsSpace(runes[i]) || runes[i] == ',':
+			i++
+		case runes[i] == '(' || runes[i] == ')':
+			tokens = append(tokens, emailQueryToken{Kind: string(runes[i]), Position: i})
+			i++
+		case runes[i] == '"':
+			value, end, err := readQuotedValue(runes, i)
+			if err != nil {
+				return tokens, err
+			}
+			tokens = append(tokens, emailQueryToken{Kind: "text", Value: value, Position: i})
+			i = end
+		case runes[i] == '-' && i+1 < len(runes) && !isEmailQuerySeparator(runes[i+1]):
+			// -subject:hello is short for NOT subject:hello
+			tokens = append(tokens, emailQueryToken{Kind: EmailConditionNot, Position: i})
+			i++
+		default:
+			start := i
+			for i < len(runes) && !isEmailQuerySeparator(runes[i]) {
+				i++
+			}
+			word := string(runes[start:i])
+
+			switch word {
+			case "AND", "OR", "NOT":
+				tokens = append(tokens, emailQueryToken{Kind: strings.ToLower(word), Position: start})
+				continue
+			}
+
+			colon := strings.Index(word, ":")
+			if colon <= 0 {
+				tokens = append(tokens, emailQueryToken{Kind: "text", Value: word, Position: start})
+				continue
+			}
+
+			token := emailQueryToken{
+				Kind:     "field",
+				Field:    strings.ToLower(word[:colon]),
+				Value:    word[colon+1:],
+				Position: start,
+			}
+
+			// Values can be quoted, after a comparison if there is one
+			if i < len(runes) && runes[i] == '"' {
+				value, end, err := readQuotedValue(runes, i)
+				if err != nil {
+					return tokens, err
+				}
+				token.Value += value
+				i = end
+			}
+
+			if token.Value == "" {
+				return tokens, &QuerySyntaxError{start, token.Field + ": needs a value"}
+			}
+
+			tokens = append(tokens, token)
+		}
+	}
+
+	return tokens, nil
+}
+
+func (p *emailQueryParser) peek() (emailQueryToken, bool) {
+	if p.position >= len(p.tokens) {
+		return emailQueryToken{}, false
+	}
+	return p.tokens[p.position], true
+}
+
+func (p *emailQueryParser) errorAtNext(message string) error {
+	token, ok := p.peek()
+	if !ok {
+		return &QuerySyntaxError{p.length, message}
+	}
+	return &QuerySyntaxError{token.Position, message}
+}
+
+// or := and (OR and)*
+func (p *emailQueryParser) parseOr() (EmailCondition, error) {
+	condition, err := p.parseAnd()
+	if err != nil {
+		return EmailCondition{}, err
+	}
+
+	conditions := []EmailCondition{condition}
+	for {
+		token, ok := p.peek()
+		if !ok || token.Kind != EmailConditionOr {
+			break
+		}
+		p.position++
+
+		condition, err := p.parseAnd()
+		if err != nil {
+			return EmailCondition{}, err
+		}
+		conditions = append(conditions, condition)
+	}
+
+	if len(conditions) == 1 {
+		return conditions[0], nil
+	}
+	return EmailCondition{Operator: EmailConditionOr, Conditions: conditions}, nil
+}
+
+// and := not ((AND)? not)*
+func (p *emailQueryParser) parseAnd() (EmailCondition, error) {
+	condition, err := p.parseNot()
+	if err != nil {
+		return EmailCondition{}, err
+	}
+
+	conditions := []EmailCondition{condition}
+	for {
+		token, ok := p.peek()
+		if !ok || token.Kind == EmailConditionOr || token.Kind == ")" {
+			break
+		}
+		if token.Kind == EmailConditionAnd {
+			p.position++
+		}
+
+		condition, err := p.parseNot()
+		if err != nil {
+			return EmailCondition{}, err
+		}
+		conditions = append(conditions, condition)
+	}
+
+	if len(conditions) == 1 {
+		return conditions[0], nil
+	}
+	return EmailCondition{Operator: EmailConditionAnd, Conditions: conditions}, nil
+}
+
+// not := NOT not | primary
+func (p *emailQueryParser) parseNot() (EmailCondition, error) {
+	token, ok := p.peek()
+	if ok && token.Kind == EmailConditionNot {
+		p.position++
+		condition, err := p.parseNot()
+		if err != nil {
+			return EmailCondition{}, err
+		}
+		return EmailCondition{Operator: EmailConditionNot, Conditions: []EmailCondition{condition}}, nil
+	}
+	return p.parsePrimary()
+}
+
+// primary := ( or ) | field:value | text
+func (p *emailQueryParser) parsePrimary() (EmailCondition, error) {
+	token, ok := p.peek()
+	if !ok {
+		return EmailCondition{}, p.errorAtNext("the search ends where a search term was expected")
+	}
+
+	switch token.Kind {
+	case "(":
+		p.position++
+		condition, err := p.parseOr()
+		if err != nil {
+			return EmailCondition{}, err
+		}
+		closing, ok := p.peek()
+		if !ok || closing.Kind != ")" {
+			return EmailCondition{}, &QuerySyntaxError{token.Position, "the parenthesis is never closed"}
+		}
+		p.position++
+		return condition, nil
+	case "field":
+		p.position++
+		return emailFieldCondition(token)
+	case "text":
+		p.position++
+		return emailTextCondition(token.Value), nil
+	case ")":
+		return EmailCondition{}, p.errorAtNext("there is no parenthesis to close")
+	}
+
+	return EmailCondition{}, p.errorAtNext(strings.ToUpper(token.Kind) + " needs a search term before and after it")
+}
+
+// Free text matches any field, except for email addresses which match who
+// the email was sent to
+func emailTextCondition(text string) EmailCondition {
+	if utilities.ValidateEmailFormat(text) {
+		return EmailCondition{Field: "to", Comparison: EmailComparisonEqual, Value: text}
+	}
+	return EmailCondition{Field: "all", Comparison: EmailComparisonMatch, Value: text}
+}
+
+// Splits a comparison like >=2 into gte and 2
+func splitEmailComparison(value string) (string, string) {
+	comparisons := []struct {
+		prefix     string
+		comparison string
+	}{
+		{">=", EmailComparisonGreaterEqual},
+		{"<=", EmailComparisonLessEqual},
+		{">", EmailComparisonGreater},
+		{"<", EmailComparisonLess},
+		{"=", EmailComparisonEqual},
+	}
+
+	for i := 0; i < len(comparisons); i++ {
+		if strings.HasPrefix(value, comparisons[i].prefix) {
+			return comparisons[i].comparison, strings.TrimPrefix(value, comparisons[i].prefix)
+		}
+	}
+	return EmailComparisonEqual, value
+}
+
+func parseEmailQueryBool(token emailQueryToken) (string, error) {
+	switch strings.ToLower(token.Value) {
+	case "true", "yes":
+		return "true", nil
+	case "false", "no":
+		return "false", nil
+	}
+	return "", &QuerySyntaxError{token.Position, token.Field + ": has to be true or false"}
+}
+
+func parseEmailQueryDate(token emailQueryToken, date string) (time.Time, error) {
+	parsedDate, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		return time.Time{}, &QuerySyntaxError{token.Position, token.Field + ": has to be a date like 2017-01-31"}
+	}
+	return parsedDate, nil
+}
+
+func emailDateCondition(comparison string, date time.Time) EmailCondition {
+	return EmailCondition{Field: "created", Comparison: comparison, Value: date.Format("2006-01-02T15:04:05")}
+}
+
+// Dates cover the whole day, so date:2017-01-31 finds every email of that
+// day and date:>2017-01-31 finds emails from the day after
+func emailDateConditions(token emailQueryToken) (EmailCondition, error) {
+	day := 24*time.Hour - time.Second
+
+	if dates := strings.Split(token.Value, ".."); len(dates) == 2 {
+		from, err := parseEmailQueryDate(token, dates[0])
+		if err != nil {
+			return EmailCondition{}, err
+		}
+		to, err := parseEmailQueryDate(token, dates[1])
+		if err != nil {
+			return EmailCondition{}, err
+		}
+		if to.Before(from) {
+			return EmailCondition{}, &QuerySyntaxError{token.Position, token.Field + ": the range ends before it starts"}
+		}
+		return EmailCondition{Operator: EmailConditionAnd, Conditions: []EmailCondition{
+			emailDateCondition(EmailComparisonGreaterEqual, from),
+			emailDateCondition(EmailComparisonLessEqual, to.Add(day)),
+		}}, nil
+	}
+
+	comparison, value := splitEmailComparison(token.Value)
+	date, err := parseEmailQueryDate(token, value)
+	if err != nil {
+		return EmailCondition{}, err
+	}
+
+	switch comparison {
+	case EmailComparisonGreater:
+		return emailDateCondition(EmailComparisonGreater, date.Add(day)), nil
+	case EmailComparisonGreaterEqual:
+		return emailDateCondition(EmailComparisonGreaterEqual, date), nil
+	case EmailComparisonLess:
+		return emailDateCondition(EmailComparisonLess, date), nil
+	case EmailComparisonLessEqual:
+		return emailDateCondition(EmailComparisonLessEqual, date.Add(day)), nil
+	}
+
+	return EmailCondition{Operator: EmailConditionAnd, Conditions: []EmailCondition{
+		emailDateCondition(EmailComparisonGreaterEqual, date),
+		emailDateCondition(EmailComparisonLessEqual, date.Add(day)),
+	}}, nil
+}
+
+func emailFieldCondition(token emailQueryToken) (EmailCondition, error) {
+	switch token.Field {
+	case "to", "from", "subject", "basesubject":
+		return EmailCondition{Field: token.Field, Comparison: EmailComparisonEqual, Value: token.Value}, nil
+	case "list":
+		_, err := strconv.ParseInt(token.Value, 10, 64)
+		if err != nil {
+			return EmailCondition{}, &QuerySyntaxError{token.Position, "list: has to be the id of a list"}
+		}
+		return EmailCondition{Field: "list", Comparison: EmailComparisonEqual, Value: token.Value}, nil
+	case "opened", "clicked":
+		comparison, value := splitEmailComparison(token.Value)
+		_, err := strconv.Atoi(value)
+		if err != nil {
+			return EmailCondition{}, &QuerySyntaxError{token.Position, token.Field + ": has to be a number, like " + token.Field + ":>0"}
+		}
+		return EmailCondition{Field: token.Field, Comparison: comparison, Value: value}, nil
+	case "bounced", "archived":
+		value, err := parseEmailQueryBool(token)
+		if err != nil {
+			return EmailCondition{}, err
+		}
+		return EmailCondition{Field: token.Field, Comparison: EmailComparisonEqual, Value: value}, nil
+	case "date":
+		return emailDateConditions(token)
+	case "status":
+		// Scheduled emails are sent when their send at date comes
+		switch strings.ToLower(token.Value) {
+		case "sent":
+			return EmailCondition{Field: "sendat", Comparison: EmailComparisonLessEqual, Value: "now"}, nil
+		case "scheduled":
+			return EmailCondition{Field: "sendat", Comparison: EmailComparisonGreater, Value: "now"}, nil
+		}
+		return EmailCondition{}, &QuerySyntaxError{token.Position, "status: has to be sent or scheduled"}
+	case "filter":
+		// The filters searches used before the query language
+		switch strings.ToLower(token.Value) {
+		case "open":
+			return EmailCondition{Field: "opened", Comparison: EmailComparisonGreaterEqual, Value: "1"}, nil
+		case "unopen":
+			return EmailCondition{Field: "opened", Comparison: EmailComparisonEqual, Value: "0"}, nil
+		case "click":
+			return EmailCondition{Field: "clicked", Comparison: EmailComparisonGreaterEqual, Value: "1"}, nil
+		case "unclick":
+			return EmailCondition{Field: "clicked", Comparison: EmailComparisonEqual, Value: "0"}, nil
+		case "bounce":
+			return EmailCondition{Field: "bounced", Comparison: EmailComparisonEqual, Value: "true"}, nil
+		}
+		return EmailCondition{}, &QuerySyntaxError{token.Position, "filter: has to be one of open, unopen, click, unclick or bounce"}
+	}
+
+	return EmailCondition{}, &QuerySyntaxError{token.Position, "there is no field " + token.Field + ", the fields are " + emailQueryFieldNames}
+}
+
+// If a condition looks at the field anywhere
+func (ec EmailCondition) hasField(field string) bool {
+	if ec.Field == field {
+		return true
+	}
+	for i := 0; i < len(ec.Conditions); i++ {
+		if ec.Conditions[i].hasField(field) {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+* Public methods
+ */
+
+// Parses an email search like
+//
+//	subject:"Launch, part 2" AND (opened:>0 OR clicked:>0) NOT bounced:true
+//
+// Terms next to each other have to all match. Fields are to, from, subject,
+// basesubject, list, opened, clicked, bounced, archived, date (a day, a day
+// compared with > or <, or a range like 2017-01-01..2017-01-31) and status
+// (sent or scheduled). Anything else matches any part of the email.
+func ParseEmailQuery(query string) (EmailCondition, error) {
+	tokens, err := tokenizeEmailQuery(query)
+	if err != nil {
+		return EmailCondition{}, err
+	}
+
+	if len(tokens) == 0 {
+		return EmailCondition{}, &QuerySyntaxError{0, "the search is empty"}
+	}
+
+	parser := emailQueryParser{
+		tokens: tokens,
+		length: len([]rune(query)),
+	}
+
+	condition, err := parser.parseOr()
+	if err != nil {
+		return EmailCondition{}, err
+	}
+
+	if _, ok := parser.peek(); ok {
+		return EmailCondition{}, parser.errorAtNext("there is no parenthesis to close")
+	}
+
+	return condition, nil
+}
@@ -0,0 +1,133 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEmailQuery(t *testing.T) {
+	opened := EmailCondition{Field: "opened", Comparison: EmailComparisonGreater, Value: "0"}
+	clicked := EmailCondition{Field: "clicked", Comparison: EmailComparisonGreater, Value: "0"}
+	bounced := EmailCondition{Field: "bounced", Comparison: EmailComparisonEqual, Value: "true"}
+
+	tests := []struct {
+		query string
+		want  EmailCondition
+	}{
+		{
+			query: "subject:hello",
+			want:  EmailCondition{Field: "subject", Comparison: EmailComparisonEqual, Value: "hello"},
+		},
+		{
+			query: `subject:"Launch, part 2"`,
+			want:  EmailCondition{Field: "subject", Comparison: EmailComparisonEqual, Value: "Launch, part 2"},
+		},
+		{
+			query: "launch",
+			want:  EmailCondition{Field: "all", Comparison: EmailComparisonMatch, Value: "launch"},
+		},
+		{
+			query: "opened:>0 OR clicked:>0",
+			want:  EmailCondition{Operator: EmailConditionOr, Conditions: []EmailCondition{opened, clicked}},
+		},
+		{
+			query: "opened:>0 clicked:>0",
+			want:  EmailCondition{Operator: EmailConditionAnd, Conditions: []EmailCondition{opened, clicked}},
+		},
+		{
+			query: "opened:>0 AND clicked:>0 OR bounced:yes",
+			want: EmailCondition{Operator: EmailConditionOr, Conditions: []EmailCondition{
+				{Operator: EmailConditionAnd, Conditions: []EmailCondition{opened, clicked}},
+				bounced,
+			}},
+		},
+		{
+			query: "(opened:>0 OR clicked:>0) NOT bounced:true",
+			want: EmailCondition{Operator: EmailConditionAnd, Conditions: []EmailCondition{
+				{Operator: EmailConditionOr, Conditions: []EmailCondition{opened, clicked}},
+				{Operator: EmailConditionNot, Conditions: []EmailCondition{bounced}},
+			}},
+		},
+		{
+			query: "-bounced:true",
+			want:  EmailCondition{Operator: EmailConditionNot, Conditions: []EmailCondition{bounced}},
+		},
+		{
+			query: "clicked:>=2",
+			want:  EmailCondition{Field: "clicked", Comparison: EmailComparisonGreaterEqual, Value: "2"},
+		},
+		{
+			query: "date:2017-01-31",
+			want: EmailCondition{Operator: EmailConditionAnd, Conditions: []EmailCondition{
+				{Field: "created", Comparison: EmailComparisonGreaterEqual, Value: "2017-01-31T00:00:00"},
+				{Field: "created", Comparison: EmailComparisonLessEqual, Value: "2017-01-31T23:59:59"},
+			}},
+		},
+		{
+			query: "date:>2017-01-31",
+			want:  EmailCondition{Field: "created", Comparison: EmailComparisonGreater, Value: "2017-01-31T23:59:59"},
+		},
+		{
+			query: "date:2017-01-01..2017-01-31",
+			want: EmailCondition{Operator: EmailConditionAnd, Conditions: []EmailCondition{
+				{Field: "created", Comparison: EmailComparisonGreaterEqual, Value: "2017-01-01T00:00:00"},
+				{Field: "created", Comparison: EmailComparisonLessEqual, Value: "2017-01-31T23:59:59"},
+			}},
+		},
+		{
+			query: "status:scheduled",
+			want:  EmailCondition{Field: "sendat", Comparison: EmailComparisonGreater, Value: "now"},
+		},
+		{
+			query: "filter:unopen",
+			want:  EmailCondition{Field: "opened", Comparison: EmailComparisonEqual, Value: "0"},
+		},
+		{
+			query: "list:42",
+			want:  EmailCondition{Field: "list", Comparison: EmailComparisonEqual, Value: "42"},
+		},
+	}
+
+	for _, test := range tests {
+		condition, err := ParseEmailQuery(test.query)
+		if err != nil {
+			t.Errorf("ParseEmailQuery(%q) failed: %v", test.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(condition, test.want) {
+			t.Errorf("ParseEmailQuery(%q) is %+v, want %+v", test.query, condition, test.want)
+		}
+	}
+}
+
+func TestParseEmailQueryErrors(t *testing.T) {
+	tests := []struct {
+		query    string
+		position int
+	}{
+		{query: "", position: 0},
+		{query: `subject:"hello`, position: 8},
+		{query: "(opened:>0", position: 0},
+		{query: "opened:>0)", position: 9},
+		{query: "opened:many", position: 0},
+		{query: "bounced:maybe", position: 0},
+		{query: "color:red", position: 0},
+		{query: "subject:", position: 0},
+		{query: "OR subject:hello", position: 0},
+		{query: "subject:hello AND", position: 17},
+		{query: "launch date:2017-02-01..2017-01-01", position: 7},
+		{query: "status:draft", position: 0},
+	}
+
+	for _, test := range tests {
+		_, err := ParseEmailQuery(test.query)
+		syntaxErr, ok := err.(*QuerySyntaxError)
+		if !ok {
+			t.Errorf("ParseEmailQuery(%q) error is %v, want a syntax error", test.query, err)
+			continue
+		}
+		if syntaxErr.Position != test.position {
+			t.Errorf("ParseEmailQuery(%q) error is at %v, want %v: %v", test.query, syntaxErr.Position, test.position, syntaxErr)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/log"
+
+	apiModels "github.com/news-ai/api/models"
+
+	"github.com/news-ai/tabulae/models"
+)
+
+// One result of a search across contacts, lists, emails, publications and
+// agencies. Title and Subtitle are what the command bar shows.
+type SearchResult struct {
+	Type     string      `json:"type"`
+	Id       int64       `json:"id"`
+	Title    string      `json:"title"`
+	Subtitle string      `json:"subtitle"`
+	Score    int         `json:"score"`
+	Data     interface{} `json:"data"`
+}
+
+// The results of one type. When searching that type fails the error is kept
+// here, so the other types are still returned.
+type SearchGroup struct {
+	Type    string         `json:"type"`
+	Total   int            `json:"total"`
+	Results []SearchResult `json:"results"`
+	Error   string         `json:"error,omitempty"`
+}
+
+var (
+	// How groups are ordered when their best results are as good
+	searchGroupTypes = []string{"contacts", "lists", "emails", "publications", "agencies"}
+
+	// Typeahead only shows a few results of each type
+	TypeaheadLimit = 5
+)
+
+type searchResultsByScore []SearchResult
+
+func (sr searchResultsByScore) Len() int {
+	return len(sr)
+}
+
+func (sr searchResultsByScore) Swap(i, j int) {
+	sr[i], sr[j] = sr[j], sr[i]
+}
+
+func (sr searchResultsByScore) Less(i, j int) bool {
+	return sr[i].Score > sr[j].Score
+}
+
+type searchGroupsByScore []SearchGroup
+
+func (sg searchGroupsByScore) Len() int {
+	return len(sg)
+}
+
+func (sg searchGroupsByScore) Swap(i, j int) {
+	sg[i], sg[j] = sg[j], sg[i]
+}
+
+func (sg searchGroupsByScore) Less(i, j int) bool {
+	return bestSearchScore(sg[i]) > bestSearchScore(sg[j])
+}
+
+/*
+* Private methods
+ */
+
+func bestSearchScore(group SearchGroup) int {
+	if len(group.Results) == 0 {
+		return -1
+	}
+	return group.Results[0].Score
+}
+
+// How well the title of a result matches the search. The backends already
+// decided the result matches, this only ranks results against each other.
+func searchScore(title string, search string) int {
+	title = strings.ToLower(strings.TrimSpace(title))
+	search = strings.ToLower(strings.TrimSpace(search))
+	if title == "" || search == "" {
+		return 0
+	}
+
+	if title == search {
+		return 4
+	} else if strings.HasPrefix(title, search) {
+		return 3
+	}
+
+	for _, word := range strings.Fields(title) {
+		if strings.HasPrefix(word, search) {
+			return 2
+		}
+	}
+
+	if strings.Contains(title, search) {
+		return 1
+	}
+	return 0
+}
+
+func contactSearchResult(contact models.Contact) SearchResult {
+	title := strings.TrimSpace(contact.FirstName + " " + contact.LastName)
+	if title == "" {
+		title = contact.Email
+	}
+	return SearchResult{
+		Type:     "contacts",
+		Id:       contact.Id,
+		Title:    title,
+		Subtitle: contact.Email,
+		Data:     contact,
+	}
+}
+
+func listSearchResult(list models.MediaList) SearchResult {
+	return SearchResult{
+		Type:     "lists",
+		Id:       list.Id,
+		Title:    list.Name,
+		Subtitle: list.Client,
+		Data:     list,
+	}
+}
+
+func emailSearchResult(email models.Email) SearchResult {
+	return SearchResult{
+		Type:     "emails",
+		Id:       email.Id,
+		Title:    email.Subject,
+		Subtitle: email.To,
+		Data:     email,
+	}
+}
+
+func publicationSearchResult(publication models.Publication) SearchResult {
+	return SearchResult{
+		Type:     "publications",
+		Id:       publication.Id,
+		Title:    publication.Name,
+		Subtitle: publication.Url,
+		Data:     publication,
+	}
+}
+
+func agencySearchResult(agency apiModels.Agency) SearchResult {
+	return SearchResult{
+		Type:  "agencies",
+		Id:    agency.Id,
+		Title: agency.Name,
+		Data:  agency,
+	}
+}
+
+// Emails are searched with the email query language, except in typeahead
+// where the search is only the start of what the user is typing
+func globalEmailQuery(search string, typeahead bool, userId int64, listIds []int64, limit int) (EmailQuery, error) {
+	query := EmailQuery{
+		Size:      limit,
+		CreatedBy: userId,
+		ListIds:   listIds,
+	}
+
+	if typeahead {
+		query.ExcludeArchived = true
+		query.Condition = &EmailCondition{
+			Field:      "all",
+			Comparison: EmailComparisonPrefix,
+			Value:      search,
+		}
+		return query, nil
+	}
+
+	condition, err := ParseEmailQuery(search)
+	if err != nil {
+		return query, err
+	}
+	query.ExcludeArchived = !condition.hasField("archived")
+	query.Condition = &condition
+	return query, nil
+}
+
+func searchGlobalType(c context.Context, searchType string, search string, typeahead bool, user apiModels.User, listIds []int64, limit int) SearchGroup {
+	group := SearchGroup{
+		Type:    searchType,
+		Results: []SearchResult{},
+	}
+
+	// Contacts and lists are only searched in the lists the user can get to,
+	// and without any there is nothing to search
+	if (searchType == "contacts" || searchType == "lists") && len(listIds) == 0 {
+		return group
+	}
+
+	var err error
+	switch searchType {
+	case "contacts":
+		contacts := []models.Contact{}
+		contacts, group.Total, err = backend.Contacts(c, ContactQuery{
+			Size:           limit,
+			ListIds:        listIds,
+			Match:          search,
+			Prefix:         typeahead,
+			ExcludeDeleted: true,
+		})
+		for i := 0; i < len(contacts); i++ {
+			contacts[i].Type = "contacts"
+			group.Results = append(group.Results, contactSearchResult(contacts[i]))
+		}
+	case "lists":
+		lists := []models.MediaList{}
+		lists, group.Total, err = backend.Lists(c, ListQuery{
+			Size:   limit,
+			Ids:    listIds,
+			Match:  search,
+			Prefix: typeahead,
+		})
+		for i := 0; i < len(lists); i++ {
+			lists[i].Type = "lists"
+			group.Results = append(group.Results, listSearchResult(lists[i]))
+		}
+	case "emails":
+		query, queryErr := globalEmailQuery(search, typeahead, user.Id, listIds, limit)
+		if queryErr != nil {
+			err = queryErr
+			break
+		}
+		emails := []models.Email{}
+		emails, _, group.Total, err = searchEmailQuery(c, query)
+		for i := 0; i < len(emails); i++ {
+			group.Results = append(group.Results, emailSearchResult(emails[i]))
+		}
+	case "publications":
+		publications := []models.Publication{}
+		publications, group.Total, err = backend.Publications(c, PublicationQuery{
+			Size:   limit,
+			Name:   search,
+			Prefix: typeahead,
+		})
+		for i := 0; i < len(publications); i++ {
+			publications[i].Type = "publications"
+			group.Results = append(group.Results, publicationSearchResult(publications[i]))
+		}
+	case "agencies":
+		agencies := []apiModels.Agency{}
+		agencies, group.Total, err = backend.Agencies(c, AgencyQuery{
+			Size:   limit,
+			Name:   search,
+			Prefix: typeahead,
+		})
+		for i := 0; i < len(agencies); i++ {
+			agencies[i].Type = "agencies"
+			group.Results = append(group.Results, agencySearchResult(agencies[i]))
+		}
+	}
+
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		group.Error = err.Error()
+		group.Results = []SearchResult{}
+		group.Total = 0
+		return group
+	}
+
+	for i := 0; i < len(group.Results); i++ {
+		group.Results[i].Score = searchScore(group.Results[i].Title, search)
+	}
+	sort.Stable(searchResultsByScore(group.Results))
+
+	return group
+}
+
+/*
+* Public methods
+ */
+
+// Searches contacts, lists, emails, publications and agencies at the same
+// time. listIds are the lists the user has a role on: contacts and lists are
+// only the ones of these lists, and emails the ones the user sent or that
+// were sent from these lists. Groups with the best matches come first, and
+// results are ranked by how well their title matches inside each group.
+func SearchEverything(c context.Context, user apiModels.User, listIds []int64, search string, typeahead bool, limit int) ([]SearchGroup, error) {
+	search = strings.TrimSpace(search)
+	if search == "" || user.Id == 0 {
+		return []SearchGroup{}, nil
+	}
+
+	if typeahead && (limit <= 0 || limit > TypeaheadLimit) {
+		limit = TypeaheadLimit
+	}
+
+	groups := make([]SearchGroup, len(searchGroupTypes))
+	var wg sync.WaitGroup
+	for i := 0; i < len(searchGroupTypes); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			groups[i] = searchGlobalType(c, searchGroupTypes[i], search, typeahead, user, listIds, limit)
+		}(i)
+	}
+	wg.Wait()
+
+	sort.Stable(searchGroupsByScore(groups))
+	return groups, nil
+}
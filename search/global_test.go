@@ -0,0 +1,152 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	apiModels "github.com/news-ai/api/models"
+
+	"github.com/news-ai/tabulae/models"
+)
+
+func TestSearchScore(t *testing.T) {
+	tests := []struct {
+		title  string
+		search string
+		want   int
+	}{
+		{title: "Launch", search: "launch", want: 4},
+		{title: "Launch Weekly", search: "launch", want: 3},
+		{title: "Product Launch", search: "launch", want: 2},
+		{title: "Prelaunch", search: "launch", want: 1},
+		{title: "Press", search: "launch", want: 0},
+		{title: "", search: "launch", want: 0},
+	}
+
+	for _, test := range tests {
+		score := searchScore(test.title, test.search)
+		if score != test.want {
+			t.Errorf("searchScore(%q, %q) is %v, want %v", test.title, test.search, score, test.want)
+		}
+	}
+}
+
+func TestGlobalEmailQuery(t *testing.T) {
+	query, err := globalEmailQuery("subj", true, 1, []int64{10}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := EmailQuery{
+		Size:            5,
+		CreatedBy:       1,
+		ListIds:         []int64{10},
+		ExcludeArchived: true,
+		Condition:       &EmailCondition{Field: "all", Comparison: EmailComparisonPrefix, Value: "subj"},
+	}
+	if !reflect.DeepEqual(query, want) {
+		t.Errorf("typeahead query is %+v, want %+v", query, want)
+	}
+
+	tests := []struct {
+		search              string
+		wantExcludeArchived bool
+		wantErr             bool
+	}{
+		{search: "subject:Launch", wantExcludeArchived: true},
+		{search: "subject:Launch archived:true", wantExcludeArchived: false},
+		{search: "color:red", wantErr: true},
+	}
+
+	for _, test := range tests {
+		query, err := globalEmailQuery(test.search, false, 1, []int64{10}, 5)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%q: error is %v, want an error %v", test.search, err, test.wantErr)
+			continue
+		}
+		if !test.wantErr && query.ExcludeArchived != test.wantExcludeArchived {
+			t.Errorf("%q: excludes archived emails %v, want %v", test.search, query.ExcludeArchived, test.wantExcludeArchived)
+		}
+	}
+}
+
+func TestSearchEverything(t *testing.T) {
+	contact := testContact(1, 1, 10, "Launch")
+	contact.LastName = "Lee"
+	notesContact := testContact(2, 1, 11, "Ann")
+	notesContact.Notes = "Met at the launch party"
+	otherListContact := testContact(3, 2, 12, "Launch")
+
+	list := models.MediaList{Name: "Launch"}
+	list.Id = 10
+	otherList := models.MediaList{Name: "Launch"}
+	otherList.Id = 12
+
+	publication := models.Publication{Name: "Launch Weekly"}
+	publication.Id = 20
+
+	memoryBackend := NewMemoryBackend()
+	memoryBackend.AddContacts(contact, notesContact, otherListContact)
+	memoryBackend.AddLists(list, otherList)
+	memoryBackend.AddPublications(publication)
+	defaultBackend := backend
+	SetBackend(memoryBackend)
+	defer SetBackend(defaultBackend)
+
+	user := apiModels.User{}
+	user.Id = 1
+
+	tests := []struct {
+		name    string
+		listIds []int64
+		want    map[string][]int64
+		order   []string
+	}{
+		{
+			name:    "lists of the user",
+			listIds: []int64{10, 11},
+			want: map[string][]int64{
+				"lists":        {10},
+				"contacts":     {1, 2},
+				"publications": {20},
+			},
+			order: []string{"lists", "contacts", "publications", "emails", "agencies"},
+		},
+		{
+			name:    "no lists",
+			listIds: []int64{},
+			want: map[string][]int64{
+				"publications": {20},
+			},
+			order: []string{"publications", "contacts", "lists", "emails", "agencies"},
+		},
+	}
+
+	for _, test := range tests {
+		groups, err := SearchEverything(context.Background(), user, test.listIds, "launch", false, 10)
+		if err != nil {
+			t.Fatalf("%v: %v", test.name, err)
+		}
+
+		order := []string{}
+		results := map[string][]int64{}
+		for i := 0; i < len(groups); i++ {
+			if groups[i].Error != "" {
+				t.Errorf("%v: searching %v failed: %v", test.name, groups[i].Type, groups[i].Error)
+			}
+
+			order = append(order, groups[i].Type)
+			for x := 0; x < len(groups[i].Results); x++ {
+				results[groups[i].Type] = append(results[groups[i].Type], groups[i].Results[x].Id)
+			}
+		}
+
+		if !reflect.DeepEqual(order, test.order) {
+			t.Errorf("%v: groups are in the order %v, want %v", test.name, order, test.order)
+		}
+		if !reflect.DeepEqual(results, test.want) {
+			t.Errorf("%v: results are %v, want %v", test.name, results, test.want)
+		}
+	}
+}
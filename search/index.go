@@ -0,0 +1,31 @@
+package search
+
+import (
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/log"
+)
+
+// When entities of a kind were last updated in search, to find the ones that
+// are missing or out of date. Entities that aren't indexed are left out.
+func GetIndexed(c context.Context, kind string, ids []int64) (map[int64]time.Time, error) {
+	indexed, err := backend.Indexed(c, kind, ids)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return map[int64]time.Time{}, err
+	}
+	return indexed, nil
+}
+
+// Ids of a page of the documents of a kind that come after an id, to find
+// documents whose entities are gone
+func GetIndexedIds(c context.Context, kind string, after int64, size int) ([]int64, error) {
+	ids, err := backend.IndexedIds(c, kind, after, size)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []int64{}, err
+	}
+	return ids, nil
+}
@@ -9,40 +9,24 @@ import (
 
 	"google.golang.org/appengine/log"
 
-	apiSearch "github.com/news-ai/api/search"
-
 	tabulaeModels "github.com/news-ai/tabulae/models"
-
-	elastic "github.com/news-ai/elastic-appengine"
 )
 
-var (
-	elasticList *elastic.Elastic
-)
+func searchList(c context.Context, r *http.Request, query ListQuery) ([]tabulaeModels.MediaList, int, error) {
+	query.From = gcontext.Get(r, "offset").(int)
+	query.Size = gcontext.Get(r, "limit").(int)
 
-func searchList(c context.Context, elasticQuery interface{}) ([]tabulaeModels.MediaList, int, error) {
-	hits, err := elasticList.QueryStruct(c, elasticQuery)
+	lists, total, err := backend.Lists(c, query)
 	if err != nil {
 		log.Errorf(c, "%v", err)
 		return []tabulaeModels.MediaList{}, 0, err
 	}
 
-	listHits := hits.Hits
-	lists := []tabulaeModels.MediaList{}
-	for i := 0; i < len(listHits); i++ {
-		rawList := listHits[i].Source.Data
-		rawMap := rawList.(map[string]interface{})
-		contact := tabulaeModels.MediaList{}
-		err := contact.FillStruct(rawMap)
-		if err != nil {
-			log.Errorf(c, "%v", err)
-		}
-
-		contact.Type = "lists"
-		lists = append(lists, contact)
+	for i := 0; i < len(lists); i++ {
+		lists[i].Type = "lists"
 	}
 
-	return lists, hits.Total, nil
+	return lists, total, nil
 }
 
 func SearchListsByClientName(c context.Context, r *http.Request, clientName string, userId int64) ([]tabulaeModels.MediaList, int, error) {
@@ -50,60 +34,26 @@ func SearchListsByClientName(c context.Context, r *http.Request, clientName stri
 		return []tabulaeModels.MediaList{}, 0, nil
 	}
 
-	offset := gcontext.Get(r, "offset").(int)
-	limit := gcontext.Get(r, "limit").(int)
-
-	elasticQuery := elastic.ElasticQueryMust{}
-	elasticQuery.Size = limit
-	elasticQuery.From = offset
-
-	elasticCreatedByQuery := apiSearch.ElasticCreatedByQuery{}
-	elasticCreatedByQuery.Term.CreatedBy = userId
-	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticCreatedByQuery)
-
-	elasticArchivedQuery := apiSearch.ElasticArchivedQuery{}
-	elasticArchivedQuery.Term.Archived = false
-	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticArchivedQuery)
-
-	elasticClientQuery := apiSearch.ElasticClientQuery{}
-	elasticClientQuery.Term.Client = clientName
-	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticClientQuery)
-
-	elasticQuery.MinScore = float32(0.5)
-	return searchList(c, elasticQuery)
+	query := ListQuery{
+		CreatedBy: userId,
+		Client:    clientName,
+	}
+	return searchList(c, r, query)
 }
 
 func SearchListsByTag(c context.Context, r *http.Request, tag string, userId int64) ([]tabulaeModels.MediaList, int, error) {
+	// Tags are stored normalized
+	tag = tabulaeModels.NormalizeTag(tag)
 	if tag == "" {
 		return []tabulaeModels.MediaList{}, 0, nil
 	}
 
-	offset := gcontext.Get(r, "offset").(int)
-	limit := gcontext.Get(r, "limit").(int)
-
-	elasticQuery := elastic.ElasticQueryMustWithSort{}
-	elasticQuery.Size = limit
-	elasticQuery.From = offset
-
-	elasticCreatedByQuery := apiSearch.ElasticCreatedByQuery{}
-	elasticCreatedByQuery.Term.CreatedBy = userId
-	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticCreatedByQuery)
-
-	elasticArchivedQuery := apiSearch.ElasticArchivedQuery{}
-	elasticArchivedQuery.Term.Archived = false
-	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticArchivedQuery)
-
-	elasticTagQuery := apiSearch.ElasticTagQuery{}
-	elasticTagQuery.Term.Tag = tag
-	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticTagQuery)
-
-	elasticCreatedQuery := apiSearch.ElasticSortDataCreatedQuery{}
-	elasticCreatedQuery.DataCreated.Order = "desc"
-	elasticCreatedQuery.DataCreated.Mode = "avg"
-	elasticQuery.Sort = append(elasticQuery.Sort, elasticCreatedQuery)
-
-	elasticQuery.MinScore = float32(0.5)
-	return searchList(c, elasticQuery)
+	query := ListQuery{
+		CreatedBy:     userId,
+		Tag:           tag,
+		SortByCreated: true,
+	}
+	return searchList(c, r, query)
 }
 
 func SearchListsByAll(c context.Context, r *http.Request, query string, userId int64) ([]tabulaeModels.MediaList, int, error) {
@@ -111,27 +61,11 @@ func SearchListsByAll(c context.Context, r *http.Request, query string, userId i
 		return []tabulaeModels.MediaList{}, 0, nil
 	}
 
-	offset := gcontext.Get(r, "offset").(int)
-	limit := gcontext.Get(r, "limit").(int)
-
-	elasticQuery := elastic.ElasticQueryMust{}
-	elasticQuery.Size = limit
-	elasticQuery.From = offset
-
-	elasticCreatedByQuery := apiSearch.ElasticCreatedByQuery{}
-	elasticCreatedByQuery.Term.CreatedBy = userId
-	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticCreatedByQuery)
-
-	elasticArchivedQuery := apiSearch.ElasticArchivedQuery{}
-	elasticArchivedQuery.Term.Archived = false
-	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticArchivedQuery)
-
-	elasticAllQuery := apiSearch.ElasticAllQuery{}
-	elasticAllQuery.Term.All = query
-	elasticQuery.Query.Bool.Must = append(elasticQuery.Query.Bool.Must, elasticAllQuery)
-
-	elasticQuery.MinScore = float32(0.5)
-	return searchList(c, elasticQuery)
+	listQuery := ListQuery{
+		CreatedBy: userId,
+		Term:      query,
+	}
+	return searchList(c, r, listQuery)
 }
 
 func SearchListsByFieldSelector(c context.Context, r *http.Request, fieldSelector string, query string, userId int64) ([]tabulaeModels.MediaList, int, error) {
@@ -0,0 +1,833 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/net/context"
+
+	apiModels "github.com/news-ai/api/models"
+
+	"github.com/news-ai/tabulae/models"
+)
+
+// Keeps everything in memory and filters it the way the Elasticsearch
+// queries do. Matching words is simpler than Elasticsearch's analyzers, and
+// results are in the order they were added unless the search is sorted.
+type MemoryBackend struct {
+	mutex sync.RWMutex
+
+	contacts        []models.Contact
+	lists           []models.MediaList
+	emails          []models.Email
+	emailLogs       []memoryEmailLog
+	emailTimeseries []memoryEmailTimeseries
+	emailCampaigns  []EmailCampaignRequest
+	publications    []models.Publication
+	agencies        []apiModels.Agency
+}
+
+type memoryEmailLog struct {
+	EmailId int64
+	Data    interface{}
+}
+
+type memoryEmailTimeseries struct {
+	UserId int64
+	Date   string
+	Data   interface{}
+}
+
+type emailsByCreated []models.Email
+
+func (ec emailsByCreated) Len() int {
+	return len(ec)
+}
+
+func (ec emailsByCreated) Swap(i, j int) {
+	ec[i], ec[j] = ec[j], ec[i]
+}
+
+func (ec emailsByCreated) Less(i, j int) bool {
+	return ec[i].Created.After(ec[j].Created)
+}
+
+type listsByCreated []models.MediaList
+
+func (lc listsByCreated) Len() int {
+	return len(lc)
+}
+
+func (lc listsByCreated) Swap(i, j int) {
+	lc[i], lc[j] = lc[j], lc[i]
+}
+
+func (lc listsByCreated) Less(i, j int) bool {
+	return lc[i].Created.After(lc[j].Created)
+}
+
+type emailTimeseriesByDate []memoryEmailTimeseries
+
+func (et emailTimeseriesByDate) Len() int {
+	return len(et)
+}
+
+func (et emailTimeseriesByDate) Swap(i, j int) {
+	et[i], et[j] = et[j], et[i]
+}
+
+func (et emailTimeseriesByDate) Less(i, j int) bool {
+	return et[i].Date > et[j].Date
+}
+
+type emailCampaignsByDate []EmailCampaignRequest
+
+func (ec emailCampaignsByDate) Len() int {
+	return len(ec)
+}
+
+func (ec emailCampaignsByDate) Swap(i, j int) {
+	ec[i], ec[j] = ec[j], ec[i]
+}
+
+func (ec emailCampaignsByDate) Less(i, j int) bool {
+	return ec[i].Date > ec[j].Date
+}
+
+type int64s []int64
+
+func (ids int64s) Len() int {
+	return len(ids)
+}
+
+func (ids int64s) Swap(i, j int) {
+	ids[i], ids[j] = ids[j], ids[i]
+}
+
+func (ids int64s) Less(i, j int) bool {
+	return ids[i] < ids[j]
+}
+
+type termsBucketsByCount []TermsBucket
+
+func (tb termsBucketsByCount) Len() int {
+	return len(tb)
+}
+
+func (tb termsBucketsByCount) Swap(i, j int) {
+	tb[i], tb[j] = tb[j], tb[i]
+}
+
+func (tb termsBucketsByCount) Less(i, j int) bool {
+	if tb[i].Count != tb[j].Count {
+		return tb[i].Count > tb[j].Count
+	}
+	return tb[i].Value < tb[j].Value
+}
+
+/*
+* Private methods
+ */
+
+// Lowercased words of a text, split on anything that isn't a letter or
+// number
+func textWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+func collectDocumentWords(value interface{}, words map[string]bool) {
+	switch v := value.(type) {
+	case string:
+		for _, word := range textWords(v) {
+			words[word] = true
+		}
+	case float64:
+		words[strconv.FormatFloat(v, 'f', -1, 64)] = true
+	case []interface{}:
+		for i := 0; i < len(v); i++ {
+			collectDocumentWords(v[i], words)
+		}
+	case map[string]interface{}:
+		for _, fieldValue := range v {
+			collectDocumentWords(fieldValue, words)
+		}
+	}
+}
+
+// Words of every field of a document, like the _all field of Elasticsearch
+func documentWords(document interface{}) map[string]bool {
+	words := map[string]bool{}
+
+	data, err := json.Marshal(document)
+	if err != nil {
+		return words
+	}
+
+	var fields interface{}
+	err = json.Unmarshal(data, &fields)
+	if err != nil {
+		return words
+	}
+
+	collectDocumentWords(fields, words)
+	return words
+}
+
+// A match finds documents with any of the words searched for
+func matchesAnyWord(words map[string]bool, search string) bool {
+	for _, word := range textWords(search) {
+		if words[word] {
+			return true
+		}
+	}
+	return false
+}
+
+// A prefix match finds documents with all of the words, where the last word
+// only has to be the start of a word
+func matchesPrefix(words map[string]bool, search string) bool {
+	searchWords := textWords(search)
+	if len(searchWords) == 0 {
+		return false
+	}
+
+	for i := 0; i < len(searchWords)-1; i++ {
+		if !words[searchWords[i]] {
+			return false
+		}
+	}
+
+	lastWord := searchWords[len(searchWords)-1]
+	for word := range words {
+		if strings.HasPrefix(word, lastWord) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesWords(words map[string]bool, search string, prefix bool) bool {
+	if prefix {
+		return matchesPrefix(words, search)
+	}
+	return matchesAnyWord(words, search)
+}
+
+func hasTag(tags []string, tag string) bool {
+	normalizedTags := models.NormalizeTags(tags)
+	for i := 0; i < len(normalizedTags); i++ {
+		if normalizedTags[i] == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Parses the dates of a range the way Elasticsearch does, without a timezone
+// being UTC
+func parseRangeDate(date string) (time.Time, bool) {
+	layouts := []string{"2006-01-02T15:04:05", time.RFC3339, "2006-01-02"}
+	for i := 0; i < len(layouts); i++ {
+		parsedDate, err := time.Parse(layouts[i], date)
+		if err == nil {
+			return parsedDate, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func inDateRange(date time.Time, from string, to string) bool {
+	if fromDate, ok := parseRangeDate(from); ok && date.Before(fromDate) {
+		return false
+	}
+	if toDate, ok := parseRangeDate(to); ok && date.After(toDate) {
+		return false
+	}
+	return true
+}
+
+// Start and end of the page of results. Like Elasticsearch, a size of zero
+// returns no results.
+func pageBounds(total int, from int, size int) (int, int) {
+	if from < 0 {
+		from = 0
+	}
+	if from > total {
+		from = total
+	}
+	if size < 0 {
+		size = 0
+	}
+	end := from + size
+	if end > total {
+		end = total
+	}
+	return from, end
+}
+
+func containsId(ids []int64, id int64) bool {
+	for i := 0; i < len(ids); i++ {
+		if ids[i] == id {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesContactQuery(contact models.Contact, query ContactQuery) bool {
+	if contact.IsMasterContact {
+		return false
+	}
+	if query.CreatedBy != 0 && contact.CreatedBy != query.CreatedBy {
+		return false
+	}
+	if query.ListId != 0 && contact.ListId != query.ListId {
+		return false
+	}
+	if len(query.ListIds) > 0 && !containsId(query.ListIds, contact.ListId) {
+		return false
+	}
+	if query.ExcludeDeleted && contact.IsDeleted {
+		return false
+	}
+	if query.Tag != "" && !hasTag(contact.Tags, query.Tag) {
+		return false
+	}
+	if query.Publication != "" {
+		isEmployer := false
+		for i := 0; i < len(contact.Employers); i++ {
+			if strconv.FormatInt(contact.Employers[i], 10) == query.Publication {
+				isEmployer = true
+			}
+		}
+		if !isEmployer {
+			return false
+		}
+	}
+	if query.Match != "" && !matchesWords(documentWords(contact), query.Match, query.Prefix) {
+		return false
+	}
+	return true
+}
+
+// Values of a contact that a terms aggregation counts, as they are indexed
+func contactTermValues(contact models.Contact, field string) []string {
+	values := []string{}
+	switch field {
+	case "employers":
+		for i := 0; i < len(contact.Employers); i++ {
+			values = append(values, strconv.FormatInt(contact.Employers[i], 10))
+		}
+	case "tags":
+		values = models.NormalizeTags(contact.Tags)
+	case "location":
+		if location := strings.TrimSpace(contact.Location); location != "" {
+			values = append(values, location)
+		}
+	case "emailbounced":
+		values = append(values, strconv.FormatBool(contact.EmailBounced))
+	case "email":
+		if contact.Email != "" {
+			values = append(values, strings.ToLower(contact.Email))
+		}
+	default:
+		if strings.HasPrefix(field, TermsCustomFieldPrefix) {
+			value := strings.TrimSpace(contact.FieldValue(strings.TrimPrefix(field, TermsCustomFieldPrefix)))
+			if value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+func countContactTerms(contacts []models.Contact, aggregation TermsAggregation) []TermsBucket {
+	include := map[string]bool{}
+	for i := 0; i < len(aggregation.Include); i++ {
+		include[aggregation.Include[i]] = true
+	}
+
+	counts := map[string]int{}
+	for i := 0; i < len(contacts); i++ {
+		values := contactTermValues(contacts[i], aggregation.Field)
+		for x := 0; x < len(values); x++ {
+			if len(include) > 0 && !include[values[x]] {
+				continue
+			}
+			counts[values[x]] += 1
+		}
+	}
+
+	buckets := []TermsBucket{}
+	for value, count := range counts {
+		buckets = append(buckets, TermsBucket{
+			Value: value,
+			Count: count,
+		})
+	}
+
+	sort.Sort(termsBucketsByCount(buckets))
+	if len(buckets) > aggregation.Size {
+		buckets = buckets[:aggregation.Size]
+	}
+	return buckets
+}
+
+func matchesListQuery(list models.MediaList, query ListQuery) bool {
+	if list.Archived {
+		return false
+	}
+	if query.CreatedBy != 0 && list.CreatedBy != query.CreatedBy {
+		return false
+	}
+	if len(query.Ids) > 0 && !containsId(query.Ids, list.Id) {
+		return false
+	}
+	if query.Client != "" && list.Client != query.Client {
+		return false
+	}
+	if query.Tag != "" && !hasTag(list.Tags, query.Tag) {
+		return false
+	}
+	// A term isn't split into words, so it has to be a single word
+	if query.Term != "" && !documentWords(list)[query.Term] {
+		return false
+	}
+	if query.Match != "" && !matchesWords(documentWords(list), query.Match, query.Prefix) {
+		return false
+	}
+	return true
+}
+
+func matchesEmailQuery(email models.Email, query EmailQuery) bool {
+	if email.CreatedBy != query.CreatedBy && !containsId(query.ListIds, email.ListId) {
+		return false
+	}
+	if !email.IsSent || email.Cancel {
+		return false
+	}
+	if query.ExcludeArchived && email.Archived {
+		return false
+	}
+	if query.OnlyDelivered && !email.Delievered {
+		return false
+	}
+	if (query.CreatedFrom != "" || query.CreatedTo != "") && !inDateRange(email.Created, query.CreatedFrom, query.CreatedTo) {
+		return false
+	}
+	if query.BaseSubject != "" {
+		if email.BaseSubject != query.BaseSubject {
+			return false
+		}
+	} else if query.Subject != "" && email.Subject != query.Subject {
+		return false
+	}
+	if query.Condition != nil && !matchesEmailCondition(email, *query.Condition) {
+		return false
+	}
+	return true
+}
+
+func compareEmailValues(comparison string, difference int) bool {
+	switch comparison {
+	case EmailComparisonEqual:
+		return difference == 0
+	case EmailComparisonGreater:
+		return difference > 0
+	case EmailComparisonGreaterEqual:
+		return difference >= 0
+	case EmailComparisonLess:
+		return difference < 0
+	case EmailComparisonLessEqual:
+		return difference <= 0
+	}
+	return false
+}
+
+func compareEmailNumbers(comparison string, number int64, value string) bool {
+	otherNumber, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	difference := 0
+	if number > otherNumber {
+		difference = 1
+	} else if number < otherNumber {
+		difference = -1
+	}
+	return compareEmailValues(comparison, difference)
+}
+
+func compareEmailDates(comparison string, date time.Time, value string) bool {
+	otherDate := time.Now()
+	if value != "now" {
+		parsedDate, ok := parseRangeDate(value)
+		if !ok {
+			return false
+		}
+		otherDate = parsedDate
+	}
+
+	difference := 0
+	if date.After(otherDate) {
+		difference = 1
+	} else if date.Before(otherDate) {
+		difference = -1
+	}
+	return compareEmailValues(comparison, difference)
+}
+
+func matchesEmailCondition(email models.Email, condition EmailCondition) bool {
+	switch condition.Operator {
+	case EmailConditionAnd:
+		for i := 0; i < len(condition.Conditions); i++ {
+			if !matchesEmailCondition(email, condition.Conditions[i]) {
+				return false
+			}
+		}
+		return true
+	case EmailConditionOr:
+		for i := 0; i < len(condition.Conditions); i++ {
+			if matchesEmailCondition(email, condition.Conditions[i]) {
+				return true
+			}
+		}
+		return false
+	case EmailConditionNot:
+		return len(condition.Conditions) == 1 && !matchesEmailCondition(email, condition.Conditions[0])
+	}
+
+	switch condition.Field {
+	case "all":
+		if condition.Comparison == EmailComparisonPrefix {
+			return matchesPrefix(documentWords(email), condition.Value)
+		}
+		return matchesAnyWord(documentWords(email), condition.Value)
+	case "to":
+		return strings.EqualFold(email.To, condition.Value)
+	case "from":
+		return strings.EqualFold(email.FromEmail, condition.Value)
+	case "subject":
+		return email.Subject == condition.Value
+	case "basesubject":
+		return email.BaseSubject == condition.Value
+	case "list":
+		return compareEmailNumbers(condition.Comparison, email.ListId, condition.Value)
+	case "opened":
+		return compareEmailNumbers(condition.Comparison, int64(email.Opened), condition.Value)
+	case "clicked":
+		return compareEmailNumbers(condition.Comparison, int64(email.Clicked), condition.Value)
+	case "bounced":
+		return strconv.FormatBool(email.Bounced) == condition.Value
+	case "archived":
+		return strconv.FormatBool(email.Archived) == condition.Value
+	case "created":
+		return compareEmailDates(condition.Comparison, email.Created, condition.Value)
+	case "sendat":
+		return compareEmailDates(condition.Comparison, email.SendAt, condition.Value)
+	}
+	return false
+}
+
+/*
+* Public methods
+ */
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{}
+}
+
+/*
+* Create methods
+ */
+
+func (mb *MemoryBackend) AddContacts(contacts ...models.Contact) {
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+	mb.contacts = append(mb.contacts, contacts...)
+}
+
+func (mb *MemoryBackend) AddLists(lists ...models.MediaList) {
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+	mb.lists = append(mb.lists, lists...)
+}
+
+func (mb *MemoryBackend) AddEmails(emails ...models.Email) {
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+	mb.emails = append(mb.emails, emails...)
+}
+
+func (mb *MemoryBackend) AddEmailLog(emailId int64, emailLog interface{}) {
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+	mb.emailLogs = append(mb.emailLogs, memoryEmailLog{
+		EmailId: emailId,
+		Data:    emailLog,
+	})
+}
+
+// The date is sorted as a string, so it should be formatted like 2006-01-02
+func (mb *MemoryBackend) AddEmailTimeseries(userId int64, date string, emailTimeseries interface{}) {
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+	mb.emailTimeseries = append(mb.emailTimeseries, memoryEmailTimeseries{
+		UserId: userId,
+		Date:   date,
+		Data:   emailTimeseries,
+	})
+}
+
+func (mb *MemoryBackend) AddEmailCampaigns(emailCampaigns ...EmailCampaignRequest) {
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+	mb.emailCampaigns = append(mb.emailCampaigns, emailCampaigns...)
+}
+
+func (mb *MemoryBackend) AddPublications(publications ...models.Publication) {
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+	mb.publications = append(mb.publications, publications...)
+}
+
+func (mb *MemoryBackend) AddAgencies(agencies ...apiModels.Agency) {
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+	mb.agencies = append(mb.agencies, agencies...)
+}
+
+/*
+* Get methods
+ */
+
+func (mb *MemoryBackend) Contacts(c context.Context, query ContactQuery) ([]models.Contact, int, error) {
+	mb.mutex.RLock()
+	defer mb.mutex.RUnlock()
+
+	contacts := []models.Contact{}
+	for i := 0; i < len(mb.contacts); i++ {
+		if matchesContactQuery(mb.contacts[i], query) {
+			contacts = append(contacts, mb.contacts[i])
+		}
+	}
+
+	start, end := pageBounds(len(contacts), query.From, query.Size)
+	return contacts[start:end], len(contacts), nil
+}
+
+func (mb *MemoryBackend) ContactTerms(c context.Context, query ContactQuery, aggregations []TermsAggregation) (map[string][]TermsBucket, int, error) {
+	mb.mutex.RLock()
+	defer mb.mutex.RUnlock()
+
+	contacts := []models.Contact{}
+	for i := 0; i < len(mb.contacts); i++ {
+		if matchesContactQuery(mb.contacts[i], query) {
+			contacts = append(contacts, mb.contacts[i])
+		}
+	}
+
+	terms := map[string][]TermsBucket{}
+	for i := 0; i < len(aggregations); i++ {
+		terms[aggregations[i].Name] = countContactTerms(contacts, aggregations[i])
+	}
+	return terms, len(contacts), nil
+}
+
+func (mb *MemoryBackend) Lists(c context.Context, query ListQuery) ([]models.MediaList, int, error) {
+	mb.mutex.RLock()
+	defer mb.mutex.RUnlock()
+
+	lists := []models.MediaList{}
+	for i := 0; i < len(mb.lists); i++ {
+		if matchesListQuery(mb.lists[i], query) {
+			lists = append(lists, mb.lists[i])
+		}
+	}
+
+	if query.SortByCreated {
+		sort.Stable(listsByCreated(lists))
+	}
+
+	start, end := pageBounds(len(lists), query.From, query.Size)
+	return lists[start:end], len(lists), nil
+}
+
+func (mb *MemoryBackend) Emails(c context.Context, query EmailQuery) ([]models.Email, int, error) {
+	mb.mutex.RLock()
+	defer mb.mutex.RUnlock()
+
+	emails := []models.Email{}
+	for i := 0; i < len(mb.emails); i++ {
+		if matchesEmailQuery(mb.emails[i], query) {
+			emails = append(emails, mb.emails[i])
+		}
+	}
+	sort.Stable(emailsByCreated(emails))
+
+	start, end := pageBounds(len(emails), query.From, query.Size)
+	return emails[start:end], len(emails), nil
+}
+
+func (mb *MemoryBackend) EmailLogs(c context.Context, query EmailLogQuery) ([]interface{}, int, error) {
+	mb.mutex.RLock()
+	defer mb.mutex.RUnlock()
+
+	emailLogs := []interface{}{}
+	for i := 0; i < len(mb.emailLogs); i++ {
+		if mb.emailLogs[i].EmailId == query.EmailId {
+			emailLogs = append(emailLogs, mb.emailLogs[i].Data)
+		}
+	}
+
+	start, end := pageBounds(len(emailLogs), query.From, query.Size)
+	return emailLogs[start:end], len(emailLogs), nil
+}
+
+func (mb *MemoryBackend) EmailTimeseries(c context.Context, query EmailTimeseriesQuery) ([]interface{}, int, error) {
+	mb.mutex.RLock()
+	defer mb.mutex.RUnlock()
+
+	userTimeseries := []memoryEmailTimeseries{}
+	for i := 0; i < len(mb.emailTimeseries); i++ {
+		if mb.emailTimeseries[i].UserId == query.UserId {
+			userTimeseries = append(userTimeseries, mb.emailTimeseries[i])
+		}
+	}
+	sort.Stable(emailTimeseriesByDate(userTimeseries))
+
+	emailTimeseries := []interface{}{}
+	for i := 0; i < len(userTimeseries); i++ {
+		emailTimeseries = append(emailTimeseries, userTimeseries[i].Data)
+	}
+
+	start, end := pageBounds(len(emailTimeseries), query.From, query.Size)
+	return emailTimeseries[start:end], len(emailTimeseries), nil
+}
+
+func (mb *MemoryBackend) EmailCampaigns(c context.Context, query EmailCampaignQuery) ([]EmailCampaignRequest, int, error) {
+	mb.mutex.RLock()
+	defer mb.mutex.RUnlock()
+
+	userId := strconv.FormatInt(query.UserId, 10)
+	emailCampaigns := []EmailCampaignRequest{}
+	for i := 0; i < len(mb.emailCampaigns); i++ {
+		if mb.emailCampaigns[i].UserId == userId {
+			emailCampaigns = append(emailCampaigns, mb.emailCampaigns[i])
+		}
+	}
+	sort.Stable(emailCampaignsByDate(emailCampaigns))
+
+	start, end := pageBounds(len(emailCampaigns), query.From, query.Size)
+	return emailCampaigns[start:end], len(emailCampaigns), nil
+}
+
+func (mb *MemoryBackend) Publications(c context.Context, query PublicationQuery) ([]models.Publication, int, error) {
+	mb.mutex.RLock()
+	defer mb.mutex.RUnlock()
+
+	publications := []models.Publication{}
+	for i := 0; i < len(mb.publications); i++ {
+		words := map[string]bool{}
+		collectDocumentWords(mb.publications[i].Name, words)
+		if matchesWords(words, query.Name, query.Prefix) {
+			publications = append(publications, mb.publications[i])
+		}
+	}
+
+	start, end := pageBounds(len(publications), query.From, query.Size)
+	return publications[start:end], len(publications), nil
+}
+
+func (mb *MemoryBackend) Agencies(c context.Context, query AgencyQuery) ([]apiModels.Agency, int, error) {
+	mb.mutex.RLock()
+	defer mb.mutex.RUnlock()
+
+	agencies := []apiModels.Agency{}
+	for i := 0; i < len(mb.agencies); i++ {
+		words := map[string]bool{}
+		collectDocumentWords(mb.agencies[i].Name, words)
+		if matchesWords(words, query.Name, query.Prefix) {
+			agencies = append(agencies, mb.agencies[i])
+		}
+	}
+
+	start, end := pageBounds(len(agencies), query.From, query.Size)
+	return agencies[start:end], len(agencies), nil
+}
+
+// When each document of a kind was updated, by id
+func (mb *MemoryBackend) indexedUpdates(kind string) (map[int64]time.Time, error) {
+	updated := map[int64]time.Time{}
+	switch kind {
+	case IndexContacts:
+		for i := 0; i < len(mb.contacts); i++ {
+			updated[mb.contacts[i].Id] = mb.contacts[i].Updated
+		}
+	case IndexLists:
+		for i := 0; i < len(mb.lists); i++ {
+			updated[mb.lists[i].Id] = mb.lists[i].Updated
+		}
+	case IndexEmails:
+		for i := 0; i < len(mb.emails); i++ {
+			updated[mb.emails[i].Id] = mb.emails[i].Updated
+		}
+	case IndexPublications:
+		for i := 0; i < len(mb.publications); i++ {
+			updated[mb.publications[i].Id] = mb.publications[i].Updated
+		}
+	default:
+		return map[int64]time.Time{}, errors.New("There is no index for " + kind)
+	}
+	return updated, nil
+}
+
+func (mb *MemoryBackend) Indexed(c context.Context, kind string, ids []int64) (map[int64]time.Time, error) {
+	mb.mutex.RLock()
+	defer mb.mutex.RUnlock()
+
+	updated, err := mb.indexedUpdates(kind)
+	if err != nil {
+		return map[int64]time.Time{}, err
+	}
+
+	indexed := map[int64]time.Time{}
+	for i := 0; i < len(ids); i++ {
+		if indexedUpdated, ok := updated[ids[i]]; ok {
+			indexed[ids[i]] = indexedUpdated
+		}
+	}
+	return indexed, nil
+}
+
+func (mb *MemoryBackend) IndexedIds(c context.Context, kind string, after int64, size int) ([]int64, error) {
+	mb.mutex.RLock()
+	defer mb.mutex.RUnlock()
+
+	updated, err := mb.indexedUpdates(kind)
+	if err != nil {
+		return []int64{}, err
+	}
+
+	ids := []int64{}
+	for id := range updated {
+		if id > after {
+			ids = append(ids, id)
+		}
+	}
+	sort.Sort(int64s(ids))
+
+	if len(ids) > size {
+		ids = ids[:size]
+	}
+	return ids, nil
+}
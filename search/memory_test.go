@@ -0,0 +1,288 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/news-ai/tabulae/models"
+)
+
+func testContact(id int64, createdBy int64, listId int64, firstName string) models.Contact {
+	contact := models.Contact{
+		FirstName: firstName,
+		ListId:    listId,
+	}
+	contact.Id = id
+	contact.CreatedBy = createdBy
+	return contact
+}
+
+func testEmail(id int64, createdBy int64, created time.Time) models.Email {
+	email := models.Email{
+		IsSent: true,
+	}
+	email.Id = id
+	email.CreatedBy = createdBy
+	email.Created = created
+	return email
+}
+
+func testMemoryContacts() *MemoryBackend {
+	jane := testContact(1, 1, 10, "Jane")
+	jane.LastName = "Doe"
+	jane.Tags = []string{"Fashion "}
+	jane.Location = "New York"
+
+	john := testContact(2, 1, 10, "John")
+	john.Tags = []string{"fashion", "Tech"}
+	john.IsDeleted = true
+
+	janet := testContact(3, 1, 11, "Janet")
+	janet.Location = "New York"
+
+	otherJane := testContact(4, 2, 12, "Jane")
+
+	masterJane := testContact(5, 1, 10, "Jane")
+	masterJane.IsMasterContact = true
+
+	memoryBackend := NewMemoryBackend()
+	memoryBackend.AddContacts(jane, john, janet, otherJane, masterJane)
+	return memoryBackend
+}
+
+func TestMemoryBackendContacts(t *testing.T) {
+	memoryBackend := testMemoryContacts()
+
+	tests := []struct {
+		name  string
+		query ContactQuery
+		want  []int64
+		total int
+	}{
+		{
+			name:  "user",
+			query: ContactQuery{Size: 10, CreatedBy: 1},
+			want:  []int64{1, 2, 3},
+			total: 3,
+		},
+		{
+			name:  "list",
+			query: ContactQuery{Size: 10, CreatedBy: 1, ListId: 10},
+			want:  []int64{1, 2},
+			total: 2,
+		},
+		{
+			name:  "lists",
+			query: ContactQuery{Size: 10, ListIds: []int64{11, 12}},
+			want:  []int64{3, 4},
+			total: 2,
+		},
+		{
+			name:  "tag",
+			query: ContactQuery{Size: 10, CreatedBy: 1, Tag: "fashion"},
+			want:  []int64{1, 2},
+			total: 2,
+		},
+		{
+			name:  "tag without deleted contacts",
+			query: ContactQuery{Size: 10, CreatedBy: 1, Tag: "fashion", ExcludeDeleted: true},
+			want:  []int64{1},
+			total: 1,
+		},
+		{
+			name:  "match",
+			query: ContactQuery{Size: 10, CreatedBy: 1, Match: "jane"},
+			want:  []int64{1},
+			total: 1,
+		},
+		{
+			name:  "prefix",
+			query: ContactQuery{Size: 10, CreatedBy: 1, Match: "jan", Prefix: true},
+			want:  []int64{1, 3},
+			total: 2,
+		},
+		{
+			name:  "prefix of the last word",
+			query: ContactQuery{Size: 10, CreatedBy: 1, Match: "jane do", Prefix: true},
+			want:  []int64{1},
+			total: 1,
+		},
+		{
+			name:  "page",
+			query: ContactQuery{From: 1, Size: 1, CreatedBy: 1},
+			want:  []int64{2},
+			total: 3,
+		},
+		{
+			name:  "no size",
+			query: ContactQuery{CreatedBy: 1},
+			want:  []int64{},
+			total: 3,
+		},
+		{
+			name:  "past the last page",
+			query: ContactQuery{From: 5, Size: 10, CreatedBy: 1},
+			want:  []int64{},
+			total: 3,
+		},
+	}
+
+	for _, test := range tests {
+		contacts, total, err := memoryBackend.Contacts(context.Background(), test.query)
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+
+		ids := []int64{}
+		for i := 0; i < len(contacts); i++ {
+			ids = append(ids, contacts[i].Id)
+		}
+		if !reflect.DeepEqual(ids, test.want) || total != test.total {
+			t.Errorf("%v: found %v of %v, want %v of %v", test.name, ids, total, test.want, test.total)
+		}
+	}
+}
+
+func TestMemoryBackendContactTerms(t *testing.T) {
+	memoryBackend := testMemoryContacts()
+
+	aggregations := []TermsAggregation{
+		{Name: "tags", Field: "tags", Size: 10},
+		{Name: "location", Field: "location", Size: 1},
+		{Name: "fashion", Field: "tags", Size: 10, Include: []string{"fashion"}},
+	}
+	terms, total, err := memoryBackend.ContactTerms(context.Background(), ContactQuery{CreatedBy: 1}, aggregations)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string][]TermsBucket{
+		"tags":     {{Value: "fashion", Count: 2}, {Value: "tech", Count: 1}},
+		"location": {{Value: "New York", Count: 2}},
+		"fashion":  {{Value: "fashion", Count: 2}},
+	}
+	if total != 3 {
+		t.Errorf("counted %v contacts, want 3", total)
+	}
+	if !reflect.DeepEqual(terms, want) {
+		t.Errorf("terms are %+v, want %+v", terms, want)
+	}
+}
+
+func TestMemoryBackendEmails(t *testing.T) {
+	day := func(date int) time.Time {
+		return time.Date(2017, time.January, date, 12, 0, 0, 0, time.UTC)
+	}
+
+	opened := testEmail(1, 1, day(2))
+	opened.Subject = "Launch"
+	opened.Opened = 2
+
+	clicked := testEmail(2, 1, day(3))
+	clicked.Subject = "Launch"
+	clicked.Clicked = 1
+
+	bounced := testEmail(3, 1, day(1))
+	bounced.Bounced = true
+
+	draft := testEmail(4, 1, day(4))
+	draft.IsSent = false
+	draft.Opened = 3
+
+	cancelled := testEmail(5, 1, day(4))
+	cancelled.Cancel = true
+	cancelled.Opened = 3
+
+	teamEmail := testEmail(6, 2, day(4))
+	teamEmail.ListId = 10
+	teamEmail.Opened = 3
+
+	memoryBackend := NewMemoryBackend()
+	memoryBackend.AddEmails(opened, clicked, bounced, draft, cancelled, teamEmail)
+
+	tests := []struct {
+		search  string
+		listIds []int64
+		want    []int64
+	}{
+		{search: "opened:>0", want: []int64{1}},
+		{search: "opened:>0 OR clicked:>0", want: []int64{2, 1}},
+		{search: "-bounced:true", want: []int64{2, 1}},
+		{search: "subject:Launch", want: []int64{2, 1}},
+		{search: "launch", want: []int64{2, 1}},
+		{search: "date:2017-01-01", want: []int64{3}},
+		{search: "date:>2017-01-01", want: []int64{2, 1}},
+		{search: "filter:unopen", want: []int64{2, 3}},
+		{search: "opened:>0", listIds: []int64{10}, want: []int64{6, 1}},
+	}
+
+	for _, test := range tests {
+		condition, err := ParseEmailQuery(test.search)
+		if err != nil {
+			t.Errorf("ParseEmailQuery(%q) failed: %v", test.search, err)
+			continue
+		}
+
+		query := EmailQuery{
+			Size:      10,
+			CreatedBy: 1,
+			ListIds:   test.listIds,
+			Condition: &condition,
+		}
+		emails, total, err := memoryBackend.Emails(context.Background(), query)
+		if err != nil {
+			t.Errorf("%q: %v", test.search, err)
+			continue
+		}
+
+		ids := []int64{}
+		for i := 0; i < len(emails); i++ {
+			ids = append(ids, emails[i].Id)
+		}
+		if !reflect.DeepEqual(ids, test.want) || total != len(test.want) {
+			t.Errorf("%q found %v of %v, want %v", test.search, ids, total, test.want)
+		}
+	}
+}
+
+func TestMemoryBackendIndexed(t *testing.T) {
+	memoryBackend := testMemoryContacts()
+
+	indexed, err := memoryBackend.Indexed(context.Background(), IndexContacts, []int64{1, 3, 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := indexed[9]; ok || len(indexed) != 2 {
+		t.Errorf("indexed %v, want contacts 1 and 3", indexed)
+	}
+
+	tests := []struct {
+		after int64
+		size  int
+		want  []int64
+	}{
+		{after: 0, size: 10, want: []int64{1, 2, 3, 4, 5}},
+		{after: 0, size: 2, want: []int64{1, 2}},
+		{after: 2, size: 2, want: []int64{3, 4}},
+		{after: 5, size: 2, want: []int64{}},
+	}
+
+	for _, test := range tests {
+		ids, err := memoryBackend.IndexedIds(context.Background(), IndexContacts, test.after, test.size)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ids, test.want) {
+			t.Errorf("ids after %v are %v, want %v", test.after, ids, test.want)
+		}
+	}
+
+	_, err = memoryBackend.IndexedIds(context.Background(), "User", 0, 10)
+	if err == nil {
+		t.Errorf("ids were read from an index that doesn't exist")
+	}
+}
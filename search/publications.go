@@ -2,7 +2,6 @@ package search
 
 import (
 	"net/http"
-	"net/url"
 
 	"golang.org/x/net/context"
 
@@ -10,41 +9,25 @@ import (
 
 	"google.golang.org/appengine/log"
 
-	elastic "github.com/news-ai/elastic-appengine"
 	"github.com/news-ai/tabulae/models"
 )
 
-var (
-	elasticPublication *elastic.Elastic
-)
-
 func SearchPublication(c context.Context, r *http.Request, search string) ([]models.Publication, int, error) {
-	search = url.QueryEscape(search)
-	search = "q=data.Name:" + search
-
-	offset := gcontext.Get(r, "offset").(int)
-	limit := gcontext.Get(r, "limit").(int)
+	query := PublicationQuery{
+		From: gcontext.Get(r, "offset").(int),
+		Size: gcontext.Get(r, "limit").(int),
+		Name: search,
+	}
 
-	hits, err := elasticPublication.Query(c, offset, limit, search)
+	publications, total, err := backend.Publications(c, query)
 	if err != nil {
 		log.Errorf(c, "%v", err)
 		return []models.Publication{}, 0, err
 	}
 
-	publicationHits := hits.Hits
-	publications := []models.Publication{}
-	for i := 0; i < len(publicationHits); i++ {
-		rawPublication := publicationHits[i].Source.Data
-		rawMap := rawPublication.(map[string]interface{})
-		publication := models.Publication{}
-		err := publication.FillStruct(rawMap)
-		if err != nil {
-			log.Errorf(c, "%v", err)
-		}
-
-		publication.Type = "publications"
-		publications = append(publications, publication)
+	for i := 0; i < len(publications); i++ {
+		publications[i].Type = "publications"
 	}
 
-	return publications, hits.Total, nil
+	return publications, total, nil
 }
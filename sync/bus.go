@@ -0,0 +1,72 @@
+package sync
+
+import (
+	"encoding/json"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// A message bus carries the sync messages to the services that process them.
+// Pub/Sub is used in production and the MemoryBus in tests and local
+// development, where it records every message so they can be checked.
+type Bus interface {
+	Publisher
+	Subscriber
+
+	// Creates the topics that don't exist yet
+	EnsureTopics(c context.Context, topics []string) error
+}
+
+type Publisher interface {
+	// Publishes a message to its topic and returns the id it was given
+	Publish(c context.Context, message Message) (string, error)
+}
+
+type Subscriber interface {
+	// Calls the handler with every message published to the topic until the
+	// context is done. A message is handled again when the handler fails.
+	Subscribe(c context.Context, subscription string, topic string, handler Handler) error
+}
+
+type Handler func(c context.Context, message Message) error
+
+// The envelope every message is sent in. Data is the JSON of the message.
+type Message struct {
+	Id         string
+	Topic      string
+	Data       []byte
+	Attributes map[string]string
+	Published  time.Time
+}
+
+var (
+	bus Bus = NewPubsubBus(projectID)
+)
+
+/*
+* Public methods
+ */
+
+// Replaces the bus messages are published to. Tests and local development
+// set a MemoryBus here.
+func SetBus(messageBus Bus) {
+	bus = messageBus
+}
+
+func NewMessage(topic string, data interface{}) (Message, error) {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Topic: topic,
+		Data:  jsonData,
+	}, nil
+}
+
+// Decodes the JSON of a message into data
+func (m Message) Decode(data interface{}) error {
+	return json.Unmarshal(m.Data, data)
+}
@@ -0,0 +1,513 @@
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Every message tabulae publishes is one of these events, encoded as JSON.
+// The type and version of an event are in every message, so consumers can
+// check what they got with DecodeEvent before they use it.
+//
+// Version 1 was the maps of strings with comma separated ids that were sent
+// before there were events. They have no type or version, so their version
+// reads as 0. DecodeTopicEvent turns them into the events of this version,
+// using the topic they were published to when the keys don't say enough.
+type Event interface {
+	EventType() string
+	Validate() error
+}
+
+// What every event starts with
+type EventHeader struct {
+	Type    string `json:"type"`
+	Version int    `json:"version"`
+}
+
+// A resource was created, updated or deleted. Consumers index the resources
+// again on create and update and remove them on delete.
+type ResourceChanged struct {
+	EventHeader
+
+	Resource string  `json:"resource"`
+	Action   string  `json:"action"`
+	Ids      []int64 `json:"ids"`
+}
+
+// Contacts and publications were added to a list, or the contacts of a list
+// changed
+type ListUploaded struct {
+	EventHeader
+
+	ListId         int64   `json:"listid"`
+	ContactIds     []int64 `json:"contactids"`
+	PublicationIds []int64 `json:"publicationids"`
+}
+
+// Emails that are ready for the email service to send
+type EmailsQueued struct {
+	EventHeader
+
+	EmailIds []int64 `json:"emailids"`
+}
+
+type RSSFeedAdded struct {
+	EventHeader
+
+	Url           string `json:"url"`
+	PublicationId int64  `json:"publicationid"`
+}
+
+type TwitterFeedAdded struct {
+	EventHeader
+
+	Username string `json:"username"`
+}
+
+type InstagramFeedAdded struct {
+	EventHeader
+
+	Username string `json:"username"`
+}
+
+// A social profile of a contact, like their LinkedIn, for the influencer
+// service to look up
+type SocialProfileAdded struct {
+	EventHeader
+
+	ContactId   int64  `json:"contactid"`
+	Network     string `json:"network"`
+	Url         string `json:"url"`
+	JustCreated bool   `json:"justcreated"`
+}
+
+var (
+	EventVersion = 2
+
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+
+	EventResourceChanged    = "resource.changed"
+	EventListUploaded       = "list.uploaded"
+	EventEmailsQueued       = "emails.queued"
+	EventRSSFeedAdded       = "rssfeed.added"
+	EventTwitterFeedAdded   = "twitterfeed.added"
+	EventInstagramFeedAdded = "instagramfeed.added"
+	EventSocialProfileAdded = "socialprofile.added"
+
+	// The topics resources are published to, when one and when several
+	// change. Publications only change one at a time.
+	resourceTopics = map[string]string{
+		"Contact":     ContactChangeTopicID,
+		"Publication": PublicationChangeTopicID,
+		"List":        ListChangeTopicID,
+		"User":        UserChangeTopicID,
+		"Email":       EmailChangeTopicID,
+	}
+	resourceBulkTopics = map[string]string{
+		"Contact": ContactBulkTopicID,
+		"User":    UserBulkTopicID,
+		"Email":   EmailBulkTopicID,
+	}
+)
+
+/*
+* Private methods
+ */
+
+func newEventHeader(eventType string) EventHeader {
+	return EventHeader{
+		Type:    eventType,
+		Version: EventVersion,
+	}
+}
+
+func validateIds(name string, ids []int64) error {
+	if len(ids) == 0 {
+		return errors.New("The event has no " + name)
+	}
+
+	for i := 0; i < len(ids); i++ {
+		if ids[i] == 0 {
+			return errors.New("The event has an empty id in " + name)
+		}
+	}
+	return nil
+}
+
+func (eh EventHeader) validate(eventType string) error {
+	if eh.Type != eventType {
+		return errors.New("The event is a " + eh.Type + " and not a " + eventType)
+	}
+
+	if eh.Version != EventVersion {
+		return errors.New("Version " + strconv.Itoa(eh.Version) + " of events is not supported")
+	}
+	return nil
+}
+
+// The keys of a version 1 event
+type v1Fields map[string]json.RawMessage
+
+func (f v1Fields) has(key string) bool {
+	_, ok := f[key]
+	return ok
+}
+
+// The value of a key, or nothing when it isn't a string
+func (f v1Fields) string(key string) string {
+	value := ""
+	json.Unmarshal(f[key], &value)
+	return value
+}
+
+func (f v1Fields) id(key string) int64 {
+	id, _ := strconv.ParseInt(f.string(key), 10, 64)
+	return id
+}
+
+// Ids were sent joined by commas. Ones that aren't numbers are left out.
+func (f v1Fields) ids(key string) []int64 {
+	ids := []int64{}
+	values := strings.Split(f.string(key), ",")
+	for i := 0; i < len(values); i++ {
+		id, err := strconv.ParseInt(strings.TrimSpace(values[i]), 10, 64)
+		if err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Makes the event of this version from a version 1 map. The topic is
+// optional except for the changes of single resources, whose map only has
+// the id and method.
+func decodeV1Event(topic string, data []byte) (Event, error) {
+	fields := v1Fields{}
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case topic == ListUploadTopicID || fields.has("ListId"):
+		return NewListUploaded(fields.id("ListId"), fields.ids("ContactId"), fields.ids("PublicationId")), nil
+	case topic == EmailServiceTopicID || fields.has("EmailIds"):
+		// The only version 1 event with ids that weren't joined
+		emailIds := []int64{}
+		err = json.Unmarshal(fields["EmailIds"], &emailIds)
+		if err != nil {
+			return nil, err
+		}
+		return NewEmailsQueued(emailIds), nil
+	case topic == RSSFeedTopicID || fields.has("publicationId"):
+		return NewRSSFeedAdded(fields.string("url"), fields.id("publicationId")), nil
+	case topic == InstagramTopicID || fields.has("access_token"):
+		return NewInstagramFeedAdded(fields.string("username")), nil
+	case topic == TwitterTopicID || fields.has("username"):
+		return NewTwitterFeedAdded(fields.string("username")), nil
+	case topic == InfluencerTopicID || fields.has("justCreated"):
+		// The network is the key of the url
+		network := ""
+		for key := range fields {
+			if key != "Id" && key != "justCreated" {
+				network = key
+			}
+		}
+		return NewSocialProfileAdded(fields.id("Id"), network, fields.string(network), fields.string("justCreated") == "true"), nil
+	case fields.has("Method"):
+		resource := ""
+		ids := fields.ids("Id")
+		for changedResource, resourceTopic := range resourceTopics {
+			if topic == resourceTopic {
+				resource = changedResource
+			}
+		}
+
+		// Bulk changes name their ids after the resource
+		for changedResource := range resourceBulkTopics {
+			if fields.has(changedResource + "Id") {
+				resource = changedResource
+				ids = fields.ids(changedResource + "Id")
+			}
+		}
+
+		if resource == "" {
+			return nil, errors.New("The change of a resource needs its topic")
+		}
+		return NewResourceChanged(resource, fields.string("Method"), ids), nil
+	}
+
+	return nil, errors.New("The version 1 event is not known")
+}
+
+// Leaves out the ids that are empty, like the ones of entities that weren't
+// saved
+func nonZeroIds(ids []int64) []int64 {
+	nonZero := []int64{}
+	for i := 0; i < len(ids); i++ {
+		if ids[i] != 0 {
+			nonZero = append(nonZero, ids[i])
+		}
+	}
+	return nonZero
+}
+
+/*
+* Public methods
+ */
+
+func NewResourceChanged(resource string, action string, ids []int64) ResourceChanged {
+	return ResourceChanged{
+		EventHeader: newEventHeader(EventResourceChanged),
+		Resource:    resource,
+		Action:      action,
+		Ids:         nonZeroIds(ids),
+	}
+}
+
+func NewListUploaded(listId int64, contactIds []int64, publicationIds []int64) ListUploaded {
+	return ListUploaded{
+		EventHeader:    newEventHeader(EventListUploaded),
+		ListId:         listId,
+		ContactIds:     nonZeroIds(contactIds),
+		PublicationIds: nonZeroIds(publicationIds),
+	}
+}
+
+func NewEmailsQueued(emailIds []int64) EmailsQueued {
+	return EmailsQueued{
+		EventHeader: newEventHeader(EventEmailsQueued),
+		EmailIds:    nonZeroIds(emailIds),
+	}
+}
+
+func NewRSSFeedAdded(url string, publicationId int64) RSSFeedAdded {
+	return RSSFeedAdded{
+		EventHeader:   newEventHeader(EventRSSFeedAdded),
+		Url:           url,
+		PublicationId: publicationId,
+	}
+}
+
+func NewTwitterFeedAdded(username string) TwitterFeedAdded {
+	return TwitterFeedAdded{
+		EventHeader: newEventHeader(EventTwitterFeedAdded),
+		Username:    username,
+	}
+}
+
+func NewInstagramFeedAdded(username string) InstagramFeedAdded {
+	return InstagramFeedAdded{
+		EventHeader: newEventHeader(EventInstagramFeedAdded),
+		Username:    username,
+	}
+}
+
+func NewSocialProfileAdded(contactId int64, network string, url string, justCreated bool) SocialProfileAdded {
+	return SocialProfileAdded{
+		EventHeader: newEventHeader(EventSocialProfileAdded),
+		ContactId:   contactId,
+		Network:     network,
+		Url:         url,
+		JustCreated: justCreated,
+	}
+}
+
+func (rc ResourceChanged) EventType() string {
+	return EventResourceChanged
+}
+
+func (rc ResourceChanged) Validate() error {
+	err := rc.validate(EventResourceChanged)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := resourceTopics[rc.Resource]; !ok {
+		return errors.New("No topic for " + rc.Resource)
+	}
+
+	if rc.Action != ActionCreate && rc.Action != ActionUpdate && rc.Action != ActionDelete {
+		return errors.New("The action of a change is " + ActionCreate + ", " + ActionUpdate + " or " + ActionDelete)
+	}
+
+	return validateIds("ids", rc.Ids)
+}
+
+func (lu ListUploaded) EventType() string {
+	return EventListUploaded
+}
+
+func (lu ListUploaded) Validate() error {
+	err := lu.validate(EventListUploaded)
+	if err != nil {
+		return err
+	}
+
+	if lu.ListId == 0 {
+		return errors.New("The event has no list")
+	}
+	return nil
+}
+
+func (eq EmailsQueued) EventType() string {
+	return EventEmailsQueued
+}
+
+func (eq EmailsQueued) Validate() error {
+	err := eq.validate(EventEmailsQueued)
+	if err != nil {
+		return err
+	}
+	return validateIds("emailids", eq.EmailIds)
+}
+
+func (rfa RSSFeedAdded) EventType() string {
+	return EventRSSFeedAdded
+}
+
+func (rfa RSSFeedAdded) Validate() error {
+	err := rfa.validate(EventRSSFeedAdded)
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(rfa.Url) == "" || rfa.PublicationId == 0 {
+		return errors.New("A feed needs a url and a publication")
+	}
+	return nil
+}
+
+func (tfa TwitterFeedAdded) EventType() string {
+	return EventTwitterFeedAdded
+}
+
+func (tfa TwitterFeedAdded) Validate() error {
+	err := tfa.validate(EventTwitterFeedAdded)
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(tfa.Username) == "" {
+		return errors.New("Twitter username is not valid")
+	}
+	return nil
+}
+
+func (ifa InstagramFeedAdded) EventType() string {
+	return EventInstagramFeedAdded
+}
+
+func (ifa InstagramFeedAdded) Validate() error {
+	err := ifa.validate(EventInstagramFeedAdded)
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(ifa.Username) == "" {
+		return errors.New("Instagram username is not valid")
+	}
+	return nil
+}
+
+func (spa SocialProfileAdded) EventType() string {
+	return EventSocialProfileAdded
+}
+
+func (spa SocialProfileAdded) Validate() error {
+	err := spa.validate(EventSocialProfileAdded)
+	if err != nil {
+		return err
+	}
+
+	if spa.ContactId == 0 || spa.Network == "" || strings.TrimSpace(spa.Url) == "" {
+		return errors.New("A social profile needs a contact, a network and a url")
+	}
+	return nil
+}
+
+// Decodes the JSON of an event into its type and checks it, for consumers
+// of the topics. Version 1 events are decoded into the events of this version
+// when their keys say what they are, and events of other versions are errors.
+func DecodeEvent(data []byte) (Event, error) {
+	return DecodeTopicEvent("", data)
+}
+
+// Like DecodeEvent, with the topic the event was published to so version 1
+// changes of single resources can be decoded too
+func DecodeTopicEvent(topic string, data []byte) (Event, error) {
+	header := EventHeader{}
+	err := json.Unmarshal(data, &header)
+	if err != nil {
+		return nil, err
+	}
+
+	var event Event
+	if header.Version == 0 || header.Version == 1 {
+		event, err = decodeV1Event(topic, data)
+		if err != nil {
+			return nil, err
+		}
+
+		err = event.Validate()
+		if err != nil {
+			return nil, err
+		}
+		return event, nil
+	}
+
+	if header.Version != EventVersion {
+		return nil, errors.New("Version " + strconv.Itoa(header.Version) + " of events is not supported")
+	}
+
+	switch header.Type {
+	case EventResourceChanged:
+		resourceChanged := ResourceChanged{}
+		err = json.Unmarshal(data, &resourceChanged)
+		event = resourceChanged
+	case EventListUploaded:
+		listUploaded := ListUploaded{}
+		err = json.Unmarshal(data, &listUploaded)
+		event = listUploaded
+	case EventEmailsQueued:
+		emailsQueued := EmailsQueued{}
+		err = json.Unmarshal(data, &emailsQueued)
+		event = emailsQueued
+	case EventRSSFeedAdded:
+		rssFeedAdded := RSSFeedAdded{}
+		err = json.Unmarshal(data, &rssFeedAdded)
+		event = rssFeedAdded
+	case EventTwitterFeedAdded:
+		twitterFeedAdded := TwitterFeedAdded{}
+		err = json.Unmarshal(data, &twitterFeedAdded)
+		event = twitterFeedAdded
+	case EventInstagramFeedAdded:
+		instagramFeedAdded := InstagramFeedAdded{}
+		err = json.Unmarshal(data, &instagramFeedAdded)
+		event = instagramFeedAdded
+	case EventSocialProfileAdded:
+		socialProfileAdded := SocialProfileAdded{}
+		err = json.Unmarshal(data, &socialProfileAdded)
+		event = socialProfileAdded
+	default:
+		return nil, errors.New("Events of type " + header.Type + " are not known")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = event.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// Decodes the event a message carries
+func (m Message) Event() (Event, error) {
+	return DecodeTopicEvent(m.Topic, m.Data)
+}
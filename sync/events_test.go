@@ -0,0 +1,157 @@
+package sync
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeTopicEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		topic   string
+		data    string
+		want    Event
+		wantErr bool
+	}{
+		{
+			name: "resource changed",
+			data: `{"type": "resource.changed", "version": 2, "resource": "Contact", "action": "update", "ids": [1, 2]}`,
+			want: NewResourceChanged("Contact", ActionUpdate, []int64{1, 2}),
+		},
+		{
+			name: "list uploaded",
+			data: `{"type": "list.uploaded", "version": 2, "listid": 1, "contactids": [2], "publicationids": []}`,
+			want: NewListUploaded(1, []int64{2}, []int64{}),
+		},
+		{
+			name:    "unknown version",
+			data:    `{"type": "resource.changed", "version": 3, "resource": "Contact", "action": "update", "ids": [1]}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown type",
+			data:    `{"type": "contact.liked", "version": 2}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid event",
+			data:    `{"type": "resource.changed", "version": 2, "resource": "Contact", "action": "update", "ids": []}`,
+			wantErr: true,
+		},
+		{
+			name:    "not json",
+			data:    `resource.changed`,
+			wantErr: true,
+		},
+		{
+			name: "version 1 list upload",
+			data: `{"ListId": "1", "ContactId": "2,3", "PublicationId": "", "Method": "create"}`,
+			want: NewListUploaded(1, []int64{2, 3}, []int64{}),
+		},
+		{
+			name: "version 1 emails queued",
+			data: `{"EmailIds": [1, 2]}`,
+			want: NewEmailsQueued([]int64{1, 2}),
+		},
+		{
+			name: "version 1 rss feed",
+			data: `{"url": "https://example.com/rss", "publicationId": "5"}`,
+			want: NewRSSFeedAdded("https://example.com/rss", 5),
+		},
+		{
+			name: "version 1 instagram feed",
+			data: `{"username": "jane", "access_token": ""}`,
+			want: NewInstagramFeedAdded("jane"),
+		},
+		{
+			name: "version 1 twitter feed",
+			data: `{"username": "jane"}`,
+			want: NewTwitterFeedAdded("jane"),
+		},
+		{
+			name:  "version 1 feed by topic",
+			topic: InstagramTopicID,
+			data:  `{"username": "jane"}`,
+			want:  NewInstagramFeedAdded("jane"),
+		},
+		{
+			name: "version 1 social profile",
+			data: `{"Id": "4", "linkedin": "https://linkedin.com/in/jane", "justCreated": "true"}`,
+			want: NewSocialProfileAdded(4, "linkedin", "https://linkedin.com/in/jane", true),
+		},
+		{
+			name: "version 1 bulk change",
+			data: `{"ContactId": "1,2", "Method": "update"}`,
+			want: NewResourceChanged("Contact", ActionUpdate, []int64{1, 2}),
+		},
+		{
+			name:  "version 1 change",
+			topic: ListChangeTopicID,
+			data:  `{"Id": "7", "Method": "delete"}`,
+			want:  NewResourceChanged("List", ActionDelete, []int64{7}),
+		},
+		{
+			name:    "version 1 change without its topic",
+			data:    `{"Id": "7", "Method": "delete"}`,
+			wantErr: true,
+		},
+		{
+			name:    "version 1 list upload without a list",
+			data:    `{"ListId": "", "ContactId": "2", "Method": "create"}`,
+			wantErr: true,
+		},
+		{
+			name:    "unknown version 1 event",
+			data:    `{"Name": "jane"}`,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		event, err := DecodeTopicEvent(test.topic, []byte(test.data))
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%v: decoded %+v, want an error", test.name, event)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%v: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(event, test.want) {
+			t.Errorf("%v: decoded %+v, want %+v", test.name, event, test.want)
+		}
+	}
+}
+
+// Events decode to what was published
+func TestDecodeEventRoundTrip(t *testing.T) {
+	events := []Event{
+		NewResourceChanged("Email", ActionCreate, []int64{1, 2}),
+		NewListUploaded(1, []int64{2, 3}, []int64{4}),
+		NewEmailsQueued([]int64{1}),
+		NewRSSFeedAdded("https://example.com/rss", 5),
+		NewTwitterFeedAdded("jane"),
+		NewInstagramFeedAdded("jane"),
+		NewSocialProfileAdded(4, "linkedin", "https://linkedin.com/in/jane", false),
+	}
+
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decoded, err := DecodeEvent(data)
+		if err != nil {
+			t.Errorf("%v: %v", event.EventType(), err)
+			continue
+		}
+		if !reflect.DeepEqual(decoded, event) {
+			t.Errorf("%v: decoded %+v, want %+v", event.EventType(), decoded, event)
+		}
+	}
+}
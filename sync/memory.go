@@ -0,0 +1,94 @@
+package sync
+
+import (
+	"strconv"
+	gosync "sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Keeps every message in memory and hands it to the subscribers of its topic
+// as soon as it is published, in the same goroutine. A handler that fails is
+// not called again.
+type MemoryBus struct {
+	mutex gosync.Mutex
+
+	messages    []Message
+	subscribers map[string][]Handler
+	topics      map[string]bool
+}
+
+/*
+* Public methods
+ */
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{
+		subscribers: map[string][]Handler{},
+		topics:      map[string]bool{},
+	}
+}
+
+func (mb *MemoryBus) Publish(c context.Context, message Message) (string, error) {
+	mb.mutex.Lock()
+	message.Id = strconv.Itoa(len(mb.messages) + 1)
+	message.Published = time.Now()
+	mb.messages = append(mb.messages, message)
+	handlers := append([]Handler{}, mb.subscribers[message.Topic]...)
+	mb.mutex.Unlock()
+
+	for i := 0; i < len(handlers); i++ {
+		handlers[i](c, message)
+	}
+
+	return message.Id, nil
+}
+
+// Adds the handler to the topic. Only messages published afterwards are
+// handled, and the subscription name isn't used.
+func (mb *MemoryBus) Subscribe(c context.Context, subscription string, topic string, handler Handler) error {
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+	mb.subscribers[topic] = append(mb.subscribers[topic], handler)
+	return nil
+}
+
+func (mb *MemoryBus) EnsureTopics(c context.Context, topics []string) error {
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+	for i := 0; i < len(topics); i++ {
+		mb.topics[topics[i]] = true
+	}
+	return nil
+}
+
+/*
+* Get methods
+ */
+
+// The messages published to a topic in the order they were published, or
+// every message when the topic is empty
+func (mb *MemoryBus) Messages(topic string) []Message {
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+
+	messages := []Message{}
+	for i := 0; i < len(mb.messages); i++ {
+		if topic == "" || mb.messages[i].Topic == topic {
+			messages = append(messages, mb.messages[i])
+		}
+	}
+	return messages
+}
+
+/*
+* Delete methods
+ */
+
+// Forgets the messages that were published, keeping the subscribers
+func (mb *MemoryBus) Reset() {
+	mb.mutex.Lock()
+	defer mb.mutex.Unlock()
+	mb.messages = []Message{}
+}
@@ -0,0 +1,328 @@
+package sync
+
+import (
+	"errors"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/datastore"
+	"google.golang.org/appengine/log"
+
+	"github.com/qedus/nds"
+)
+
+// A change waiting to be published. Events are written to the datastore
+// first, in the same transaction as the change when there is one, so a
+// change is never lost when publishing fails. DispatchOutbox retries events
+// with backoff until they run out of attempts and are left dead for an admin
+// to look at. An event can be published more than once, so subscribers have
+// to handle repeats.
+type OutboxEvent struct {
+	Id   int64  `json:"id" datastore:"-"`
+	Type string `json:"type" datastore:"-"`
+
+	Topic string `json:"topic"`
+	Data  string `json:"data" datastore:",noindex"`
+
+	Status      string    `json:"status"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"nextattempt"`
+	LastError   string    `json:"lasterror" datastore:",noindex"`
+
+	Created   time.Time `json:"created"`
+	Delivered time.Time `json:"delivered"`
+}
+
+var (
+	OutboxPending   = "pending"
+	OutboxDelivered = "delivered"
+	OutboxDead      = "dead"
+
+	// Retries wait twice as long each time, up to the longest backoff
+	outboxMaxAttempts = 10
+	outboxBackoff     = 30 * time.Second
+	outboxMaxBackoff  = 6 * time.Hour
+
+	// How many events one dispatch goes through
+	outboxBatchSize = 200
+
+	// Delivered events are kept this long before they are removed
+	outboxRetention = 7 * 24 * time.Hour
+)
+
+/*
+* Private methods
+ */
+
+func (oe *OutboxEvent) key(c context.Context) *datastore.Key {
+	if oe.Id != 0 {
+		return datastore.NewKey(c, "OutboxEvent", "", oe.Id, nil)
+	}
+	return datastore.NewIncompleteKey(c, "OutboxEvent", nil)
+}
+
+func (oe *OutboxEvent) save(c context.Context) error {
+	k, err := nds.Put(c, oe.key(c), oe)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+	oe.Id = k.IntID()
+	oe.Type = "outboxevents"
+	return nil
+}
+
+func outboxBackoffFor(attempts int) time.Duration {
+	backoff := outboxBackoff
+	for i := 1; i < attempts && backoff < outboxMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > outboxMaxBackoff {
+		backoff = outboxMaxBackoff
+	}
+	return backoff
+}
+
+func newOutboxEvent(topicName string, data interface{}) (OutboxEvent, error) {
+	message, err := NewMessage(topicName, data)
+	if err != nil {
+		return OutboxEvent{}, err
+	}
+
+	return OutboxEvent{
+		Topic:       message.Topic,
+		Data:        string(message.Data),
+		Status:      OutboxPending,
+		NextAttempt: time.Now(),
+		Created:     time.Now(),
+	}, nil
+}
+
+// Writes an event to the outbox. With a transaction's context the event is
+// only written if the transaction commits.
+func enqueue(c context.Context, topicName string, data interface{}) (OutboxEvent, error) {
+	event, err := newOutboxEvent(topicName, data)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return OutboxEvent{}, err
+	}
+
+	err = event.save(c)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return OutboxEvent{}, err
+	}
+	return event, nil
+}
+
+// Writes several events to the outbox, 500 to a write
+func enqueueMulti(c context.Context, events []OutboxEvent) ([]OutboxEvent, error) {
+	for i := 0; i < len(events); i += 500 {
+		end := i + 500
+		if end > len(events) {
+			end = len(events)
+		}
+
+		keys := []*datastore.Key{}
+		for x := i; x < end; x++ {
+			keys = append(keys, events[x].key(c))
+		}
+
+		ks, err := nds.PutMulti(c, keys, events[i:end])
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return []OutboxEvent{}, err
+		}
+
+		for x := 0; x < len(ks); x++ {
+			events[i+x].Id = ks[x].IntID()
+			events[i+x].Type = "outboxevents"
+		}
+	}
+	return events, nil
+}
+
+func publish(c context.Context, topicName string, data string) error {
+	_, err := bus.Publish(c, Message{
+		Topic: topicName,
+		Data:  []byte(data),
+	})
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+	return nil
+}
+
+// Publishes an event and records how it went. The event is read again in a
+// transaction so a delivery isn't recorded twice when dispatches overlap.
+func deliverOutboxEvent(c context.Context, event OutboxEvent) error {
+	publishErr := publish(c, event.Topic, event.Data)
+
+	err := nds.RunInTransaction(c, func(ctx context.Context) error {
+		current := OutboxEvent{Id: event.Id}
+		err := nds.Get(ctx, current.key(ctx), &current)
+		if err != nil {
+			return err
+		}
+		current.Id = event.Id
+
+		if current.Status != OutboxPending {
+			return nil
+		}
+
+		current.Attempts += 1
+		if publishErr == nil {
+			current.Status = OutboxDelivered
+			current.Delivered = time.Now()
+			current.LastError = ""
+		} else {
+			current.LastError = publishErr.Error()
+			current.NextAttempt = time.Now().Add(outboxBackoffFor(current.Attempts))
+			if current.Attempts >= outboxMaxAttempts {
+				current.Status = OutboxDead
+			}
+		}
+
+		return current.save(ctx)
+	}, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	return publishErr
+}
+
+func purgeDeliveredOutboxEvents(c context.Context) error {
+	ks, err := datastore.NewQuery("OutboxEvent").Filter("Status =", OutboxDelivered).Filter("Delivered <", time.Now().Add(-outboxRetention)).Limit(500).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+
+	err = nds.DeleteMulti(c, ks)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+	return nil
+}
+
+/*
+* Public methods
+ */
+
+// Tries to publish events right away. Events that fail stay in the outbox
+// for DispatchOutbox to retry, so errors are only logged. Events without an
+// id were never written, like a bulk sync without ids, and are skipped.
+func DeliverOutboxEvents(c context.Context, events []OutboxEvent) {
+	for i := 0; i < len(events); i++ {
+		if events[i].Id == 0 {
+			continue
+		}
+
+		err := deliverOutboxEvent(c, events[i])
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
+	}
+}
+
+// Publishes the events that are due, and removes old delivered events.
+// Returns how many events were delivered and how many failed.
+func DispatchOutbox(c context.Context) (int, int, error) {
+	ks, err := datastore.NewQuery("OutboxEvent").Filter("Status =", OutboxPending).Filter("NextAttempt <=", time.Now()).Order("NextAttempt").Limit(outboxBatchSize).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return 0, 0, err
+	}
+
+	events := make([]OutboxEvent, len(ks))
+	err = nds.GetMulti(c, ks, events)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return 0, 0, err
+	}
+
+	delivered := 0
+	failed := 0
+	for i := 0; i < len(events); i++ {
+		events[i].Id = ks[i].IntID()
+		err = deliverOutboxEvent(c, events[i])
+		if err != nil {
+			failed += 1
+			continue
+		}
+		delivered += 1
+	}
+
+	err = purgeDeliveredOutboxEvents(c)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+	}
+
+	return delivered, failed, nil
+}
+
+// Events with a status, newest first. Dead events are the ones that need
+// someone to look at them.
+func GetOutboxEvents(c context.Context, status string, offset int, limit int) ([]OutboxEvent, error) {
+	ks, err := datastore.NewQuery("OutboxEvent").Filter("Status =", status).Order("-Created").Offset(offset).Limit(limit).KeysOnly().GetAll(c, nil)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []OutboxEvent{}, err
+	}
+
+	events := make([]OutboxEvent, len(ks))
+	err = nds.GetMulti(c, ks, events)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return []OutboxEvent{}, err
+	}
+
+	for i := 0; i < len(events); i++ {
+		events[i].Id = ks[i].IntID()
+		events[i].Type = "outboxevents"
+	}
+	return events, nil
+}
+
+// Gives an event its attempts back and publishes it again
+func RetryOutboxEvent(c context.Context, id int64) (OutboxEvent, error) {
+	event := OutboxEvent{Id: id}
+	err := nds.Get(c, event.key(c), &event)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return OutboxEvent{}, err
+	}
+	event.Id = id
+
+	if event.Status == OutboxDelivered {
+		return event, errors.New("The event has already been delivered")
+	}
+
+	event.Status = OutboxPending
+	event.Attempts = 0
+	event.NextAttempt = time.Now()
+	err = event.save(c)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return OutboxEvent{}, err
+	}
+
+	err = deliverOutboxEvent(c, event)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+	}
+
+	err = nds.Get(c, event.key(c), &event)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return OutboxEvent{}, err
+	}
+	event.Id = id
+	event.Type = "outboxevents"
+	return event, nil
+}
@@ -0,0 +1,128 @@
+package sync
+
+import (
+	"golang.org/x/net/context"
+
+	"google.golang.org/appengine/log"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// Publishes to Google Cloud Pub/Sub. A client is created for every call with
+// the context of the request, since App Engine only lets a request's context
+// make calls while the request is running. A client shared between requests
+// would stop working once the request that created it ended.
+type PubsubBus struct {
+	projectID string
+}
+
+/*
+* Private methods
+ */
+
+func (pb *PubsubBus) newClient(c context.Context) (*pubsub.Client, error) {
+	client, err := pubsub.NewClient(c, pb.projectID)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return nil, err
+	}
+	return client, nil
+}
+
+/*
+* Public methods
+ */
+
+func NewPubsubBus(projectID string) *PubsubBus {
+	return &PubsubBus{
+		projectID: projectID,
+	}
+}
+
+func (pb *PubsubBus) Publish(c context.Context, message Message) (string, error) {
+	client, err := pb.newClient(c)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return "", err
+	}
+	defer client.Close()
+
+	topic := client.Topic(message.Topic)
+	defer topic.Stop()
+
+	id, err := topic.Publish(c, &pubsub.Message{
+		Data:       message.Data,
+		Attributes: message.Attributes,
+	}).Get(c)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return "", err
+	}
+
+	log.Infof(c, "Published a message with a message ID: %s\n", id)
+	return id, nil
+}
+
+// Creates the subscription to the topic if it doesn't exist yet, then
+// receives from it. Messages are acknowledged when the handler succeeds.
+func (pb *PubsubBus) Subscribe(c context.Context, subscription string, topic string, handler Handler) error {
+	client, err := pb.newClient(c)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+	defer client.Close()
+
+	sub := client.Subscription(subscription)
+	if exists, err := sub.Exists(c); err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	} else if !exists {
+		sub, err = client.CreateSubscription(c, subscription, pubsub.SubscriptionConfig{
+			Topic: client.Topic(topic),
+		})
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			return err
+		}
+	}
+
+	return sub.Receive(c, func(ctx context.Context, m *pubsub.Message) {
+		err := handler(ctx, Message{
+			Id:         m.ID,
+			Topic:      topic,
+			Data:       m.Data,
+			Attributes: m.Attributes,
+			Published:  m.PublishTime,
+		})
+		if err != nil {
+			log.Errorf(ctx, "%v", err)
+			m.Nack()
+			return
+		}
+		m.Ack()
+	})
+}
+
+func (pb *PubsubBus) EnsureTopics(c context.Context, topics []string) error {
+	client, err := pb.newClient(c)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return err
+	}
+	defer client.Close()
+
+	for i := 0; i < len(topics); i++ {
+		if exists, err := client.Topic(topics[i]).Exists(c); err != nil {
+			log.Errorf(c, "%v", err)
+			return err
+		} else if !exists {
+			if _, err := client.CreateTopic(c, topics[i]); err != nil {
+				log.Errorf(c, "%v", err)
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -1,239 +1,216 @@
 package sync
 
 import (
-	"encoding/json"
 	"errors"
 	"net/http"
-	"strconv"
-	"strings"
+
+	"golang.org/x/net/context"
 
 	"google.golang.org/appengine"
 	"google.golang.org/appengine/log"
-
-	"cloud.google.com/go/pubsub"
 )
 
-func sync(r *http.Request, data map[string]string, topicName string) error {
+/*
+* Private methods
+ */
+
+// Writes the event to the outbox and publishes it right away. An event that
+// can't be published stays in the outbox for DispatchOutbox to retry, so only
+// an event that isn't valid or can't be written is an error.
+func sync(r *http.Request, event Event, topicName string) error {
 	c := appengine.NewContext(r)
-	PubsubClient, err := configurePubsub(r)
+	err := event.Validate()
 	if err != nil {
 		log.Errorf(c, "%v", err)
 		return err
 	}
 
-	jsonData, err := json.Marshal(data)
+	outboxEvent, err := enqueue(c, topicName, event)
 	if err != nil {
 		log.Errorf(c, "%v", err)
 		return err
 	}
 
-	topic := PubsubClient.Topic(topicName)
-	defer topic.Stop()
-
-	var results []*pubsub.PublishResult
-	res := topic.Publish(c, &pubsub.Message{Data: jsonData})
-	results = append(results, res)
-	for _, result := range results {
-		id, err := result.Get(c)
-		if err != nil {
-			log.Infof(c, "%v", err)
-			continue
-		}
-		log.Infof(c, "Published a message with a message ID: %s\n", id)
-	}
-
+	DeliverOutboxEvents(c, []OutboxEvent{outboxEvent})
 	return nil
 }
 
-func NewRSSFeedSync(r *http.Request, url string, publicationId int64) error {
-	// Create an map with RSS feed url and publicationId
-	data := map[string]string{
-		"url":           url,
-		"publicationId": strconv.FormatInt(publicationId, 10),
+// Sends the change of several resources of a kind to its bulk topic
+func resourceBulkSync(r *http.Request, resource string, ids []int64, method string) error {
+	event := NewResourceChanged(resource, method, ids)
+	if len(event.Ids) == 0 {
+		return nil
 	}
 
-	return sync(r, data, RSSFeedTopicID)
+	return sync(r, event, resourceBulkTopics[resource])
 }
 
-func InstagramSync(r *http.Request, instagramUser string, instagramAccessToken string) error {
-	// Create an map with instagram username and instagramAccessToken
-	if instagramUser != "" {
-		data := map[string]string{
-			"username":     instagramUser,
-			"access_token": "",
-		}
-
-		return sync(r, data, InstagramTopicID)
+func resourceSyncEvent(resourceId int64, resource string, method string) (ResourceChanged, string, error) {
+	event := NewResourceChanged(resource, method, []int64{resourceId})
+	err := event.Validate()
+	if err != nil {
+		return ResourceChanged{}, "", err
 	}
+	return event, resourceTopics[resource], nil
+}
+
+// Ids of a bulk event, so no message gets too big
+var resourceBulkSize = 500
+
+/*
+* Public methods
+ */
 
-	return errors.New("Instagram username is not valid")
+func NewRSSFeedSync(r *http.Request, url string, publicationId int64) error {
+	return sync(r, NewRSSFeedAdded(url, publicationId), RSSFeedTopicID)
 }
 
-func TwitterSync(r *http.Request, twitterUser string) error {
-	// Create an map with twitter username
-	data := map[string]string{
-		"username": twitterUser,
-	}
+func InstagramSync(r *http.Request, instagramUser string, instagramAccessToken string) error {
+	return sync(r, NewInstagramFeedAdded(instagramUser), InstagramTopicID)
+}
 
-	return sync(r, data, TwitterTopicID)
+func TwitterSync(r *http.Request, twitterUser string) error {
+	return sync(r, NewTwitterFeedAdded(twitterUser), TwitterTopicID)
 }
 
 func SocialSync(r *http.Request, socialField string, url string, contactId int64, justCreated bool) error {
-	// Create an map with linkedinUrl and Id of the corresponding contact
-	data := map[string]string{
-		"Id":          strconv.FormatInt(contactId, 10),
-		socialField:   url,
-		"justCreated": strconv.FormatBool(justCreated),
-	}
-
-	return sync(r, data, InfluencerTopicID)
+	return sync(r, NewSocialProfileAdded(contactId, socialField, url, justCreated), InfluencerTopicID)
 }
 
 func SendEmailsToEmailService(r *http.Request, emailIds []int64) error {
-	if len(emailIds) == 0 {
+	event := NewEmailsQueued(emailIds)
+	if len(event.EmailIds) == 0 {
 		return nil
 	}
 
 	c := appengine.NewContext(r)
-	topicName := EmailServiceTopicID
-	data := map[string][]int64{
-		"EmailIds": emailIds,
-	}
-
-	log.Infof(c, "%v", emailIds)
-
-	PubsubClient, err := configurePubsub(r)
-	if err != nil {
-		log.Errorf(c, "%v", err)
-		return err
-	}
+	log.Infof(c, "%v", event.EmailIds)
 
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		log.Errorf(c, "%v", err)
-		return err
-	}
-
-	topic := PubsubClient.Topic(topicName)
-	defer topic.Stop()
-
-	var results []*pubsub.PublishResult
-	res := topic.Publish(c, &pubsub.Message{Data: jsonData})
-	results = append(results, res)
-	for _, result := range results {
-		id, err := result.Get(c)
-		if err != nil {
-			log.Infof(c, "%v", err)
-			continue
-		}
-		log.Infof(c, "Published a message with a message ID: %s\n", id)
-	}
+	return sync(r, event, EmailServiceTopicID)
+}
 
-	return nil
+func EmailResourceBulkSync(r *http.Request, emailIds []int64, method string) error {
+	return resourceBulkSync(r, "Email", emailIds, method)
 }
 
-func EmailResourceBulkSync(r *http.Request, emailIds []int64) error {
-	if len(emailIds) == 0 {
-		return nil
-	}
+func UserResourceBulkSync(r *http.Request, userIds []int64, method string) error {
+	return resourceBulkSync(r, "User", userIds, method)
+}
 
-	tempEmailResourceIds := []string{}
-	for i := 0; i < len(emailIds); i++ {
-		if emailIds[i] != 0 {
-			tempEmailResourceIds = append(tempEmailResourceIds, strconv.FormatInt(emailIds[i], 10))
-		}
-	}
+func ContactResourceBulkSync(r *http.Request, contactIds []int64, method string) error {
+	return resourceBulkSync(r, "Contact", contactIds, method)
+}
 
-	topicName := EmailBulkTopicID
-	data := map[string]string{
-		"EmailId": strings.Join(tempEmailResourceIds, ","),
-		"Method":  "create",
-	}
+func ListUploadResourceBulkSync(r *http.Request, listId int64, contactIds []int64, publicationIds []int64) error {
+	return sync(r, NewListUploaded(listId, contactIds, publicationIds), ListUploadTopicID)
+}
 
-	err := sync(r, data, topicName)
+// Sends the change of a resource: one of ActionCreate, ActionUpdate or
+// ActionDelete
+func ResourceSync(r *http.Request, resourceId int64, resource string, method string) error {
+	event, topicName, err := resourceSyncEvent(resourceId, resource, method)
 	if err != nil {
+		c := appengine.NewContext(r)
+		log.Errorf(c, "%v", err)
 		return err
 	}
 
-	return nil
+	return sync(r, event, topicName)
 }
 
-func UserResourceBulkSync(r *http.Request, userIds []int64) error {
-	if len(userIds) == 0 {
-		return nil
-	}
-
-	tempUserResourceIds := []string{}
-	for i := 0; i < len(userIds); i++ {
-		if userIds[i] != 0 {
-			tempUserResourceIds = append(tempUserResourceIds, strconv.FormatInt(userIds[i], 10))
-		}
-	}
-
-	topicName := UserBulkTopicID
-	data := map[string]string{
-		"UserId": strings.Join(tempUserResourceIds, ","),
-		"Method": "create",
-	}
-
-	err := sync(r, data, topicName)
+// Writes the change of a resource to the outbox without publishing it. Use
+// the context of the transaction that saves the resource, and deliver the
+// event with DeliverOutboxEvents once the transaction commits.
+func QueueResourceSync(c context.Context, resourceId int64, resource string, method string) (OutboxEvent, error) {
+	event, topicName, err := resourceSyncEvent(resourceId, resource, method)
 	if err != nil {
-		return err
+		log.Errorf(c, "%v", err)
+		return OutboxEvent{}, err
 	}
 
-	return nil
+	return enqueue(c, topicName, event)
 }
 
-func ListUploadResourceBulkSync(r *http.Request, listId int64, contactIds []int64, publicationIds []int64) error {
-	tempContactResourceIds := []string{}
-	for i := 0; i < len(contactIds); i++ {
-		if contactIds[i] != 0 {
-			tempContactResourceIds = append(tempContactResourceIds, strconv.FormatInt(contactIds[i], 10))
-		}
+// Writes the change of several resources of a kind to the outbox without
+// publishing it, like QueueResourceSync. Nothing is written when there are
+// no ids.
+func QueueResourceBulkSync(c context.Context, resourceIds []int64, resource string, method string) (OutboxEvent, error) {
+	event := NewResourceChanged(resource, method, resourceIds)
+	if len(event.Ids) == 0 {
+		return OutboxEvent{}, nil
 	}
 
-	tempPublicationResourceIds := []string{}
-	for i := 0; i < len(publicationIds); i++ {
-		if publicationIds[i] != 0 {
-			tempPublicationResourceIds = append(tempPublicationResourceIds, strconv.FormatInt(publicationIds[i], 10))
-		}
+	err := event.Validate()
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		return OutboxEvent{}, err
 	}
 
-	topicName := ListUploadTopicID
-	data := map[string]string{
-		"ListId":        strconv.FormatInt(listId, 10),
-		"PublicationId": strings.Join(tempPublicationResourceIds, ","),
-		"ContactId":     strings.Join(tempContactResourceIds, ","),
-		"Method":        "create",
+	topicName, ok := resourceBulkTopics[resource]
+	if !ok {
+		return OutboxEvent{}, errors.New("No bulk topic for " + resource)
 	}
 
-	err := sync(r, data, topicName)
+	return enqueue(c, topicName, event)
+}
+
+// Writes a change of the contacts of a list to the outbox without publishing
+// it, like QueueResourceSync
+func QueueListUploadSync(c context.Context, listId int64, contactIds []int64, publicationIds []int64) (OutboxEvent, error) {
+	event := NewListUploaded(listId, contactIds, publicationIds)
+	err := event.Validate()
 	if err != nil {
-		return err
+		log.Errorf(c, "%v", err)
+		return OutboxEvent{}, err
 	}
 
-	return nil
+	return enqueue(c, ListUploadTopicID, event)
 }
 
-func ResourceSync(r *http.Request, resourceId int64, resource string, method string) error {
-	data := map[string]string{
-		"Id":     strconv.FormatInt(resourceId, 10),
-		"Method": method,
-	}
-
-	topicName := ""
-
-	if resource == "Contact" {
-		topicName = ContactChangeTopicID
-	} else if resource == "Publication" {
-		topicName = PublicationChangeTopicID
-	} else if resource == "List" {
-		topicName = ListChangeTopicID
-	} else if resource == "User" {
-		topicName = UserChangeTopicID
-	} else if resource == "Email" {
-		topicName = EmailChangeTopicID
+// Writes the changes of many resources of a kind to the outbox without
+// publishing them. Kinds with a bulk topic get an event for every 500 ids and
+// the others an event for each id, all written in a few datastore writes.
+// DispatchOutbox publishes them, so a request doesn't wait on publishing
+// thousands of events.
+func QueueResourceSyncs(c context.Context, resourceIds []int64, resource string, method string) ([]OutboxEvent, error) {
+	events := []OutboxEvent{}
+	if bulkTopicName, ok := resourceBulkTopics[resource]; ok {
+		for i := 0; i < len(resourceIds); i += resourceBulkSize {
+			end := i + resourceBulkSize
+			if end > len(resourceIds) {
+				end = len(resourceIds)
+			}
+
+			event := NewResourceChanged(resource, method, resourceIds[i:end])
+			err := event.Validate()
+			if err != nil {
+				log.Errorf(c, "%v", err)
+				return []OutboxEvent{}, err
+			}
+
+			outboxEvent, err := newOutboxEvent(bulkTopicName, event)
+			if err != nil {
+				log.Errorf(c, "%v", err)
+				return []OutboxEvent{}, err
+			}
+			events = append(events, outboxEvent)
+		}
+	} else {
+		for i := 0; i < len(resourceIds); i++ {
+			event, topicName, err := resourceSyncEvent(resourceIds[i], resource, method)
+			if err != nil {
+				log.Errorf(c, "%v", err)
+				return []OutboxEvent{}, err
+			}
+
+			outboxEvent, err := newOutboxEvent(topicName, event)
+			if err != nil {
+				log.Errorf(c, "%v", err)
+				return []OutboxEvent{}, err
+			}
+			events = append(events, outboxEvent)
+		}
 	}
 
-	return sync(r, data, topicName)
+	return enqueueMulti(c, events)
 }
@@ -0,0 +1,83 @@
+package sync
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/appengine/aetest"
+)
+
+func TestListUploadResourceBulkSync(t *testing.T) {
+	if testing.Short() {
+		t.Skip("needs the development datastore")
+	}
+
+	inst, err := aetest.NewInstance(&aetest.Options{StronglyConsistentDatastore: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer inst.Close()
+
+	tests := []struct {
+		name           string
+		listId         int64
+		contactIds     []int64
+		publicationIds []int64
+		want           []Event
+		wantErr        bool
+	}{
+		{
+			name:           "contacts and publications",
+			listId:         1,
+			contactIds:     []int64{2, 0, 3},
+			publicationIds: []int64{4},
+			want:           []Event{NewListUploaded(1, []int64{2, 3}, []int64{4})},
+		},
+		{
+			name:           "no contacts",
+			listId:         1,
+			contactIds:     []int64{},
+			publicationIds: []int64{},
+			want:           []Event{NewListUploaded(1, []int64{}, []int64{})},
+		},
+		{
+			name:           "no list",
+			contactIds:     []int64{2},
+			publicationIds: []int64{},
+			want:           []Event{},
+			wantErr:        true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := inst.NewRequest("POST", "/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			memoryBus := NewMemoryBus()
+			SetBus(memoryBus)
+
+			err = ListUploadResourceBulkSync(r, test.listId, test.contactIds, test.publicationIds)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("err is %v, want an error: %v", err, test.wantErr)
+			}
+
+			if messages := memoryBus.Messages(""); len(messages) != len(test.want) {
+				t.Fatalf("published %v messages, want %v", len(messages), len(test.want))
+			}
+
+			messages := memoryBus.Messages(ListUploadTopicID)
+			for i := 0; i < len(messages); i++ {
+				event, err := messages[i].Event()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(event, test.want[i]) {
+					t.Errorf("event is %+v, want %+v", event, test.want[i])
+				}
+			}
+		})
+	}
+}
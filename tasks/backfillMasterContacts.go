@@ -0,0 +1,29 @@
+package tasks
+
+import (
+	"net/http"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/log"
+
+	"github.com/news-ai/tabulae/controllers"
+
+	"github.com/news-ai/web/errors"
+)
+
+func BackfillMasterContactsHandler(w http.ResponseWriter, r *http.Request) {
+	c := appengine.NewContext(r)
+
+	linked, err := controllers.BackfillMasterContacts(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		errors.ReturnError(w, http.StatusInternalServerError, "Could not backfill master contacts", err.Error())
+		return
+	}
+
+	log.Infof(c, "Linked %v contacts to master contacts", linked)
+
+	// If successful
+	w.WriteHeader(200)
+	return
+}
@@ -0,0 +1,27 @@
+package tasks
+
+import (
+	"net/http"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/log"
+
+	"github.com/news-ai/tabulae/controllers"
+
+	"github.com/news-ai/web/errors"
+)
+
+func DispatchWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	c := appengine.NewContext(r)
+
+	err := controllers.DispatchWebhooks(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		errors.ReturnError(w, http.StatusInternalServerError, "Could not dispatch webhooks", err.Error())
+		return
+	}
+
+	// If successful
+	w.WriteHeader(200)
+	return
+}
@@ -0,0 +1,28 @@
+package tasks
+
+import (
+	"net/http"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/log"
+
+	"github.com/news-ai/tabulae/sync"
+
+	"github.com/news-ai/web/errors"
+)
+
+// Creates the pubsub topics that are missing. Run it after a deploy.
+func EnsureTopicsHandler(w http.ResponseWriter, r *http.Request) {
+	c := appengine.NewContext(r)
+
+	err := sync.EnsureTopics(r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		errors.ReturnError(w, http.StatusInternalServerError, "Could not create pubsub topics", err.Error())
+		return
+	}
+
+	// If successful
+	w.WriteHeader(200)
+	return
+}
@@ -0,0 +1,32 @@
+package tasks
+
+import (
+	"net/http"
+
+	"google.golang.org/appengine"
+	"google.golang.org/appengine/log"
+
+	"github.com/news-ai/tabulae/controllers"
+
+	"github.com/news-ai/web/errors"
+)
+
+// Migrates a page of media lists from the "cursor" parameter. The cursor of
+// the next page is logged and returned, and is empty after the last page.
+func MigrateFieldTypesHandler(w http.ResponseWriter, r *http.Request) {
+	c := appengine.NewContext(r)
+
+	cursor, err := controllers.MigrateCustomFieldTypes(c, r)
+	if err != nil {
+		log.Errorf(c, "%v", err)
+		errors.ReturnError(w, http.StatusInternalServerError, "Could not migrate field types", err.Error())
+		return
+	}
+
+	log.Infof(c, "Next field types cursor: %v", cursor)
+
+	// If successful
+	w.WriteHeader(200)
+	w.Write([]byte(cursor))
+	return
+}
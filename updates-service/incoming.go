@@ -132,19 +132,10 @@ func internalTrackerHandler(w http.ResponseWriter, r *http.Request) {
 					}
 				}
 			case "unsubscribe":
-				if email.To != "" {
-					unsubscribe := models.ContactUnsubscribe{}
-					unsubscribe.CreatedBy = email.CreatedBy
-					unsubscribe.ListId = email.ListId
-					unsubscribe.ContactId = email.ContactId
-
-					unsubscribe.Email = email.To
-					unsubscribe.Unsubscribed = true
-					_, err = unsubscribe.Create(c, r)
-					if err != nil {
-						hasErrors = true
-						log.Errorf(c, "%v", err)
-					}
+				_, err = controllers.MarkUnsubscribed(c, r, &email)
+				if err != nil {
+					hasErrors = true
+					log.Errorf(c, "%v", err)
 				}
 			default:
 				hasErrors = true
@@ -246,7 +237,10 @@ func internalTrackerHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if len(emailIds) > 0 {
-		sync.EmailResourceBulkSync(r, emailIds)
+		err = sync.EmailResourceBulkSync(r, emailIds, sync.ActionUpdate)
+		if err != nil {
+			log.Errorf(c, "%v", err)
+		}
 	}
 
 	w.WriteHeader(200)
@@ -14,6 +14,7 @@ import (
 	apiControllers "github.com/news-ai/api/controllers"
 
 	"github.com/news-ai/tabulae/controllers"
+	"github.com/news-ai/tabulae/models"
 )
 
 type Social struct {
@@ -64,9 +65,11 @@ func SocialUsernameToDetails(w http.ResponseWriter, r *http.Request, _ httproute
 		return
 	}
 
+	hasErrors := false
 	for i := 0; i < len(contacts); i++ {
 		// If the contact does not have a first/last name & the full name from the network is not empty
 		if contacts[i].FirstName == "" && contacts[i].LastName == "" && socialData.FullName != "" {
+			previousContact := contacts[i]
 			fullNameSplit := strings.Split(socialData.FullName, " ")
 			if len(fullNameSplit) > 1 {
 				contacts[i].FirstName = fullNameSplit[0]
@@ -74,10 +77,24 @@ func SocialUsernameToDetails(w http.ResponseWriter, r *http.Request, _ httproute
 			} else {
 				contacts[i].FirstName = fullNameSplit[0]
 			}
-			controllers.Save(c, r, &contacts[i])
+			_, err = controllers.Save(c, r, &contacts[i])
+			if err != nil {
+				log.Errorf(c, "%v", err)
+				hasErrors = true
+				continue
+			}
+			err = controllers.RecordContactRevisions(c, r, previousContact, contacts[i], models.RevisionSourceSocial)
+			if err != nil {
+				log.Errorf(c, "%v", err)
+			}
 		}
 	}
 
+	if hasErrors {
+		w.WriteHeader(500)
+		return
+	}
+
 	// If successful
 	w.WriteHeader(200)
 	return
@@ -119,6 +136,7 @@ func SocialUsernameInvalid(w http.ResponseWriter, r *http.Request, _ httprouter.
 		return
 	}
 
+	hasErrors := false
 	for i := 0; i < len(contacts); i++ {
 		switch socialData.Network {
 		case "Twitter":
@@ -136,7 +154,16 @@ func SocialUsernameInvalid(w http.ResponseWriter, r *http.Request, _ httprouter.
 				contacts[i].InstagramPrivate = true
 			}
 		}
-		controllers.Save(c, r, &contacts[i])
+		_, err = controllers.Save(c, r, &contacts[i])
+		if err != nil {
+			log.Errorf(c, "%v", err)
+			hasErrors = true
+		}
+	}
+
+	if hasErrors {
+		w.WriteHeader(500)
+		return
 	}
 
 	// If successful
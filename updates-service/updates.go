@@ -109,6 +109,8 @@ func incomingUpdates(w http.ResponseWriter, r *http.Request) {
 
 		if err != nil {
 			log.Errorf(c, "%v", err)
+		} else {
+			tabulaeControllers.TriggerEmailWebhooks(c, models.WebhookEmailSent, updatedEmails)
 		}
 
 		if len(memcacheKeys) > 0 {
@@ -121,7 +123,10 @@ func incomingUpdates(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if len(emailIds) > 0 {
-			sync.EmailResourceBulkSync(r, emailIds)
+			err = sync.EmailResourceBulkSync(r, emailIds, sync.ActionUpdate)
+			if err != nil {
+				log.Errorf(c, "%v", err)
+			}
 		}
 
 		w.WriteHeader(200)